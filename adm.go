@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// admSessions logs in to NetScaler Console once per endpoint and user and shares the session
+// between all environments proxied through it. The zero value is ready to use.
+type admSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*netscaler.ADM
+}
+
+// session returns the session for the ADM of the environment, logging in on first use
+func (s *admSessions) session(cfg netscaler.Config) (*netscaler.ADM, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := cfg.Endpoint + "\x00" + cfg.Username
+	if adm, ok := s.sessions[key]; ok {
+		return adm, nil
+	}
+
+	adm, err := netscaler.NewADM(cfg.ClientConfig())
+	if err != nil {
+		return nil, fmt.Errorf("ADM %s: %w", cfg.Endpoint, err)
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[string]*netscaler.ADM)
+	}
+	s.sessions[key] = adm
+	return adm, nil
+}
+
+// expand replaces the environments with an ADM instance group by one environment per instance
+// and checks the prefixes of the expanded environments
+func (s *admSessions) expand(envConfigs envConfig) (envConfig, error) {
+	expanded, err := netscaler.ExpandInstanceGroups(envConfigs, func(cfg netscaler.Config) ([]netscaler.ManagedInstance, error) {
+		adm, err := s.session(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return adm.InstanceGroup(cfg.InstanceGroup)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, err := range netscaler.CheckDuplicatePrefixes(expanded) {
+		errs = append(errs, fmt.Errorf("invalid config: %w", err))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return expanded, nil
+}
+
+// newClient creates the client of an environment proxied through ADM
+func (s *admSessions) newClient(cfg netscaler.Config) (*netscaler.Client, error) {
+	adm, err := s.session(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return adm.NewClient(cfg.Prefix, cfg.ClientConfig())
+}
+
+// Close logs out of all sessions
+func (s *admSessions) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for key, adm := range s.sessions {
+		if err := adm.Close(); err != nil {
+			endpoint, _, _ := strings.Cut(key, "\x00")
+			errs = append(errs, fmt.Errorf("ADM %s: %w", endpoint, err))
+		}
+	}
+	s.sessions = nil
+	return errors.Join(errs...)
+}
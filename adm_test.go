@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newTestADM returns a fake ADM managing two instances with the certificate of example.com, grouped as web
+func newTestADM(t *testing.T) *netscalertest.ADM {
+	t.Helper()
+	fake := netscalertest.NewADM()
+	t.Cleanup(fake.Close)
+	for i, instance := range []struct{ ip, hostname string }{{"192.0.2.21", "adc1"}, {"192.0.2.22", "adc2"}} {
+		srv := netscalertest.NewServer()
+		t.Cleanup(srv.Close)
+		if err := srv.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "serial": fmt.Sprintf("0%d", i+1)}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+		fake.AddInstance(instance.ip, instance.hostname, srv)
+	}
+	fake.AddGroup("web", "192.0.2.21", "192.0.2.22")
+	return fake
+}
+
+func TestNetscalerPlugin_InitializeADM(t *testing.T) {
+	fake := newTestADM(t)
+	plugin := &NetscalerPlugin{logger: hclog.NewNullLogger(), config: proto.NewPluginConfig()}
+	plugin.config.Set("environments", map[string]any{
+		"fleet": map[string]any{
+			"backend":       "adm",
+			"endpoint":      fake.URL,
+			"username":      fake.Username,
+			"password":      fake.Password,
+			"instanceGroup": "web",
+			"prefix":        "prod-",
+		},
+	})
+	protoConfig, err := plugin.config.ToProto()
+	if err != nil {
+		t.Fatalf("Failed to convert config to proto: %v", err)
+	}
+
+	if _, err := plugin.Initialize(context.Background(), &proto.InitializeRequest{Config: protoConfig}); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	if got := sortedKeys(plugin.clients); !reflect.DeepEqual(got, []string{"fleet-adc1", "fleet-adc2"}) {
+		t.Errorf("Initialize() created %v, want one environment per instance", got)
+	}
+	if fake.Logins() != 1 {
+		t.Errorf("Logins() = %d, the instances should share one ADM session", fake.Logins())
+	}
+
+	resp, err := plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+		DomainEntry: &proto.DomainEntry{Domain: "example.com"},
+	})
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	for env, want := range map[string]string{"fleet-adc1": "192.0.2.21", "fleet-adc2": "192.0.2.22"} {
+		cert := resp.Metadata[env].GetStructValue().AsMap()
+		if cert["certkey"] != "prod-example.com" || cert["managedInstance"] != want {
+			t.Errorf("GetMetadata() %s = %v, want prod-example.com from %s", env, cert, want)
+		}
+	}
+	if _, ok := resp.Metadata["drift"]; !ok {
+		t.Error("GetMetadata() should compare the certificates of the instances")
+	}
+
+	if _, err := plugin.Close(context.Background(), &proto.CloseRequest{}); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if fake.Sessions() != 0 {
+		t.Errorf("Sessions() = %d after Close(), want 0", fake.Sessions())
+	}
+}
+
+func TestCLI_LoadEnvironmentsADM(t *testing.T) {
+	fake := newTestADM(t)
+	path := writeTestConfig(t, fmt.Sprintf(`
+defaults:
+  endpoint: %s
+  username: %s
+  password: %s
+  prefix: prod-
+environments:
+  fleet:
+    backend: adm
+    instanceGroup: web
+  adc3:
+    backend: adm
+    managedInstance: 192.0.2.23
+  dc:
+    endpoint: https://netscaler.example.com
+`, fake.URL, fake.Username, fake.Password))
+
+	tests := []struct {
+		name         string
+		environments string
+		wantEnvs     []string
+		wantErr      bool
+		description  string
+	}{
+		{
+			name:        "all environments",
+			wantEnvs:    []string{"adc3", "dc", "fleet-adc1", "fleet-adc2"},
+			description: "the instance group should be expanded",
+		},
+		{
+			name:         "instance group",
+			environments: "fleet",
+			wantEnvs:     []string{"fleet-adc1", "fleet-adc2"},
+			description:  "selecting the group should select all its instances",
+		},
+		{
+			name:         "instance",
+			environments: "fleet-adc2",
+			wantEnvs:     []string{"fleet-adc2"},
+			description:  "an instance of the group should be selectable alone",
+		},
+		{
+			name:         "unknown environment",
+			environments: "fleet-adc9",
+			wantErr:      true,
+			description:  "should fail for instances missing in the group",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newTestCLI()
+			defer func() { _ = c.adm.Close() }()
+			got, err := c.loadEnvironments(&commonOptions{config: path, environments: tt.environments})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadEnvironments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if keys := sortedKeys(got); !reflect.DeepEqual(keys, tt.wantEnvs) {
+				t.Errorf("loadEnvironments() = %v, want %v", keys, tt.wantEnvs)
+			}
+		})
+	}
+
+	// the client of an instance is proxied through ADM
+	c, _, _ := newTestCLI()
+	defer func() { _ = c.adm.Close() }()
+	env, client, err := c.openEnvironment(&commonOptions{config: path, environments: "fleet-adc1"})
+	if err != nil {
+		t.Fatalf("openEnvironment() error = %v", err)
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+	cert, err := client.GetCertificate("example.com")
+	if err != nil || cert["serial"] != "01" || fake.Proxied("192.0.2.21") == 0 {
+		t.Errorf("GetCertificate() = %v, %v, want the certificate of adc1", cert, err)
+	}
+}
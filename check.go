@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// Nagios plugin states, the value is the process exit code
+const (
+	stateOK       = 0
+	stateWarning  = 1
+	stateCritical = 2
+	stateUnknown  = 3
+)
+
+// stateSeverity orders the states for the overall result, an unknown certificate must not hide a critical one
+var stateSeverity = map[int]int{
+	stateOK:       0,
+	stateUnknown:  1,
+	stateWarning:  2,
+	stateCritical: 3,
+}
+
+var stateNames = map[int]string{
+	stateOK:       "OK",
+	stateWarning:  "WARNING",
+	stateCritical: "CRITICAL",
+	stateUnknown:  "UNKNOWN",
+}
+
+// checkThresholds configures when a certificate is reported as warning or critical
+type checkThresholds struct {
+	warningDays  int
+	criticalDays int
+	requireChain bool
+}
+
+// checkProblem is a single finding of the check command
+type checkProblem struct {
+	state   int
+	message string
+}
+
+// checkResult is the aggregated outcome of a check run
+type checkResult struct {
+	state        int
+	certificates int
+	warnings     int
+	criticals    int
+	unknowns     int
+	unreachable  int
+	minDays      *int
+	problems     []checkProblem
+}
+
+func (r *checkResult) add(state int, format string, args ...any) {
+	r.problems = append(r.problems, checkProblem{state: state, message: fmt.Sprintf(format, args...)})
+	if stateSeverity[state] > stateSeverity[r.state] {
+		r.state = state
+	}
+}
+
+// evaluateCheck checks the certificates of every environment against the thresholds.
+// Environments with an error are considered unreachable.
+func evaluateCheck(certs map[string][]map[string]any, failures map[string]error, t checkThresholds) *checkResult {
+	r := &checkResult{}
+
+	for _, env := range sortedKeys(failures) {
+		r.unreachable++
+		r.add(stateCritical, "%s unreachable: %v", env, failures[env])
+	}
+
+	for _, env := range sortedKeys(certs) {
+		for _, raw := range certs[env] {
+			r.certificates++
+			cert, err := netscaler.ParseCertificate(raw)
+			if err != nil {
+				r.warnings++
+				r.add(stateWarning, "%s: %v", env, err)
+				continue
+			}
+			if !hasExpiry(raw, cert) {
+				r.unknowns++
+				r.add(stateUnknown, "%s/%s has no expiry date", env, cert.Certkey)
+				continue
+			}
+
+			if r.minDays == nil || cert.DaysToExpiration < *r.minDays {
+				days := cert.DaysToExpiration
+				r.minDays = &days
+			}
+
+			state := stateOK
+			switch {
+			case cert.DaysToExpiration <= t.criticalDays:
+				state = stateCritical
+				r.add(state, "%s/%s expires in %d days", env, cert.Certkey, cert.DaysToExpiration)
+			case cert.DaysToExpiration <= t.warningDays:
+				state = stateWarning
+				r.add(state, "%s/%s expires in %d days", env, cert.Certkey, cert.DaysToExpiration)
+			}
+
+			// Intermediate and CA certkeys have no key, their issuer may not be installed at all
+			if t.requireChain && hasKey(raw) && cert.LinkCertkey == "" && !cert.IsSelfSigned() {
+				state = max(state, stateWarning)
+				r.add(stateWarning, "%s/%s has no linked chain certificate", env, cert.Certkey)
+			}
+
+			switch state {
+			case stateCritical:
+				r.criticals++
+			case stateWarning:
+				r.warnings++
+			}
+		}
+	}
+
+	return r
+}
+
+// hasExpiry reports whether the appliance returned the days to expiration or the expiry date of the certificate
+func hasExpiry(raw map[string]any, cert *netscaler.Certificate) bool {
+	if days, ok := raw["daystoexpiration"]; ok && days != nil && days != "" {
+		return true
+	}
+	return !cert.NotAfter.IsZero()
+}
+
+// hasKey reports whether the certkey has a private key, i.e. is a server certificate
+func hasKey(raw map[string]any) bool {
+	key, _ := raw["key"].(string)
+	return key != ""
+}
+
+// write prints the one-line plugin output followed by the performance data
+func (r *checkResult) write(w io.Writer, t checkThresholds) {
+	var summary string
+	if len(r.problems) == 0 {
+		summary = fmt.Sprintf("%d certificates ok", r.certificates)
+	} else {
+		messages := make([]string, 0, len(r.problems))
+		// Most severe problems first
+		for _, state := range []int{stateCritical, stateWarning, stateUnknown} {
+			for _, p := range r.problems {
+				if p.state == state {
+					messages = append(messages, p.message)
+				}
+			}
+		}
+		counts := fmt.Sprintf("%d critical, %d warning", r.criticals, r.warnings)
+		if r.unknowns > 0 {
+			counts += fmt.Sprintf(", %d unknown", r.unknowns)
+		}
+		summary = fmt.Sprintf("%s, %d unreachable: %s", counts, r.unreachable, strings.Join(messages, ", "))
+	}
+
+	// U marks the value as unknown when there is no certificate to take it from. The ranges alert
+	// below their start, the thresholds themselves are already a warning or critical state.
+	minDays := "U"
+	if r.minDays != nil {
+		minDays = fmt.Sprint(*r.minDays)
+	}
+
+	_, _ = fmt.Fprintf(w, "NETSCALER CERTIFICATES %s - %s | certificates=%d;;;0 critical=%d;;;0 warning=%d;;;0 unreachable=%d;;;0 unknown=%d;;;0 min_days=%s;%d:;%d:;;\n",
+		stateNames[r.state], summary,
+		r.certificates, r.criticals, r.warnings, r.unreachable, r.unknowns,
+		minDays, t.warningDays+1, t.criticalDays+1)
+}
+
+func runCheck(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("check", opts)
+	t := checkThresholds{}
+	fs.IntVar(&t.warningDays, "warning", 30, "Warn when a certificate expires within this number of days")
+	fs.IntVar(&t.criticalDays, "critical", 14, "Critical when a certificate expires within this number of days")
+	fs.BoolVar(&t.requireChain, "require-chain", true, "Warn about server certificates without a linked chain certificate")
+	if err := fs.Parse(args); err != nil {
+		return unknownState(c.stdout, err)
+	}
+	if t.criticalDays > t.warningDays {
+		return unknownState(c.stdout, fmt.Errorf("-critical (%d) must not be greater than -warning (%d)", t.criticalDays, t.warningDays))
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return unknownState(c.stdout, err)
+	}
+	defer c.closeEnvironments(clients)
+
+	certs := make(map[string][]map[string]any)
+	for env, client := range clients {
+		all, err := client.GetAllCertificates()
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		certs[env] = all
+	}
+
+	r := evaluateCheck(certs, failures, t)
+	r.write(c.stdout, t)
+	if r.state == stateOK {
+		return nil
+	}
+
+	return &exitError{code: r.state}
+}
+
+// unknownState reports an error that prevented the check from running
+func unknownState(w io.Writer, err error) error {
+	_, _ = fmt.Fprintf(w, "NETSCALER CERTIFICATES UNKNOWN - %v\n", err)
+	return &exitError{code: stateUnknown}
+}
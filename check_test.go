@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateCheck(t *testing.T) {
+	thresholds := checkThresholds{warningDays: 30, criticalDays: 14, requireChain: true}
+
+	tests := []struct {
+		name          string
+		certs         map[string][]map[string]any
+		failures      map[string]error
+		wantState     int
+		wantCriticals int
+		wantWarnings  int
+		description   string
+	}{
+		{
+			name: "all ok",
+			certs: map[string][]map[string]any{
+				"prod": {{"certkey": "prod-a", "daystoexpiration": float64(60), "linkcertkeyname": "ca"}},
+			},
+			wantState:   stateOK,
+			description: "should be OK when no certificate is near expiry",
+		},
+		{
+			name: "warning",
+			certs: map[string][]map[string]any{
+				"prod": {
+					{"certkey": "prod-a", "daystoexpiration": float64(60), "linkcertkeyname": "ca"},
+					{"certkey": "prod-b", "daystoexpiration": float64(20), "linkcertkeyname": "ca"},
+				},
+			},
+			wantState:    stateWarning,
+			wantWarnings: 1,
+			description:  "should warn when a certificate is within the warning threshold",
+		},
+		{
+			name: "critical",
+			certs: map[string][]map[string]any{
+				"prod": {{"certkey": "prod-a", "daystoexpiration": float64(3), "linkcertkeyname": "ca"}},
+				"dev":  {{"certkey": "dev-a", "daystoexpiration": float64(20), "linkcertkeyname": "ca"}},
+			},
+			wantState:     stateCritical,
+			wantCriticals: 1,
+			wantWarnings:  1,
+			description:   "should be critical when a certificate is within the critical threshold",
+		},
+		{
+			name: "missing chain",
+			certs: map[string][]map[string]any{
+				"prod": {
+					{"certkey": "prod-a", "key": "prod-a.key", "daystoexpiration": float64(60), "subject": "CN=a", "issuer": "CN=ca"},
+					{"certkey": "prod-self", "key": "prod-self.key", "daystoexpiration": float64(60), "subject": "CN=self", "issuer": "CN=self"},
+				},
+			},
+			wantState:    stateWarning,
+			wantWarnings: 1,
+			description:  "should warn about leaf certificates without linked chain",
+		},
+		{
+			name: "intermediate without chain",
+			certs: map[string][]map[string]any{
+				"prod": {{"certkey": "prod-r3", "daystoexpiration": float64(300), "subject": "CN=R3", "issuer": "CN=ISRG Root X1"}},
+			},
+			wantState:   stateOK,
+			description: "intermediates have no key and should not need a linked root",
+		},
+		{
+			name: "no expiry",
+			certs: map[string][]map[string]any{
+				"prod": {{"certkey": "prod-a", "linkcertkeyname": "ca"}},
+			},
+			wantState:   stateUnknown,
+			description: "should be unknown when the appliance reports no expiry for a certificate",
+		},
+		{
+			name: "no expiry and critical",
+			certs: map[string][]map[string]any{
+				"prod": {
+					{"certkey": "prod-a", "linkcertkeyname": "ca"},
+					{"certkey": "prod-b", "daystoexpiration": float64(3), "linkcertkeyname": "ca"},
+				},
+			},
+			wantState:     stateCritical,
+			wantCriticals: 1,
+			description:   "an unknown certificate should not hide a critical one",
+		},
+		{
+			name:        "unreachable",
+			certs:       map[string][]map[string]any{},
+			failures:    map[string]error{"prod": errors.New("connection refused")},
+			wantState:   stateCritical,
+			description: "should be critical when an environment cannot be reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.failures == nil {
+				tt.failures = map[string]error{}
+			}
+			got := evaluateCheck(tt.certs, tt.failures, thresholds)
+			if got.state != tt.wantState {
+				t.Errorf("evaluateCheck() state = %d, want %d (%v)", got.state, tt.wantState, got.problems)
+			}
+			if got.criticals != tt.wantCriticals || got.warnings != tt.wantWarnings {
+				t.Errorf("evaluateCheck() criticals/warnings = %d/%d, want %d/%d",
+					got.criticals, got.warnings, tt.wantCriticals, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestCheckResult_Write(t *testing.T) {
+	thresholds := checkThresholds{warningDays: 30, criticalDays: 14}
+	r := evaluateCheck(map[string][]map[string]any{
+		"prod": {
+			{"certkey": "prod-a", "daystoexpiration": float64(10)},
+			{"certkey": "prod-b", "daystoexpiration": float64(20)},
+		},
+	}, map[string]error{}, thresholds)
+
+	var buf bytes.Buffer
+	r.write(&buf, thresholds)
+	out := buf.String()
+
+	if strings.Count(out, "\n") != 1 {
+		t.Errorf("write() should print a single line, got %q", out)
+	}
+	want := "NETSCALER CERTIFICATES CRITICAL - 1 critical, 1 warning, 0 unreachable: prod/prod-a expires in 10 days, prod/prod-b expires in 20 days"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("write() = %q, want prefix %q", out, want)
+	}
+	if !strings.Contains(out, "| certificates=2;;;0") || !strings.Contains(out, "min_days=10;31:;15:;;") {
+		t.Errorf("write() perfdata missing in %q", out)
+	}
+}
+
+func TestCheckResult_WriteWithoutCertificates(t *testing.T) {
+	thresholds := checkThresholds{warningDays: 30, criticalDays: 14}
+	var buf bytes.Buffer
+	evaluateCheck(map[string][]map[string]any{}, map[string]error{}, thresholds).write(&buf, thresholds)
+
+	if !strings.Contains(buf.String(), "min_days=U;31:;15:;;") {
+		t.Errorf("write() = %q, want min_days reported as unknown", buf.String())
+	}
+}
+
+func TestRunCheck_Thresholds(t *testing.T) {
+	c, stdout, _ := newTestCLI()
+	err := runCheck(c, []string{"-config", writeTestConfig(t, testConfigYAML), "-warning", "7", "-critical", "14"})
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != stateUnknown {
+		t.Errorf("runCheck() error = %v, want exit code %d", err, stateUnknown)
+	}
+	if !strings.Contains(stdout.String(), "-critical (14) must not be greater than -warning (7)") {
+		t.Errorf("runCheck() output = %q", stdout.String())
+	}
+}
+
+func TestRunCheck_Unknown(t *testing.T) {
+	t.Setenv(defaultConfigEnv, "")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"check"}, &stdout, &stderr); code != stateUnknown {
+		t.Errorf("runCommand(check) = %d, want %d", code, stateUnknown)
+	}
+	if !strings.HasPrefix(stdout.String(), "NETSCALER CERTIFICATES UNKNOWN - ") {
+		t.Errorf("runCommand(check) output = %q", stdout.String())
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// defaultConfigEnv names the environment variable holding the default config file for CLI commands
+const defaultConfigEnv = "NETSCALER_PLUGIN_CONFIG"
+
+// cliCommand is a subcommand of the standalone CLI mode
+type cliCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(c *cli, args []string) error
+}
+
+// cli holds the state shared by all subcommands
+type cli struct {
+	stdin         io.Reader
+	stdout        io.Writer
+	stderr        io.Writer
+	logger        hclog.Logger
+	clientFactory func(prefix string, config *netscaler.ClientConfig) (*netscaler.Client, error)
+	// adm holds the NetScaler Console sessions of the environments proxied through ADM
+	adm admSessions
+	// envConfigs holds the configs of the environments opened by openEnvironments
+	envConfigs envConfig
+	// configured holds the configs of all environments of the config file, set by loadEnvironments
+	configured envConfig
+	// command is the name of the running subcommand, set by newFlagSet
+	command string
+}
+
+// exitError carries a specific process exit code out of a subcommand
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// commonOptions are the flags every inventory subcommand understands
+type commonOptions struct {
+	config       string
+	environments string
+	format       string
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{name: "list", usage: "list [flags]", description: "List certificates of all environments", run: runList},
+		{name: "get", usage: "get [flags] <domain>", description: "Show the certificate for a domain in every environment", run: runGet},
+		{name: "expiring", usage: "expiring [flags]", description: "List certificates expiring within the given number of days", run: runExpiring},
+		{name: "check", usage: "check [flags]", description: "Monitoring plugin check with Nagios compatible exit codes", run: runCheck},
+		{name: "drift", usage: "drift [flags] [domain...]", description: "List domains whose certificate differs between environments", run: runDrift},
+		{name: "hostnames", usage: "hostnames [flags]", description: "List hostnames routed to SSL vservers that no bound certificate covers", run: runHostnames},
+		{name: "export", usage: "export [flags]", description: "Write a snapshot of all certificates, bindings and chains", run: runExport},
+		{name: "diff", usage: "diff [flags] <before> <after>", description: "Compare two snapshots written by export", run: runDiff},
+		{name: "import", usage: "import [flags]", description: "Generate dehydrated domain entries from the certificates of an environment", run: runImport},
+		{name: "csr", usage: "csr [flags] <domain> [name...]", description: "Create a key on the appliance and print a CSR for dehydrated --signcsr", run: runCSR},
+		{name: "deploy", usage: "deploy [flags] <domain>", description: "Install a signed certificate against the key on the appliance", run: runDeploy},
+		{name: "verify", usage: "verify [flags] <domain>", description: "Check with a TLS handshake that every bound VIP serves the certificate of a domain", run: runVerify},
+		{name: "prune", usage: "prune [flags]", description: "List or delete expired, unbound and replaced certkeys and orphaned files", run: runPrune},
+		{name: "intermediates", usage: "intermediates [flags]", description: "Report certkeys holding the same CA certificate and consolidate their links", run: runIntermediates},
+		{name: "ha", usage: "ha [flags]", description: "Check that the HA secondary has the certificate files of an environment", run: runHA},
+		{name: "apply", usage: "apply [flags] -plan <file>", description: "Execute a plan written with -dry-run -format json", run: runApply},
+		{name: "validate-config", usage: "validate-config [flags]", description: "Report every problem of the config file", run: runValidateConfig},
+		{name: "exporter", usage: "exporter [flags]", description: "Serve certificate metrics for Prometheus", run: runExporter},
+	}
+}
+
+// runCommand executes a CLI subcommand and returns the process exit code
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	c := &cli{
+		stdin:  os.Stdin,
+		stdout: stdout,
+		stderr: stderr,
+		logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "netscaler-plugin",
+			Level:  hclog.Warn,
+			Output: stderr,
+		}),
+		clientFactory: netscaler.NewClient,
+	}
+
+	commands := cliCommands()
+	i := slices.IndexFunc(commands, func(cmd cliCommand) bool { return cmd.name == args[0] })
+	if i < 0 {
+		c.printUsage(commands)
+		return 2
+	}
+
+	err := commands[i].run(c, args[1:])
+	if closeErr := c.adm.Close(); closeErr != nil {
+		c.logger.Warn("Failed to log out of ADM", "error", closeErr)
+	}
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		if exitErr.err != nil {
+			_, _ = fmt.Fprintln(stderr, exitErr.err)
+		}
+		return exitErr.code
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+	return 1
+}
+
+func (c *cli) printUsage(commands []cliCommand) {
+	_, _ = fmt.Fprintf(c.stderr, "Usage: %s [-version] <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(c.stderr, "  %-28s %s\n", cmd.usage, cmd.description)
+	}
+}
+
+// newFlagSet creates the flag set of a subcommand including the common options
+func (c *cli) newFlagSet(name string, opts *commonOptions) *flag.FlagSet {
+	c.command = name
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&opts.config, "config", os.Getenv(defaultConfigEnv), "Path to the plugin config file (YAML or JSON)")
+	fs.StringVar(&opts.environments, "env", "", "Comma separated list of environments (default: all)")
+	fs.StringVar(&opts.format, "format", formatTable, "Output format: table, json, yaml or csv")
+	return fs
+}
+
+// loadConfigFile reads the plugin config from a YAML or JSON file
+func loadConfigFile(path string) (map[string]any, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no config file given, use -config or set %s", defaultConfigEnv)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// loadEnvironments reads the config file and returns the selected environment configs. Selecting an
+// environment with an ADM instance group selects all its instances, which can also be selected alone.
+func (c *cli) loadEnvironments(opts *commonOptions) (envConfig, error) {
+	config, err := loadConfigFile(opts.config)
+	if err != nil {
+		return nil, err
+	}
+
+	environments, ok := config["environments"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid config format: environments is not a map")
+	}
+
+	var defaults map[string]any
+	if v, ok := config["defaults"]; ok {
+		if defaults, ok = v.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid config format: defaults is not a map")
+		}
+	}
+
+	envConfigs, err := parseEnvironments(c.logger, defaults, environments)
+	if err != nil {
+		return nil, err
+	}
+	c.configured = envConfigs
+
+	if opts.environments == "" {
+		return c.adm.expand(envConfigs)
+	}
+
+	selected := make(envConfig)
+	var expanded envConfig
+	for _, env := range strings.Split(opts.environments, ",") {
+		env = strings.TrimSpace(env)
+		cfg, ok := envConfigs[env]
+		if !ok {
+			if expanded == nil {
+				if expanded, err = c.adm.expand(envConfigs); err != nil {
+					return nil, err
+				}
+			}
+			if cfg, ok = expanded[env]; !ok {
+				return nil, fmt.Errorf("unknown environment %s", env)
+			}
+		}
+		selected[env] = cfg
+	}
+
+	return c.adm.expand(selected)
+}
+
+// openEnvironments creates a client for every selected environment.
+// Environments that cannot be reached are returned as failures instead of aborting the command.
+func (c *cli) openEnvironments(opts *commonOptions) (map[string]*netscaler.Client, map[string]error, error) {
+	envConfigs, err := c.loadEnvironments(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.envConfigs = envConfigs
+
+	clients := make(map[string]*netscaler.Client)
+	failures := make(map[string]error)
+	for env, cfg := range envConfigs {
+		client, err := c.newClient(cfg)
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		clients[env] = client
+	}
+
+	return clients, failures, nil
+}
+
+// openEnvironment creates the client of the single environment selected with -env.
+// Commands changing an appliance or reading one environment use it.
+func (c *cli) openEnvironment(opts *commonOptions) (string, *netscaler.Client, error) {
+	envConfigs, err := c.loadEnvironments(opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(envConfigs) != 1 {
+		return "", nil, fmt.Errorf("exactly one environment is required, select it with -env")
+	}
+	c.envConfigs = envConfigs
+
+	env := sortedKeys(envConfigs)[0]
+	client, err := c.newClient(envConfigs[env])
+	if err != nil {
+		return "", nil, fmt.Errorf("environment %s: %w", env, err)
+	}
+	return env, client, nil
+}
+
+// newClient connects to an environment, through the shared ADM session for the adm backend.
+// Inventory snapshots are only read by the plugin, commands need the NITRO API.
+func (c *cli) newClient(cfg netscaler.Config) (*netscaler.Client, error) {
+	if cfg.Inventory != "" {
+		return nil, fmt.Errorf("inventory environments are not supported by %s", c.command)
+	}
+	if cfg.ADM() {
+		return c.adm.newClient(cfg)
+	}
+	client, err := c.clientFactory(cfg.Prefix, cfg.ClientConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := client.ClusterError(); err != nil {
+		c.logger.Warn("Treating the appliance as standalone", "endpoint", cfg.Endpoint, "error", err)
+	}
+	return client, nil
+}
+
+// appliesTo reports whether the domain routing of an opened environment includes the domain
+func (c *cli) appliesTo(env, domain string) bool {
+	cfg, ok := c.envConfigs[env]
+	return !ok || cfg.AppliesTo(domain)
+}
+
+// closeEnvironments ends the sessions of all clients
+func (c *cli) closeEnvironments(clients map[string]*netscaler.Client) {
+	for env, client := range clients {
+		if err := client.Close(); err != nil {
+			c.logger.Warn("Failed to close client", "environment", env, "error", err)
+		}
+	}
+}
+
+// warnFailures prints one warning per environment that could not be queried
+func (c *cli) warnFailures(failures map[string]error) {
+	for _, env := range sortedKeys(failures) {
+		_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: %v\n", env, failures[env])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
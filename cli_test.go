@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+const testConfigYAML = `
+environments:
+  prod:
+    endpoint: https://netscaler-prod.example.com
+    username: admin
+    password: secret
+    prefix: prod-
+  dev:
+    endpoint: https://netscaler-dev.example.com
+    username: admin
+    password: secret
+    prefix: dev-
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func newTestCLI() (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &cli{
+		stdout:        stdout,
+		stderr:        stderr,
+		logger:        hclog.NewNullLogger(),
+		clientFactory: mockClientFactory,
+	}, stdout, stderr
+}
+
+// memoryClientFactory creates clients backed by the in-memory NITRO client registered for the prefix
+func memoryClientFactory(apis map[string]*netscalertest.NitroClient) func(string, *netscaler.ClientConfig) (*netscaler.Client, error) {
+	return func(prefix string, _ *netscaler.ClientConfig) (*netscaler.Client, error) {
+		api, ok := apis[prefix]
+		if !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return netscaler.NewClientFromNitro(prefix, api)
+	}
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"unknown"}, &stdout, &stderr); code != 2 {
+		t.Errorf("runCommand() = %d, want 2", code)
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("Commands:")) {
+		t.Errorf("runCommand() should print usage, got %q", stderr.String())
+	}
+}
+
+func TestRunCommand_MissingConfig(t *testing.T) {
+	t.Setenv(defaultConfigEnv, "")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"list"}, &stdout, &stderr); code != 1 {
+		t.Errorf("runCommand() = %d, want 1", code)
+	}
+}
+
+func TestCLI_LoadEnvironments(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML)
+
+	tests := []struct {
+		name         string
+		environments string
+		wantEnvs     []string
+		wantErr      bool
+		description  string
+	}{
+		{
+			name:        "all environments",
+			wantEnvs:    []string{"dev", "prod"},
+			description: "should load every environment by default",
+		},
+		{
+			name:         "selected environment",
+			environments: "prod",
+			wantEnvs:     []string{"prod"},
+			description:  "should only load the selected environments",
+		},
+		{
+			name:         "unknown environment",
+			environments: "prod,qa",
+			wantErr:      true,
+			description:  "should fail for environments missing in the config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newTestCLI()
+			got, err := c.loadEnvironments(&commonOptions{config: path, environments: tt.environments})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadEnvironments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			keys := sortedKeys(got)
+			if len(keys) != len(tt.wantEnvs) {
+				t.Fatalf("loadEnvironments() = %v, want %v", keys, tt.wantEnvs)
+			}
+			for i := range keys {
+				if keys[i] != tt.wantEnvs[i] {
+					t.Errorf("loadEnvironments() = %v, want %v", keys, tt.wantEnvs)
+				}
+			}
+		})
+	}
+}
+
+func TestCLI_InventoryEnvironment(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML+`
+  snapshot:
+    inventory: `+writeTestInventory(t)+`
+    prefix: prod-
+`)
+
+	c, _, stderr := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": netscalertest.NewNitroClient(), "dev-": netscalertest.NewNitroClient()})
+	if err := runList(c, []string{"-config", config}); err != nil {
+		t.Fatalf("runList() error = %v", err)
+	}
+	if !strings.Contains(stderr.String(), "Warning: environment snapshot: inventory environments are not supported by list") {
+		t.Errorf("runList() stderr = %q, want the inventory environment rejected", stderr.String())
+	}
+
+	c, _, _ = newTestCLI()
+	err := runHA(c, []string{"-config", config, "-env", "snapshot"})
+	if err == nil || !strings.Contains(err.Error(), "environment snapshot: inventory environments are not supported by ha") {
+		t.Errorf("runHA() error = %v, want the inventory environment rejected", err)
+	}
+}
+
+func TestLoadConfigFile_JSON(t *testing.T) {
+	path := writeTestConfig(t, `{"environments": {"prod": {"endpoint": "https://ns", "username": "u", "password": "p"}}}`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("loadConfigFile() error = %v", err)
+	}
+	if _, ok := config["environments"].(map[string]any)["prod"]; !ok {
+		t.Errorf("loadConfigFile() = %v, want prod environment", config)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// deploymentRows is the result of a deploy
+type deploymentRows []netscaler.Deployment
+
+func (r deploymentRows) Header() []string {
+	return []string{"CERTKEY", "CERT FILE", "KEY FILE", "CHAIN", "UPDATED", "PREVIOUS", "REBOUND", "RETIRED", "VERIFIED"}
+}
+
+func (r deploymentRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, d := range r {
+		rebound := make([]string, 0, len(d.Rebound))
+		for _, b := range d.Rebound {
+			rebound = append(rebound, b.Type+"/"+b.Name)
+		}
+		verified := make([]string, 0, len(d.Verified))
+		for _, check := range d.Verified {
+			verified = append(verified, check.Vserver+":"+checkState(check))
+		}
+		rows = append(rows, []string{d.Certkey, d.CertFile, d.KeyFile, d.ChainCertkey, fmt.Sprint(d.Updated),
+			d.Previous, strings.Join(rebound, ","), strings.Join(d.Retired, ","), strings.Join(verified, ",")})
+	}
+	return rows
+}
+
+// Deploy strategies
+const (
+	strategyInPlace = "inplace"
+	strategyRotate  = "rotate"
+)
+
+// runCSR creates the key of a domain on the appliance unless it exists and writes a CSR for it,
+// to be signed with dehydrated --signcsr
+func runCSR(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("csr", opts)
+	spec := netscaler.KeySpec{}
+	fs.StringVar(&spec.Type, "key-type", "rsa", "Type of a new key: rsa or ec")
+	fs.IntVar(&spec.Bits, "bits", 2048, "Size of a new RSA key")
+	fs.StringVar(&spec.Curve, "curve", "P-256", "Curve of a new EC key: P-256 or P-384")
+	req := netscaler.CSRRequest{}
+	fs.StringVar(&req.Country, "country", "", "Country of the CSR subject (required by the appliance)")
+	fs.StringVar(&req.State, "state", "", "State of the CSR subject (required by the appliance)")
+	fs.StringVar(&req.Organization, "organization", "", "Organization of the CSR subject (required by the appliance)")
+	output := fs.String("output", "", "Write the CSR to this file instead of stdout")
+	dryRun := fs.Bool("dry-run", false, "Print the planned NITRO operations instead of creating the key and CSR, see apply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: csr [flags] <domain> [alternative name...]")
+	}
+	req.CommonName = fs.Arg(0)
+	req.SANs = fs.Args()
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	target := client
+	var planner *netscaler.Planner
+	if *dryRun {
+		target, planner = client.Planned()
+	}
+
+	keyfile := client.KeyFile(req.CommonName)
+	exists, err := client.FileExists(keyfile)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := target.CreateKey(keyfile, spec); err != nil {
+			return err
+		}
+		if planner == nil {
+			_, _ = fmt.Fprintf(c.stderr, "Created key %s in environment %s\n", keyfile, env)
+		}
+	}
+
+	reqfile := client.CSRFile(req.CommonName)
+	csr, err := target.CreateCSR(keyfile, reqfile, req)
+	if err != nil {
+		return err
+	}
+	if planner != nil {
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+	if err := c.afterWrite(env, client, []string{keyfile, reqfile}); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		return os.WriteFile(*output, csr, 0o644)
+	}
+	_, err = c.stdout.Write(csr)
+	return err
+}
+
+// runDeploy installs a signed certificate, e.g. the output of dehydrated --signcsr, against the on-box key.
+// The rotate strategy installs a new certkey version and moves the bindings instead of updating in place.
+// With -retire only the versions replaced longer than the grace period ago are retired.
+func runDeploy(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("deploy", opts)
+	certPath := fs.String("cert", "-", "PEM file with the certificate and optionally its chain, - reads stdin")
+	keyfile := fs.String("key", "", "Key file on the appliance (default: the key created by csr)")
+	strategy := fs.String("strategy", strategyInPlace, "Deploy strategy: inplace updates the certkey, rotate creates a new version and rebinds it")
+	grace := fs.Duration("grace", 24*time.Hour, "How long replaced versions are kept before they are retired (rotate and -retire)")
+	retire := fs.Bool("retire", false, "Only retire the versions replaced longer than -grace ago, without deploying a certificate")
+	dryRun := fs.Bool("dry-run", false, "Print the planned NITRO operations instead of executing them, see apply")
+	verify := fs.Bool("verify", false, "Verify with a TLS handshake that every bound VIP serves the deployed certificate")
+	verifyAddress := fs.String("verify-address", "", "Address to connect to instead of the address of each VIP (with -verify)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: deploy [flags] <domain>")
+	}
+	if *strategy != strategyInPlace && *strategy != strategyRotate {
+		return fmt.Errorf("unknown strategy %q, use %s or %s", *strategy, strategyInPlace, strategyRotate)
+	}
+	domain := fs.Arg(0)
+	if *retire {
+		return retireVersions(c, opts, domain, *grace, *dryRun)
+	}
+
+	var pemData []byte
+	var err error
+	if *certPath == "-" {
+		pemData, err = io.ReadAll(c.stdin)
+	} else {
+		pemData, err = os.ReadFile(*certPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	if *keyfile == "" {
+		*keyfile = client.KeyFile(domain)
+	}
+	target := client
+	var planner *netscaler.Planner
+	if *dryRun {
+		target, planner = client.Planned()
+	}
+
+	var d *netscaler.Deployment
+	if *strategy == strategyRotate {
+		d, err = target.RotateCertificate(domain, pemData, *keyfile, netscaler.Rotation{Grace: *grace})
+	} else {
+		d, err = target.DeployCertificate(domain, pemData, *keyfile)
+	}
+	if err != nil && d == nil {
+		return fmt.Errorf("failed to deploy %s to environment %s: %w", domain, env, err)
+	}
+	if planner != nil {
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+	if err != nil {
+		// The certificate is live, only retiring replaced versions failed
+		_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: %v\n", env, err)
+	}
+	files, err := client.CertkeyFiles(d.Certkey)
+	if err != nil {
+		return fmt.Errorf("deployed %s to environment %s, but failed to read it back: %w", d.Certkey, env, err)
+	}
+	if err := c.afterWrite(env, client, files); err != nil {
+		return err
+	}
+
+	if *verify {
+		d.Verified, err = client.VerifyTLS(d.Certkey, netscaler.Verification{ServerName: domain, Address: *verifyAddress})
+		if err != nil {
+			return fmt.Errorf("deployed %s to environment %s, but failed to verify it: %w", d.Certkey, env, err)
+		}
+	}
+	if err := render(c.stdout, opts.format, deploymentRows{*d}); err != nil {
+		return err
+	}
+	return verificationError(env, d.Verified)
+}
+
+// retireVersions deletes the unbound certkey versions of a domain replaced longer than grace ago.
+// Rotations retire them only when the next version is deployed, this runs once the grace period passed.
+func retireVersions(c *cli, opts *commonOptions, domain string, grace time.Duration, dryRun bool) error {
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	target := client
+	var planner *netscaler.Planner
+	if dryRun {
+		target, planner = client.Planned()
+	}
+	retired, err := target.RetireVersions(domain, grace, time.Now())
+	if err != nil {
+		err = fmt.Errorf("failed to retire versions of %s in environment %s: %w", domain, env, err)
+	}
+	if planner != nil {
+		if err != nil {
+			return err
+		}
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+
+	if len(retired) > 0 {
+		if saveErr := c.afterWrite(env, client, nil); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	} else if err == nil {
+		_, _ = fmt.Fprintf(c.stderr, "No versions of %s to retire in environment %s\n", domain, env)
+	}
+	rows := make(pruneRows, 0, len(retired))
+	for _, certkey := range retired {
+		rows = append(rows, netscaler.PruneCandidate{Type: "sslcertkey", Name: certkey, Reason: netscaler.PruneReplaced})
+	}
+	if renderErr := render(c.stdout, opts.format, rows); renderErr != nil && err == nil {
+		err = renderErr
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// signCSR plays dehydrated --signcsr, it returns a self-signed certificate for the CSR key
+func signCSR(t *testing.T, csrPEM []byte) []byte {
+	t.Helper()
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		t.Fatalf("CSR is not PEM encoded: %q", csrPEM)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, csr.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to sign CSR: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestRunCSRAndDeploy(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	apis := map[string]*netscalertest.NitroClient{"prod-": prod}
+
+	c, stdout, stderr := newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	csrArgs := []string{"-config", config, "-env", "prod", "-key-type", "ec", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com", "www.example.com"}
+	if err := runCSR(c, csrArgs); err != nil {
+		t.Fatalf("runCSR() error = %v", err)
+	}
+	if !strings.Contains(stderr.String(), "Created key prod-example.com.key") {
+		t.Errorf("runCSR() should report the new key, got %q", stderr.String())
+	}
+	csr := stdout.Bytes()
+
+	// A second CSR reuses the key on the appliance
+	key, _ := prod.Store.ReadFile("prod-example.com.key")
+	c, _, stderr = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runCSR(c, csrArgs); err != nil {
+		t.Fatalf("runCSR() again error = %v", err)
+	}
+	if again, _ := prod.Store.ReadFile("prod-example.com.key"); !bytes.Equal(key, again) || stderr.Len() != 0 {
+		t.Error("runCSR() should reuse an existing key")
+	}
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(signCSR(t, csr))
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-format", "json", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() error = %v", err)
+	}
+
+	var got []netscaler.Deployment
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("runDeploy() wrote invalid JSON: %v", err)
+	}
+	if len(got) != 1 || got[0].Certkey != "prod-example.com" || got[0].KeyFile != "prod-example.com.key" {
+		t.Errorf("runDeploy() = %+v", got)
+	}
+	if res, err := prod.Store.Get("sslcertkey", "prod-example.com"); err != nil || res["key"] != "prod-example.com.key" {
+		t.Errorf("certkey = %v, %v", res, err)
+	}
+}
+
+func TestRunDeploy_Rotate(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	apis := map[string]*netscalertest.NitroClient{"prod-": prod}
+
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runCSR(c, []string{"-config", config, "-env", "prod", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com"}); err != nil {
+		t.Fatalf("runCSR() error = %v", err)
+	}
+	csr := stdout.Bytes()
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(signCSR(t, csr))
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() error = %v", err)
+	}
+	_ = prod.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(signCSR(t, csr))
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-strategy", "rotate", "-grace", "0s", "-format", "json", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() -strategy rotate error = %v", err)
+	}
+
+	var got []netscaler.Deployment
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("runDeploy() wrote invalid JSON: %v", err)
+	}
+	if len(got) != 1 || !strings.HasPrefix(got[0].Certkey, "prod-example.com-") || got[0].Previous != "prod-example.com" {
+		t.Fatalf("runDeploy() -strategy rotate = %+v", got)
+	}
+	if len(got[0].Rebound) != 1 || got[0].Rebound[0].Name != "vs-web" || len(got[0].Retired) != 1 {
+		t.Errorf("runDeploy() -strategy rotate should move the binding and retire the previous certkey, got %+v", got[0])
+	}
+	if bound := prod.Store.Bindings("sslvserver_sslcertkey_binding", "vs-web"); len(bound) != 1 || bound[0]["certkeyname"] != got[0].Certkey {
+		t.Errorf("vs-web bindings = %v", bound)
+	}
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-strategy", "bluegreen", "example.com"}); err == nil || !strings.Contains(err.Error(), "unknown strategy") {
+		t.Errorf("runDeploy() with unknown strategy error = %v", err)
+	}
+}
+
+func TestRunDeploy_Retire(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	for _, certkey := range []string{"prod-example.com", "prod-example.com-20200101000000"} {
+		_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": certkey, "cert": certkey + ".crt"})
+	}
+	_ = prod.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com-20200101000000"})
+	apis := map[string]*netscalertest.NitroClient{"prod-": prod}
+
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-retire", "-dry-run", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() -retire -dry-run error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "prod-example.com") {
+		t.Errorf("runDeploy() -retire -dry-run should plan deleting the replaced version, got %q", stdout.String())
+	}
+	if _, err := prod.Store.Get("sslcertkey", "prod-example.com"); err != nil {
+		t.Fatal("runDeploy() -retire -dry-run should not delete anything")
+	}
+
+	// No certificate is read from stdin
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-retire", "-format", "json", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() -retire error = %v", err)
+	}
+	var got []netscaler.PruneCandidate
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("runDeploy() -retire wrote invalid JSON: %v", err)
+	}
+	want := []netscaler.PruneCandidate{{Type: "sslcertkey", Name: "prod-example.com", Reason: netscaler.PruneReplaced}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runDeploy() -retire = %+v, want %+v", got, want)
+	}
+	if _, err := prod.Store.Get("sslcertkey", "prod-example.com-20200101000000"); err != nil {
+		t.Error("runDeploy() -retire should keep the current version")
+	}
+	if _, err := prod.Store.Get("sslcertkey", "prod-example.com"); err == nil {
+		t.Error("runDeploy() -retire should delete the replaced version")
+	}
+}
+
+func TestRunCSR_Errors(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+
+	tests := []struct {
+		name        string
+		args        []string
+		description string
+	}{
+		{"no domain", []string{"-config", config, "-env", "prod"}, "should require a domain"},
+		{"all environments", []string{"-config", config, "example.com"}, "should require exactly one environment"},
+		{"missing subject", []string{"-config", config, "-env", "prod", "example.com"}, "should require the subject fields of the appliance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": netscalertest.NewNitroClient()})
+			if err := runCSR(c, tt.args); err == nil {
+				t.Error("runCSR() should fail")
+			}
+		})
+	}
+}
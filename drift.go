@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// errNotDeployed marks environments without a certificate for a domain
+var errNotDeployed = errors.New("certificate not deployed")
+
+// driftRows lists the certificate of every domain per environment
+type driftRows []netscaler.Drift
+
+func (r driftRows) Header() []string {
+	return []string{"DOMAIN", "ENVIRONMENT", "STATUS", "SERIAL", "NOT AFTER"}
+}
+
+func (r driftRows) Rows() [][]string {
+	rows := [][]string{}
+	for _, d := range r {
+		for _, env := range sortedKeys(d.Environments) {
+			e := d.Environments[env]
+			rows = append(rows, []string{d.Domain, env, string(e.Status), e.Serial, formatDate(e.NotAfter)})
+		}
+	}
+	return rows
+}
+
+func runDrift(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("drift", opts)
+	all := fs.Bool("all", false, "Also list domains that are in sync")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	// certificates per domain and environment, with the environment prefix and version removed
+	byDomain := make(map[string]map[string]map[string]any)
+	for env, client := range clients {
+		certs, err := client.GetAllCertificates()
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		for _, raw := range certs {
+			certkey, _ := raw["certkey"].(string)
+			domain, _ := netscaler.CertkeyDomain(client.Prefix(), certkey)
+			if byDomain[domain] == nil {
+				byDomain[domain] = make(map[string]map[string]any)
+			}
+			// Rotated domains are compared by their newest version
+			if prev, ok := byDomain[domain][env]["certkey"].(string); ok && prev > certkey {
+				continue
+			}
+			byDomain[domain][env] = raw
+		}
+	}
+	c.warnFailures(failures)
+
+	domains := fs.Args()
+	if len(domains) == 0 {
+		domains = sortedKeys(byDomain)
+	}
+
+	rows := driftRows{}
+	for _, domain := range domains {
+		certs := make(map[string]map[string]any)
+		missing := make(map[string]error)
+		for env := range clients {
+			if !c.appliesTo(env, domain) || failures[env] != nil {
+				continue
+			}
+			if raw, ok := byDomain[domain][env]; ok {
+				certs[env] = raw
+			} else {
+				missing[env] = errNotDeployed
+			}
+		}
+		d := netscaler.CompareCertificates(domain, certs, missing)
+		if *all || !d.InSync {
+			rows = append(rows, d)
+		}
+	}
+
+	return render(c.stdout, opts.format, rows)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestRunDrift(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	dev := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "serial": "02", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT"})
+	_ = dev.Store.Add("sslcertkey", map[string]any{"certkey": "dev-example.com", "serial": "01", "clientcertnotafter": "Dec  1 00:00:00 2026 GMT"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-shared.example.com", "serial": "05"})
+	_ = dev.Store.Add("sslcertkey", map[string]any{"certkey": "dev-shared.example.com", "serial": "05"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-api.example.com", "serial": "07"})
+
+	tests := []struct {
+		name        string
+		args        []string
+		want        map[string]map[string]netscaler.DriftStatus
+		description string
+	}{
+		{
+			name: "drifting domains",
+			args: []string{"-config", config, "-format", "json"},
+			want: map[string]map[string]netscaler.DriftStatus{
+				"api.example.com": {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftMissing},
+				"example.com":     {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftOutdated},
+			},
+			description: "should list domains that are missing or outdated somewhere",
+		},
+		{
+			name: "all domains",
+			args: []string{"-config", config, "-format", "json", "-all"},
+			want: map[string]map[string]netscaler.DriftStatus{
+				"api.example.com":    {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftMissing},
+				"example.com":        {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftOutdated},
+				"shared.example.com": {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftCurrent},
+			},
+			description: "should include domains in sync with -all",
+		},
+		{
+			name: "selected domain",
+			args: []string{"-config", config, "-format", "json", "-all", "shared.example.com"},
+			want: map[string]map[string]netscaler.DriftStatus{
+				"shared.example.com": {"prod": netscaler.DriftCurrent, "dev": netscaler.DriftCurrent},
+			},
+			description: "should only compare the given domains",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod, "dev-": dev})
+
+			if err := runDrift(c, tt.args); err != nil {
+				t.Fatalf("runDrift() error = %v", err)
+			}
+
+			var got []netscaler.Drift
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("runDrift() = %s, want %d domains", stdout, len(tt.want))
+			}
+			for _, d := range got {
+				for env, status := range tt.want[d.Domain] {
+					if d.Environments[env].Status != status {
+						t.Errorf("%s in %s = %s, want %s", d.Domain, env, d.Environments[env].Status, status)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// inventoryRows renders an inventory snapshot as one row per certificate
+type inventoryRows struct {
+	inventory *netscaler.Inventory
+}
+
+func (r inventoryRows) Header() []string {
+	return []string{"ENVIRONMENT", "CERTKEY", "SUBJECT", "ISSUER", "SERIAL", "NOT BEFORE", "NOT AFTER", "DAYS",
+		"KEY", "SIGNATURE", "SANS", "CHAIN", "BINDINGS", "STATUS"}
+}
+
+func (r inventoryRows) Rows() [][]string {
+	rows := [][]string{}
+	for _, env := range sortedKeys(r.inventory.Environments) {
+		details := r.inventory.Environments[env].Details
+		for _, certkey := range sortedKeys(details) {
+			d := details[certkey]
+			bindings := make([]string, 0, len(d.Bindings))
+			for _, b := range d.Bindings {
+				bindings = append(bindings, b.Type+"/"+b.Name)
+			}
+			rows = append(rows, []string{
+				env,
+				d.Certkey,
+				d.Subject,
+				d.Issuer,
+				d.Serial,
+				formatDate(d.NotBefore),
+				formatDate(d.NotAfter),
+				strconv.Itoa(d.DaysToExpiration),
+				strings.TrimSpace(fmt.Sprintf("%s %d", d.KeyType, d.KeySize)),
+				d.SignatureAlg,
+				strings.Join(d.SANs, " "),
+				strings.Join(d.Chain, " "),
+				strings.Join(bindings, " "),
+				d.Status,
+			})
+		}
+	}
+	return rows
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+// changeRows are the differences between two inventory snapshots
+type changeRows []netscaler.Change
+
+func (r changeRows) Header() []string {
+	return []string{"ENVIRONMENT", "CERTKEY", "CHANGE", "BEFORE", "AFTER"}
+}
+
+func (r changeRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, c := range r {
+		rows = append(rows, []string{c.Environment, c.Certkey, string(c.Type), c.Before, c.After})
+	}
+	return rows
+}
+
+func runExport(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("export", opts)
+	format := fs.Lookup("format")
+	format.DefValue = formatJSON
+	format.Usage = "Output format: json or csv"
+	_ = format.Value.Set(formatJSON)
+	output := fs.String("output", "", "Write the snapshot to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.format != formatJSON && opts.format != formatCSV {
+		return fmt.Errorf("unsupported export format %q", opts.format)
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	inv := netscaler.NewInventory()
+	for _, env := range sortedKeys(clients) {
+		snapshot, err := clients[env].Snapshot()
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		inv.Environments[env] = *snapshot
+	}
+
+	// An incomplete snapshot would show up as removed certificates in a later diff
+	if len(failures) > 0 {
+		c.warnFailures(failures)
+		return fmt.Errorf("failed to export %d environment(s)", len(failures))
+	}
+
+	var w io.Writer = c.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if opts.format == formatCSV {
+		return render(w, formatCSV, inventoryRows{inventory: inv})
+	}
+	return inv.Save(w)
+}
+
+func runDiff(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("diff", opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [flags] <before.json> <after.json>")
+	}
+
+	before, err := netscaler.LoadInventory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := netscaler.LoadInventory(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	changes, err := netscaler.DiffInventories(before, after)
+	if err != nil {
+		return err
+	}
+
+	return render(c.stdout, opts.format, changeRows(changes))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestRunExportAndDiff(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "serial": "01", "linkcertkeyname": "le-r3"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "le-r3", "serial": "99"})
+	_ = prod.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+
+	c, stdout, stderr := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+
+	before := filepath.Join(t.TempDir(), "before.json")
+	if err := runExport(c, []string{"-config", config, "-env", "prod", "-output", before}); err != nil {
+		t.Fatalf("runExport() error = %v, stderr %s", err, stderr)
+	}
+	inv, err := netscaler.LoadInventory(before)
+	if err != nil {
+		t.Fatalf("LoadInventory() error = %v", err)
+	}
+	d := inv.Environments["prod"].Details["prod-example.com"]
+	if len(d.Chain) != 1 || d.Chain[0] != "le-r3" || len(d.Bindings) != 1 {
+		t.Errorf("exported details = %+v, want chain and bindings", d)
+	}
+
+	// renew the certificate and add another one during the "maintenance window"
+	_ = prod.Store.Update("sslcertkey", "prod-example.com", map[string]any{"serial": "02"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-api.example.com", "serial": "03"})
+	after := filepath.Join(t.TempDir(), "after.json")
+	if err := runExport(c, []string{"-config", config, "-env", "prod", "-output", after}); err != nil {
+		t.Fatalf("runExport() error = %v", err)
+	}
+
+	stdout.Reset()
+	if err := runDiff(c, []string{"-format", "csv", before, after}); err != nil {
+		t.Fatalf("runDiff() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV output: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("runDiff() = %q, want header and 2 changes", stdout.String())
+	}
+	if records[1][1] != "prod-api.example.com" || records[1][2] != "added" {
+		t.Errorf("first change = %v, want prod-api.example.com added", records[1])
+	}
+	if records[2][1] != "prod-example.com" || records[2][2] != "renewed" {
+		t.Errorf("second change = %v, want prod-example.com renewed", records[2])
+	}
+}
+
+func TestRunExport_CSV(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com"})
+
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+
+	if err := runExport(c, []string{"-config", config, "-env", "prod", "-format", "csv"}); err != nil {
+		t.Fatalf("runExport() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV output: %v", err)
+	}
+	if len(records) != 2 || records[1][1] != "prod-example.com" || records[1][2] != "CN=example.com" {
+		t.Errorf("runExport() = %v", records)
+	}
+}
+
+func TestRunExport_Errors(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+
+	tests := []struct {
+		name        string
+		args        []string
+		description string
+	}{
+		{
+			name:        "unreachable environment",
+			args:        []string{"-config", config},
+			description: "should not write an incomplete snapshot",
+		},
+		{
+			name:        "unsupported format",
+			args:        []string{"-config", config, "-format", "table"},
+			description: "should only support json and csv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": netscalertest.NewNitroClient()})
+			if err := runExport(c, tt.args); err == nil {
+				t.Error("runExport() should fail")
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("runExport() wrote %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunDiff_Usage(t *testing.T) {
+	c, _, _ := newTestCLI()
+	if err := runDiff(c, []string{"only-one.json"}); err == nil {
+		t.Error("runDiff() should require two snapshots")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// exportedCertificate is the metric relevant part of a deployed certificate
+type exportedCertificate struct {
+	certkey  string
+	subject  string
+	notAfter time.Time
+	bindings int
+}
+
+// environmentScrape is the result of the last inventory refresh of an environment
+type environmentScrape struct {
+	success      bool
+	duration     time.Duration
+	lastSuccess  time.Time
+	parseErrors  int
+	certificates []exportedCertificate
+}
+
+// bindingCounts caches the number of bindings per certkey of an environment, the bindings are
+// queried with one call per certkey and change less often than the inventory
+type bindingCounts struct {
+	fetched time.Time
+	counts  map[string]int
+}
+
+// exporter periodically refreshes the certificate inventory and serves it as Prometheus metrics
+type exporter struct {
+	c          *cli
+	envConfigs envConfig
+	clients    map[string]*netscaler.Client
+	// bindingsInterval is the minimum time between two queries of the bindings of a certkey
+	bindingsInterval time.Duration
+	bindings         map[string]*bindingCounts
+
+	mu      sync.RWMutex
+	scrapes map[string]*environmentScrape
+}
+
+func newExporter(c *cli, envConfigs envConfig, bindingsInterval time.Duration) *exporter {
+	return &exporter{
+		c:                c,
+		envConfigs:       envConfigs,
+		clients:          make(map[string]*netscaler.Client),
+		bindingsInterval: bindingsInterval,
+		bindings:         make(map[string]*bindingCounts),
+		scrapes:          make(map[string]*environmentScrape),
+	}
+}
+
+// close ends the sessions of the clients opened by refresh
+func (e *exporter) close() {
+	e.c.closeEnvironments(e.clients)
+	e.clients = make(map[string]*netscaler.Client)
+}
+
+// refresh queries every environment once and replaces the cached scrape results
+func (e *exporter) refresh() {
+	for env, cfg := range e.envConfigs {
+		start := time.Now()
+		certs, parseErrors, err := e.scrape(env, cfg)
+		if err != nil {
+			e.c.logger.Warn("Failed to refresh inventory", "environment", env, "error", err)
+		}
+
+		e.mu.Lock()
+		s, ok := e.scrapes[env]
+		if !ok {
+			s = &environmentScrape{}
+			e.scrapes[env] = s
+		}
+		s.duration = time.Since(start)
+		s.success = err == nil
+		if err == nil {
+			s.lastSuccess = time.Now()
+			s.parseErrors = parseErrors
+			s.certificates = certs
+		}
+		e.mu.Unlock()
+	}
+}
+
+// scrape returns the certificates of the environment and the number of certificates that could not be parsed
+func (e *exporter) scrape(env string, cfg netscaler.Config) ([]exportedCertificate, int, error) {
+	client, ok := e.clients[env]
+	if !ok {
+		var err error
+		client, err = e.c.newClient(cfg)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.clients[env] = client
+	}
+
+	all, err := client.GetAllCertificates()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// all bindings are queried again once the interval passed, new certkeys right away
+	cache, ok := e.bindings[env]
+	if !ok || time.Since(cache.fetched) >= e.bindingsInterval {
+		cache = &bindingCounts{fetched: time.Now(), counts: make(map[string]int)}
+	}
+
+	certs := make([]exportedCertificate, 0, len(all))
+	parseErrors := 0
+	for _, raw := range all {
+		cert, err := netscaler.ParseCertificate(raw)
+		if err != nil {
+			e.c.logger.Warn("Skipping unparsable certificate", "environment", env, "certkey", raw["certkey"], "error", err)
+			parseErrors++
+			continue
+		}
+		count, ok := cache.counts[cert.Certkey]
+		if !ok {
+			bindings, err := client.GetCertificateBindings(cert.Certkey)
+			if err != nil {
+				return nil, 0, err
+			}
+			count = len(bindings)
+			cache.counts[cert.Certkey] = count
+		}
+		certs = append(certs, exportedCertificate{
+			certkey:  cert.Certkey,
+			subject:  cert.Subject,
+			notAfter: cert.NotAfter,
+			bindings: count,
+		})
+	}
+	e.bindings[env] = cache
+
+	return certs, parseErrors, nil
+}
+
+// ServeHTTP renders the cached scrape results in the Prometheus text exposition format
+func (e *exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, e.scrapes)
+}
+
+// writeMetrics writes all metric families for the given scrape results
+func writeMetrics(w io.Writer, scrapes map[string]*environmentScrape) {
+	envs := sortedKeys(scrapes)
+
+	writeMetricHeader(w, "netscaler_certificate_expiry_timestamp_seconds", "Expiry of the certificate as unix timestamp.")
+	for _, env := range envs {
+		for _, cert := range scrapes[env].certificates {
+			if cert.notAfter.IsZero() {
+				continue
+			}
+			writeMetric(w, "netscaler_certificate_expiry_timestamp_seconds",
+				[]string{"environment", env, "certkey", cert.certkey, "subject", cert.subject}, float64(cert.notAfter.Unix()))
+		}
+	}
+
+	writeMetricHeader(w, "netscaler_certificate_bindings", "Number of vservers, services and service groups the certificate is bound to.")
+	for _, env := range envs {
+		for _, cert := range scrapes[env].certificates {
+			writeMetric(w, "netscaler_certificate_bindings",
+				[]string{"environment", env, "certkey", cert.certkey}, float64(cert.bindings))
+		}
+	}
+
+	writeMetricHeader(w, "netscaler_certificate_parse_errors", "Number of certificates skipped in the last inventory refresh because they could not be parsed.")
+	for _, env := range envs {
+		writeMetric(w, "netscaler_certificate_parse_errors", []string{"environment", env}, float64(scrapes[env].parseErrors))
+	}
+
+	writeMetricHeader(w, "netscaler_scrape_success", "Whether the last inventory refresh of the environment succeeded.")
+	for _, env := range envs {
+		success := 0.0
+		if scrapes[env].success {
+			success = 1
+		}
+		writeMetric(w, "netscaler_scrape_success", []string{"environment", env}, success)
+	}
+
+	writeMetricHeader(w, "netscaler_scrape_duration_seconds", "Duration of the last inventory refresh of the environment.")
+	for _, env := range envs {
+		writeMetric(w, "netscaler_scrape_duration_seconds", []string{"environment", env}, scrapes[env].duration.Seconds())
+	}
+
+	writeMetricHeader(w, "netscaler_scrape_last_success_timestamp_seconds", "Time of the last successful inventory refresh as unix timestamp.")
+	for _, env := range envs {
+		if !scrapes[env].lastSuccess.IsZero() {
+			writeMetric(w, "netscaler_scrape_last_success_timestamp_seconds",
+				[]string{"environment", env}, float64(scrapes[env].lastSuccess.Unix()))
+		}
+	}
+}
+
+func writeMetricHeader(w io.Writer, name, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// writeMetric writes a single sample, labels are given as alternating names and values
+func writeMetric(w io.Writer, name string, labels []string, value float64) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelValueReplacer.Replace(labels[i+1])))
+	}
+	_, _ = fmt.Fprintf(w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+// labelValueReplacer escapes label values for the text exposition format
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func runExporter(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("exporter", opts)
+	listen := fs.String("listen", "127.0.0.1:9810", "Address to serve /metrics on")
+	interval := fs.Duration("interval", 5*time.Minute, "Inventory refresh interval")
+	bindingsInterval := fs.Duration("bindings-interval", time.Hour, "Refresh interval of the binding counts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 || *bindingsInterval <= 0 {
+		return fmt.Errorf("-interval and -bindings-interval must be positive")
+	}
+
+	envConfigs, err := c.loadEnvironments(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	e := newExporter(c, envConfigs, *bindingsInterval)
+	e.refresh()
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.refresh()
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintln(w, `<html><body><a href="/metrics">Metrics</a></body></html>`)
+	})
+
+	srv := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	_, _ = fmt.Fprintf(c.stderr, "Serving metrics on http://%s/metrics\n", *listen)
+	err = srv.ListenAndServe()
+
+	// The sessions are closed once no refresh uses them anymore
+	stop()
+	<-refreshed
+	e.close()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
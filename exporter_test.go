@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	scrapes := map[string]*environmentScrape{
+		"prod": {
+			success:     true,
+			duration:    1500 * time.Millisecond,
+			lastSuccess: time.Unix(1700000000, 0),
+			certificates: []exportedCertificate{
+				{
+					certkey:  "prod-example.com",
+					subject:  `CN=example.com, O="Example"`,
+					notAfter: time.Unix(1750000000, 0),
+					bindings: 2,
+				},
+			},
+		},
+		"dev": {
+			success: false,
+		},
+	}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, scrapes)
+	out := buf.String()
+
+	want := []string{
+		"# TYPE netscaler_certificate_expiry_timestamp_seconds gauge",
+		`netscaler_certificate_expiry_timestamp_seconds{environment="prod",certkey="prod-example.com",subject="CN=example.com, O=\"Example\""} 1.75e+09`,
+		`netscaler_certificate_bindings{environment="prod",certkey="prod-example.com"} 2`,
+		`netscaler_scrape_success{environment="dev"} 0`,
+		`netscaler_scrape_success{environment="prod"} 1`,
+		`netscaler_scrape_duration_seconds{environment="prod"} 1.5`,
+		`netscaler_scrape_last_success_timestamp_seconds{environment="prod"} 1.7e+09`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("writeMetrics() output does not contain %q:\n%s", w, out)
+		}
+	}
+	if strings.Contains(out, `netscaler_scrape_last_success_timestamp_seconds{environment="dev"}`) {
+		t.Error("writeMetrics() should not report a last success for environments that never succeeded")
+	}
+}
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	c, _, _ := newTestCLI()
+	e := newExporter(c, envConfig{}, time.Hour)
+	e.scrapes["prod"] = &environmentScrape{success: true}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("ServeHTTP() Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `netscaler_scrape_success{environment="prod"} 1`) {
+		t.Errorf("ServeHTTP() body = %q", rec.Body.String())
+	}
+}
+
+func TestExporter_Refresh(t *testing.T) {
+	prod := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-broken.example.com", "daystoexpiration": "soon"})
+	_ = prod.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+
+	c, _, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+	e := newExporter(c, envConfig{
+		"prod": netscaler.Config{Prefix: "prod-"},
+		"dev":  netscaler.Config{Prefix: "dev-"},
+	}, time.Hour)
+
+	e.refresh()
+	e.refresh()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	want := []string{
+		`netscaler_certificate_expiry_timestamp_seconds{environment="prod",certkey="prod-example.com",subject="CN=example.com"} 1.8038592e+09`,
+		`netscaler_certificate_bindings{environment="prod",certkey="prod-example.com"} 1`,
+		`netscaler_certificate_parse_errors{environment="prod"} 1`,
+		`netscaler_scrape_success{environment="dev"} 0`,
+		`netscaler_scrape_success{environment="prod"} 1`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("ServeHTTP() output does not contain %q:\n%s", w, out)
+		}
+	}
+	if strings.Contains(out, "prod-broken.example.com") {
+		t.Error("ServeHTTP() should skip the unparsable certificate")
+	}
+
+	bindingCalls := 0
+	for _, call := range prod.Calls() {
+		if call.ResourceType == "sslcertkey_binding" {
+			bindingCalls++
+		}
+	}
+	if bindingCalls != 1 {
+		t.Errorf("refresh() queried the bindings %d times, want 1 within the bindings interval", bindingCalls)
+	}
+
+	e.close()
+	if prod.IsLoggedIn() {
+		t.Error("close() should log out of the environments")
+	}
+}
+
+func TestRunExporter_Intervals(t *testing.T) {
+	for _, args := range [][]string{{"-interval", "0"}, {"-bindings-interval", "-1m"}} {
+		c, _, _ := newTestCLI()
+		err := runExporter(c, append([]string{"-config", writeTestConfig(t, testConfigYAML)}, args...))
+		if err == nil || !strings.Contains(err.Error(), "must be positive") {
+			t.Errorf("runExporter(%v) error = %v, want the interval rejected", args, err)
+		}
+	}
+}
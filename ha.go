@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// haNodeRows lists the nodes of an HA pair
+type haNodeRows []netscaler.HANode
+
+func (r haNodeRows) Header() []string {
+	return []string{"ID", "NAME", "IP ADDRESS", "STATE", "SYNC", "SYNC STATE"}
+}
+
+func (r haNodeRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, node := range r {
+		rows = append(rows, []string{fmt.Sprint(node.ID), node.Name, node.IPAddress, node.State, node.Sync, node.SyncState})
+	}
+	return rows
+}
+
+// afterWrite saves the configuration and checks the HA pair after a command changed the appliance
+// of an environment. files lists the files in the FileLocation of the client the secondary needs.
+// Problems of the HA pair are printed as warnings since the change itself succeeded.
+func (c *cli) afterWrite(env string, client *netscaler.Client, files []string) error {
+	cfg := c.envConfigs[env]
+	if cfg.SaveConfig {
+		if err := client.SaveConfig(); err != nil {
+			return fmt.Errorf("environment %s: %w", env, err)
+		}
+	}
+
+	status, err := c.haStatus(env, client, files, cfg.HASyncFiles)
+	if err != nil {
+		_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: failed to check the HA pair: %v\n", env, err)
+		return nil
+	}
+	for _, problem := range status.Problems {
+		_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: %s, a failover could bring back old certificates\n", env, problem)
+	}
+	return nil
+}
+
+// planAfterWrite plans the save and the file synchronization afterWrite executes after a command
+// changed the appliance of an environment
+func (c *cli) planAfterWrite(env string, client *netscaler.Client) ([]netscaler.PlanStep, error) {
+	cfg := c.envConfigs[env]
+	planned, planner := client.Planned()
+	if cfg.SaveConfig {
+		if err := planned.SaveConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.HASyncFiles {
+		if err := planSync(client, planned); err != nil {
+			return nil, err
+		}
+	}
+	return planner.Plan(env).Steps, nil
+}
+
+// planSync plans the synchronization of the files to the secondary with a client returned by
+// client.Planned, a standalone appliance has no secondary
+func planSync(client, planned *netscaler.Client) error {
+	nodes, err := client.HANodes()
+	if err != nil {
+		return err
+	}
+	if (&netscaler.HAStatus{Nodes: nodes}).Peer() == nil {
+		return nil
+	}
+	return planned.SyncFiles()
+}
+
+// haStatus checks the HA pair of an environment. With sync the files are synchronized to the
+// secondary first. The files are looked up on the secondary.
+func (c *cli) haStatus(env string, client *netscaler.Client, files []string, sync bool) (*netscaler.HAStatus, error) {
+	nodes, err := client.HANodes()
+	if err != nil {
+		return nil, err
+	}
+	peer := (&netscaler.HAStatus{Nodes: nodes}).Peer()
+	if peer == nil {
+		return &netscaler.HAStatus{Nodes: nodes}, nil
+	}
+
+	if sync {
+		if err := client.SyncFiles(); err != nil {
+			return nil, err
+		}
+	}
+
+	var secondary *netscaler.Client
+	var unreachable error
+	if len(files) > 0 {
+		if secondary, unreachable = c.openSecondary(c.envConfigs[env], peer); secondary != nil {
+			defer c.closeEnvironments(map[string]*netscaler.Client{env + " secondary": secondary})
+		}
+	}
+	status, err := client.HAStatus(nodes, secondary, files)
+	if err != nil {
+		return nil, err
+	}
+	if unreachable != nil {
+		status.Problems = append(status.Problems, fmt.Sprintf("the files on the secondary can't be checked: %v", unreachable))
+	}
+	return status, nil
+}
+
+// openSecondary connects to the secondary node with the credentials of the environment, at
+// haPeerEndpoint or its NSIP with the scheme and port of the endpoint. Environments proxied
+// through ADM reach the secondary through ADM as well.
+func (c *cli) openSecondary(cfg netscaler.Config, peer *netscaler.HANode) (*netscaler.Client, error) {
+	if cfg.ADM() {
+		cfg.ManagedInstance, cfg.Record = peer.IPAddress, ""
+		secondary, err := c.newClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("secondary %s: %w", peer.IPAddress, err)
+		}
+		return secondary, nil
+	}
+
+	endpoint := cfg.HAPeerEndpoint
+	if endpoint == "" {
+		var err error
+		if endpoint, err = netscaler.NodeEndpoint(cfg.Endpoint, peer.IPAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	clientConfig := cfg.ClientConfig()
+	clientConfig.Endpoint = endpoint
+	clientConfig.Record = ""
+	secondary, err := c.clientFactory(cfg.Prefix, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("secondary %s: %w", endpoint, err)
+	}
+	return secondary, nil
+}
+
+// runHA reports the nodes of the HA pair of an environment and whether the secondary has the
+// certificate and key files of all certkeys of the environment
+func runHA(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("ha", opts)
+	sync := fs.Bool("sync", false, "Synchronize the certificate and key files to the secondary before checking it")
+	dryRun := fs.Bool("dry-run", false, "With -sync, print the planned NITRO operations instead of executing them, see apply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	if *dryRun && *sync {
+		planned, planner := client.Planned()
+		if err := planSync(client, planned); err != nil {
+			return fmt.Errorf("environment %s: %w", env, err)
+		}
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+
+	certs, err := client.GetAllCertificates()
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	certkeys := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		if name, ok := cert["certkey"].(string); ok {
+			certkeys = append(certkeys, name)
+		}
+	}
+	files, err := client.CertkeyFiles(certkeys...)
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+
+	status, err := c.haStatus(env, client, files, *sync)
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	if status.Peer() == nil {
+		_, _ = fmt.Fprintf(c.stderr, "Environment %s is a standalone appliance\n", env)
+	}
+	if err := render(c.stdout, opts.format, haNodeRows(status.Nodes)); err != nil {
+		return err
+	}
+	for _, problem := range status.Problems {
+		_, _ = fmt.Fprintf(c.stderr, "Environment %s: %s\n", env, problem)
+	}
+	if len(status.Problems) > 0 {
+		return fmt.Errorf("a failover of environment %s could bring back old certificates", env)
+	}
+	return nil
+}
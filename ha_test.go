@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+const testHAConfigYAML = `
+environments:
+  prod:
+    endpoint: https://netscaler-prod.example.com:8443
+    username: admin
+    password: secret
+    prefix: prod-
+    saveConfig: %t
+    haSyncFiles: %t
+`
+
+// endpointClientFactory creates clients backed by the in-memory NITRO client registered for the endpoint
+func endpointClientFactory(apis map[string]*netscalertest.NitroClient) func(string, *netscaler.ClientConfig) (*netscaler.Client, error) {
+	return func(prefix string, config *netscaler.ClientConfig) (*netscaler.Client, error) {
+		api, ok := apis[config.Endpoint]
+		if !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return netscaler.NewClientFromNitro(prefix, api)
+	}
+}
+
+// newHAPair returns the primary and secondary of an HA pair, keyed by their endpoint
+func newHAPair(t *testing.T) (*netscalertest.NitroClient, *netscalertest.NitroClient, map[string]*netscalertest.NitroClient) {
+	t.Helper()
+	primary, secondary := netscalertest.NewNitroClient(), netscalertest.NewNitroClient()
+	primary.Store.Pair(secondary.Store)
+	if err := primary.Store.Update("hanode", "0", map[string]any{"name": "ns-a", "ipaddress": "192.0.2.1", "state": "Primary"}); err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+	if err := primary.Store.Add("hanode", map[string]any{"id": 1, "name": "ns-b", "ipaddress": "192.0.2.2", "state": "Secondary", "hasync": "ENABLED", "syncstate": "SUCCESS"}); err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+	return primary, secondary, map[string]*netscalertest.NitroClient{
+		"https://netscaler-prod.example.com:8443": primary,
+		"https://192.0.2.2:8443":                  secondary,
+	}
+}
+
+func TestRunCSR_AfterWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		save        bool
+		sync        bool
+		wantSaves   int
+		wantWarning string
+		description string
+	}{
+		{
+			name:        "save and sync",
+			save:        true,
+			sync:        true,
+			wantSaves:   1,
+			description: "the configuration should be saved and the files synchronized to the secondary",
+		},
+		{
+			name:        "unsynchronized",
+			wantWarning: "the secondary is missing prod-example.com.key, prod-example.com.csr, a failover could bring back old certificates",
+			description: "files missing on the secondary should be reported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary, apis := newHAPair(t)
+			c, _, stderr := newTestCLI()
+			c.clientFactory = endpointClientFactory(apis)
+			config := writeTestConfig(t, fmt.Sprintf(testHAConfigYAML, tt.save, tt.sync))
+
+			if err := runCSR(c, []string{"-config", config, "-env", "prod", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com"}); err != nil {
+				t.Fatalf("runCSR() error = %v", err)
+			}
+			if primary.Store.Saves() != tt.wantSaves {
+				t.Errorf("Saves() = %d, want %d", primary.Store.Saves(), tt.wantSaves)
+			}
+			_, err := secondary.Store.ReadFile("prod-example.com.key")
+			if synced := err == nil; synced != tt.sync {
+				t.Errorf("key on the secondary = %v, want %v", synced, tt.sync)
+			}
+			if tt.wantWarning == "" && strings.Contains(stderr.String(), "Warning") || !strings.Contains(stderr.String(), tt.wantWarning) {
+				t.Errorf("runCSR() stderr = %q, want %q", stderr.String(), tt.wantWarning)
+			}
+
+			// The nodes are checked as read once, a second read could see them after a failover
+			hanodeCalls := 0
+			for _, call := range primary.Calls() {
+				if call.ResourceType == "hanode" {
+					hanodeCalls++
+				}
+			}
+			if hanodeCalls != 1 {
+				t.Errorf("runCSR() queried hanode %d times, want 1", hanodeCalls)
+			}
+		})
+	}
+}
+
+func TestRunHA(t *testing.T) {
+	primary, secondary, apis := newHAPair(t)
+	for _, file := range []string{"prod-example.com.crt", "prod-example.com.key"} {
+		if err := primary.Store.WriteFile(file, []byte("test")); err != nil {
+			t.Fatalf("Store.WriteFile() error = %v", err)
+		}
+	}
+	if err := primary.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "cert": "prod-example.com.crt", "key": "prod-example.com.key"}); err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+	config := writeTestConfig(t, fmt.Sprintf(testHAConfigYAML, false, false))
+
+	c, stdout, stderr := newTestCLI()
+	c.clientFactory = endpointClientFactory(apis)
+	err := runHA(c, []string{"-config", config, "-env", "prod"})
+	if err == nil || !strings.Contains(err.Error(), "could bring back old certificates") {
+		t.Errorf("runHA() with missing files error = %v", err)
+	}
+	if !strings.Contains(stderr.String(), "the secondary is missing prod-example.com.crt, prod-example.com.key") {
+		t.Errorf("runHA() stderr = %q", stderr.String())
+	}
+	for _, want := range []string{"IP ADDRESS", "ns-a", "192.0.2.2", "Secondary", "SUCCESS"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("runHA() output should contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = endpointClientFactory(apis)
+	if err := runHA(c, []string{"-config", config, "-env", "prod", "-sync", "-dry-run"}); err != nil {
+		t.Errorf("runHA() -sync -dry-run error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "hafiles") {
+		t.Errorf("runHA() -sync -dry-run output should contain the planned synchronization, got:\n%s", stdout.String())
+	}
+	if _, err := secondary.Store.ReadFile("prod-example.com.key"); err == nil {
+		t.Error("runHA() -sync -dry-run should not synchronize the files")
+	}
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = endpointClientFactory(apis)
+	if err := runHA(c, []string{"-config", config, "-env", "prod", "-sync"}); err != nil {
+		t.Errorf("runHA() -sync error = %v", err)
+	}
+
+	// The secondary can't be reached at its NSIP
+	delete(apis, "https://192.0.2.2:8443")
+	c, _, stderr = newTestCLI()
+	c.clientFactory = endpointClientFactory(apis)
+	if err := runHA(c, []string{"-config", config, "-env", "prod"}); err == nil || !strings.Contains(stderr.String(), "can't be checked") {
+		t.Errorf("runHA() with unreachable secondary error = %v, stderr = %q", err, stderr.String())
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// hostnameRow is a hostname routed to an SSL vserver of an environment
+type hostnameRow struct {
+	Environment string   `json:"environment"`
+	VIP         string   `json:"vip"`
+	Address     string   `json:"address,omitempty"`
+	Hostname    string   `json:"hostname"`
+	Covered     bool     `json:"covered"`
+	Certkeys    []string `json:"certkeys"`
+}
+
+type hostnameRows []hostnameRow
+
+func (r hostnameRows) Header() []string {
+	return []string{"ENVIRONMENT", "VIP", "ADDRESS", "HOSTNAME", "STATUS", "CERTKEYS"}
+}
+
+func (r hostnameRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, h := range r {
+		status := "uncovered"
+		if h.Covered {
+			status = "covered"
+		}
+		rows = append(rows, []string{h.Environment, h.VIP, h.Address, h.Hostname, status, strings.Join(h.Certkeys, ",")})
+	}
+	return rows
+}
+
+func runHostnames(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("hostnames", opts)
+	all := fs.Bool("all", false, "Also list hostnames covered by a bound certificate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	rows := hostnameRows{}
+	for _, env := range sortedKeys(clients) {
+		vips, err := clients[env].DiscoverHostnames()
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		for _, vip := range vips {
+			for _, problem := range vip.Errors {
+				_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: %s: %s, its hostnames are reported as uncovered\n", env, vip.Name, problem)
+			}
+			for _, host := range vip.Hostnames {
+				covered := !slices.Contains(vip.Uncovered, host)
+				if covered && !*all {
+					continue
+				}
+				rows = append(rows, hostnameRow{
+					Environment: env,
+					VIP:         vip.Name,
+					Address:     vip.Address,
+					Hostname:    host,
+					Covered:     covered,
+					Certkeys:    vip.Certkeys,
+				})
+			}
+		}
+	}
+	c.warnFailures(failures)
+
+	return render(c.stdout, opts.format, rows)
+}
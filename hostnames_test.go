@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newContentSwitchingClient returns an appliance serving www.example.com and shop.example.com on cs-web,
+// with a certificate bound for www.example.com only
+func newContentSwitchingClient(t *testing.T) *netscalertest.NitroClient {
+	t.Helper()
+	api := netscalertest.NewNitroClient()
+	for _, r := range []struct {
+		resourceType string
+		attrs        map[string]any
+	}{
+		{"csvserver", map[string]any{"name": "cs-web", "servicetype": "SSL", "ipv46": "10.0.0.1", "port": 443}},
+		{"sslvserver", map[string]any{"vservername": "cs-web"}},
+		{"cspolicy", map[string]any{"policyname": "pol-www", "rule": `HTTP.REQ.HOSTNAME.EQ("www.example.com")`}},
+		{"cspolicy", map[string]any{"policyname": "pol-shop", "rule": `HTTP.REQ.HOSTNAME.EQ("shop.example.com")`}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-www"}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-shop"}},
+		{"sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com", "sandns": "example.com,www.example.com"}},
+		{"sslvserver_sslcertkey_binding", map[string]any{"vservername": "cs-web", "certkeyname": "prod-example.com"}},
+	} {
+		if err := api.Store.Add(r.resourceType, r.attrs); err != nil {
+			t.Fatalf("Failed to seed %s: %v", r.resourceType, err)
+		}
+	}
+	return api
+}
+
+func TestRunHostnames(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := newContentSwitchingClient(t)
+
+	tests := []struct {
+		name        string
+		args        []string
+		want        map[string]bool
+		description string
+	}{
+		{
+			name:        "uncovered",
+			args:        []string{"-config", config, "-env", "prod", "-format", "json"},
+			want:        map[string]bool{"shop.example.com": false},
+			description: "should only list hostnames without a certificate",
+		},
+		{
+			name:        "all",
+			args:        []string{"-config", config, "-env", "prod", "-format", "json", "-all"},
+			want:        map[string]bool{"shop.example.com": false, "www.example.com": true},
+			description: "should list every hostname with -all",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+
+			if err := runHostnames(c, tt.args); err != nil {
+				t.Fatalf("runHostnames() error = %v", err)
+			}
+
+			var rows []hostnameRow
+			if err := json.Unmarshal(stdout.Bytes(), &rows); err != nil {
+				t.Fatalf("runHostnames() wrote invalid JSON: %v", err)
+			}
+			got := make(map[string]bool)
+			for _, row := range rows {
+				if row.VIP != "cs-web" || row.Address != "10.0.0.1:443" {
+					t.Errorf("runHostnames() row = %+v", row)
+				}
+				got[row.Hostname] = row.Covered
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("runHostnames() = %v, want %v", got, tt.want)
+			}
+			for host, covered := range tt.want {
+				if c, ok := got[host]; !ok || c != covered {
+					t.Errorf("runHostnames() %s covered = %v, want %v", host, c, covered)
+				}
+			}
+		})
+	}
+}
+
+func TestNetscalerPlugin_GetMetadataUncoveredHostnames(t *testing.T) {
+	api := newContentSwitchingClient(t)
+	client, err := netscaler.NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	plugin := &NetscalerPlugin{
+		logger:  hclog.NewNullLogger(),
+		config:  proto.NewPluginConfig(),
+		clients: map[string]netscaler.CertificateSource{"prod": client},
+		configs: map[string]*netscaler.Config{"prod": {Prefix: "prod-", DiscoverHostnames: true}},
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+			DomainEntry: &proto.DomainEntry{Domain: "example.com", AlternativeNames: []string{"www.example.com", "shop.example.com"}},
+		})
+		if err != nil {
+			t.Fatalf("GetMetadata() error = %v", err)
+		}
+
+		got := resp.Metadata["uncoveredHostnames"].GetStructValue().AsMap()
+		hosts, _ := got["prod"].(map[string]any)["cs-web"].([]any)
+		if len(hosts) != 1 || hosts[0] != "shop.example.com" {
+			t.Errorf("GetMetadata() uncoveredHostnames = %v, want shop.example.com on cs-web", got)
+		}
+	}
+
+	discoveries := 0
+	for _, call := range api.Calls() {
+		if call.ResourceType == "csvserver" {
+			discoveries++
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("DiscoverHostnames() ran %d times, want 1 (cached)", discoveries)
+	}
+
+	// Without discoverHostnames the metadata stays unchanged
+	plugin.configs["prod"].DiscoverHostnames = false
+	resp, _ := plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+		DomainEntry: &proto.DomainEntry{Domain: "shop.example.com"},
+	})
+	if _, ok := resp.Metadata["uncoveredHostnames"]; ok {
+		t.Error("GetMetadata() should only discover hostnames when enabled")
+	}
+}
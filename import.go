@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// formatDomains writes domain entries in the dehydrated domains.txt format
+const formatDomains = "domains"
+
+// domainEntryRows are the domain entries generated by import
+type domainEntryRows []netscaler.DomainEntry
+
+func (r domainEntryRows) Header() []string {
+	return []string{"DOMAIN", "ALTERNATIVE NAMES", "ALIAS"}
+}
+
+func (r domainEntryRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, e := range r {
+		rows = append(rows, []string{e.Domain, strings.Join(e.AlternativeNames, " "), e.Alias})
+	}
+	return rows
+}
+
+// managedNames returns the lookup names and domains of the entries in a JSON or domains.txt file
+func managedNames(path string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	if path == "" {
+		return names, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open managed domains: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := netscaler.ReadDomainEntries(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, e := range entries {
+		names[strings.ToLower(e.Name())] = true
+		names[strings.ToLower(e.Domain)] = true
+	}
+	return names, nil
+}
+
+// domainEntries converts the certificates of an environment into domain entries.
+// Certificates linked by others are CA certificates and are skipped, as are certificates
+// without a hostname and those whose domain or name is already managed.
+func (c *cli) domainEntries(env string, client *netscaler.Client, managed map[string]bool) ([]netscaler.DomainEntry, int, error) {
+	raw, err := client.GetAllCertificates()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	certs := make([]*netscaler.Certificate, 0, len(raw))
+	linked := make(map[string]bool)
+	for _, r := range raw {
+		cert, err := netscaler.ParseCertificate(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		certs = append(certs, cert)
+		if cert.LinkCertkey != "" {
+			linked[cert.LinkCertkey] = true
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool { return certs[i].Certkey < certs[j].Certkey })
+
+	entries := []netscaler.DomainEntry{}
+	skipped := 0
+	for _, cert := range certs {
+		if linked[cert.Certkey] {
+			continue
+		}
+		entry, err := netscaler.NewDomainEntry(cert, client.Prefix())
+		if err != nil {
+			_, _ = fmt.Fprintf(c.stderr, "Warning: environment %s: %v\n", env, err)
+			continue
+		}
+		if managed[strings.ToLower(entry.Name())] || managed[entry.Domain] {
+			skipped++
+			continue
+		}
+		managed[strings.ToLower(entry.Name())] = true
+		entries = append(entries, *entry)
+	}
+	return entries, skipped, nil
+}
+
+func runImport(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("import", opts)
+	format := fs.Lookup("format")
+	format.DefValue = formatJSON
+	format.Usage = "Output format: json (dehydrated-api domain entries), domains (domains.txt), table, yaml or csv"
+	_ = format.Value.Set(formatJSON)
+	managedPath := fs.String("managed", "", "JSON or domains.txt file with the domains already managed by dehydrated")
+	output := fs.String("output", "", "Write the domain entries to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	managed, err := managedNames(*managedPath)
+	if err != nil {
+		return err
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	entries, skipped, err := c.domainEntries(env, client, managed)
+	if err != nil {
+		return fmt.Errorf("failed to import environment %s: %w", env, err)
+	}
+
+	var w io.Writer = c.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if opts.format == formatDomains {
+		err = netscaler.WriteDomainsTxt(w, entries)
+	} else {
+		err = render(w, opts.format, domainEntryRows(entries))
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(c.stderr, "Imported %d domain(s), skipped %d already managed\n", len(entries), skipped)
+	return nil
+}
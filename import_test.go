@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestRunImport(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	for _, cert := range []map[string]any{
+		{"certkey": "prod-example.com", "subject": "C=DE, CN=example.com", "sandns": "example.com, www.example.com", "linkcertkeyname": "prod-ca"},
+		{"certkey": "prod-wildcard", "subject": "CN=*.api.example.com", "linkcertkeyname": "prod-ca"},
+		{"certkey": "prod-managed.example.com", "subject": "CN=managed.example.com"},
+		{"certkey": "prod-ca", "subject": "CN=Example Intermediate CA"},
+		{"certkey": "prod-internal", "subject": "CN=localhost"},
+		{"certkey": "other.example.com", "subject": "CN=other.example.com"},
+	} {
+		_ = prod.Store.Add("sslcertkey", cert)
+	}
+
+	managed := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(managed, []byte("# managed by dehydrated\nmanaged.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		want        string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "domains.txt",
+			args:        []string{"-config", config, "-env", "prod", "-format", "domains", "-managed", managed},
+			want:        "example.com www.example.com\n*.api.example.com > wildcard\n",
+			description: "should skip managed, CA and non-hostname certificates and certkeys without prefix",
+		},
+		{
+			name:        "without managed domains",
+			args:        []string{"-config", config, "-env", "prod", "-format", "domains"},
+			want:        "example.com www.example.com\nmanaged.example.com\n*.api.example.com > wildcard\n",
+			description: "should import every certificate when no managed domains are given",
+		},
+		{
+			name:        "multiple environments",
+			args:        []string{"-config", config},
+			wantErr:     true,
+			description: "should require exactly one environment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+
+			err := runImport(c, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runImport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && stdout.String() != tt.want {
+				t.Errorf("runImport() = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunImport_JSON(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com", "sandns": "DNS:www.example.com"})
+
+	c, stdout, stderr := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+	if err := runImport(c, []string{"-config", config, "-env", "prod"}); err != nil {
+		t.Fatalf("runImport() error = %v", err)
+	}
+
+	var got []netscaler.DomainEntry
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("runImport() wrote invalid JSON: %v", err)
+	}
+	want := []netscaler.DomainEntry{{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Enabled: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runImport() = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(stderr.String(), "Imported 1 domain(s)") {
+		t.Errorf("runImport() should print a summary, got %q", stderr.String())
+	}
+}
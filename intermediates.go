@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// intermediateRows lists the certkeys of duplicate intermediates, one row per certkey
+type intermediateRows []netscaler.IntermediateGroup
+
+func (r intermediateRows) Header() []string {
+	return []string{"FINGERPRINT", "SUBJECT", "CERTKEY", "LINKED BY", "KEEP"}
+}
+
+func (r intermediateRows) Rows() [][]string {
+	var rows [][]string
+	for _, group := range r {
+		for _, in := range group.Certkeys {
+			keep := ""
+			if in.Certkey == group.Keep {
+				keep = "yes"
+			}
+			rows = append(rows, []string{group.Fingerprint[:16], group.Subject, in.Certkey, strings.Join(in.LinkedBy, ","), keep})
+		}
+	}
+	return rows
+}
+
+// relinkRows lists the certkeys whose link was moved to the kept intermediate
+type relinkRows []netscaler.Relink
+
+func (r relinkRows) Header() []string {
+	return []string{"CERTKEY", "FROM", "TO"}
+}
+
+func (r relinkRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, relink := range r {
+		rows = append(rows, []string{relink.Certkey, relink.From, relink.To})
+	}
+	return rows
+}
+
+// runIntermediates reports certkeys holding the same CA certificate and, with -consolidate, links
+// the certkeys of the environment to one of them
+func runIntermediates(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("intermediates", opts)
+	consolidate := fs.Bool("consolidate", false, "Link the certkeys of the environment to the kept intermediate of each group")
+	dryRun := fs.Bool("dry-run", false, "With -consolidate, print the planned NITRO operations instead of executing them, see apply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	groups, err := client.DuplicateIntermediates()
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	if !*consolidate {
+		return render(c.stdout, opts.format, intermediateRows(groups))
+	}
+	if len(groups) == 0 {
+		_, _ = fmt.Fprintf(c.stderr, "No duplicate intermediates in environment %s\n", env)
+		return nil
+	}
+
+	if *dryRun {
+		planned, planner := client.Planned()
+		if _, err := planned.ConsolidateIntermediates(groups); err != nil {
+			return err
+		}
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+
+	relinked, err := client.ConsolidateIntermediates(groups)
+	if len(relinked) > 0 {
+		if saveErr := c.afterWrite(env, client, nil); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	if renderErr := render(c.stdout, opts.format, relinkRows(relinked)); renderErr != nil && err == nil {
+		err = renderErr
+	}
+	return err
+}
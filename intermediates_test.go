@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newDuplicateIntermediatesAPI returns an appliance with the same CA certificate installed twice
+func newDuplicateIntermediatesAPI(t *testing.T) *netscalertest.NitroClient {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	api := netscalertest.NewNitroClient()
+	for _, certkey := range []map[string]any{
+		{"certkey": "prod-ca-shared", "cert": "prod-ca-shared.crt"},
+		{"certkey": "prod-example.net-ca", "cert": "prod-example.net-ca.crt"},
+		{"certkey": "prod-a.com", "cert": "prod-a.com.crt", "key": "prod-a.com.key", "linkcertkeyname": "prod-ca-shared"},
+		{"certkey": "prod-b.com", "cert": "prod-b.com.crt", "key": "prod-b.com.key", "linkcertkeyname": "prod-ca-shared"},
+		{"certkey": "prod-example.net", "cert": "prod-example.net.crt", "key": "prod-example.net.key", "linkcertkeyname": "prod-example.net-ca"},
+	} {
+		if err := api.Store.Add("sslcertkey", certkey); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	for _, file := range []string{"prod-ca-shared.crt", "prod-example.net-ca.crt"} {
+		if err := api.Store.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})); err != nil {
+			t.Fatalf("Store.WriteFile() error = %v", err)
+		}
+	}
+	return api
+}
+
+func TestRunIntermediates(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantOutput  []string
+		wantLink    string
+		description string
+	}{
+		{
+			name:        "report",
+			wantOutput:  []string{"FINGERPRINT", "CN=Test Intermediate CA", "prod-ca-shared", "prod-a.com,prod-b.com", "yes", "prod-example.net-ca"},
+			wantLink:    "prod-example.net-ca",
+			description: "duplicates should be reported without changing links",
+		},
+		{
+			name:        "dry run",
+			args:        []string{"-consolidate", "-dry-run"},
+			wantOutput:  []string{"unlink", "link", "prod-example.net", "linkcertkeyname: prod-example.net-ca -> prod-ca-shared"},
+			wantLink:    "prod-example.net-ca",
+			description: "-dry-run should print the plan of the consolidation",
+		},
+		{
+			name:        "consolidate",
+			args:        []string{"-consolidate"},
+			wantOutput:  []string{"CERTKEY", "prod-example.net", "prod-example.net-ca", "prod-ca-shared"},
+			wantLink:    "prod-ca-shared",
+			description: "-consolidate should link the certkeys to the kept intermediate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newDuplicateIntermediatesAPI(t)
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": api})
+
+			if err := runIntermediates(c, append([]string{"-config", writeTestConfig(t, testConfigYAML), "-env", "prod"}, tt.args...)); err != nil {
+				t.Fatalf("runIntermediates() error = %v", err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("runIntermediates() output should contain %q, got:\n%s", want, stdout.String())
+				}
+			}
+			if res, _ := api.Store.Get("sslcertkey", "prod-example.net"); res["linkcertkeyname"] != tt.wantLink {
+				t.Errorf("prod-example.net linked to %v, want %s", res["linkcertkeyname"], tt.wantLink)
+			}
+		})
+	}
+}
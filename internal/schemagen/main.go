@@ -0,0 +1,24 @@
+// Command schemagen writes the JSON Schema of the plugin config.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+func main() {
+	output := flag.String("o", "config.schema.json", "Output file")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(netscaler.ConfigSchema(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// certificateRow is a certificate deployed in a specific environment
+type certificateRow struct {
+	Environment string `json:"environment"`
+	*netscaler.Certificate
+}
+
+type certificateRows []certificateRow
+
+func (r certificateRows) Header() []string {
+	return []string{"ENVIRONMENT", "CERTKEY", "SUBJECT", "ISSUER", "NOT AFTER", "DAYS", "STATUS"}
+}
+
+func (r certificateRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, c := range r {
+		notAfter := ""
+		if !c.NotAfter.IsZero() {
+			notAfter = c.NotAfter.Format(time.DateOnly)
+		}
+		rows = append(rows, []string{
+			c.Environment,
+			c.Certkey,
+			c.Subject,
+			c.Issuer,
+			notAfter,
+			strconv.Itoa(c.DaysToExpiration),
+			c.Status,
+		})
+	}
+	return rows
+}
+
+// collectCertificates fetches and parses the certificates of every client, sorted by environment
+func (c *cli) collectCertificates(clients map[string]*netscaler.Client, failures map[string]error) certificateRows {
+	rows := certificateRows{}
+	for _, env := range sortedKeys(clients) {
+		certs, err := clients[env].GetAllCertificates()
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		var errs []error
+		for _, raw := range certs {
+			cert, err := netscaler.ParseCertificate(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("certificate %v: %w", raw["certkey"], err))
+				continue
+			}
+			rows = append(rows, certificateRow{Environment: env, Certificate: cert})
+		}
+		if len(errs) > 0 {
+			failures[env] = errors.Join(errs...)
+		}
+	}
+	return rows
+}
+
+func runList(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("list", opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	rows := c.collectCertificates(clients, failures)
+	c.warnFailures(failures)
+
+	return render(c.stdout, opts.format, rows)
+}
+
+func runGet(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("get", opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: get [flags] <domain>")
+	}
+	domain := fs.Arg(0)
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	rows := certificateRows{}
+	for _, env := range sortedKeys(clients) {
+		if !c.appliesTo(env, domain) {
+			continue
+		}
+		raw, err := clients[env].GetCertificate(domain)
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		cert, err := netscaler.ParseCertificate(raw)
+		if err != nil {
+			failures[env] = err
+			continue
+		}
+		rows = append(rows, certificateRow{Environment: env, Certificate: cert})
+	}
+	c.warnFailures(failures)
+
+	if len(rows) == 0 {
+		return fmt.Errorf("no certificate found for domain %s", domain)
+	}
+
+	return render(c.stdout, opts.format, rows)
+}
+
+func runExpiring(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("expiring", opts)
+	days := fs.Int("days", 30, "List certificates expiring within this number of days")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	clients, failures, err := c.openEnvironments(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(clients)
+
+	rows := certificateRows{}
+	for _, row := range c.collectCertificates(clients, failures) {
+		if row.DaysToExpiration <= *days {
+			rows = append(rows, row)
+		}
+	}
+	c.warnFailures(failures)
+
+	return render(c.stdout, opts.format, rows)
+}
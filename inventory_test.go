@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+const inventoryConfigYAML = testConfigYAML + `
+  qa:
+    endpoint: https://netscaler-qa.example.com
+    username: admin
+    password: secret
+    prefix: qa-
+`
+
+// newInventoryCLI returns a CLI with certificates in prod and dev, and an unreachable qa environment
+func newInventoryCLI(t *testing.T) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	prod := netscalertest.NewNitroClient()
+	dev := netscalertest.NewNitroClient()
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com", "issuer": "CN=ca", "daystoexpiration": float64(60), "clientcertnotafter": "Mar  1 00:00:00 2027 GMT", "status": "Valid"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-broken.example.com", "publickeysize": "large"})
+	_ = prod.Store.Add("sslcertkey", map[string]any{"certkey": "prod-odd.example.com", "daystoexpiration": "soon"})
+	_ = dev.Store.Add("sslcertkey", map[string]any{"certkey": "dev-example.com", "subject": "CN=example.com", "issuer": "CN=ca", "daystoexpiration": float64(10), "status": "Valid"})
+
+	c, stdout, stderr := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod, "dev-": dev})
+	return c, stdout, stderr
+}
+
+func TestRunInventory(t *testing.T) {
+	config := writeTestConfig(t, inventoryConfigYAML)
+
+	tests := []struct {
+		name         string
+		run          func(*cli, []string) error
+		args         []string
+		wantLines    []string
+		wantWarnings []string
+		wantErr      bool
+		description  string
+	}{
+		{
+			name: "list table",
+			run:  runList,
+			args: []string{"-config", config},
+			wantLines: []string{
+				"ENVIRONMENT  CERTKEY           SUBJECT         ISSUER  NOT AFTER   DAYS  STATUS",
+				"dev          dev-example.com   CN=example.com  CN=ca               10    Valid",
+				"prod         prod-example.com  CN=example.com  CN=ca   2027-03-01  60    Valid",
+			},
+			wantWarnings: []string{
+				"Warning: environment prod: certificate prod-broken.example.com: invalid publickeysize",
+				"certificate prod-odd.example.com: invalid daystoexpiration",
+				"Warning: environment qa: connection refused",
+			},
+			description: "should list the parsable certificates and warn about every failure",
+		},
+		{
+			name: "list csv",
+			run:  runList,
+			args: []string{"-config", config, "-format", "csv", "-env", "dev"},
+			wantLines: []string{
+				"ENVIRONMENT,CERTKEY,SUBJECT,ISSUER,NOT AFTER,DAYS,STATUS",
+				"dev,dev-example.com,CN=example.com,CN=ca,,10,Valid",
+			},
+			description: "should render the selected environment as CSV",
+		},
+		{
+			name:      "expiring",
+			run:       runExpiring,
+			args:      []string{"-config", config, "-format", "csv", "-days", "30"},
+			wantLines: []string{"ENVIRONMENT,CERTKEY,SUBJECT,ISSUER,NOT AFTER,DAYS,STATUS", "dev,dev-example.com,CN=example.com,CN=ca,,10,Valid"},
+			wantWarnings: []string{
+				"Warning: environment qa: connection refused",
+			},
+			description: "should only list certificates expiring within the days",
+		},
+		{
+			name:         "get",
+			run:          runGet,
+			args:         []string{"-config", config, "-format", "csv", "example.com"},
+			wantLines:    []string{"ENVIRONMENT,CERTKEY,SUBJECT,ISSUER,NOT AFTER,DAYS,STATUS", "dev,dev-example.com,CN=example.com,CN=ca,,10,Valid", "prod,prod-example.com,CN=example.com,CN=ca,2027-03-01,60,Valid"},
+			wantWarnings: []string{"Warning: environment qa: connection refused"},
+			description:  "should show the certificate of the domain in every environment",
+		},
+		{
+			name:         "get unparsable",
+			run:          runGet,
+			args:         []string{"-config", config, "broken.example.com"},
+			wantWarnings: []string{"Warning: environment prod: invalid publickeysize"},
+			wantErr:      true,
+			description:  "should fail when no environment has a usable certificate",
+		},
+		{
+			name:        "get without domain",
+			run:         runGet,
+			args:        []string{"-config", config},
+			wantErr:     true,
+			description: "should require the domain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, stderr := newInventoryCLI(t)
+			err := tt.run(c, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if want := strings.Join(tt.wantLines, "\n"); strings.TrimRight(stdout.String(), "\n") != want {
+				t.Errorf("output =\n%s\nwant\n%s", stdout, want)
+			}
+			for _, want := range tt.wantWarnings {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("warnings = %q, want %q", stderr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunList_JSON(t *testing.T) {
+	config := writeTestConfig(t, inventoryConfigYAML)
+	c, stdout, stderr := newInventoryCLI(t)
+
+	if err := runList(c, []string{"-config", config, "-format", "json"}); err != nil {
+		t.Fatalf("runList() error = %v", err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(got) != 2 || got[0]["environment"] != "dev" || got[1]["certkey"] != "prod-example.com" || got[1]["daysToExpiration"] != float64(60) {
+		t.Errorf("runList() = %v, want the certificates of dev and prod", got)
+	}
+	if strings.Count(stderr.String(), "Warning: environment prod:") != 1 {
+		t.Errorf("warnings = %q, want a single warning for prod listing both certificates", stderr)
+	}
+}
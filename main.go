@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
@@ -13,8 +17,16 @@ import (
 	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
 )
 
+//go:generate go run ./internal/schemagen -o config.schema.json
+
 type envConfig map[string]netscaler.Config
 
+// statusNotApplicable marks environments in the metadata that are excluded from a domain on purpose
+const statusNotApplicable = "not-applicable"
+
+// reservedEnvironments are the metadata keys GetMetadata adds next to the environments
+var reservedEnvironments = []string{"drift", "uncoveredHostnames"}
+
 var (
 	// These variables are set by GoReleaser during build
 	Version   = "dev"
@@ -27,8 +39,31 @@ type NetscalerPlugin struct {
 	proto.UnimplementedPluginServer
 	logger        hclog.Logger
 	config        *proto.PluginConfig
-	clients       map[string]*netscaler.Client
-	clientFactory func(prefix string, config *netscaler.ClientConfig) (*netscaler.Client, error)
+	clients       map[string]netscaler.CertificateSource
+	configs       map[string]*netscaler.Config
+	clientFactory func(prefix string, config *netscaler.ClientConfig) (netscaler.CertificateSource, error)
+	// adm holds the NetScaler Console sessions of the environments proxied through ADM
+	adm admSessions
+
+	// mu guards the hostnames discovered per environment and the unavailable environments
+	mu        sync.Mutex
+	hostnames map[string]discoveredHostnames
+	// unavailable holds the environments whose last health check failed
+	unavailable map[string]error
+}
+
+// hostnameCacheTTL is how long discovered hostnames are reused by GetMetadata
+const hostnameCacheTTL = 5 * time.Minute
+
+// discoveredHostnames are the VIPs of an environment and when they were read
+type discoveredHostnames struct {
+	vips []netscaler.VIP
+	at   time.Time
+}
+
+// newNetscalerSource connects to the NITRO API of an environment
+func newNetscalerSource(prefix string, config *netscaler.ClientConfig) (netscaler.CertificateSource, error) {
+	return netscaler.NewClient(prefix, config)
 }
 
 // Initialize implements the plugin.Plugin interface
@@ -41,66 +76,139 @@ func (p *NetscalerPlugin) Initialize(_ context.Context, req *proto.InitializeReq
 
 	p.logger.Debug("Initialize called")
 
-	p.clients = make(map[string]*netscaler.Client)
+	p.clients = make(map[string]netscaler.CertificateSource)
+	p.configs = make(map[string]*netscaler.Config)
+	p.hostnames = nil
+	p.unavailable = make(map[string]error)
 
-	// Convert the protobuf Config to our envConfig type
-	envConfigs := make(envConfig)
 	environments, err := p.config.GetMap("environments")
 	if err != nil {
 		return nil, fmt.Errorf("invalid config format: %s", err.Error())
 	}
 
-	for env, value := range environments {
-		p.logger.Debug("Creating client", "environment", env)
-		cfg, err := netscaler.NewConfig(value)
+	var defaults map[string]any
+	if v := p.config.Get("defaults"); v != nil {
+		defaults, err = v.GetMap()
 		if err != nil {
-			return nil, fmt.Errorf("invalid Config format for environment %s: %w", env, err)
+			return nil, fmt.Errorf("invalid config format: %s", err.Error())
 		}
+	}
 
-		p.logger.Debug("Config",
+	envConfigs, err := parseEnvironments(p.logger, defaults, environments)
+	if err != nil {
+		return nil, err
+	}
+	if envConfigs, err = p.adm.expand(envConfigs); err != nil {
+		return nil, err
+	}
+
+	factory := p.clientFactory
+	if factory == nil {
+		factory = newNetscalerSource
+	}
+	inventories := make(map[string]*netscaler.Inventory)
+
+	// Create a certificate source for each environment
+	for env, cfg := range envConfigs {
+		var client netscaler.CertificateSource
+		if cfg.Inventory != "" {
+			p.logger.Debug("Loading inventory", "environment", env, "inventory", cfg.Inventory)
+			inv, ok := inventories[cfg.Inventory]
+			if !ok {
+				inv, err = netscaler.LoadInventory(cfg.Inventory)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load inventory for environment %s: %w", env, err)
+				}
+				inventories[cfg.Inventory] = inv
+			}
+			client, err = inv.Source(env)
+		} else if cfg.ADM() {
+			p.logger.Debug("Creating Netscaler client through ADM", "environment", env, "instance", cfg.ManagedInstance)
+			client, err = p.adm.newClient(cfg)
+		} else {
+			p.logger.Debug("Creating Netscaler client", "environment", env)
+			client, err = factory(cfg.Prefix, cfg.ClientConfig())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Netscaler client for environment %s: %w", env, err)
+		}
+		if cluster, ok := client.(netscaler.ClusterSource); ok && cluster.ClusterError() != nil {
+			p.logger.Warn("Treating the appliance as standalone", "environment", env, "error", cluster.ClusterError())
+		}
+		// An unavailable environment must not take down the others, it is checked again by GetMetadata
+		if err := client.Health(); err != nil {
+			p.logger.Warn("Environment is not available", "environment", env, "error", err)
+			p.unavailable[env] = err
+		}
+		p.clients[env] = client
+		p.configs[env] = &cfg
+	}
+
+	return &proto.InitializeResponse{}, nil
+}
+
+// parseEnvironments merges the defaults into the raw environments config and converts it into validated Netscaler configs
+func parseEnvironments(logger hclog.Logger, defaults map[string]any, environments map[string]any) (envConfig, error) {
+	envConfigs, errs := validateEnvironments(defaults, environments)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for env, cfg := range envConfigs {
+		logger.Debug("Config",
 			"environment", env,
 			"endpoint", cfg.Endpoint,
 			"username", cfg.Username,
 			"prefix", cfg.Prefix,
+			"partition", cfg.Partition,
+			"backend", cfg.Backend,
 			"sslverify", cfg.SslVerify)
+	}
 
-		// Validate required fields
-		if cfg.Endpoint == "" {
-			return nil, fmt.Errorf("missing required field 'endpoint' for environment %s", env)
-		}
-		if cfg.Username == "" {
-			return nil, fmt.Errorf("missing required field 'username' for environment %s", env)
-		}
-		if cfg.Password == "" {
-			return nil, fmt.Errorf("missing required field 'password' for environment %s", env)
-		}
+	return envConfigs, nil
+}
 
-		envConfigs[env] = *cfg
+// validateEnvironments merges and decodes all environments and returns the valid configs
+// together with every problem found, one error per problem
+func validateEnvironments(defaults map[string]any, environments map[string]any) (envConfig, []error) {
+	resolved, err := netscaler.ResolveEnvironments(defaults, environments)
+	if err != nil {
+		return nil, []error{fmt.Errorf("invalid config: %w", err)}
 	}
 
-	// Create Netscaler clients for each environment
-	for env, cfg := range envConfigs {
-		p.logger.Debug("Creating Netscaler client", "environment", env)
-		clientConfig := &netscaler.ClientConfig{
-			Endpoint:  cfg.Endpoint,
-			Username:  cfg.Username,
-			Password:  cfg.Password,
-			SslVerify: cfg.SslVerify,
-			Headers:   make(map[string]string),
+	envConfigs := make(envConfig)
+	var errs []error
+	for _, env := range sortedKeys(resolved) {
+		if slices.Contains(reservedEnvironments, env) {
+			errs = append(errs, fmt.Errorf("invalid config for environment %s: the name is reserved for the metadata of all environments", env))
+			continue
 		}
-
-		factory := p.clientFactory
-		if factory == nil {
-			factory = netscaler.NewClient
+		cfg, err := netscaler.NewConfig(resolved[env])
+		if err == nil {
+			err = cfg.Validate()
 		}
-		client, err := factory(cfg.Prefix, clientConfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create Netscaler client for environment %s: %w", env, err)
+			for _, e := range splitErrors(err) {
+				errs = append(errs, fmt.Errorf("invalid config for environment %s: %w", env, e))
+			}
+			continue
 		}
-		p.clients[env] = client
+		envConfigs[env] = *cfg
 	}
 
-	return &proto.InitializeResponse{}, nil
+	for _, err := range netscaler.CheckDuplicatePrefixes(envConfigs) {
+		errs = append(errs, fmt.Errorf("invalid config: %w", err))
+	}
+
+	return envConfigs, errs
+}
+
+// splitErrors returns the errors joined in err
+func splitErrors(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
 }
 
 // GetMetadata implements the plugin.Plugin interface
@@ -110,27 +218,150 @@ func (p *NetscalerPlugin) GetMetadata(_ context.Context, req *proto.GetMetadataR
 	// Create a new Metadata for the response
 	metadata := proto.NewMetadata()
 
+	name := req.GetDomainEntry().GetDomain()
+	if req.DomainEntry.GetAlias() != "" {
+		name = req.DomainEntry.GetAlias()
+	}
+
+	domains := append([]string{req.GetDomainEntry().GetDomain()}, req.GetDomainEntry().GetAlternativeNames()...)
+	found := make(map[string]map[string]any)
+	missing := make(map[string]error)
+	uncovered := make(map[string]any)
 	for env, client := range p.clients {
-		name := req.GetDomainEntry().GetDomain()
-		if req.DomainEntry.GetAlias() != "" {
-			name = req.DomainEntry.GetAlias()
+		// Skip environments that are not responsible for the domain
+		if cfg := p.configs[env]; cfg != nil && !cfg.AppliesTo(req.GetDomainEntry().GetDomain()) {
+			_ = metadata.SetMap(env, map[string]any{"status": statusNotApplicable})
+			continue
+		}
+
+		if err := p.checkAvailable(env, client); err != nil {
+			missing[env] = err
+			_ = metadata.SetMap(env, map[string]any{"error": err.Error()})
+			continue
 		}
+
+		if hosts := p.uncoveredHostnames(env, client, domains); len(hosts) > 0 {
+			uncovered[env] = hosts
+		}
+
 		cert, err := client.GetCertificate(name)
 		if err != nil {
+			missing[env] = err
 			cert = map[string]any{
 				"error": fmt.Sprintf("failed to retrieve certificate for domain %s: %v", req.GetDomainEntry().GetDomain(), err),
 			}
+		} else {
+			found[env] = cert
+			if cfg := p.configs[env]; cfg != nil && cfg.Policy != nil {
+				violations := cfg.Policy.Evaluate(cert, domains...)
+				if violations == nil {
+					violations = []netscaler.Violation{}
+				}
+				cert["violations"] = violations
+			}
+		}
+		if cfg := p.configs[env]; cfg != nil && cfg.Partition != "" {
+			cert["partition"] = cfg.Partition
+		}
+		if cfg := p.configs[env]; cfg != nil && cfg.ManagedInstance != "" {
+			cert["managedInstance"] = cfg.ManagedInstance
+		}
+		if nodes := p.nodeCertificates(env, client, cert); nodes != nil {
+			cert["nodes"] = nodes
 		}
 
 		_ = metadata.SetMap(env, cert)
 	}
 
+	// Compare the certificates of all responsible environments once there is more than one
+	if len(found)+len(missing) > 1 {
+		_ = metadata.SetMap("drift", netscaler.CompareCertificates(name, found, missing))
+	}
+
+	if len(uncovered) > 0 {
+		_ = metadata.SetMap("uncoveredHostnames", uncovered)
+	}
+
 	return metadata.ToGetMetadataResponse()
 }
 
+// checkAvailable checks an environment that was unavailable again and returns an error while it still is
+func (p *NetscalerPlugin) checkAvailable(env string, client netscaler.CertificateSource) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.unavailable[env]; !ok {
+		return nil
+	}
+	if err := client.Health(); err != nil {
+		p.unavailable[env] = err
+		return fmt.Errorf("environment %s is not available: %w", env, err)
+	}
+	p.logger.Info("Environment is available again", "environment", env)
+	delete(p.unavailable, env)
+	return nil
+}
+
+// uncoveredHostnames returns the hostnames of the domain entry that are routed to a VIP of the environment
+// without a certificate covering them, keyed by VIP. Discovery is opt-in per environment and cached.
+func (p *NetscalerPlugin) uncoveredHostnames(env string, client netscaler.CertificateSource, domains []string) map[string][]string {
+	cfg := p.configs[env]
+	discoverer, ok := client.(netscaler.HostnameDiscoverer)
+	if cfg == nil || !cfg.DiscoverHostnames || !ok {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cached, ok := p.hostnames[env]
+	if !ok || time.Since(cached.at) > hostnameCacheTTL {
+		vips, err := discoverer.DiscoverHostnames()
+		if err != nil {
+			p.logger.Warn("Failed to discover hostnames", "environment", env, "error", err)
+			return nil
+		}
+		if p.hostnames == nil {
+			p.hostnames = make(map[string]discoveredHostnames)
+		}
+		cached = discoveredHostnames{vips: vips, at: time.Now()}
+		p.hostnames[env] = cached
+	}
+
+	return netscaler.UncoveredHostnames(cached.vips, domains...)
+}
+
+// nodeCertificates returns the certkey of the retrieved certificate as found on every node of a
+// cluster. Node queries are opt-in per environment.
+func (p *NetscalerPlugin) nodeCertificates(env string, client netscaler.CertificateSource, cert map[string]any) []netscaler.NodeCertificate {
+	cfg := p.configs[env]
+	cluster, ok := client.(netscaler.ClusterSource)
+	certkey, _ := cert["certkey"].(string)
+	if cfg == nil || !cfg.ClusterNodeQueries || !ok || certkey == "" {
+		return nil
+	}
+
+	nodes, err := cluster.NodeCertificates(certkey)
+	if err != nil {
+		p.logger.Warn("Failed to query the cluster nodes", "environment", env, "error", err)
+		return nil
+	}
+	return nodes
+}
+
 // Close implements the plugin.Plugin interface
 func (p *NetscalerPlugin) Close(_ context.Context, _ *proto.CloseRequest) (*proto.CloseResponse, error) {
 	p.logger.Debug("Close called")
+
+	for env, client := range p.clients {
+		if err := client.Close(); err != nil {
+			p.logger.Warn("Failed to close certificate source", "environment", env, "error", err)
+		}
+	}
+	p.clients = nil
+	if err := p.adm.Close(); err != nil {
+		p.logger.Warn("Failed to log out of ADM", "error", err)
+	}
+
 	return &proto.CloseResponse{}, nil
 }
 
@@ -144,6 +375,11 @@ func main() {
 		printVersionInfoAndExit()
 	}
 
+	// Run a CLI subcommand instead of serving the plugin protocol
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args(), os.Stdout, os.Stderr))
+	}
+
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:       "netscaler-plugin",
 		Level:      hclog.Trace,
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
 	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -25,11 +29,51 @@ func (m *MockClient) GetAllCertificates() ([]map[string]any, error) {
 	return args.Get(0).([]map[string]any), args.Error(1)
 }
 
+func (m *MockClient) Health() error {
+	return m.Called().Error(0)
+}
+
+func (m *MockClient) Close() error {
+	return m.Called().Error(0)
+}
+
 func mockClientFactory(_ string, _ *netscaler.ClientConfig) (*netscaler.Client, error) {
 	return &netscaler.Client{}, nil
 }
 
+// mockSourceFactory returns healthy MockClients
+func mockSourceFactory(_ string, _ *netscaler.ClientConfig) (netscaler.CertificateSource, error) {
+	m := &MockClient{}
+	m.On("Health").Return(nil)
+	m.On("Close").Return(nil)
+	return m, nil
+}
+
+const testInventoryJSON = `{
+  "version": 1,
+  "generatedAt": "2026-10-01T00:00:00Z",
+  "environments": {
+    "prod": {
+      "prefix": "prod-",
+      "certificates": [
+        {"certkey": "prod-example.com", "subject": "CN=example.com", "status": "Valid"}
+      ]
+    }
+  }
+}`
+
+func writeTestInventory(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	if err := os.WriteFile(path, []byte(testInventoryJSON), 0o600); err != nil {
+		t.Fatalf("Failed to write inventory: %v", err)
+	}
+	return path
+}
+
 func TestNetscalerPlugin_Initialize(t *testing.T) {
+	inventory := writeTestInventory(t)
+
 	tests := []struct {
 		name        string
 		config      map[string]any
@@ -106,6 +150,67 @@ func TestNetscalerPlugin_Initialize(t *testing.T) {
 			wantErr:     true,
 			description: "should fail when environments is not a map",
 		},
+		{
+			name: "defaults and extends",
+			config: map[string]any{
+				"defaults": map[string]any{
+					"username":  "admin",
+					"sslVerify": true,
+				},
+				"environments": map[string]any{
+					"prod": map[string]any{
+						"endpoint": "https://netscaler-prod.example.com",
+						"password": "secret",
+						"prefix":   "prod-",
+					},
+					"prod-dr": map[string]any{
+						"extends":  "prod",
+						"endpoint": "https://netscaler-dr.example.com",
+					},
+				},
+			},
+			wantErr:     false,
+			description: "should merge defaults and extended environments before validation",
+		},
+		{
+			name: "missing field after merging",
+			config: map[string]any{
+				"defaults": map[string]any{
+					"username": "admin",
+				},
+				"environments": map[string]any{
+					"prod": map[string]any{
+						"endpoint": "https://netscaler-prod.example.com",
+					},
+				},
+			},
+			wantErr:     true,
+			description: "should fail when a required field is set neither in the environment nor the defaults",
+		},
+		{
+			name: "inventory snapshot",
+			config: map[string]any{
+				"environments": map[string]any{
+					"prod": map[string]any{
+						"inventory": inventory,
+					},
+				},
+			},
+			wantErr:     false,
+			description: "should not require connection settings when an inventory is configured",
+		},
+		{
+			name: "environment missing in inventory",
+			config: map[string]any{
+				"environments": map[string]any{
+					"staging": map[string]any{
+						"inventory": inventory,
+					},
+				},
+			},
+			wantErr:     true,
+			description: "should fail when the inventory has no snapshot of the environment",
+		},
 	}
 
 	for _, tt := range tests {
@@ -119,7 +224,7 @@ func TestNetscalerPlugin_Initialize(t *testing.T) {
 			plugin := &NetscalerPlugin{
 				logger:        logger,
 				config:        proto.NewPluginConfig(),
-				clientFactory: mockClientFactory,
+				clientFactory: mockSourceFactory,
 			}
 
 			// Set the config
@@ -152,6 +257,70 @@ func TestNetscalerPlugin_Initialize(t *testing.T) {
 	}
 }
 
+func TestParseEnvironments_ErrorNamesEnvironmentAndField(t *testing.T) {
+	_, err := parseEnvironments(hclog.NewNullLogger(), map[string]any{"username": "admin"}, map[string]any{
+		"prod": map[string]any{"endpoint": "https://netscaler-prod.example.com"},
+	})
+	if err == nil || err.Error() != "invalid config for environment prod: field 'password' is required" {
+		t.Errorf("parseEnvironments() error = %v", err)
+	}
+}
+
+func TestNetscalerPlugin_InitializeUnhealthy(t *testing.T) {
+	dev := &MockClient{}
+	dev.On("Health").Return(errors.New("connection refused")).Once()
+	dev.On("Health").Return(errors.New("connection refused")).Once()
+	dev.On("Health").Return(nil)
+	dev.On("GetCertificate", "example.com").Return(map[string]any{"certkey": "dev-example.com"}, nil)
+	prod := &MockClient{}
+	prod.On("Health").Return(nil)
+	prod.On("GetCertificate", "example.com").Return(map[string]any{"certkey": "prod-example.com"}, nil)
+
+	plugin := &NetscalerPlugin{
+		logger: hclog.NewNullLogger(),
+		config: proto.NewPluginConfig(),
+		clientFactory: func(prefix string, _ *netscaler.ClientConfig) (netscaler.CertificateSource, error) {
+			if prefix == "dev-" {
+				return dev, nil
+			}
+			return prod, nil
+		},
+	}
+	plugin.config.Set("environments", map[string]any{
+		"prod": map[string]any{"endpoint": "https://netscaler-prod.example.com", "username": "admin", "password": "secret", "prefix": "prod-"},
+		"dev":  map[string]any{"endpoint": "https://netscaler-dev.example.com", "username": "admin", "password": "secret", "prefix": "dev-"},
+	})
+
+	protoConfig, err := plugin.config.ToProto()
+	if err != nil {
+		t.Fatalf("Failed to convert config to proto: %v", err)
+	}
+	if _, err := plugin.Initialize(context.Background(), &proto.InitializeRequest{Config: protoConfig}); err != nil {
+		t.Fatalf("Initialize() error = %v, an unavailable environment should not fail the plugin", err)
+	}
+
+	req := &proto.GetMetadataRequest{DomainEntry: &proto.DomainEntry{Domain: "example.com"}}
+	resp, err := plugin.GetMetadata(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	if got := resp.Metadata["dev"].GetStructValue().AsMap()["error"]; got != "environment dev is not available: connection refused" {
+		t.Errorf("GetMetadata() dev error = %v", got)
+	}
+	if got := resp.Metadata["prod"].GetStructValue().AsMap()["certkey"]; got != "prod-example.com" {
+		t.Errorf("GetMetadata() prod certkey = %v", got)
+	}
+
+	// The environment is checked again and used once it is available
+	resp, err = plugin.GetMetadata(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	if got := resp.Metadata["dev"].GetStructValue().AsMap()["certkey"]; got != "dev-example.com" {
+		t.Errorf("GetMetadata() dev certkey = %v after the environment recovered", got)
+	}
+}
+
 func TestNetscalerPlugin_GetMetadata(t *testing.T) {
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   "test",
@@ -159,10 +328,25 @@ func TestNetscalerPlugin_GetMetadata(t *testing.T) {
 		Output: hclog.DefaultOutput,
 	})
 
+	prod := &MockClient{}
+	prod.On("GetCertificate", "example.com").Return(map[string]any{
+		"certkey": "prod-example.com",
+		"subject": "CN=example.com",
+	}, nil)
+	dev := &MockClient{}
+	dev.On("GetCertificate", "example.com").Return(map[string]any(nil), errors.New("not found"))
+
 	plugin := &NetscalerPlugin{
-		logger:  logger,
-		config:  proto.NewPluginConfig(),
-		clients: make(map[string]*netscaler.Client),
+		logger: logger,
+		config: proto.NewPluginConfig(),
+		clients: map[string]netscaler.CertificateSource{
+			"prod": prod,
+			"dev":  dev,
+		},
+		configs: map[string]*netscaler.Config{
+			"prod": {Partition: "team-web"},
+			"dev":  {Partition: "team-web-dev"},
+		},
 	}
 
 	req := &proto.GetMetadataRequest{
@@ -173,13 +357,32 @@ func TestNetscalerPlugin_GetMetadata(t *testing.T) {
 
 	resp, err := plugin.GetMetadata(context.Background(), req)
 	if err != nil {
-		t.Errorf("GetMetadata() error = %v", err)
-		return
+		t.Fatalf("GetMetadata() error = %v", err)
 	}
 
-	if resp == nil {
-		t.Error("GetMetadata() should return a response")
+	prodMeta := resp.Metadata["prod"].GetStructValue().AsMap()
+	if prodMeta["subject"] != "CN=example.com" {
+		t.Errorf("GetMetadata() prod subject = %v, want CN=example.com", prodMeta["subject"])
+	}
+	devMeta := resp.Metadata["dev"].GetStructValue().AsMap()
+	if _, ok := devMeta["error"]; !ok {
+		t.Errorf("GetMetadata() dev = %v, want an error entry", devMeta)
+	}
+	if prodMeta["partition"] != "team-web" || devMeta["partition"] != "team-web-dev" {
+		t.Errorf("GetMetadata() partitions = %v, %v, want the partitions of the environments", prodMeta["partition"], devMeta["partition"])
+	}
+
+	drift := resp.Metadata["drift"].GetStructValue().AsMap()
+	if drift["inSync"] != false {
+		t.Errorf("GetMetadata() drift = %v, want out of sync", drift)
 	}
+	driftEnvs := drift["environments"].(map[string]any)
+	if status := driftEnvs["dev"].(map[string]any)["status"]; status != string(netscaler.DriftMissing) {
+		t.Errorf("GetMetadata() dev drift status = %v, want missing", status)
+	}
+
+	prod.AssertExpectations(t)
+	dev.AssertExpectations(t)
 }
 
 func TestNetscalerPlugin_Close(t *testing.T) {
@@ -189,9 +392,13 @@ func TestNetscalerPlugin_Close(t *testing.T) {
 		Output: hclog.DefaultOutput,
 	})
 
+	source := &MockClient{}
+	source.On("Close").Return(nil)
+
 	plugin := &NetscalerPlugin{
-		logger: logger,
-		config: proto.NewPluginConfig(),
+		logger:  logger,
+		config:  proto.NewPluginConfig(),
+		clients: map[string]netscaler.CertificateSource{"prod": source},
 	}
 
 	req := &proto.CloseRequest{}
@@ -205,4 +412,99 @@ func TestNetscalerPlugin_Close(t *testing.T) {
 	if resp == nil {
 		t.Error("Close() should return a response")
 	}
+	source.AssertExpectations(t)
+}
+
+func TestNetscalerPlugin_GetMetadataDomainRouting(t *testing.T) {
+	prod := &MockClient{}
+	prod.On("GetCertificate", "example.com").Return(map[string]any{"certkey": "prod-example.com"}, nil)
+	internal := &MockClient{}
+
+	plugin := &NetscalerPlugin{
+		logger: hclog.NewNullLogger(),
+		config: proto.NewPluginConfig(),
+		clients: map[string]netscaler.CertificateSource{
+			"prod":     prod,
+			"internal": internal,
+		},
+		configs: map[string]*netscaler.Config{
+			"prod":     {IncludeDomains: []string{"*.com"}},
+			"internal": {IncludeDomains: []string{"*.internal"}},
+		},
+	}
+
+	resp, err := plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+		DomainEntry: &proto.DomainEntry{Domain: "example.com"},
+	})
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	if got := resp.Metadata["internal"].GetStructValue().AsMap(); got["status"] != statusNotApplicable {
+		t.Errorf("GetMetadata() internal = %v, want not-applicable", got)
+	}
+	if got := resp.Metadata["prod"].GetStructValue().AsMap(); got["certkey"] != "prod-example.com" {
+		t.Errorf("GetMetadata() prod = %v, want the certificate", got)
+	}
+	if got := resp.Metadata["prod"].GetStructValue().AsMap(); got["partition"] != nil {
+		t.Errorf("GetMetadata() prod = %v, environments without partition should not report one", got)
+	}
+	if _, ok := resp.Metadata["drift"]; ok {
+		t.Error("GetMetadata() should only compare environments responsible for the domain")
+	}
+
+	prod.AssertExpectations(t)
+	internal.AssertNotCalled(t, "GetCertificate", "example.com")
+}
+
+func TestNetscalerPlugin_GetMetadataClusterNodes(t *testing.T) {
+	cluster := netscalertest.NewCluster("192.0.2.10", "192.0.2.11", "192.0.2.12")
+	if err := cluster.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "serial": "01"}); err != nil {
+		t.Fatalf("Cluster.Add() error = %v", err)
+	}
+	_ = cluster.Store("192.0.2.12").Remove("sslcertkey", "prod-example.com", nil)
+
+	api, _ := cluster.Client("192.0.2.10")
+	client, err := netscaler.NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	if _, err := client.DetectCluster("192.0.2.10", func(address string) (netscaler.NitroClientInterface, error) {
+		return cluster.Client(address)
+	}); err != nil {
+		t.Fatalf("DetectCluster() error = %v", err)
+	}
+
+	plugin := &NetscalerPlugin{
+		logger:  hclog.NewNullLogger(),
+		config:  proto.NewPluginConfig(),
+		clients: map[string]netscaler.CertificateSource{"prod": client},
+		configs: map[string]*netscaler.Config{"prod": {Prefix: "prod-", ClusterNodeQueries: true}},
+	}
+
+	resp, err := plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+		DomainEntry: &proto.DomainEntry{Domain: "example.com"},
+	})
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	nodes, _ := resp.Metadata["prod"].GetStructValue().AsMap()["nodes"].([]any)
+	if len(nodes) != 2 {
+		t.Fatalf("GetMetadata() nodes = %v, want both nodes", nodes)
+	}
+	if inSync := nodes[0].(map[string]any)["inSync"]; inSync != true {
+		t.Errorf("GetMetadata() node 0 = %v, want in sync", nodes[0])
+	}
+	if missing := nodes[1].(map[string]any); missing["inSync"] != false || missing["error"] == nil {
+		t.Errorf("GetMetadata() node 1 = %v, want the missing certificate", missing)
+	}
+
+	// Without clusterNodeQueries the nodes are not queried
+	plugin.configs["prod"].ClusterNodeQueries = false
+	resp, _ = plugin.GetMetadata(context.Background(), &proto.GetMetadataRequest{
+		DomainEntry: &proto.DomainEntry{Domain: "example.com"},
+	})
+	if _, ok := resp.Metadata["prod"].GetStructValue().AsMap()["nodes"]; ok {
+		t.Error("GetMetadata() should only query the nodes when enabled")
+	}
 }
@@ -0,0 +1,441 @@
+package netscaler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// ADMProxyHeader is the header NetScaler Console (ADM) forwards a NITRO request with to the
+// managed instance with this IP address
+const ADMProxyHeader = "_MPS_API_PROXY_MANAGED_INSTANCE_IP"
+
+// admSessionCookie is the cookie holding the ADM session
+const admSessionCookie = "SESSID"
+
+// defaultADMTimeout limits ADM requests if the config has no timeout
+const defaultADMTimeout = 30 * time.Second
+
+// ManagedInstance is an ADC instance managed by NetScaler Console as reported by managed_device
+type ManagedInstance struct {
+	IPAddress string `json:"ipAddress"`
+	Hostname  string `json:"hostname,omitempty"`
+	// Type is the platform, e.g. nsvpx or nssdx
+	Type string `json:"type,omitempty"`
+	// State is Up or Down
+	State string `json:"state,omitempty"`
+}
+
+// Name returns the hostname of the instance, or its IP address if it has none
+func (i ManagedInstance) Name() string {
+	if i.Hostname != "" {
+		return i.Hostname
+	}
+	return i.IPAddress
+}
+
+// ADM is a session with NetScaler Console (ADM). NITRO calls to managed instances are sent to
+// ADM with ADMProxyHeader and share the session, see NewClient.
+type ADM struct {
+	config *ClientConfig
+	http   *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewADM logs in to NetScaler Console with the endpoint, credentials and TLS settings of the config
+func NewADM(config *ClientConfig) (*ADM, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// sslVerify is off by default like for NITRO endpoints
+		InsecureSkipVerify: !config.SslVerify, //nolint:gosec
+	}
+	if config.RootCAPath != "" {
+		data, err := os.ReadFile(config.RootCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read root CAs: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", config.RootCAPath)
+		}
+	}
+	timeout := defaultADMTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+
+	a := &ADM{
+		config: config,
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}
+	if _, err := a.session(""); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Instances returns the instances managed by ADM
+func (a *ADM) Instances() ([]ManagedInstance, error) {
+	raw, err := a.list("managed_device", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve managed instances: %w", err)
+	}
+
+	instances := make([]ManagedInstance, 0, len(raw))
+	for _, r := range raw {
+		instances = append(instances, ManagedInstance{
+			IPAddress: stringField(r, "ip_address"),
+			Hostname:  stringField(r, "hostname"),
+			Type:      stringField(r, "type"),
+			State:     stringField(r, "instance_state"),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].IPAddress < instances[j].IPAddress
+	})
+	return instances, nil
+}
+
+// InstanceGroup returns the managed instances of an ADM instance group (device_group)
+func (a *ADM) InstanceGroup(name string) ([]ManagedInstance, error) {
+	groups, err := a.list("device_group", url.Values{"filter": {"name:" + name}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instance group %s: %w", name, err)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("instance group %s not found", name)
+	}
+
+	members := make(map[string]bool)
+	switch list := groups[0]["static_device_list_arr"].(type) {
+	case []any:
+		for _, ip := range list {
+			members[fmt.Sprint(ip)] = true
+		}
+	default:
+		for _, ip := range strings.Split(stringField(groups[0], "static_device_list"), ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				members[ip] = true
+			}
+		}
+	}
+
+	instances, err := a.Instances()
+	if err != nil {
+		return nil, err
+	}
+	var grouped []ManagedInstance
+	for _, instance := range instances {
+		if members[instance.IPAddress] {
+			grouped = append(grouped, instance)
+			delete(members, instance.IPAddress)
+		}
+	}
+	if len(members) > 0 {
+		return nil, fmt.Errorf("instance group %s lists instances ADM doesn't manage: %s", name, strings.Join(sortedMapKeys(members), ", "))
+	}
+	return grouped, nil
+}
+
+// ExpandInstanceGroups replaces every environment with an instanceGroup by one environment per
+// instance of the group, named after the environment and the hostname of the instance. The
+// instances of a group are looked up with the given function.
+func ExpandInstanceGroups(configs map[string]Config, instances func(cfg Config) ([]ManagedInstance, error)) (map[string]Config, error) {
+	expanded := make(map[string]Config, len(configs))
+	for env, cfg := range configs {
+		if cfg.InstanceGroup == "" {
+			expanded[env] = cfg
+		}
+	}
+
+	var errs []error
+	for _, env := range sortedMapKeys(configs) {
+		cfg := configs[env]
+		if cfg.InstanceGroup == "" {
+			continue
+		}
+		group, err := instances(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment %s: %w", env, err))
+			continue
+		}
+		if len(group) == 0 {
+			errs = append(errs, fmt.Errorf("environment %s: instance group %s has no instances", env, cfg.InstanceGroup))
+			continue
+		}
+		for _, instance := range group {
+			name := env + "-" + instance.Name()
+			if _, ok := expanded[name]; ok {
+				errs = append(errs, fmt.Errorf("environment %s: instance %s clashes with environment %s", env, instance.IPAddress, name))
+				continue
+			}
+			cfg := cfg
+			cfg.InstanceGroup = ""
+			cfg.ManagedInstance = instance.IPAddress
+			expanded[name] = cfg
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return expanded, nil
+}
+
+// NewClient creates a client for the managed instance in the ADMProxyHeader of the config. The
+// client is logged in with the session of ADM, expired sessions are renewed for all clients.
+func (a *ADM) NewClient(prefix string, config *ClientConfig) (*Client, error) {
+	instance := config.Headers[ADMProxyHeader]
+	if instance == "" {
+		return nil, fmt.Errorf("no managed instance given in header %s", ADMProxyHeader)
+	}
+
+	proxy := &admProxy{adm: a, config: config}
+	var api NitroClientInterface = proxy
+	var recorder *Recorder
+	if config.Record != "" {
+		recorder = NewRecorder(proxy, config.Record)
+		api = recorder
+	}
+	c, err := NewClientFromNitro(prefix, api)
+	if err != nil {
+		return nil, err
+	}
+	c.recorder = recorder
+	c.rotation = config.Rotation
+	return c, nil
+}
+
+// Close logs out of ADM, the clients of its instances can't be used afterwards
+func (a *ADM) Close() error {
+	a.mu.Lock()
+	sessionID := a.sessionID
+	a.sessionID = ""
+	a.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	_, err := a.do(http.MethodPost, "logout", nil, map[string]any{"logout": map[string]any{}}, sessionID)
+	return err
+}
+
+// session returns the current session. If it is the stale session that expired, or there is
+// none yet, it logs in again. Clients of several instances renew an expired session only once.
+func (a *ADM) session(stale string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sessionID != "" && a.sessionID != stale {
+		return a.sessionID, nil
+	}
+
+	res, err := a.do(http.MethodPost, "login", nil, map[string]any{"login": map[string]any{
+		"username": a.config.Username,
+		"password": a.config.Password,
+	}}, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to ADM: %w", err)
+	}
+	var sessionID string
+	if login, ok := res["login"].([]any); ok && len(login) > 0 {
+		if attrs, ok := login[0].(map[string]any); ok {
+			sessionID = stringField(attrs, "sessionid")
+		}
+	}
+	if sessionID == "" {
+		return "", errors.New("failed to log in to ADM: no sessionid in the response")
+	}
+	a.sessionID = sessionID
+	return sessionID, nil
+}
+
+// list returns the resources of an ADM resource type
+func (a *ADM) list(resourceType string, query url.Values) ([]map[string]any, error) {
+	sessionID, err := a.session("")
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.do(http.MethodGet, resourceType, query, nil, sessionID)
+	if err != nil && sessionExpired.MatchString(err.Error()) {
+		if sessionID, err = a.session(sessionID); err == nil {
+			res, err = a.do(http.MethodGet, resourceType, query, nil, sessionID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _ := res[resourceType].([]any)
+	list := make([]map[string]any, 0, len(raw))
+	for _, r := range raw {
+		if attrs, ok := r.(map[string]any); ok {
+			list = append(list, attrs)
+		}
+	}
+	return list, nil
+}
+
+// do sends a request to the NITRO API of ADM. Errors include the status and the response body like
+// the errors of the NITRO client.
+func (a *ADM) do(method, resourceType string, query url.Values, body any, sessionID string) (map[string]any, error) {
+	endpoint := strings.TrimRight(a.config.Endpoint, "/") + "/nitro/v1/config/" + resourceType
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: admSessionCookie, Value: sessionID})
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("failed: %s (%s)", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	res := make(map[string]any)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, fmt.Errorf("failed to parse the response of %s: %w", resourceType, err)
+		}
+	}
+	return res, nil
+}
+
+// admProxy sends the NITRO calls of a client through ADM. It uses the session of ADM instead of
+// logging in to the instance, Login renews the session of ADM when it expired.
+type admProxy struct {
+	adm    *ADM
+	config *ClientConfig
+
+	mu        sync.Mutex
+	sessionID string
+	api       NitroClientInterface
+}
+
+// Login connects with the current session of ADM, or a new one if the session used so far expired
+func (p *admProxy) Login() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sessionID, err := p.adm.session(p.sessionID)
+	if err != nil {
+		return err
+	}
+	headers := maps.Clone(p.config.Headers)
+	headers["Cookie"] = admSessionCookie + "=" + sessionID
+	api, err := service.NewNitroClientFromParams(service.NitroParams{
+		Url:        p.adm.config.Endpoint,
+		SslVerify:  p.adm.config.SslVerify,
+		Timeout:    p.adm.config.Timeout,
+		RootCAPath: p.adm.config.RootCAPath,
+		Headers:    headers,
+	})
+	if err != nil {
+		return err
+	}
+	p.sessionID, p.api = sessionID, api
+	return nil
+}
+
+// Logout does nothing, the session of ADM is shared and ended by ADM.Close
+func (p *admProxy) Logout() error {
+	return nil
+}
+
+// current returns the NITRO client of the current session
+func (p *admProxy) current() NitroClientInterface {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.api
+}
+
+// FindAllResources lists the resources of the type on the instance. The NITRO client returns no
+// resources instead of the error when the session of ADM expired, the query reports it.
+func (p *admProxy) FindAllResources(resourceType string) ([]map[string]any, error) {
+	return p.current().FindResourceArrayWithParams(service.FindParams{ResourceType: resourceType})
+}
+
+// FindResource returns the named resource of the instance, queried for the same reason
+func (p *admProxy) FindResource(resourceType string, name string) (map[string]any, error) {
+	res, err := p.current().FindResourceArrayWithParams(service.FindParams{
+		ResourceType:             resourceType,
+		ResourceName:             name,
+		ResourceMissingErrorCode: errCodeNoSuchResource,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no resource %s of type %s found", name, resourceType)
+	}
+	return res[0], nil
+}
+
+// FindResourceArrayWithParams runs the query on the instance
+func (p *admProxy) FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error) {
+	return p.current().FindResourceArrayWithParams(findParams)
+}
+
+// AddResource creates a resource on the instance
+func (p *admProxy) AddResource(resourceType string, name string, resourceStruct any) (string, error) {
+	return p.current().AddResource(resourceType, name, resourceStruct)
+}
+
+// UpdateResource updates a resource on the instance
+func (p *admProxy) UpdateResource(resourceType string, name string, resourceStruct any) (string, error) {
+	return p.current().UpdateResource(resourceType, name, resourceStruct)
+}
+
+// ActOnResource applies an action on the instance
+func (p *admProxy) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	return p.current().ActOnResource(resourceType, resourceStruct, action)
+}
+
+// DeleteResource removes a resource on the instance
+func (p *admProxy) DeleteResource(resourceType string, name string) error {
+	return p.current().DeleteResource(resourceType, name)
+}
+
+// DeleteResourceWithArgsMap removes a resource or bindings on the instance
+func (p *admProxy) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	return p.current().DeleteResourceWithArgsMap(resourceType, name, args)
+}
@@ -0,0 +1,276 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newTestADM returns a fake ADM managing two instances with a certificate each, grouped as web
+func newTestADM(t *testing.T) (*netscalertest.ADM, *ClientConfig) {
+	t.Helper()
+	fake := netscalertest.NewADM()
+	t.Cleanup(fake.Close)
+	for _, instance := range []struct{ ip, hostname, certkey string }{
+		{"192.0.2.21", "adc1", "prod-one.com"},
+		{"192.0.2.22", "adc2", "prod-two.com"},
+	} {
+		srv := netscalertest.NewServer()
+		t.Cleanup(srv.Close)
+		if err := srv.Store.Add("sslcertkey", map[string]any{"certkey": instance.certkey}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+		fake.AddInstance(instance.ip, instance.hostname, srv)
+	}
+	fake.AddGroup("web", "192.0.2.21", "192.0.2.22")
+
+	return fake, &ClientConfig{Endpoint: fake.URL, Username: fake.Username, Password: fake.Password, Headers: map[string]string{}}
+}
+
+func TestNewADM(t *testing.T) {
+	fake, config := newTestADM(t)
+
+	wrong := *config
+	wrong.Password = "wrong"
+	if _, err := NewADM(&wrong); err == nil || !strings.Contains(err.Error(), "failed to log in to ADM") {
+		t.Errorf("NewADM() with a wrong password error = %v", err)
+	}
+
+	adm, err := NewADM(config)
+	if err != nil {
+		t.Fatalf("NewADM() error = %v", err)
+	}
+	instances, err := adm.Instances()
+	if err != nil {
+		t.Fatalf("Instances() error = %v", err)
+	}
+	want := []ManagedInstance{
+		{IPAddress: "192.0.2.21", Hostname: "adc1", Type: "nsvpx", State: "Up"},
+		{IPAddress: "192.0.2.22", Hostname: "adc2", Type: "nsvpx", State: "Up"},
+	}
+	if !reflect.DeepEqual(instances, want) {
+		t.Errorf("Instances() = %+v, want %+v", instances, want)
+	}
+
+	// an expired session is renewed
+	fake.ExpireSessions()
+	if _, err := adm.Instances(); err != nil {
+		t.Errorf("Instances() after the session expired error = %v", err)
+	}
+	if fake.Logins() != 2 {
+		t.Errorf("Logins() = %d, want 2", fake.Logins())
+	}
+
+	if err := adm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if fake.Sessions() != 0 {
+		t.Errorf("Sessions() = %d after Close(), want 0", fake.Sessions())
+	}
+}
+
+func TestADM_InstanceGroup(t *testing.T) {
+	tests := []struct {
+		name        string
+		group       string
+		want        []string
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "group",
+			group:       "web",
+			want:        []string{"192.0.2.21", "192.0.2.22"},
+			description: "should return the instances of the group",
+		},
+		{
+			name:        "missing",
+			group:       "db",
+			wantErr:     "instance group db not found",
+			description: "unknown groups should fail",
+		},
+		{
+			name:        "unmanaged",
+			group:       "stale",
+			wantErr:     "lists instances ADM doesn't manage: 192.0.2.99",
+			description: "groups listing instances ADM doesn't manage should fail",
+		},
+	}
+
+	fake, config := newTestADM(t)
+	fake.AddGroup("stale", "192.0.2.21", "192.0.2.99")
+	adm, err := NewADM(config)
+	if err != nil {
+		t.Fatalf("NewADM() error = %v", err)
+	}
+	defer func() { _ = adm.Close() }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances, err := adm.InstanceGroup(tt.group)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("InstanceGroup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InstanceGroup() error = %v", err)
+			}
+			var got []string
+			for _, instance := range instances {
+				got = append(got, instance.IPAddress)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InstanceGroup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestADM_NewClient(t *testing.T) {
+	fake, config := newTestADM(t)
+	adm, err := NewADM(config)
+	if err != nil {
+		t.Fatalf("NewADM() error = %v", err)
+	}
+
+	if _, err := adm.NewClient("prod-", config); err == nil || !strings.Contains(err.Error(), ADMProxyHeader) {
+		t.Errorf("NewClient() without managed instance error = %v", err)
+	}
+
+	clients := make(map[string]*Client)
+	for ip, want := range map[string]string{"192.0.2.21": "prod-one.com", "192.0.2.22": "prod-two.com"} {
+		cfg := (&Config{Endpoint: fake.URL, Backend: BackendADM, ManagedInstance: ip}).ClientConfig()
+		client, err := adm.NewClient("prod-", cfg)
+		if err != nil {
+			t.Fatalf("NewClient(%s) error = %v", ip, err)
+		}
+		certs, err := client.GetAllCertificates()
+		if err != nil {
+			t.Fatalf("GetAllCertificates() of %s error = %v", ip, err)
+		}
+		if len(certs) != 1 || certs[0]["certkey"] != want {
+			t.Errorf("GetAllCertificates() of %s = %v, want %s", ip, certs, want)
+		}
+		if fake.Proxied(ip) == 0 {
+			t.Errorf("no request was proxied to %s", ip)
+		}
+		clients[ip] = client
+	}
+
+	// the clients share the session of ADM and renew it once
+	fake.ExpireSessions()
+	for ip, client := range clients {
+		if _, err := client.GetAllCertificates(); err != nil {
+			t.Errorf("GetAllCertificates() of %s after the session expired error = %v", ip, err)
+		}
+	}
+	if fake.Logins() != 2 {
+		t.Errorf("Logins() = %d, want one login and one renewal", fake.Logins())
+	}
+
+	// closing a client keeps the session of ADM for the others
+	if err := clients["192.0.2.21"].Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := clients["192.0.2.22"].GetAllCertificates(); err != nil {
+		t.Errorf("GetAllCertificates() after closing another client error = %v", err)
+	}
+	if err := adm.Close(); err != nil {
+		t.Fatalf("ADM.Close() error = %v", err)
+	}
+	if fake.Sessions() != 0 {
+		t.Errorf("Sessions() = %d after ADM.Close(), want 0", fake.Sessions())
+	}
+}
+
+func TestExpandInstanceGroups(t *testing.T) {
+	groups := map[string][]ManagedInstance{
+		"web":   {{IPAddress: "192.0.2.21", Hostname: "adc1"}, {IPAddress: "192.0.2.22"}},
+		"empty": nil,
+	}
+	instances := func(cfg Config) ([]ManagedInstance, error) {
+		group, ok := groups[cfg.InstanceGroup]
+		if !ok {
+			return nil, errors.New("instance group " + cfg.InstanceGroup + " not found")
+		}
+		return group, nil
+	}
+
+	tests := []struct {
+		name        string
+		configs     map[string]Config
+		want        map[string]string
+		wantErr     string
+		description string
+	}{
+		{
+			name: "group",
+			configs: map[string]Config{
+				"fleet": {Backend: BackendADM, InstanceGroup: "web", Prefix: "prod-"},
+				"adc3":  {Backend: BackendADM, ManagedInstance: "192.0.2.23"},
+				"dc":    {Endpoint: "https://netscaler.example.com"},
+			},
+			want: map[string]string{
+				"fleet-adc1":       "192.0.2.21",
+				"fleet-192.0.2.22": "192.0.2.22",
+				"adc3":             "192.0.2.23",
+				"dc":               "",
+			},
+			description: "groups should be expanded per instance, named by hostname or IP, other environments kept",
+		},
+		{
+			name:        "missing",
+			configs:     map[string]Config{"fleet": {Backend: BackendADM, InstanceGroup: "db"}},
+			wantErr:     "environment fleet: instance group db not found",
+			description: "lookup errors should name the environment",
+		},
+		{
+			name:        "empty",
+			configs:     map[string]Config{"fleet": {Backend: BackendADM, InstanceGroup: "empty"}},
+			wantErr:     "instance group empty has no instances",
+			description: "an empty group should fail instead of dropping the environment",
+		},
+		{
+			name: "clash",
+			configs: map[string]Config{
+				"fleet":      {Backend: BackendADM, InstanceGroup: "web"},
+				"fleet-adc1": {Backend: BackendADM, ManagedInstance: "192.0.2.21"},
+			},
+			wantErr:     "instance 192.0.2.21 clashes with environment fleet-adc1",
+			description: "expanded environments should not replace configured ones",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, err := ExpandInstanceGroups(tt.configs, instances)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExpandInstanceGroups() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandInstanceGroups() error = %v", err)
+			}
+			got := make(map[string]string)
+			for env, cfg := range expanded {
+				got[env] = cfg.ManagedInstance
+				if cfg.InstanceGroup != "" {
+					t.Errorf("environment %s still has instance group %s", env, cfg.InstanceGroup)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandInstanceGroups() = %v, want %v", got, tt.want)
+			}
+			if cfg := expanded["fleet-adc1"]; cfg.Prefix != "prod-" || cfg.ClientConfig().Headers[ADMProxyHeader] != "192.0.2.21" {
+				t.Errorf("fleet-adc1 = %+v, want the settings of fleet proxied to 192.0.2.21", cfg)
+			}
+		})
+	}
+}
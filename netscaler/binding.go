@@ -0,0 +1,70 @@
+package netscaler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sslcertkeyBindingType is the NITRO resource listing everything a certkey is bound to
+const sslcertkeyBindingType = "sslcertkey_binding"
+
+// bindingNameKeys maps the bound resource type to the attribute holding its name
+var bindingNameKeys = map[string][]string{
+	"sslvserver":   {"servername", "vservername"},
+	"service":      {"servicename"},
+	"servicegroup": {"servicegroupname"},
+}
+
+// Binding is an entity a certificate is bound to
+type Binding struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// SNI is set for certificates bound to a vserver for SNI, only read by RotateCertificate
+	SNI bool `json:"sni,omitempty"`
+}
+
+// GetCertificateBindings returns the vservers, services and service groups the certkey is bound to.
+// The certkey is the full name on the appliance, including the prefix.
+func (c *Client) GetCertificateBindings(certkey string) ([]Binding, error) {
+	res, err := c.api.FindResource(sslcertkeyBindingType, certkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bindings of %s: %w", certkey, err)
+	}
+
+	return parseBindings(res), nil
+}
+
+// parseBindings extracts the bindings from an sslcertkey_binding resource
+func parseBindings(res map[string]any) []Binding {
+	bindings := []Binding{}
+	for key, value := range res {
+		boundType, ok := strings.CutPrefix(key, "sslcertkey_")
+		if !ok {
+			continue
+		}
+		boundType, ok = strings.CutSuffix(boundType, "_binding")
+		if !ok {
+			continue
+		}
+		entries, _ := value.([]any)
+		for _, entry := range entries {
+			attrs, _ := entry.(map[string]any)
+			for _, nameKey := range bindingNameKeys[boundType] {
+				if name := stringField(attrs, nameKey); name != "" {
+					bindings = append(bindings, Binding{Type: boundType, Name: name})
+					break
+				}
+			}
+		}
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Type != bindings[j].Type {
+			return bindings[i].Type < bindings[j].Type
+		}
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	return bindings
+}
@@ -0,0 +1,70 @@
+package netscaler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClient_GetCertificateBindings(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockRes     map[string]any
+		mockErr     error
+		want        []Binding
+		wantErr     bool
+		description string
+	}{
+		{
+			name: "vserver and service bindings",
+			mockRes: map[string]any{
+				"certkey": "prod-example.com",
+				"sslcertkey_sslvserver_binding": []any{
+					map[string]any{"certkey": "prod-example.com", "servername": "vs-web"},
+					map[string]any{"certkey": "prod-example.com", "servername": "vs-api"},
+				},
+				"sslcertkey_service_binding": []any{
+					map[string]any{"certkey": "prod-example.com", "servicename": "svc-backend"},
+				},
+			},
+			want: []Binding{
+				{Type: "service", Name: "svc-backend"},
+				{Type: "sslvserver", Name: "vs-api"},
+				{Type: "sslvserver", Name: "vs-web"},
+			},
+			description: "should return all bindings sorted by type and name",
+		},
+		{
+			name:        "unbound certificate",
+			mockRes:     map[string]any{"certkey": "prod-example.com"},
+			want:        []Binding{},
+			description: "should return an empty list for unbound certificates",
+		},
+		{
+			name:        "api error",
+			mockErr:     errors.New("not found"),
+			wantErr:     true,
+			description: "should return the API error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{
+				api: &MockNitroClient{cert: tt.mockRes, findErr: tt.mockErr},
+			}
+
+			got, err := client.GetCertificateBindings("prod-example.com")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCertificateBindings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetCertificateBindings() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("GetCertificateBindings()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package netscaler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nitroTimeLayout is the format NITRO uses for certificate validity dates, e.g. "Jun 27 12:00:00 2025 GMT"
+const nitroTimeLayout = "Jan 2 15:04:05 2006 MST"
+
+// Certificate holds the parsed fields of an sslcertkey resource
+type Certificate struct {
+	Certkey          string    `json:"certkey"`
+	Subject          string    `json:"subject,omitempty"`
+	Issuer           string    `json:"issuer,omitempty"`
+	Serial           string    `json:"serial,omitempty"`
+	Digest           string    `json:"digest,omitempty"`
+	NotBefore        time.Time `json:"notBefore,omitempty"`
+	NotAfter         time.Time `json:"notAfter,omitempty"`
+	DaysToExpiration int       `json:"daysToExpiration"`
+	KeyType          string    `json:"keyType,omitempty"`
+	KeySize          int       `json:"keySize,omitempty"`
+	SignatureAlg     string    `json:"signatureAlg,omitempty"`
+	SANs             []string  `json:"sans,omitempty"`
+	LinkCertkey      string    `json:"linkCertkey,omitempty"`
+	Status           string    `json:"status,omitempty"`
+}
+
+// ParseCertificate converts the raw NITRO sslcertkey attributes into a Certificate
+func ParseCertificate(raw map[string]any) (*Certificate, error) {
+	name, ok := raw["certkey"].(string)
+	if !ok {
+		return nil, fmt.Errorf("certificate name is not a string: %v", raw["certkey"])
+	}
+
+	c := &Certificate{
+		Certkey:      name,
+		Subject:      stringField(raw, "subject"),
+		Issuer:       stringField(raw, "issuer"),
+		Serial:       stringField(raw, "serial"),
+		Digest:       stringField(raw, "certkeydigest"),
+		KeyType:      stringField(raw, "publickey"),
+		SignatureAlg: stringField(raw, "signaturealg"),
+		LinkCertkey:  stringField(raw, "linkcertkeyname"),
+		Status:       stringField(raw, "status"),
+		SANs:         parseSANs(raw["sandns"]),
+	}
+
+	var err error
+	if c.KeySize, err = intField(raw, "publickeysize"); err != nil {
+		return nil, err
+	}
+	if c.DaysToExpiration, err = intField(raw, "daystoexpiration"); err != nil {
+		return nil, err
+	}
+	if c.NotBefore, err = timeField(raw, "clientcertnotbefore"); err != nil {
+		return nil, err
+	}
+	if c.NotAfter, err = timeField(raw, "clientcertnotafter"); err != nil {
+		return nil, err
+	}
+	if _, ok := raw["daystoexpiration"]; !ok && !c.NotAfter.IsZero() {
+		c.DaysToExpiration = int(time.Until(c.NotAfter).Hours() / 24)
+	}
+
+	return c, nil
+}
+
+// CommonName returns the CN component of the certificate subject
+func (c *Certificate) CommonName() string {
+	return distinguishedNameField(c.Subject, "CN")
+}
+
+// IsSelfSigned reports whether subject and issuer are identical
+func (c *Certificate) IsSelfSigned() bool {
+	return c.Subject != "" && c.Subject == c.Issuer
+}
+
+// Covers reports whether the certificate is valid for the given hostname, honoring wildcard entries
+func (c *Certificate) Covers(hostname string) bool {
+	names := c.SANs
+	if len(names) == 0 && c.CommonName() != "" {
+		names = []string{c.CommonName()}
+	}
+	for _, name := range names {
+		if matchHostname(name, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHostname(pattern, hostname string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if pattern == hostname {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	i := strings.IndexByte(hostname, '.')
+	return i > 0 && hostname[i:] == pattern[1:]
+}
+
+func distinguishedNameField(dn, key string) string {
+	for _, part := range strings.FieldsFunc(dn, func(r rune) bool { return r == ',' || r == '/' }) {
+		k, v, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func parseSANs(v any) []string {
+	var entries []string
+	switch t := v.(type) {
+	case string:
+		entries = strings.FieldsFunc(t, func(r rune) bool { return r == ',' || r == ' ' })
+	case []any:
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				entries = append(entries, strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })...)
+			}
+		}
+	}
+
+	var sans []string
+	for _, e := range entries {
+		e = strings.TrimPrefix(strings.TrimSpace(e), "DNS:")
+		if e != "" {
+			sans = append(sans, e)
+		}
+	}
+	return sans
+}
+
+func stringField(raw map[string]any, key string) string {
+	s, _ := raw[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func intField(raw map[string]any, key string) (int, error) {
+	switch v := raw[key].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", key, v)
+	}
+}
+
+func timeField(raw map[string]any, key string) (time.Time, error) {
+	s := stringField(raw, key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(nitroTimeLayout, strings.Join(strings.Fields(s), " "))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %w", key, s, err)
+	}
+	return t, nil
+}
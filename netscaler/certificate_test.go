@@ -0,0 +1,137 @@
+package netscaler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCertificate(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         map[string]any
+		want        *Certificate
+		wantErr     bool
+		description string
+	}{
+		{
+			name: "all fields",
+			raw: map[string]any{
+				"certkey":             "prod-example.com",
+				"subject":             " C=US, CN=example.com",
+				"issuer":              "C=US, O=Let's Encrypt, CN=R11",
+				"serial":              "04A1B2",
+				"clientcertnotbefore": "Mar  1 00:00:00 2025 GMT",
+				"clientcertnotafter":  "May 30 00:00:00 2025 GMT",
+				"daystoexpiration":    float64(42),
+				"publickey":           "RSA",
+				"publickeysize":       float64(2048),
+				"signaturealg":        "sha256WithRSAEncryption",
+				"sandns":              "DNS:example.com, DNS:www.example.com",
+				"linkcertkeyname":     "le-r11",
+				"status":              "Valid",
+			},
+			want: &Certificate{
+				Certkey:          "prod-example.com",
+				Subject:          "C=US, CN=example.com",
+				Issuer:           "C=US, O=Let's Encrypt, CN=R11",
+				Serial:           "04A1B2",
+				NotBefore:        time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+				NotAfter:         time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC),
+				DaysToExpiration: 42,
+				KeyType:          "RSA",
+				KeySize:          2048,
+				SignatureAlg:     "sha256WithRSAEncryption",
+				SANs:             []string{"example.com", "www.example.com"},
+				LinkCertkey:      "le-r11",
+				Status:           "Valid",
+			},
+			description: "should parse all known NITRO fields",
+		},
+		{
+			name:        "minimal",
+			raw:         map[string]any{"certkey": "minimal"},
+			want:        &Certificate{Certkey: "minimal"},
+			description: "should accept certificates without optional fields",
+		},
+		{
+			name:        "missing certkey",
+			raw:         map[string]any{"subject": "CN=example.com"},
+			wantErr:     true,
+			description: "should fail without a certkey name",
+		},
+		{
+			name:        "invalid date",
+			raw:         map[string]any{"certkey": "x", "clientcertnotafter": "tomorrow"},
+			wantErr:     true,
+			description: "should fail on unparsable dates",
+		},
+		{
+			name:        "invalid number",
+			raw:         map[string]any{"certkey": "x", "publickeysize": "large"},
+			wantErr:     true,
+			description: "should fail on unparsable numbers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCertificate(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if got.Certkey != tt.want.Certkey || got.Subject != tt.want.Subject || got.Issuer != tt.want.Issuer {
+				t.Errorf("ParseCertificate() names = %+v, want %+v", got, tt.want)
+			}
+			if !got.NotBefore.Equal(tt.want.NotBefore) || !got.NotAfter.Equal(tt.want.NotAfter) {
+				t.Errorf("ParseCertificate() validity = %v - %v, want %v - %v", got.NotBefore, got.NotAfter, tt.want.NotBefore, tt.want.NotAfter)
+			}
+			if got.KeySize != tt.want.KeySize || got.DaysToExpiration != tt.want.DaysToExpiration {
+				t.Errorf("ParseCertificate() numbers = %d/%d, want %d/%d", got.KeySize, got.DaysToExpiration, tt.want.KeySize, tt.want.DaysToExpiration)
+			}
+			if len(got.SANs) != len(tt.want.SANs) {
+				t.Errorf("ParseCertificate() SANs = %v, want %v", got.SANs, tt.want.SANs)
+			}
+		})
+	}
+}
+
+func TestCertificate_Covers(t *testing.T) {
+	cert := &Certificate{SANs: []string{"example.com", "*.example.org"}}
+
+	tests := []struct {
+		hostname string
+		want     bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com.", true},
+		{"www.example.com", false},
+		{"www.example.org", true},
+		{"example.org", false},
+		{"a.b.example.org", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			if got := cert.Covers(tt.hostname); got != tt.want {
+				t.Errorf("Covers(%s) = %v, want %v", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertificate_CommonName(t *testing.T) {
+	cert := &Certificate{Subject: "C=DE, O=Example, CN=www.example.com"}
+	if got := cert.CommonName(); got != "www.example.com" {
+		t.Errorf("CommonName() = %v, want www.example.com", got)
+	}
+
+	cert = &Certificate{Subject: "/C=DE/CN=legacy.example.com"}
+	if got := cert.CommonName(); got != "legacy.example.com" {
+		t.Errorf("CommonName() = %v, want legacy.example.com", got)
+	}
+}
@@ -1,8 +1,11 @@
 package netscaler
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/citrix/adc-nitro-go/service"
 )
@@ -10,13 +13,34 @@ import (
 // NitroClientInterface defines the interface for NitroClient methods we use
 type NitroClientInterface interface {
 	Login() error
+	Logout() error
 	FindAllResources(resourceType string) ([]map[string]any, error)
 	FindResource(resourceType string, name string) (map[string]any, error)
+	FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error)
+	AddResource(resourceType string, name string, resourceStruct any) (string, error)
+	UpdateResource(resourceType string, name string, resourceStruct any) (string, error)
+	ActOnResource(resourceType string, resourceStruct any, action string) error
+	DeleteResource(resourceType string, name string) error
+	DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error
 }
 
 type Client struct {
-	api    NitroClientInterface
-	prefix string
+	api       NitroClientInterface
+	prefix    string
+	partition string
+	recorder  *Recorder
+	// rotation is set for environments deployed with RotateCertificate, see GetCertificate
+	rotation bool
+	// planning is set for clients returned by Planned, their writes are not executed
+	planning bool
+
+	// cluster is set by DetectCluster, nodes holds the sessions to its nodes and cluster IP
+	cluster *Cluster
+	// clusterErr is the error of the cluster detection of NewClient
+	clusterErr error
+	connect    NodeConnector
+	mu         sync.Mutex
+	nodes      map[string]NitroClientInterface
 }
 
 type ClientConfig struct {
@@ -24,28 +48,75 @@ type ClientConfig struct {
 	Username  string
 	Password  string
 	SslVerify bool
-	Headers   map[string]string
+	// Timeout of requests in seconds
+	Timeout int
+	// RootCAPath is a PEM file with the CA certificates used to verify the endpoint
+	RootCAPath string
+	Headers    map[string]string
+	// Record is the path of a fixture file all NITRO calls are recorded to, see Recorder
+	Record string
+	// Partition is the admin partition the client switches to after every login
+	Partition string
+	// Rotation makes GetCertificate return the newest certkey version of a domain
+	Rotation bool
 }
 
 func NewClient(prefix string, config *ClientConfig) (*Client, error) {
-	c := &Client{
-		prefix: prefix,
-	}
-
 	api, err := service.NewNitroClientFromParams(service.NitroParams{
-		Url:       config.Endpoint,
-		Username:  config.Username,
-		Password:  config.Password,
-		SslVerify: config.SslVerify,
-		Headers:   config.Headers,
+		Url:        config.Endpoint,
+		Username:   config.Username,
+		Password:   config.Password,
+		SslVerify:  config.SslVerify,
+		Timeout:    config.Timeout,
+		RootCAPath: config.RootCAPath,
+		Headers:    config.Headers,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	c.api = api
+	var c *Client
+	if config.Record == "" {
+		c, err = NewPartitionClientFromNitro(prefix, config.Partition, api)
+	} else {
+		recorder := NewRecorder(api, config.Record)
+		c, err = NewPartitionClientFromNitro(prefix, config.Partition, recorder)
+		if c != nil {
+			c.recorder = recorder
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.rotation = config.Rotation
 
-	err = c.api.Login()
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// A user without read access to clusternode still works on a standalone appliance
+	if _, err := c.DetectCluster(endpoint.Hostname(), nodeConnector(config)); err != nil {
+		c.clusterErr = fmt.Errorf("cluster detection failed: %w", err)
+	}
+
+	return c, nil
+}
+
+// NewClientFromNitro creates a client using the given NITRO implementation and logs in
+func NewClientFromNitro(prefix string, api NitroClientInterface) (*Client, error) {
+	return NewPartitionClientFromNitro(prefix, "", api)
+}
+
+// NewPartitionClientFromNitro creates a client working in the admin partition and logs in. Expired
+// sessions are renewed, switching to the partition again.
+func NewPartitionClientFromNitro(prefix, partition string, api NitroClientInterface) (*Client, error) {
+	c := &Client{
+		api:       newSession(api, partition),
+		prefix:    prefix,
+		partition: partition,
+	}
+
+	err := c.api.Login()
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +144,57 @@ func (c *Client) GetAllCertificates() ([]map[string]any, error) {
 	return certs, nil
 }
 
+// GetCertificate returns the certkey of a domain. With rotation it returns the newest version, which
+// is also looked up when the unversioned certkey is missing, e.g. after it was retired.
 func (c *Client) GetCertificate(name string) (map[string]any, error) {
-	return c.api.FindResource(service.Sslcertkey.Type(), fmt.Sprintf("%s%s", c.prefix, name))
+	var findErr error
+	if !c.rotation {
+		cert, err := c.api.FindResource(service.Sslcertkey.Type(), fmt.Sprintf("%s%s", c.prefix, name))
+		if err == nil {
+			return cert, nil
+		}
+		// nitro-go reports every failure as a missing resource, the versions query tells them apart
+		findErr = err
+	}
+
+	versions, err := c.certkeyVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		if findErr != nil {
+			return nil, findErr
+		}
+		return nil, fmt.Errorf("certkey %s%s not found", c.prefix, name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Prefix returns the prefix of the certificate names of the environment
+func (c *Client) Prefix() string {
+	return c.prefix
+}
+
+// Partition returns the admin partition of the environment, empty for the default partition
+func (c *Client) Partition() string {
+	return c.partition
+}
+
+// Health checks that the NITRO API is reachable and the session is valid
+func (c *Client) Health() error {
+	if _, err := c.api.FindResource(service.Nsversion.Type(), ""); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
+
+// Close ends the NITRO sessions and saves the recorded fixture in recording mode
+func (c *Client) Close() error {
+	err := errors.Join(c.api.Logout(), c.closeNodes())
+	if c.recorder != nil {
+		if saveErr := c.recorder.Save(); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
 }
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"strings"
 	"testing"
+
+	"github.com/citrix/adc-nitro-go/service"
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
 )
 
 // MockNitroClient is a mock implementation of the NitroClient
@@ -21,6 +24,10 @@ func (m *MockNitroClient) Login() error {
 	return m.loginErr
 }
 
+func (m *MockNitroClient) Logout() error {
+	return nil
+}
+
 func (m *MockNitroClient) FindAllResources(_ string) ([]map[string]any, error) {
 	return m.allCerts, m.findAllErr
 }
@@ -29,6 +36,32 @@ func (m *MockNitroClient) FindResource(_, _ string) (map[string]any, error) {
 	return m.cert, m.findErr
 }
 
+func (m *MockNitroClient) FindResourceArrayWithParams(_ service.FindParams) ([]map[string]any, error) {
+	return m.allCerts, m.findAllErr
+}
+
+func (m *MockNitroClient) AddResource(_, name string, _ any) (string, error) {
+	return name, nil
+}
+
+func (m *MockNitroClient) UpdateResource(_, name string, _ any) (string, error) {
+	return name, nil
+}
+
+func (m *MockNitroClient) ActOnResource(_ string, _ any, _ string) error {
+	return nil
+}
+
+func (m *MockNitroClient) DeleteResource(_, _ string) error {
+	return nil
+}
+
+func (m *MockNitroClient) DeleteResourceWithArgsMap(_, _ string, _ map[string]string) error {
+	return nil
+}
+
+var _ NitroClientInterface = (*netscalertest.NitroClient)(nil)
+
 func TestNewClient(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -326,3 +359,72 @@ func TestClient_GetCertificate_KeyFormat(t *testing.T) {
 		t.Errorf("Certificate key format = %v, want %v", actualKey, expectedKey)
 	}
 }
+
+func TestNewClientFromNitro(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	for _, certkey := range []string{"prod-example.com", "prod-www.example.com", "staging-example.com"} {
+		if err := api.Store.Add("sslcertkey", map[string]any{"certkey": certkey}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	if !api.IsLoggedIn() {
+		t.Error("NewClientFromNitro() should log in")
+	}
+
+	all, err := client.GetAllCertificates()
+	if err != nil {
+		t.Fatalf("GetAllCertificates() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("GetAllCertificates() returned %d certificates, want 2", len(all))
+	}
+
+	if _, err := client.GetCertificate("example.com"); err != nil {
+		t.Errorf("GetCertificate() of an existing certificate error = %v", err)
+	}
+	if _, err := client.GetCertificate("missing.example.com"); err == nil {
+		t.Error("GetCertificate() of a missing certificate should fail")
+	}
+
+	failing := netscalertest.NewNitroClient()
+	failing.FailNext("Login", errors.New("authentication failed"))
+	if _, err := NewClientFromNitro("prod-", failing); err == nil {
+		t.Error("NewClientFromNitro() should fail when login fails")
+	}
+}
+
+func TestClient_HealthAndClose(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	if err := client.Health(); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+
+	api.ExpireSession()
+	api.FailNext("Login", errors.New("authentication failed"))
+	if err := client.Health(); err == nil {
+		t.Error("Health() should fail when the session expired and login fails")
+	}
+	if err := client.Health(); err != nil {
+		t.Errorf("Health() should log in again when the session expired, error = %v", err)
+	}
+	if !api.IsLoggedIn() {
+		t.Error("Health() should have logged in again")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if api.IsLoggedIn() {
+		t.Error("Close() should log out")
+	}
+}
@@ -0,0 +1,312 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// ClusterNode is a node of a NetScaler cluster as reported by clusternode
+type ClusterNode struct {
+	ID        int    `json:"id"`
+	IPAddress string `json:"ipAddress"`
+	// State is the configured state of the node, ACTIVE, PASSIVE or SPARE
+	State string `json:"state,omitempty"`
+	// Health is UP or the reason the node doesn't serve traffic
+	Health string `json:"health,omitempty"`
+	// Coordinator is set for the configuration coordinator, the node owning the cluster IP
+	Coordinator bool `json:"coordinator,omitempty"`
+}
+
+// Cluster describes the cluster an appliance is a node of
+type Cluster struct {
+	// CLIP is the cluster IP, configuration changes are only accepted there
+	CLIP  string        `json:"clip"`
+	Nodes []ClusterNode `json:"nodes"`
+}
+
+// NodeConnector opens a NITRO client to another address of the appliance, a cluster node or the
+// cluster IP, with the credentials of the client. The client is not logged in yet.
+type NodeConnector func(address string) (NitroClientInterface, error)
+
+// NodeCertificate is the certkey of a domain as found on one node of a cluster
+type NodeCertificate struct {
+	Node      int    `json:"node"`
+	IPAddress string `json:"ipAddress"`
+	Serial    string `json:"serial,omitempty"`
+	Status    string `json:"status,omitempty"`
+	// InSync is set when the node has the certkey with the serial reported by the cluster IP
+	InSync bool   `json:"inSync"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ClusterSource is implemented by certificate sources that can query every node of a cluster
+type ClusterSource interface {
+	// NodeCertificates returns the certkey as found on every node, nil for standalone appliances
+	NodeCertificates(certkey string) ([]NodeCertificate, error)
+	// ClusterError returns why the cluster could not be detected, nil if it was or there is none
+	ClusterError() error
+}
+
+// lookupHost resolves the hostname of the endpoint, replaced in tests
+var lookupHost = net.LookupHost
+
+var _ ClusterSource = (*Client)(nil)
+
+// NodeEndpoint returns the NITRO endpoint of another address of the appliance, with the scheme
+// and port of the endpoint
+func NodeEndpoint(endpoint, address string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(address, port)
+	} else if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		u.Host = "[" + address + "]"
+	} else {
+		u.Host = address
+	}
+	return u.String(), nil
+}
+
+// nodeConnector connects to other addresses of the appliance with the settings of the client config
+func nodeConnector(config *ClientConfig) NodeConnector {
+	return func(address string) (NitroClientInterface, error) {
+		endpoint, err := NodeEndpoint(config.Endpoint, address)
+		if err != nil {
+			return nil, err
+		}
+		return service.NewNitroClientFromParams(service.NitroParams{
+			Url:        endpoint,
+			Username:   config.Username,
+			Password:   config.Password,
+			SslVerify:  config.SslVerify,
+			Timeout:    config.Timeout,
+			RootCAPath: config.RootCAPath,
+			Headers:    config.Headers,
+		})
+	}
+}
+
+// Cluster returns the cluster found by DetectCluster, nil for standalone appliances
+func (c *Client) Cluster() *Cluster {
+	return c.cluster
+}
+
+// ClusterError returns the error of the cluster detection of NewClient. The client then works like
+// on a standalone appliance and sends writes to the endpoint.
+func (c *Client) ClusterError() error {
+	return c.clusterErr
+}
+
+// DetectCluster checks whether the appliance at the address the client is connected to is a
+// cluster node. Unless the address is or resolves to the cluster IP, writes are sent to the cluster
+// IP from then on. The connector is also used by NodeCertificates to query the nodes.
+func (c *Client) DetectCluster(address string, connect NodeConnector) (*Cluster, error) {
+	// FindAllResources reports failed requests as an empty list, a node would look standalone
+	raw, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType:             service.Clusternode.Type(),
+		ResourceMissingErrorCode: errCodeNoSuchResource,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve cluster nodes: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	cluster := &Cluster{Nodes: make([]ClusterNode, 0, len(raw))}
+	for _, r := range raw {
+		id, err := intField(r, "nodeid")
+		if err != nil {
+			return nil, err
+		}
+		cluster.Nodes = append(cluster.Nodes, ClusterNode{
+			ID:          id,
+			IPAddress:   stringField(r, "ipaddress"),
+			State:       stringField(r, "state"),
+			Health:      stringField(r, "health"),
+			Coordinator: fmt.Sprint(r["isconfigurationcoordinator"]) == "true",
+		})
+	}
+	sort.Slice(cluster.Nodes, func(i, j int) bool {
+		return cluster.Nodes[i].ID < cluster.Nodes[j].ID
+	})
+
+	clips, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: service.Nsip.Type(),
+		FilterMap:    map[string]string{"type": "CLIP"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the cluster IP: %w", err)
+	}
+	if len(clips) == 0 {
+		return nil, errors.New("the appliance is a cluster node, but has no cluster IP")
+	}
+	cluster.CLIP = stringField(clips[0], "ipaddress")
+
+	// The session to the endpoint is reused when its hostname points at the cluster IP
+	isCLIP, err := resolvesTo(address, cluster.CLIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", address, err)
+	}
+
+	c.cluster = cluster
+	c.connect = connect
+	if !isCLIP {
+		clip, err := c.node(cluster.CLIP)
+		if err != nil {
+			c.cluster = nil
+			return nil, fmt.Errorf("cluster IP %s: %w", cluster.CLIP, err)
+		}
+		c.api = &clusterRouter{NitroClientInterface: c.api, clip: clip}
+	}
+	return cluster, nil
+}
+
+// resolvesTo reports whether the host, an IP address or a hostname, is or resolves to the IP address
+func resolvesTo(host, ip string) (bool, error) {
+	want := net.ParseIP(ip)
+	if addr := net.ParseIP(host); addr != nil {
+		return addr.Equal(want), nil
+	}
+	addrs, err := lookupHost(host)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		if net.ParseIP(addr).Equal(want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NodeCertificates compares the certkey of every cluster node with the certkey of the cluster IP,
+// to find nodes that didn't receive a certificate. Nodes that can't be queried are reported with
+// an error.
+func (c *Client) NodeCertificates(certkey string) ([]NodeCertificate, error) {
+	if c.cluster == nil {
+		return nil, c.clusterErr
+	}
+	expected, err := c.api.FindResource(service.Sslcertkey.Type(), certkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve certificate %s: %w", certkey, err)
+	}
+	serial := stringField(expected, "serial")
+
+	results := make([]NodeCertificate, 0, len(c.cluster.Nodes))
+	for _, n := range c.cluster.Nodes {
+		result := NodeCertificate{Node: n.ID, IPAddress: n.IPAddress}
+		if raw, err := c.nodeCertkey(n.IPAddress, certkey); err != nil {
+			result.Error = err.Error()
+		} else if raw == nil {
+			result.Error = fmt.Sprintf("certkey %s not found", certkey)
+		} else {
+			result.Serial = stringField(raw, "serial")
+			result.Status = stringField(raw, "status")
+			result.InSync = result.Serial == serial
+			if !result.InSync {
+				result.Error = "serial differs from the cluster IP"
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// nodeCertkey looks up a certkey on a node, nil if the node doesn't have it
+func (c *Client) nodeCertkey(address, certkey string) (map[string]any, error) {
+	api, err := c.node(address)
+	if err != nil {
+		return nil, err
+	}
+	res, err := api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType:             service.Sslcertkey.Type(),
+		ResourceName:             certkey,
+		ResourceMissingErrorCode: errCodeNoSuchResource,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
+
+// node returns the logged in session to an address of the cluster, connecting on first use
+func (c *Client) node(address string) (NitroClientInterface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if api, ok := c.nodes[address]; ok {
+		return api, nil
+	}
+	if c.connect == nil {
+		return nil, errors.New("no connector to the cluster nodes")
+	}
+
+	api, err := c.connect(address)
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(api, c.partition)
+	if err := s.Login(); err != nil {
+		return nil, err
+	}
+	if c.nodes == nil {
+		c.nodes = make(map[string]NitroClientInterface)
+	}
+	c.nodes[address] = s
+	return s, nil
+}
+
+// closeNodes logs out of the sessions opened by node
+func (c *Client) closeNodes() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for address, api := range c.nodes {
+		if err := api.Logout(); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", address, err))
+		}
+	}
+	c.nodes = nil
+	return errors.Join(errs...)
+}
+
+// clusterRouter sends reads to the node the client is connected to and writes to the cluster IP
+type clusterRouter struct {
+	NitroClientInterface
+	clip NitroClientInterface
+}
+
+// AddResource creates a resource on the cluster IP
+func (r *clusterRouter) AddResource(resourceType string, name string, resourceStruct any) (string, error) {
+	return r.clip.AddResource(resourceType, name, resourceStruct)
+}
+
+// UpdateResource updates a resource on the cluster IP
+func (r *clusterRouter) UpdateResource(resourceType string, name string, resourceStruct any) (string, error) {
+	return r.clip.UpdateResource(resourceType, name, resourceStruct)
+}
+
+// ActOnResource applies an action on the cluster IP
+func (r *clusterRouter) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	return r.clip.ActOnResource(resourceType, resourceStruct, action)
+}
+
+// DeleteResource removes a resource on the cluster IP
+func (r *clusterRouter) DeleteResource(resourceType string, name string) error {
+	return r.clip.DeleteResource(resourceType, name)
+}
+
+// DeleteResourceWithArgsMap removes a resource or bindings on the cluster IP
+func (r *clusterRouter) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	return r.clip.DeleteResourceWithArgsMap(resourceType, name, args)
+}
@@ -0,0 +1,286 @@
+package netscaler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// clusterConnector connects to the cluster IP and the nodes of the cluster
+func clusterConnector(cluster *netscalertest.Cluster) NodeConnector {
+	return func(address string) (NitroClientInterface, error) {
+		api, err := cluster.Client(address)
+		if err != nil {
+			return nil, err
+		}
+		return api, nil
+	}
+}
+
+// newTestCluster returns a cluster of two nodes and a client connected to the given address
+func newTestCluster(t *testing.T, address string) (*netscalertest.Cluster, *Client) {
+	t.Helper()
+	cluster := netscalertest.NewCluster("192.0.2.10", "192.0.2.11", "192.0.2.12")
+	api, err := cluster.Client(address)
+	if err != nil {
+		t.Fatalf("Cluster.Client() error = %v", err)
+	}
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	return cluster, client
+}
+
+func TestClient_DetectCluster(t *testing.T) {
+	lookupHost = func(host string) ([]string, error) {
+		if host != "clip.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"2001:db8::10", "192.0.2.10"}, nil
+	}
+	t.Cleanup(func() { lookupHost = net.LookupHost })
+
+	tests := []struct {
+		name    string
+		address string
+		// host is the hostname of the endpoint, the address by default
+		host        string
+		wantWrites  string
+		wantConnect []string
+		description string
+	}{
+		{
+			name:        "cluster IP",
+			address:     "192.0.2.10",
+			wantWrites:  "192.0.2.10",
+			description: "a client connected to the cluster IP should write there",
+		},
+		{
+			name:        "cluster IP hostname",
+			address:     "192.0.2.10",
+			host:        "clip.example.com",
+			wantWrites:  "192.0.2.10",
+			description: "a hostname resolving to the cluster IP should reuse the session of the endpoint",
+		},
+		{
+			name:        "node",
+			address:     "192.0.2.12",
+			wantWrites:  "192.0.2.10",
+			wantConnect: []string{"192.0.2.10"},
+			description: "writes of a client connected to a node should be routed to the cluster IP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, client := newTestCluster(t, tt.address)
+			host := tt.host
+			if host == "" {
+				host = tt.address
+			}
+			var connected []string
+			connect := func(address string) (NitroClientInterface, error) {
+				connected = append(connected, address)
+				return clusterConnector(cluster)(address)
+			}
+
+			got, err := client.DetectCluster(host, connect)
+			if err != nil {
+				t.Fatalf("DetectCluster() error = %v", err)
+			}
+			if !reflect.DeepEqual(connected, tt.wantConnect) {
+				t.Errorf("DetectCluster() connected to %v, want %v", connected, tt.wantConnect)
+			}
+			want := &Cluster{CLIP: "192.0.2.10", Nodes: []ClusterNode{
+				{ID: 0, IPAddress: "192.0.2.11", State: "ACTIVE", Health: "UP", Coordinator: true},
+				{ID: 1, IPAddress: "192.0.2.12", State: "ACTIVE", Health: "UP"},
+			}}
+			if !reflect.DeepEqual(got, want) || client.Cluster() != got {
+				t.Errorf("DetectCluster() = %+v, want %+v", got, want)
+			}
+
+			if err := client.SaveConfig(); err != nil {
+				t.Fatalf("SaveConfig() error = %v", err)
+			}
+			for _, address := range []string{"192.0.2.10", "192.0.2.11", "192.0.2.12"} {
+				want := 0
+				if address == tt.wantWrites {
+					want = 1
+				}
+				if saves := cluster.Store(address).Saves(); saves != want {
+					t.Errorf("saves on %s = %d, want %d", address, saves, want)
+				}
+			}
+			if _, err := client.GetAllCertificates(); err != nil {
+				t.Errorf("GetAllCertificates() error = %v", err)
+			}
+
+			if err := client.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			for _, address := range []string{"192.0.2.10", tt.address} {
+				if api, _ := cluster.Client(address); api.IsLoggedIn() {
+					t.Errorf("Close() should log out of %s", address)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_DetectCluster_Standalone(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	cluster, err := client.DetectCluster("192.0.2.1", nil)
+	if err != nil || cluster != nil || client.Cluster() != nil {
+		t.Fatalf("DetectCluster() = %+v, %v, want no cluster", cluster, err)
+	}
+	if nodes, err := client.NodeCertificates("prod-example.com"); nodes != nil || err != nil {
+		t.Errorf("NodeCertificates() = %v, %v, want nil for a standalone appliance", nodes, err)
+	}
+
+	// a cluster node without cluster IP can't be configured
+	_ = api.Store.Add("clusternode", map[string]any{"nodeid": 0, "ipaddress": "192.0.2.1"})
+	if _, err := client.DetectCluster("192.0.2.1", nil); err == nil || !strings.Contains(err.Error(), "no cluster IP") {
+		t.Errorf("DetectCluster() error = %v, want no cluster IP", err)
+	}
+}
+
+func TestNewClient_ClusterDetectionFails(t *testing.T) {
+	tests := []struct {
+		name        string
+		resource    string
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "cluster nodes",
+			resource:    "clusternode",
+			wantErr:     "cluster nodes",
+			description: "a failed clusternode query should not make a node look standalone",
+		},
+		{
+			name:        "cluster IP",
+			resource:    "nsip",
+			wantErr:     "cluster IP",
+			description: "a user that may not read nsip should still be able to use the appliance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := netscalertest.NewServer()
+			defer srv.Close()
+			_ = srv.Store.Add("clusternode", map[string]any{"nodeid": 0, "ipaddress": "192.0.2.11"})
+			_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com"})
+			srv.FailNext(tt.resource, &netscalertest.NitroError{Status: http.StatusForbidden, Code: 1, Message: "Not authorized to execute this command"})
+
+			client, err := NewClient("prod-", &ClientConfig{Endpoint: srv.URL, Username: srv.Username, Password: srv.Password})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v, a failed cluster detection should not be fatal", err)
+			}
+			defer func() { _ = client.Close() }()
+
+			if err := client.ClusterError(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ClusterError() = %v, want the failed %s query", err, tt.resource)
+			}
+			if client.Cluster() != nil {
+				t.Errorf("Cluster() = %+v, want none", client.Cluster())
+			}
+			if _, err := client.GetCertificate("example.com"); err != nil {
+				t.Errorf("GetCertificate() error = %v", err)
+			}
+			if _, err := client.NodeCertificates("prod-example.com"); err == nil {
+				t.Error("NodeCertificates() should report the failed cluster detection")
+			}
+		})
+	}
+}
+
+func TestClient_NodeCertificates(t *testing.T) {
+	cluster := netscalertest.NewCluster("192.0.2.10", "192.0.2.11", "192.0.2.12", "192.0.2.13", "192.0.2.14")
+	// the second node missed the certificate, the third one still has the previous one
+	for address, serial := range map[string]string{"192.0.2.10": "01", "192.0.2.11": "01", "192.0.2.13": "02", "192.0.2.14": "01"} {
+		if err := cluster.Store(address).Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "serial": serial, "status": "Valid"}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	api, _ := cluster.Client("192.0.2.10")
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	// the last node is unreachable
+	connect := clusterConnector(cluster)
+	if _, err := client.DetectCluster("192.0.2.10", func(address string) (NitroClientInterface, error) {
+		if address == "192.0.2.14" {
+			return nil, errors.New("connection refused")
+		}
+		return connect(address)
+	}); err != nil {
+		t.Fatalf("DetectCluster() error = %v", err)
+	}
+
+	got, err := client.NodeCertificates("prod-example.com")
+	if err != nil {
+		t.Fatalf("NodeCertificates() error = %v", err)
+	}
+	want := []NodeCertificate{
+		{Node: 0, IPAddress: "192.0.2.11", Serial: "01", Status: "Valid", InSync: true},
+		{Node: 1, IPAddress: "192.0.2.12", Error: "certkey prod-example.com not found"},
+		{Node: 2, IPAddress: "192.0.2.13", Serial: "02", Status: "Valid", Error: "serial differs from the cluster IP"},
+		{Node: 3, IPAddress: "192.0.2.14", Error: "connection refused"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeCertificates() = %+v, want %+v", got, want)
+	}
+
+	if _, err := client.NodeCertificates("prod-missing.com"); err == nil {
+		t.Error("NodeCertificates() of a certkey missing on the cluster IP should fail")
+	}
+}
+
+func TestNodeEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    string
+		address     string
+		want        string
+		description string
+	}{
+		{
+			name:        "port",
+			endpoint:    "https://netscaler.example.com:8443/",
+			address:     "192.0.2.11",
+			want:        "https://192.0.2.11:8443/",
+			description: "the port and path of the endpoint should be kept",
+		},
+		{
+			name:        "default port",
+			endpoint:    "https://netscaler.example.com",
+			address:     "192.0.2.11",
+			want:        "https://192.0.2.11",
+			description: "without port only the host should be replaced",
+		},
+		{
+			name:        "ipv6",
+			endpoint:    "https://netscaler.example.com",
+			address:     "2001:db8::11",
+			want:        "https://[2001:db8::11]",
+			description: "IPv6 addresses should be bracketed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NodeEndpoint(tt.endpoint, tt.address)
+			if err != nil || got != tt.want {
+				t.Errorf("NodeEndpoint() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
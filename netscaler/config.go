@@ -1,6 +1,21 @@
 package netscaler
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Backends of an environment
+const (
+	// BackendNITRO connects to the NITRO API of the appliance at Endpoint
+	BackendNITRO = "nitro"
+	// BackendADM sends the NITRO calls through NetScaler Console (ADM) at Endpoint to a managed instance
+	BackendADM = "adm"
+)
 
 type Config struct {
 	Prefix    string `json:"prefix,omitempty"`
@@ -8,20 +23,175 @@ type Config struct {
 	Username  string `json:"username,omitempty"`
 	Password  string `json:"password,omitempty"`
 	SslVerify bool   `json:"sslVerify,omitempty"`
+	// Backend is nitro (default) or adm
+	Backend string `json:"backend,omitempty"`
+	// ManagedInstance is the IP address of the instance ADM proxies the NITRO calls to
+	ManagedInstance string `json:"managedInstance,omitempty"`
+	// InstanceGroup expands the environment to one environment per instance of the ADM instance group
+	InstanceGroup string `json:"instanceGroup,omitempty"`
+	// Partition is the admin partition of the environment, several environments can share an appliance this way
+	Partition string `json:"partition,omitempty"`
+	// Timeout of NITRO API requests in seconds, 0 uses the default of the NITRO client
+	Timeout int `json:"timeout,omitempty"`
+	// RootCAPath is a PEM file with the CA certificates used to verify the endpoint
+	RootCAPath string  `json:"rootCaPath,omitempty"`
+	Policy     *Policy `json:"policy,omitempty"`
+	// Inventory is the path of an inventory snapshot used instead of the NITRO API
+	Inventory string `json:"inventory,omitempty"`
+	// Record is the path of a fixture file the NITRO calls of the environment are recorded to
+	Record string `json:"record,omitempty"`
+	// IncludeDomains limits the environment to domains matching one of the patterns
+	IncludeDomains []string `json:"includeDomains,omitempty"`
+	// ExcludeDomains lists patterns of domains the environment is not responsible for
+	ExcludeDomains []string `json:"excludeDomains,omitempty"`
+	// DiscoverHostnames adds the hostnames routed to the appliance but not covered by a certificate to the metadata
+	DiscoverHostnames bool `json:"discoverHostnames,omitempty"`
+	// SaveConfig saves the running configuration after commands changed the appliance
+	SaveConfig bool `json:"saveConfig,omitempty"`
+	// HASyncFiles synchronizes the FileLocation to the HA secondary after commands changed the appliance
+	HASyncFiles bool `json:"haSyncFiles,omitempty"`
+	// HAPeerEndpoint is the NITRO endpoint of the HA secondary, by default its NSIP with the scheme and port of Endpoint
+	HAPeerEndpoint string `json:"haPeerEndpoint,omitempty"`
+	// ClusterNodeQueries looks up the certificates on every node of a cluster and reports the nodes in the metadata
+	ClusterNodeQueries bool `json:"clusterNodeQueries,omitempty"`
+	// Rotation reports the newest certkey version created by deploy -strategy rotate in the metadata
+	Rotation bool `json:"rotation,omitempty"`
 }
 
+// NewConfig decodes a raw environment config. Unknown fields are rejected, every unknown field is reported.
 func NewConfig(v any) (*Config, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
 		return nil, err
 	}
 
+	var raw map[string]any
+	if json.Unmarshal(b, &raw) == nil {
+		if errs := unknownFields(raw, reflect.TypeOf(Config{}), ""); len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+	}
+
 	var c = &Config{}
-	err = json.Unmarshal(b, c)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(c)
 
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return nil, &FieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value)}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.validateDomainPatterns(); err != nil {
+		return nil, err
+	}
+	if c.Policy != nil {
+		if err := c.Policy.validateSeverities(); err != nil {
+			return nil, err
+		}
+	}
+
 	return c, nil
 }
+
+// FieldError is a config value that failed validation
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field '%s' %s", e.Field, e.Message)
+}
+
+// Validate checks that all fields required to reach the environment are set and valid.
+// An inventory snapshot replaces the connection settings. All problems are reported at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Inventory == "" {
+		if c.Endpoint == "" {
+			errs = append(errs, &FieldError{Field: "endpoint", Message: "is required"})
+		} else if err := validateEndpoint(c.Endpoint); err != nil {
+			errs = append(errs, err)
+		}
+		if c.Username == "" {
+			errs = append(errs, &FieldError{Field: "username", Message: "is required"})
+		}
+		if c.Password == "" {
+			errs = append(errs, &FieldError{Field: "password", Message: "is required"})
+		}
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, &FieldError{Field: "timeout", Message: "must not be negative"})
+	}
+	if c.HAPeerEndpoint != "" {
+		var fieldErr *FieldError
+		if err := validateEndpoint(c.HAPeerEndpoint); errors.As(err, &fieldErr) {
+			errs = append(errs, &FieldError{Field: "haPeerEndpoint", Message: fieldErr.Message})
+		}
+	}
+	errs = append(errs, c.validateBackend()...)
+	return errors.Join(errs...)
+}
+
+// validateBackend checks the backend and the settings depending on it. Partitions, HA peer
+// endpoints and cluster node queries need a direct connection to the appliance.
+func (c *Config) validateBackend() []error {
+	var errs []error
+	switch c.Backend {
+	case "", BackendNITRO:
+		for field, value := range map[string]string{"managedInstance": c.ManagedInstance, "instanceGroup": c.InstanceGroup} {
+			if value != "" {
+				errs = append(errs, &FieldError{Field: field, Message: "requires backend adm"})
+			}
+		}
+	case BackendADM:
+		if c.ManagedInstance == "" && c.InstanceGroup == "" {
+			errs = append(errs, &FieldError{Field: "managedInstance", Message: "or instanceGroup is required with backend adm"})
+		}
+		if c.ManagedInstance != "" && c.InstanceGroup != "" {
+			errs = append(errs, &FieldError{Field: "instanceGroup", Message: "can't be combined with managedInstance"})
+		}
+		if c.Partition != "" && c.Partition != DefaultPartition {
+			errs = append(errs, &FieldError{Field: "partition", Message: "is not supported with backend adm"})
+		}
+		if c.HAPeerEndpoint != "" {
+			errs = append(errs, &FieldError{Field: "haPeerEndpoint", Message: "is not supported with backend adm"})
+		}
+		if c.ClusterNodeQueries {
+			errs = append(errs, &FieldError{Field: "clusterNodeQueries", Message: "is not supported with backend adm"})
+		}
+	default:
+		errs = append(errs, &FieldError{Field: "backend", Message: fmt.Sprintf("must be nitro or adm, got '%s'", c.Backend)})
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// ADM reports whether the NITRO calls of the environment are sent through NetScaler Console
+func (c *Config) ADM() bool {
+	return c.Backend == BackendADM
+}
+
+// ClientConfig returns the connection settings for a Client
+func (c *Config) ClientConfig() *ClientConfig {
+	config := &ClientConfig{
+		Endpoint:   c.Endpoint,
+		Username:   c.Username,
+		Password:   c.Password,
+		SslVerify:  c.SslVerify,
+		Timeout:    c.Timeout,
+		RootCAPath: c.RootCAPath,
+		Headers:    make(map[string]string),
+		Record:     c.Record,
+		Partition:  c.Partition,
+		Rotation:   c.Rotation,
+	}
+	if c.ADM() {
+		config.Headers[ADMProxyHeader] = c.ManagedInstance
+	}
+	return config
+}
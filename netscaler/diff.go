@@ -0,0 +1,104 @@
+package netscaler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangeType describes how a certificate changed between two inventory snapshots
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeRenewed ChangeType = "renewed"
+	ChangeRebound ChangeType = "rebound"
+)
+
+// Change is a difference of a certificate between two inventory snapshots
+type Change struct {
+	Environment string     `json:"environment"`
+	Certkey     string     `json:"certkey"`
+	Type        ChangeType `json:"type"`
+	Before      string     `json:"before,omitempty"`
+	After       string     `json:"after,omitempty"`
+}
+
+// DiffInventories lists the certificates added, removed, renewed or rebound from before to after,
+// sorted by environment and certkey. A certificate is renewed when its serial or expiry changed and
+// rebound when the set of vservers, services and service groups it is bound to changed.
+func DiffInventories(before, after *Inventory) ([]Change, error) {
+	envs := make(map[string]bool)
+	for env := range before.Environments {
+		envs[env] = true
+	}
+	for env := range after.Environments {
+		envs[env] = true
+	}
+
+	changes := []Change{}
+	for env := range envs {
+		old, err := before.Environments[env].CertificateDetails()
+		if err != nil {
+			return nil, fmt.Errorf("environment %s: %w", env, err)
+		}
+		cur, err := after.Environments[env].CertificateDetails()
+		if err != nil {
+			return nil, fmt.Errorf("environment %s: %w", env, err)
+		}
+
+		for certkey, o := range old {
+			c, ok := cur[certkey]
+			if !ok {
+				changes = append(changes, Change{Environment: env, Certkey: certkey, Type: ChangeRemoved, Before: describeVersion(o)})
+				continue
+			}
+			if o.Serial != c.Serial || !o.NotAfter.Equal(c.NotAfter) {
+				changes = append(changes, Change{Environment: env, Certkey: certkey, Type: ChangeRenewed, Before: describeVersion(o), After: describeVersion(c)})
+			}
+			if !slices.Equal(o.Bindings, c.Bindings) {
+				changes = append(changes, Change{Environment: env, Certkey: certkey, Type: ChangeRebound, Before: describeBindings(o.Bindings), After: describeBindings(c.Bindings)})
+			}
+		}
+		for certkey, c := range cur {
+			if _, ok := old[certkey]; !ok {
+				changes = append(changes, Change{Environment: env, Certkey: certkey, Type: ChangeAdded, After: describeVersion(c)})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Environment != changes[j].Environment {
+			return changes[i].Environment < changes[j].Environment
+		}
+		if changes[i].Certkey != changes[j].Certkey {
+			return changes[i].Certkey < changes[j].Certkey
+		}
+		return changes[i].Type < changes[j].Type
+	})
+
+	return changes, nil
+}
+
+// describeVersion identifies a certificate by serial and expiry
+func describeVersion(d CertificateDetails) string {
+	parts := []string{}
+	if d.Serial != "" {
+		parts = append(parts, "serial "+d.Serial)
+	}
+	if !d.NotAfter.IsZero() {
+		parts = append(parts, "expires "+d.NotAfter.Format(time.DateOnly))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeBindings(bindings []Binding) string {
+	names := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		names = append(names, b.Type+"/"+b.Name)
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,103 @@
+package netscaler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffInventories(t *testing.T) {
+	expiry := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	details := func(certkey, serial string, notAfter time.Time, bindings ...Binding) CertificateDetails {
+		if bindings == nil {
+			bindings = []Binding{}
+		}
+		return CertificateDetails{
+			Certificate: &Certificate{Certkey: certkey, Serial: serial, NotAfter: notAfter},
+			Bindings:    bindings,
+			Chain:       []string{},
+		}
+	}
+	environment := func(certs ...CertificateDetails) InventoryEnvironment {
+		env := InventoryEnvironment{Details: map[string]CertificateDetails{}}
+		for _, d := range certs {
+			env.Certificates = append(env.Certificates, map[string]any{"certkey": d.Certkey})
+			env.Details[d.Certkey] = d
+		}
+		return env
+	}
+	web := Binding{Type: "sslvserver", Name: "vs-web"}
+	api := Binding{Type: "sslvserver", Name: "vs-api"}
+
+	before := &Inventory{Environments: map[string]InventoryEnvironment{
+		"prod": environment(
+			details("prod-example.com", "01", expiry, web),
+			details("prod-api.example.com", "02", expiry, api),
+			details("prod-old.example.com", "03", expiry),
+			details("prod-same.example.com", "04", expiry, web),
+		),
+		"dev": environment(details("dev-example.com", "05", expiry)),
+	}}
+	after := &Inventory{Environments: map[string]InventoryEnvironment{
+		"prod": environment(
+			details("prod-example.com", "11", expiry.AddDate(0, 3, 0), web),
+			details("prod-api.example.com", "02", expiry, api, web),
+			details("prod-new.example.com", "06", expiry),
+			details("prod-same.example.com", "04", expiry, web),
+		),
+	}}
+
+	changes, err := DiffInventories(before, after)
+	if err != nil {
+		t.Fatalf("DiffInventories() error = %v", err)
+	}
+
+	want := []struct {
+		environment string
+		certkey     string
+		change      ChangeType
+	}{
+		{"dev", "dev-example.com", ChangeRemoved},
+		{"prod", "prod-api.example.com", ChangeRebound},
+		{"prod", "prod-example.com", ChangeRenewed},
+		{"prod", "prod-new.example.com", ChangeAdded},
+		{"prod", "prod-old.example.com", ChangeRemoved},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("DiffInventories() = %+v, want %d changes", changes, len(want))
+	}
+	for i, w := range want {
+		if changes[i].Environment != w.environment || changes[i].Certkey != w.certkey || changes[i].Type != w.change {
+			t.Errorf("change %d = %+v, want %s %s %s", i, changes[i], w.environment, w.certkey, w.change)
+		}
+	}
+	if changes[2].Before != "serial 01, expires 2026-12-01" || changes[2].After != "serial 11, expires 2027-03-01" {
+		t.Errorf("renewed change = %+v", changes[2])
+	}
+	if changes[1].After != "sslvserver/vs-api sslvserver/vs-web" {
+		t.Errorf("rebound change = %+v", changes[1])
+	}
+}
+
+func TestDiffInventories_WithoutDetails(t *testing.T) {
+	before := &Inventory{Environments: map[string]InventoryEnvironment{
+		"prod": {Certificates: []map[string]any{{"certkey": "prod-example.com", "serial": "01"}}},
+	}}
+	after := &Inventory{Environments: map[string]InventoryEnvironment{
+		"prod": {Certificates: []map[string]any{{"certkey": "prod-example.com", "serial": "02"}}},
+	}}
+
+	changes, err := DiffInventories(before, after)
+	if err != nil {
+		t.Fatalf("DiffInventories() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Type != ChangeRenewed {
+		t.Errorf("DiffInventories() = %+v, want one renewal", changes)
+	}
+
+	invalid := &Inventory{Environments: map[string]InventoryEnvironment{
+		"prod": {Certificates: []map[string]any{{"certkey": 1}}},
+	}}
+	if _, err := DiffInventories(before, invalid); err == nil {
+		t.Error("DiffInventories() should fail on unparsable certificates")
+	}
+}
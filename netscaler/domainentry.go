@@ -0,0 +1,127 @@
+package netscaler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// hostnamePattern matches DNS names including a leading wildcard label
+var hostnamePattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// DomainEntry is a dehydrated-api domain entry as accepted by its REST API and written to domains.txt
+type DomainEntry struct {
+	Domain           string   `json:"domain"`
+	AlternativeNames []string `json:"alternative_names,omitempty"`
+	Alias            string   `json:"alias,omitempty"`
+	Enabled          bool     `json:"enabled"`
+	Comment          string   `json:"comment,omitempty"`
+}
+
+// Name returns the name the certificate of the entry is looked up by, the alias if set and the domain otherwise
+func (e *DomainEntry) Name() string {
+	if e.Alias != "" {
+		return e.Alias
+	}
+	return e.Domain
+}
+
+// NewDomainEntry derives a domain entry from a certificate. The CN becomes the domain, or the first SAN
+// when the CN is not a hostname, the other SANs the alternative names. The certkey without prefix and version becomes
+// the alias when it differs from the domain, so the plugin finds the certificate again.
+func NewDomainEntry(cert *Certificate, prefix string) (*DomainEntry, error) {
+	var names []string
+	for _, name := range append([]string{cert.CommonName()}, cert.SANs...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if hostnamePattern.MatchString(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("certificate %s has no hostname in its subject or SANs", cert.Certkey)
+	}
+
+	entry := &DomainEntry{
+		Domain:           names[0],
+		AlternativeNames: names[1:],
+		Enabled:          true,
+	}
+	if name, _ := CertkeyDomain(prefix, cert.Certkey); name != entry.Domain {
+		entry.Alias = name
+	}
+	return entry, nil
+}
+
+// ReadDomainEntries reads domain entries from a JSON list or a dehydrated domains.txt file
+func ReadDomainEntries(r io.Reader) ([]DomainEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domain entries: %w", err)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []DomainEntry
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse domain entries: %w", err)
+		}
+		return entries, nil
+	}
+
+	entries := []DomainEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		enabled := !strings.HasPrefix(line, "#")
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+		var comment string
+		line, comment, _ = strings.Cut(line, "#")
+		main, alias, _ := strings.Cut(line, ">")
+		fields := strings.Fields(main)
+		if len(fields) == 0 {
+			continue
+		}
+		// a disabled line that doesn't start with a hostname is a plain comment
+		if !enabled && !hostnamePattern.MatchString(strings.ToLower(fields[0])) {
+			continue
+		}
+
+		entries = append(entries, DomainEntry{
+			Domain:           fields[0],
+			AlternativeNames: fields[1:],
+			Alias:            strings.TrimSpace(alias),
+			Enabled:          enabled,
+			Comment:          strings.TrimSpace(comment),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read domains file: %w", err)
+	}
+	return entries, nil
+}
+
+// WriteDomainsTxt writes domain entries in the dehydrated domains.txt format
+func WriteDomainsTxt(w io.Writer, entries []DomainEntry) error {
+	for _, e := range entries {
+		var line strings.Builder
+		if !e.Enabled {
+			line.WriteString("# ")
+		}
+		line.WriteString(strings.Join(append([]string{e.Domain}, e.AlternativeNames...), " "))
+		if e.Alias != "" {
+			line.WriteString(" > " + e.Alias)
+		}
+		if e.Comment != "" {
+			line.WriteString(" # " + e.Comment)
+		}
+		if _, err := fmt.Fprintln(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
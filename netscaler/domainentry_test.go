@@ -0,0 +1,138 @@
+package netscaler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewDomainEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		cert        *Certificate
+		prefix      string
+		want        *DomainEntry
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "CN and SANs",
+			cert:        &Certificate{Certkey: "prod-example.com", Subject: "CN=Example.com", SANs: []string{"example.com", "www.example.com"}},
+			prefix:      "prod-",
+			want:        &DomainEntry{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Enabled: true},
+			description: "should use the CN as domain and the other SANs as alternative names",
+		},
+		{
+			name:        "alias",
+			cert:        &Certificate{Certkey: "prod-web", Subject: "CN=example.com"},
+			prefix:      "prod-",
+			want:        &DomainEntry{Domain: "example.com", AlternativeNames: []string{}, Alias: "web", Enabled: true},
+			description: "should keep the certkey as alias when it differs from the domain",
+		},
+		{
+			name:        "rotated",
+			cert:        &Certificate{Certkey: "prod-example.com-20261001120000", Subject: "CN=example.com"},
+			prefix:      "prod-",
+			want:        &DomainEntry{Domain: "example.com", AlternativeNames: []string{}, Enabled: true},
+			description: "should ignore the version suffix of rotated certkeys",
+		},
+		{
+			name:        "CN without hostname",
+			cert:        &Certificate{Certkey: "web", Subject: "O=Example, CN=Example Web", SANs: []string{"*.example.com"}},
+			want:        &DomainEntry{Domain: "*.example.com", AlternativeNames: []string{}, Alias: "web", Enabled: true},
+			description: "should fall back to the first SAN",
+		},
+		{
+			name:        "no hostname",
+			cert:        &Certificate{Certkey: "ca", Subject: "CN=Example CA"},
+			wantErr:     true,
+			description: "should reject certificates without hostnames",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDomainEntry(tt.cert, tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDomainEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDomainEntry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDomainEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        []DomainEntry
+		description string
+	}{
+		{
+			name: "domains.txt",
+			input: `# certificates managed by dehydrated
+example.com www.example.com
+*.example.com > star_example_com # wildcard
+# old.example.com
+`,
+			want: []DomainEntry{
+				{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Enabled: true},
+				{Domain: "*.example.com", AlternativeNames: []string{}, Alias: "star_example_com", Enabled: true, Comment: "wildcard"},
+				{Domain: "old.example.com", AlternativeNames: []string{}},
+			},
+			description: "should parse entries, aliases, comments and disabled entries",
+		},
+		{
+			name:        "JSON",
+			input:       ` [{"domain": "example.com", "alternative_names": ["www.example.com"], "alias": "web", "enabled": true}]`,
+			want:        []DomainEntry{{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Alias: "web", Enabled: true}},
+			description: "should parse a list of dehydrated-api domain entries",
+		},
+		{
+			name:        "empty",
+			input:       "",
+			want:        []DomainEntry{},
+			description: "should return no entries for an empty file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadDomainEntries(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ReadDomainEntries() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadDomainEntries() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainsTxt(t *testing.T) {
+	entries := []DomainEntry{
+		{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Enabled: true},
+		{Domain: "*.example.com", Alias: "wildcard", Enabled: true, Comment: "imported"},
+		{Domain: "old.example.com"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDomainsTxt(&buf, entries); err != nil {
+		t.Fatalf("WriteDomainsTxt() error = %v", err)
+	}
+	want := "example.com www.example.com\n*.example.com > wildcard # imported\n# old.example.com\n"
+	if buf.String() != want {
+		t.Errorf("WriteDomainsTxt() = %q, want %q", buf.String(), want)
+	}
+
+	got, err := ReadDomainEntries(&buf)
+	if err != nil {
+		t.Fatalf("ReadDomainEntries() error = %v", err)
+	}
+	if len(got) != len(entries) || got[1].Alias != "wildcard" || got[2].Enabled {
+		t.Errorf("ReadDomainEntries() round trip = %+v", got)
+	}
+}
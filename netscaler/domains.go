@@ -0,0 +1,58 @@
+package netscaler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// AppliesTo reports whether the environment is responsible for the domain. A domain applies when it
+// matches one of IncludeDomains, or IncludeDomains is empty, and matches none of ExcludeDomains.
+func (c *Config) AppliesTo(domain string) bool {
+	domain = strings.ToLower(domain)
+	if len(c.IncludeDomains) > 0 && !matchAnyDomain(c.IncludeDomains, domain) {
+		return false
+	}
+	return !matchAnyDomain(c.ExcludeDomains, domain)
+}
+
+// validateDomainPatterns checks that all include and exclude patterns can be compiled
+func (c *Config) validateDomainPatterns() error {
+	for field, patterns := range map[string][]string{"includeDomains": c.IncludeDomains, "excludeDomains": c.ExcludeDomains} {
+		for _, pattern := range patterns {
+			if _, err := matchDomain(pattern, ""); err != nil {
+				return &FieldError{Field: field, Message: err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+func matchAnyDomain(patterns []string, domain string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := matchDomain(pattern, domain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomain matches a domain against a glob such as "*.example.com", or a regular expression
+// when the pattern is enclosed in slashes, e.g. "/^(www|api)\.example\.com$/". Both are case-insensitive
+// like the domain names they match.
+func matchDomain(pattern, domain string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid domain pattern %s: %w", pattern, err)
+		}
+		return re.MatchString(domain), nil
+	}
+
+	ok, err := path.Match(strings.ToLower(pattern), domain)
+	if err != nil {
+		return false, fmt.Errorf("invalid domain pattern %s: %w", pattern, err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,89 @@
+package netscaler
+
+import "testing"
+
+func TestConfig_AppliesTo(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		domain      string
+		want        bool
+		description string
+	}{
+		{
+			name:        "no patterns",
+			config:      Config{},
+			domain:      "example.com",
+			want:        true,
+			description: "should apply to all domains without patterns",
+		},
+		{
+			name:        "included glob",
+			config:      Config{IncludeDomains: []string{"*.example.com"}},
+			domain:      "www.example.com",
+			want:        true,
+			description: "should apply to domains matching an include glob",
+		},
+		{
+			name:        "not included",
+			config:      Config{IncludeDomains: []string{"*.example.com"}},
+			domain:      "example.org",
+			want:        false,
+			description: "should not apply to domains matching no include pattern",
+		},
+		{
+			name:        "glob is case-insensitive",
+			config:      Config{IncludeDomains: []string{"*.Example.com"}},
+			domain:      "WWW.example.COM",
+			want:        true,
+			description: "should match globs regardless of case",
+		},
+		{
+			name:        "excluded",
+			config:      Config{IncludeDomains: []string{"*.example.com"}, ExcludeDomains: []string{"internal.example.com"}},
+			domain:      "internal.example.com",
+			want:        false,
+			description: "should not apply to excluded domains even if included",
+		},
+		{
+			name:        "included regex",
+			config:      Config{IncludeDomains: []string{`/^(www|api)\.example\.com$/`}},
+			domain:      "api.example.com",
+			want:        true,
+			description: "should apply to domains matching an include regex",
+		},
+		{
+			name:        "excluded regex",
+			config:      Config{ExcludeDomains: []string{`/\.internal$/`}},
+			domain:      "db.internal",
+			want:        false,
+			description: "should not apply to domains matching an exclude regex",
+		},
+		{
+			name:        "regex case",
+			config:      Config{IncludeDomains: []string{`/^WWW\.Example\.com$/`}},
+			domain:      "www.EXAMPLE.com",
+			want:        true,
+			description: "should match regular expressions case-insensitively like globs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.AppliesTo(tt.domain); got != tt.want {
+				t.Errorf("AppliesTo(%s) = %v, want %v", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewConfig_InvalidDomainPatterns(t *testing.T) {
+	for _, input := range []map[string]any{
+		{"includeDomains": []string{"/[a-/"}},
+		{"excludeDomains": []string{"[a-"}},
+	} {
+		if _, err := NewConfig(input); err == nil {
+			t.Errorf("NewConfig(%v) should fail on invalid patterns", input)
+		}
+	}
+}
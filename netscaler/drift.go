@@ -0,0 +1,98 @@
+package netscaler
+
+import (
+	"strings"
+	"time"
+)
+
+// DriftStatus is the state of a domain's certificate in one environment compared to the others
+type DriftStatus string
+
+const (
+	// DriftCurrent means the environment serves the newest certificate
+	DriftCurrent DriftStatus = "current"
+	// DriftOutdated means the environment serves a certificate expiring before the newest one
+	DriftOutdated DriftStatus = "outdated"
+	// DriftDiffers means the environment serves another certificate with the same expiry
+	DriftDiffers DriftStatus = "differs"
+	// DriftMissing means the environment has no certificate for the domain
+	DriftMissing DriftStatus = "missing"
+)
+
+// EnvironmentDrift is the certificate of a domain in one environment
+type EnvironmentDrift struct {
+	Status   DriftStatus `json:"status"`
+	Serial   string      `json:"serial,omitempty"`
+	Issuer   string      `json:"issuer,omitempty"`
+	Digest   string      `json:"digest,omitempty"`
+	NotAfter time.Time   `json:"notAfter,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Drift compares the certificates a domain is served with across environments
+type Drift struct {
+	Domain       string                      `json:"domain"`
+	InSync       bool                        `json:"inSync"`
+	Environments map[string]EnvironmentDrift `json:"environments"`
+}
+
+// CompareCertificates compares the raw certificates of a domain per environment. Environments
+// missing from certs or listed in errs don't have the certificate. The newest certificate is
+// the one expiring last; certificates are identified by issuer and serial, and by the digest
+// of the certificate when the firmware reports it.
+func CompareCertificates(domain string, certs map[string]map[string]any, errs map[string]error) Drift {
+	d := Drift{Domain: domain, InSync: true, Environments: make(map[string]EnvironmentDrift)}
+
+	parsed := make(map[string]*Certificate, len(certs))
+	var newest *Certificate
+	for env, raw := range certs {
+		cert, err := ParseCertificate(raw)
+		if err != nil {
+			d.Environments[env] = EnvironmentDrift{Status: DriftMissing, Error: err.Error()}
+			continue
+		}
+		parsed[env] = cert
+		if newest == nil || cert.NotAfter.After(newest.NotAfter) ||
+			(cert.NotAfter.Equal(newest.NotAfter) && cert.Serial > newest.Serial) ||
+			(cert.NotAfter.Equal(newest.NotAfter) && cert.Serial == newest.Serial && strings.ToLower(cert.Digest) > strings.ToLower(newest.Digest)) {
+			newest = cert
+		}
+	}
+	for env, err := range errs {
+		d.Environments[env] = EnvironmentDrift{Status: DriftMissing, Error: err.Error()}
+	}
+
+	for env, cert := range parsed {
+		e := EnvironmentDrift{Serial: cert.Serial, Issuer: cert.Issuer, Digest: cert.Digest, NotAfter: cert.NotAfter}
+		switch {
+		case sameCertificate(cert, newest):
+			e.Status = DriftCurrent
+		case cert.NotAfter.Before(newest.NotAfter):
+			e.Status = DriftOutdated
+		default:
+			e.Status = DriftDiffers
+		}
+		d.Environments[env] = e
+	}
+
+	for _, e := range d.Environments {
+		if e.Status != DriftCurrent {
+			d.InSync = false
+		}
+	}
+
+	return d
+}
+
+// sameCertificate reports whether two certificates are identical. Issuer and serial also match for
+// a certificate reissued by a CA reusing serials or a self-signed certificate created twice, the
+// digest tells them apart.
+func sameCertificate(a, b *Certificate) bool {
+	if a.Serial != b.Serial || a.Issuer != b.Issuer || !a.NotAfter.Equal(b.NotAfter) {
+		return false
+	}
+	if a.Digest != "" && b.Digest != "" {
+		return strings.EqualFold(a.Digest, b.Digest)
+	}
+	return true
+}
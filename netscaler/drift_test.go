@@ -0,0 +1,86 @@
+package netscaler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareCertificates(t *testing.T) {
+	current := map[string]any{"certkey": "example.com", "serial": "02", "issuer": "CN=R3", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT"}
+	older := map[string]any{"certkey": "example.com", "serial": "01", "issuer": "CN=R3", "clientcertnotafter": "Dec  1 00:00:00 2026 GMT"}
+	sameExpiry := map[string]any{"certkey": "example.com", "serial": "03", "issuer": "CN=R10", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT"}
+	digest := map[string]any{"certkey": "example.com", "serial": "02", "issuer": "CN=R3", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT", "certkeydigest": "c0ffee01"}
+	otherDigest := map[string]any{"certkey": "example.com", "serial": "02", "issuer": "CN=R3", "clientcertnotafter": "Mar  1 00:00:00 2027 GMT", "certkeydigest": "C0FFEE02"}
+
+	tests := []struct {
+		name        string
+		certs       map[string]map[string]any
+		errs        map[string]error
+		wantInSync  bool
+		want        map[string]DriftStatus
+		description string
+	}{
+		{
+			name:        "in sync",
+			certs:       map[string]map[string]any{"prod": current, "staging": current},
+			wantInSync:  true,
+			want:        map[string]DriftStatus{"prod": DriftCurrent, "staging": DriftCurrent},
+			description: "should report environments serving the same certificate as in sync",
+		},
+		{
+			name:        "outdated",
+			certs:       map[string]map[string]any{"prod": older, "staging": current},
+			want:        map[string]DriftStatus{"prod": DriftOutdated, "staging": DriftCurrent},
+			description: "should report environments serving a certificate expiring earlier as outdated",
+		},
+		{
+			name:        "differs",
+			certs:       map[string]map[string]any{"prod": current, "staging": sameExpiry},
+			want:        map[string]DriftStatus{"prod": DriftDiffers, "staging": DriftCurrent},
+			description: "should report other certificates with the same expiry as differing",
+		},
+		{
+			name:        "same digest",
+			certs:       map[string]map[string]any{"prod": digest, "staging": digest, "dev": current},
+			wantInSync:  true,
+			want:        map[string]DriftStatus{"prod": DriftCurrent, "staging": DriftCurrent, "dev": DriftCurrent},
+			description: "certificates without a digest should still be compared by issuer and serial",
+		},
+		{
+			name:        "other digest",
+			certs:       map[string]map[string]any{"prod": digest, "staging": otherDigest},
+			want:        map[string]DriftStatus{"prod": DriftDiffers, "staging": DriftCurrent},
+			description: "certificates with the same issuer and serial but another digest should differ",
+		},
+		{
+			name:        "missing",
+			certs:       map[string]map[string]any{"prod": current},
+			errs:        map[string]error{"staging": errors.New("not found")},
+			want:        map[string]DriftStatus{"prod": DriftCurrent, "staging": DriftMissing},
+			description: "should report environments without the certificate as missing",
+		},
+		{
+			name:        "unparsable",
+			certs:       map[string]map[string]any{"prod": current, "staging": {"certkey": 1}},
+			want:        map[string]DriftStatus{"prod": DriftCurrent, "staging": DriftMissing},
+			description: "should treat unparsable certificates as missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareCertificates("example.com", tt.certs, tt.errs)
+			if got.InSync != tt.wantInSync {
+				t.Errorf("InSync = %v, want %v", got.InSync, tt.wantInSync)
+			}
+			if len(got.Environments) != len(tt.want) {
+				t.Fatalf("Environments = %v, want %v", got.Environments, tt.want)
+			}
+			for env, status := range tt.want {
+				if got.Environments[env].Status != status {
+					t.Errorf("%s status = %s, want %s", env, got.Environments[env].Status, status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package netscaler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// HA node states and synchronization values as reported by hanode
+const (
+	haStatePrimary  = "Primary"
+	haSyncDisabled  = "DISABLED"
+	haSyncStateGood = "SUCCESS"
+)
+
+// HANode is a node of a high availability pair as reported by hanode
+type HANode struct {
+	// ID is 0 for the node the client is connected to
+	ID        int    `json:"id"`
+	Name      string `json:"name,omitempty"`
+	IPAddress string `json:"ipAddress"`
+	// State is Primary, Secondary or another state of the node
+	State string `json:"state,omitempty"`
+	// Sync is the configured synchronization of the pair, ENABLED or DISABLED
+	Sync string `json:"sync,omitempty"`
+	// SyncState is the result of the last synchronization, e.g. SUCCESS
+	SyncState string `json:"syncState,omitempty"`
+}
+
+// HAStatus describes whether the secondary of an HA pair would serve the same certificates after a failover
+type HAStatus struct {
+	Nodes []HANode `json:"nodes"`
+	// MissingFiles lists files in the FileLocation the secondary doesn't have
+	MissingFiles []string `json:"missingFiles,omitempty"`
+	// Problems describes what could bring back old certificates on a failover
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Peer returns the other node of the pair, or nil for a standalone appliance
+func (s *HAStatus) Peer() *HANode {
+	for i := range s.Nodes {
+		if s.Nodes[i].ID != 0 {
+			return &s.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// SaveConfig saves the running configuration, changes made through NITRO are lost on reboot otherwise
+func (c *Client) SaveConfig() error {
+	if err := c.api.ActOnResource("nsconfig", map[string]any{}, "save"); err != nil {
+		return fmt.Errorf("failed to save the configuration: %w", err)
+	}
+	return nil
+}
+
+// SyncFiles copies the files in the FileLocation from the primary to the secondary node
+func (c *Client) SyncFiles() error {
+	if err := c.api.ActOnResource("hafiles", map[string]any{"mode": []string{"ssl"}}, "sync"); err != nil {
+		return fmt.Errorf("failed to synchronize %s to the secondary: %w", c.FileLocation(), err)
+	}
+	return nil
+}
+
+// HANodes returns the nodes of the HA pair, the node the client is connected to first. A standalone
+// appliance reports only itself.
+func (c *Client) HANodes() ([]HANode, error) {
+	// FindAllResources reports failed requests as an empty list, which looks like a standalone appliance
+	raw, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: "hanode"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve HA nodes: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("failed to retrieve HA nodes: the appliance reported none, not even itself")
+	}
+
+	nodes := make([]HANode, 0, len(raw))
+	for _, r := range raw {
+		id, err := intField(r, "id")
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, HANode{
+			ID:        id,
+			Name:      stringField(r, "name"),
+			IPAddress: stringField(r, "ipaddress"),
+			State:     stringField(r, "state"),
+			Sync:      stringField(r, "hasync"),
+			SyncState: stringField(r, "syncstate"),
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes, nil
+}
+
+// HAStatus checks the synchronization of the HA pair with the nodes returned by HANodes, so a
+// failover between reading the nodes and checking them doesn't mix two states. If the client of the
+// secondary node is given, the files are looked up on it. A standalone appliance has no problems.
+func (c *Client) HAStatus(nodes []HANode, secondary *Client, files []string) (*HAStatus, error) {
+	status := &HAStatus{Nodes: nodes}
+	peer := status.Peer()
+	if peer == nil {
+		return status, nil
+	}
+
+	if local := nodes[0]; local.ID == 0 && local.State != "" && local.State != haStatePrimary {
+		status.Problems = append(status.Problems, fmt.Sprintf("the endpoint is the %s node, changes have to be made on the primary", strings.ToLower(local.State)))
+	}
+	if strings.EqualFold(peer.Sync, haSyncDisabled) {
+		status.Problems = append(status.Problems, "HA synchronization is disabled")
+	} else if peer.SyncState != "" && !strings.EqualFold(peer.SyncState, haSyncStateGood) {
+		status.Problems = append(status.Problems, fmt.Sprintf("HA synchronization state is %s", peer.SyncState))
+	}
+
+	if secondary == nil || len(files) == 0 {
+		return status, nil
+	}
+	peerFiles, err := secondary.listFiles()
+	if err != nil {
+		return nil, fmt.Errorf("secondary %s: %w", peer.IPAddress, err)
+	}
+	for _, file := range files {
+		if !slices.Contains(peerFiles, file) {
+			status.MissingFiles = append(status.MissingFiles, file)
+		}
+	}
+	if len(status.MissingFiles) > 0 {
+		status.Problems = append(status.Problems, fmt.Sprintf("the secondary is missing %s", strings.Join(status.MissingFiles, ", ")))
+	}
+	return status, nil
+}
+
+// CertkeyFiles returns the certificate and key files in the FileLocation of the certkeys and the
+// certkeys they link to
+func (c *Client) CertkeyFiles(certkeys ...string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for len(certkeys) > 0 {
+		certkey := certkeys[0]
+		certkeys = certkeys[1:]
+		if certkey == "" || seen[certkey] {
+			continue
+		}
+		seen[certkey] = true
+
+		raw, err := c.findCertkey(certkey)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			continue
+		}
+		for _, attr := range []string{"cert", "key"} {
+			if file := c.sslFileName(stringField(raw, attr)); file != "" && !slices.Contains(files, file) {
+				files = append(files, file)
+			}
+		}
+		certkeys = append(certkeys, stringField(raw, "linkcertkeyname"))
+	}
+	sort.Strings(files)
+	return files, nil
+}
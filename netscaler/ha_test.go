@@ -0,0 +1,188 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// addHANodes updates the local node of the store and adds its peer to the hanode resources
+func addHANodes(t *testing.T, api *netscalertest.NitroClient, local, peer map[string]any) {
+	t.Helper()
+	if err := api.Store.Update("hanode", "0", local); err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+	if err := api.Store.Add("hanode", peer); err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+}
+
+func TestClient_HAStatus(t *testing.T) {
+	primary := map[string]any{"id": 0, "name": "ns-a", "ipaddress": "192.0.2.1", "state": "Primary"}
+	secondary := map[string]any{"id": 1, "name": "ns-b", "ipaddress": "192.0.2.2", "state": "Secondary", "hasync": "ENABLED", "syncstate": "SUCCESS"}
+	with := func(node map[string]any, key string, value any) map[string]any {
+		copied := make(map[string]any, len(node))
+		for k, v := range node {
+			copied[k] = v
+		}
+		copied[key] = value
+		return copied
+	}
+
+	tests := []struct {
+		name         string
+		local        map[string]any
+		peer         map[string]any
+		peerFiles    []string
+		wantMissing  []string
+		wantProblems []string
+		description  string
+	}{
+		{
+			name:        "standalone",
+			description: "a standalone appliance should have no problems",
+		},
+		{
+			name:        "in sync",
+			local:       primary,
+			peer:        secondary,
+			peerFiles:   []string{"prod-example.com.crt", "prod-example.com.key"},
+			description: "a synchronized secondary with all files should have no problems",
+		},
+		{
+			name:         "missing files",
+			local:        primary,
+			peer:         secondary,
+			peerFiles:    []string{"prod-example.com.key"},
+			wantMissing:  []string{"prod-example.com.crt"},
+			wantProblems: []string{"the secondary is missing prod-example.com.crt"},
+			description:  "files missing on the secondary should be reported",
+		},
+		{
+			name:         "sync disabled",
+			local:        primary,
+			peer:         with(secondary, "hasync", "DISABLED"),
+			peerFiles:    []string{"prod-example.com.crt", "prod-example.com.key"},
+			wantProblems: []string{"HA synchronization is disabled"},
+			description:  "disabled synchronization should be reported",
+		},
+		{
+			name:         "sync failed",
+			local:        primary,
+			peer:         with(secondary, "syncstate", "FAILED"),
+			peerFiles:    []string{"prod-example.com.crt", "prod-example.com.key"},
+			wantProblems: []string{"HA synchronization state is FAILED"},
+			description:  "failed synchronization should be reported",
+		},
+		{
+			name:         "connected to secondary",
+			local:        with(primary, "state", "Secondary"),
+			peer:         with(secondary, "state", "Primary"),
+			peerFiles:    []string{"prod-example.com.crt", "prod-example.com.key"},
+			wantProblems: []string{"the endpoint is the secondary node, changes have to be made on the primary"},
+			description:  "an endpoint pointing to the secondary should be reported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api := newTestKeyClient(t)
+			peerClient, peerAPI := newTestKeyClient(t)
+			if tt.local != nil {
+				addHANodes(t, api, tt.local, tt.peer)
+			}
+			for _, file := range tt.peerFiles {
+				if err := peerAPI.Store.WriteFile(file, []byte("test")); err != nil {
+					t.Fatalf("Store.WriteFile() error = %v", err)
+				}
+			}
+
+			nodes, err := client.HANodes()
+			if err != nil {
+				t.Fatalf("HANodes() error = %v", err)
+			}
+			status, err := client.HAStatus(nodes, peerClient, []string{"prod-example.com.crt", "prod-example.com.key"})
+			if err != nil {
+				t.Fatalf("HAStatus() error = %v", err)
+			}
+			if (status.Peer() == nil) != (tt.peer == nil) {
+				t.Errorf("Peer() = %v", status.Peer())
+			}
+			if !reflect.DeepEqual(status.MissingFiles, tt.wantMissing) {
+				t.Errorf("MissingFiles = %v, want %v", status.MissingFiles, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(status.Problems, tt.wantProblems) {
+				t.Errorf("Problems = %v, want %v", status.Problems, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func TestClient_HANodes_Errors(t *testing.T) {
+	client, api := newTestKeyClient(t)
+
+	injected := errors.New("session expired")
+	api.FailNextCall("FindResourceArrayWithParams", "hanode", injected)
+	if _, err := client.HANodes(); !errors.Is(err, injected) {
+		t.Errorf("HANodes() error = %v, want the failed hanode query", err)
+	}
+
+	// NITRO reports at least the local node, an empty list is not a standalone appliance
+	if err := api.Store.Delete("hanode", "0"); err != nil {
+		t.Fatalf("Store.Delete() error = %v", err)
+	}
+	if nodes, err := client.HANodes(); err == nil {
+		t.Errorf("HANodes() = %v, want an error for an empty hanode list", nodes)
+	}
+}
+
+func TestClient_SaveConfigAndSyncFiles(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	peerClient, peerAPI := newTestKeyClient(t)
+
+	if err := client.SyncFiles(); err == nil || !strings.Contains(err.Error(), "standalone") {
+		t.Errorf("SyncFiles() on a standalone appliance error = %v", err)
+	}
+
+	api.Store.Pair(peerAPI.Store)
+	if err := api.Store.WriteFile("prod-example.com.crt", []byte("test")); err != nil {
+		t.Fatalf("Store.WriteFile() error = %v", err)
+	}
+	if err := client.SyncFiles(); err != nil {
+		t.Fatalf("SyncFiles() error = %v", err)
+	}
+	if exists, err := peerClient.FileExists("prod-example.com.crt"); err != nil || !exists {
+		t.Errorf("secondary FileExists() after SyncFiles() = %v, %v", exists, err)
+	}
+
+	if err := client.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	if api.Store.Saves() != 1 {
+		t.Errorf("Saves() = %d, want 1", api.Store.Saves())
+	}
+}
+
+func TestClient_CertkeyFiles(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	for _, certkey := range []map[string]any{
+		{"certkey": "prod-example.com", "cert": "prod-example.com.crt", "key": "/nsconfig/ssl/prod-example.com.key", "linkcertkeyname": "prod-ca"},
+		{"certkey": "prod-ca", "cert": "prod-ca.crt", "linkcertkeyname": "prod-root"},
+		{"certkey": "prod-root", "cert": "/var/certs/root.crt"},
+	} {
+		if err := api.Store.Add("sslcertkey", certkey); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+
+	got, err := client.CertkeyFiles("prod-example.com", "prod-missing")
+	if err != nil {
+		t.Fatalf("CertkeyFiles() error = %v", err)
+	}
+	if want := []string{"prod-ca.crt", "prod-example.com.crt", "prod-example.com.key"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CertkeyFiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+package netscaler
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// hostExpressionPattern matches host comparisons in content switching policy rules such as
+// HTTP.REQ.HOSTNAME.EQ("www.example.com") or HTTP.REQ.HEADER("Host").SET_TEXT_MODE(IGNORECASE).EQ("example.com")
+var hostExpressionPattern = regexp.MustCompile(`(?i)HTTP\.REQ\.(?:HOSTNAME(?:\.SERVER)?|HEADER\(\s*"Host"\s*\))(?:\.SET_TEXT_MODE\(\s*IGNORECASE\s*\))?\.EQ\(\s*"([^"]+)"\s*\)`)
+
+// VIP is an SSL content switching vserver with the hostnames routed to it and the certificates bound to it
+type VIP struct {
+	Name      string   `json:"name"`
+	Address   string   `json:"address,omitempty"`
+	Hostnames []string `json:"hostnames"`
+	Certkeys  []string `json:"certkeys"`
+	// Uncovered lists the hostnames none of the bound certificates is valid for
+	Uncovered []string `json:"uncovered,omitempty"`
+	// Errors lists the bound certificates that could not be parsed, hostnames only they are valid
+	// for are reported as uncovered
+	Errors []string `json:"errors,omitempty"`
+}
+
+// HostnameDiscoverer is implemented by certificate sources that can read the hostnames served by the appliance
+type HostnameDiscoverer interface {
+	DiscoverHostnames() ([]VIP, error)
+}
+
+// DiscoverHostnames reads the hostnames of all SSL content switching vservers from the host expressions
+// of their bound policies, and checks them against the server and SNI certificates bound to the vserver
+func (c *Client) DiscoverHostnames() ([]VIP, error) {
+	// FindAllResources reports failed requests as an empty list, which looks like no hostnames at all
+	policies, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: "cspolicy", ResourceMissingErrorCode: errCodeNoSuchResource})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve content switching policies: %w", err)
+	}
+	hostsByPolicy := make(map[string][]string)
+	for _, policy := range policies {
+		hostsByPolicy[stringField(policy, "policyname")] = PolicyHostnames(policy)
+	}
+
+	vservers, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: "csvserver", ResourceMissingErrorCode: errCodeNoSuchResource})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve content switching vservers: %w", err)
+	}
+
+	certs := make(map[string]*Certificate)
+	parseErrs := make(map[string]error)
+	vips := []VIP{}
+	for _, vs := range vservers {
+		if !strings.EqualFold(stringField(vs, "servicetype"), "SSL") {
+			continue
+		}
+		vip := VIP{Name: stringField(vs, "name"), Hostnames: []string{}, Certkeys: []string{}}
+		if ip := stringField(vs, "ipv46"); ip != "" {
+			vip.Address = fmt.Sprintf("%s:%v", ip, vs["port"])
+		}
+
+		res, err := c.api.FindResource("csvserver_binding", vip.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve policies of %s: %w", vip.Name, err)
+		}
+		for _, binding := range bindingEntries(res, "csvserver_cspolicy_binding") {
+			for _, host := range hostsByPolicy[stringField(binding, "policyname")] {
+				if !slices.Contains(vip.Hostnames, host) {
+					vip.Hostnames = append(vip.Hostnames, host)
+				}
+			}
+		}
+		sort.Strings(vip.Hostnames)
+
+		res, err = c.api.FindResource("sslvserver_binding", vip.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve certificates of %s: %w", vip.Name, err)
+		}
+		for _, binding := range bindingEntries(res, "sslvserver_sslcertkey_binding") {
+			if ca, _ := binding["ca"].(bool); ca {
+				continue
+			}
+			if name := stringField(binding, "certkeyname"); name != "" {
+				vip.Certkeys = append(vip.Certkeys, name)
+			}
+		}
+		sort.Strings(vip.Certkeys)
+
+		var bound []*Certificate
+		for _, name := range vip.Certkeys {
+			cert, parseErr, err := c.boundCertificate(certs, parseErrs, name)
+			if err != nil {
+				return nil, err
+			}
+			if parseErr != nil {
+				vip.Errors = append(vip.Errors, fmt.Sprintf("certificate %s: %v", name, parseErr))
+				continue
+			}
+			bound = append(bound, cert)
+		}
+
+		for _, host := range vip.Hostnames {
+			covered := false
+			for _, cert := range bound {
+				if cert.Covers(host) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				vip.Uncovered = append(vip.Uncovered, host)
+			}
+		}
+
+		vips = append(vips, vip)
+	}
+
+	sort.Slice(vips, func(i, j int) bool { return vips[i].Name < vips[j].Name })
+	return vips, nil
+}
+
+// boundCertificate returns the parsed certkey, fetching it only once per discovery. parseErr is set
+// if the certkey can't be parsed, err if it can't be retrieved.
+func (c *Client) boundCertificate(certs map[string]*Certificate, parseErrs map[string]error, name string) (cert *Certificate, parseErr error, err error) {
+	if cert, ok := certs[name]; ok {
+		return cert, nil, nil
+	}
+	if parseErr, ok := parseErrs[name]; ok {
+		return nil, parseErr, nil
+	}
+	raw, err := c.api.FindResource(service.Sslcertkey.Type(), name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve certificate %s: %w", name, err)
+	}
+	if cert, parseErr = ParseCertificate(raw); parseErr != nil {
+		parseErrs[name] = parseErr
+		return nil, parseErr, nil
+	}
+	certs[name] = cert
+	return cert, nil, nil
+}
+
+// PolicyHostnames returns the hostnames a content switching policy matches, from the host
+// comparisons in its rule or the domain of classic policies
+func PolicyHostnames(policy map[string]any) []string {
+	var hosts []string
+	add := func(host string) {
+		// a port in the Host header is not part of the certificate name
+		if h, _, found := strings.Cut(host, ":"); found {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, m := range hostExpressionPattern.FindAllStringSubmatch(stringField(policy, "rule"), -1) {
+		add(m[1])
+	}
+	add(stringField(policy, "domain"))
+	return hosts
+}
+
+// UncoveredHostnames returns the uncovered hostnames of all VIPs that are one of the given names
+// or matched by one of them, keyed by VIP
+func UncoveredHostnames(vips []VIP, names ...string) map[string][]string {
+	uncovered := make(map[string][]string)
+	for _, vip := range vips {
+		for _, host := range vip.Uncovered {
+			if slices.ContainsFunc(names, func(name string) bool { return matchHostname(name, host) }) {
+				uncovered[vip.Name] = append(uncovered[vip.Name], host)
+			}
+		}
+	}
+	return uncovered
+}
+
+// bindingEntries returns the entries of a binding type in an aggregate binding resource
+func bindingEntries(res map[string]any, bindingType string) []map[string]any {
+	var entries []map[string]any
+	list, _ := res[bindingType].([]any)
+	for _, e := range list {
+		if attrs, ok := e.(map[string]any); ok {
+			entries = append(entries, attrs)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,218 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// seedContentSwitching adds an SSL and an HTTP content switching vserver with host based policies
+func seedContentSwitching(t *testing.T, store *netscalertest.Store) {
+	t.Helper()
+	for _, r := range []struct {
+		resourceType string
+		attrs        map[string]any
+	}{
+		{"csvserver", map[string]any{"name": "cs-web", "servicetype": "SSL", "ipv46": "10.0.0.1", "port": 443}},
+		{"csvserver", map[string]any{"name": "cs-plain", "servicetype": "HTTP", "ipv46": "10.0.0.1", "port": 80}},
+		{"sslvserver", map[string]any{"vservername": "cs-web"}},
+		{"cspolicy", map[string]any{"policyname": "pol-www", "rule": `HTTP.REQ.HOSTNAME.EQ("www.example.com") || HTTP.REQ.HOSTNAME.EQ("example.com")`}},
+		{"cspolicy", map[string]any{"policyname": "pol-shop", "rule": `HTTP.REQ.HEADER("Host").SET_TEXT_MODE(IGNORECASE).EQ("Shop.example.com:443")`}},
+		{"cspolicy", map[string]any{"policyname": "pol-api", "rule": `HTTP.REQ.HOSTNAME.SERVER.EQ("api.example.org")`}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-www"}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-shop"}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-api"}},
+		{"csvserver_cspolicy_binding", map[string]any{"name": "cs-plain", "policyname": "pol-api"}},
+		{"sslcertkey", map[string]any{"certkey": "prod-example.com", "subject": "CN=example.com", "sandns": "example.com,www.example.com"}},
+		{"sslcertkey", map[string]any{"certkey": "prod-wildcard", "subject": "CN=*.example.com"}},
+		{"sslcertkey", map[string]any{"certkey": "ca", "subject": "CN=Example CA"}},
+		{"sslvserver_sslcertkey_binding", map[string]any{"vservername": "cs-web", "certkeyname": "prod-example.com"}},
+		{"sslvserver_sslcertkey_binding", map[string]any{"vservername": "cs-web", "certkeyname": "prod-wildcard", "snicert": true}},
+		{"sslvserver_sslcertkey_binding", map[string]any{"vservername": "cs-web", "certkeyname": "ca", "ca": true}},
+	} {
+		if err := store.Add(r.resourceType, r.attrs); err != nil {
+			t.Fatalf("Failed to seed %s: %v", r.resourceType, err)
+		}
+	}
+}
+
+func TestPolicyHostnames(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      map[string]any
+		want        []string
+		description string
+	}{
+		{
+			name:        "hostname",
+			policy:      map[string]any{"rule": `HTTP.REQ.HOSTNAME.EQ("www.example.com")`},
+			want:        []string{"www.example.com"},
+			description: "should read host comparisons",
+		},
+		{
+			name:        "alternatives",
+			policy:      map[string]any{"rule": `HTTP.REQ.HOSTNAME.SERVER.EQ("A.example.com") || HTTP.REQ.HEADER("Host").EQ("b.example.com:8443") || HTTP.REQ.HOSTNAME.EQ("a.example.com")`},
+			want:        []string{"a.example.com", "b.example.com"},
+			description: "should read every comparison, lowercase hosts and drop ports",
+		},
+		{
+			name:        "classic domain",
+			policy:      map[string]any{"domain": "legacy.example.com"},
+			want:        []string{"legacy.example.com"},
+			description: "should read the domain of classic policies",
+		},
+		{
+			name:        "no host",
+			policy:      map[string]any{"rule": `HTTP.REQ.URL.STARTSWITH("/api")`},
+			want:        nil,
+			description: "should ignore rules without host comparisons",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PolicyHostnames(tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PolicyHostnames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_DiscoverHostnames(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	seedContentSwitching(t, api.Store)
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	vips, err := client.DiscoverHostnames()
+	if err != nil {
+		t.Fatalf("DiscoverHostnames() error = %v", err)
+	}
+
+	want := []VIP{{
+		Name:      "cs-web",
+		Address:   "10.0.0.1:443",
+		Hostnames: []string{"api.example.org", "example.com", "shop.example.com", "www.example.com"},
+		Certkeys:  []string{"prod-example.com", "prod-wildcard"},
+		Uncovered: []string{"api.example.org"},
+	}}
+	if !reflect.DeepEqual(vips, want) {
+		t.Errorf("DiscoverHostnames() = %+v, want %+v", vips, want)
+	}
+}
+
+func TestClient_DiscoverHostnames_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(api *netscalertest.NitroClient) error
+		wantErr       string
+		wantUncovered []string
+		wantErrors    string
+		description   string
+	}{
+		{
+			name: "policies fail",
+			setup: func(api *netscalertest.NitroClient) error {
+				api.FailNextCall("FindResourceArrayWithParams", "cspolicy", errors.New("connection reset"))
+				return nil
+			},
+			wantErr:     "failed to retrieve content switching policies: connection reset",
+			description: "a failed policy query should not report that no hostname is uncovered",
+		},
+		{
+			name: "vservers fail",
+			setup: func(api *netscalertest.NitroClient) error {
+				api.FailNextCall("FindResourceArrayWithParams", "csvserver", errors.New("connection reset"))
+				return nil
+			},
+			wantErr:     "failed to retrieve content switching vservers: connection reset",
+			description: "a failed vserver query should not report that no hostname is uncovered",
+		},
+		{
+			name: "unparsable certificate",
+			setup: func(api *netscalertest.NitroClient) error {
+				return api.Store.Update("sslcertkey", "prod-wildcard", map[string]any{"daystoexpiration": "soon"})
+			},
+			wantUncovered: []string{"api.example.org", "shop.example.com"},
+			wantErrors:    "certificate prod-wildcard",
+			description:   "an unparsable certificate should be recorded on the VIP without aborting the discovery",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := netscalertest.NewNitroClient()
+			seedContentSwitching(t, api.Store)
+			client, err := NewClientFromNitro("prod-", api)
+			if err != nil {
+				t.Fatalf("NewClientFromNitro() error = %v", err)
+			}
+			if err := tt.setup(api); err != nil {
+				t.Fatalf("setup error = %v", err)
+			}
+
+			vips, err := client.DiscoverHostnames()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("DiscoverHostnames() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(vips) != 1 {
+				t.Fatalf("DiscoverHostnames() = %+v, %v", vips, err)
+			}
+			if !reflect.DeepEqual(vips[0].Uncovered, tt.wantUncovered) {
+				t.Errorf("Uncovered = %v, want %v", vips[0].Uncovered, tt.wantUncovered)
+			}
+			if len(vips[0].Errors) != 1 || !strings.Contains(vips[0].Errors[0], tt.wantErrors) {
+				t.Errorf("Errors = %v, want %q", vips[0].Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestUncoveredHostnames(t *testing.T) {
+	vips := []VIP{
+		{Name: "cs-web", Uncovered: []string{"api.example.org", "shop.example.com"}},
+		{Name: "cs-api", Uncovered: []string{"api.example.org"}},
+	}
+
+	tests := []struct {
+		name        string
+		names       []string
+		want        map[string][]string
+		description string
+	}{
+		{
+			name:        "exact",
+			names:       []string{"example.org", "api.example.org"},
+			want:        map[string][]string{"cs-web": {"api.example.org"}, "cs-api": {"api.example.org"}},
+			description: "should report the hostname on every VIP",
+		},
+		{
+			name:        "wildcard",
+			names:       []string{"*.example.com"},
+			want:        map[string][]string{"cs-web": {"shop.example.com"}},
+			description: "should match hostnames covered by a wildcard name",
+		},
+		{
+			name:        "unrelated",
+			names:       []string{"example.net"},
+			want:        map[string][]string{},
+			description: "should report nothing for other domains",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UncoveredHostnames(vips, tt.names...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UncoveredHostnames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
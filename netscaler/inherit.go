@@ -0,0 +1,89 @@
+package netscaler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// extendsKey names the environment an environment inherits its settings from
+const extendsKey = "extends"
+
+// ResolveEnvironments merges the defaults and the environment an entry extends into every raw
+// environment config. Nested objects such as policy are merged, all other values are replaced.
+// The result can be decoded with NewConfig.
+func ResolveEnvironments(defaults map[string]any, environments map[string]any) (map[string]map[string]any, error) {
+	r := &resolver{
+		defaults:     defaults,
+		environments: environments,
+		resolved:     make(map[string]map[string]any),
+	}
+
+	for env := range environments {
+		if _, err := r.resolve(env, nil); err != nil {
+			return nil, err
+		}
+	}
+	return r.resolved, nil
+}
+
+type resolver struct {
+	defaults     map[string]any
+	environments map[string]any
+	resolved     map[string]map[string]any
+}
+
+// resolve returns the merged config of env, path holds the environments currently being resolved
+func (r *resolver) resolve(env string, path []string) (map[string]any, error) {
+	if cfg, ok := r.resolved[env]; ok {
+		return cfg, nil
+	}
+	for _, p := range path {
+		if p == env {
+			return nil, fmt.Errorf("environment %s: extends loop %s", path[0], strings.Join(append(path, env), " -> "))
+		}
+	}
+
+	raw, ok := r.environments[env].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("environment %s: config is not an object", env)
+	}
+
+	base := r.defaults
+	if parent, ok := raw[extendsKey]; ok {
+		name, ok := parent.(string)
+		if !ok {
+			return nil, fmt.Errorf("environment %s: %w", env, &FieldError{Field: extendsKey, Message: fmt.Sprintf("must be a string, got %v", parent)})
+		}
+		if _, ok := r.environments[name]; !ok {
+			return nil, fmt.Errorf("environment %s: extends unknown environment %s", env, name)
+		}
+		var err error
+		base, err = r.resolve(name, append(path, env))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := mergeMaps(base, raw)
+	delete(cfg, extendsKey)
+	r.resolved[env] = cfg
+	return cfg, nil
+}
+
+// mergeMaps returns a copy of base with the values of override applied, merging nested maps
+func mergeMaps(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		if o, ok := v.(map[string]any); ok {
+			if b, ok := merged[k].(map[string]any); ok {
+				merged[k] = mergeMaps(b, o)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
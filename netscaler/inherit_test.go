@@ -0,0 +1,183 @@
+package netscaler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveEnvironments(t *testing.T) {
+	defaults := map[string]any{
+		"username":  "admin",
+		"sslVerify": true,
+		"timeout":   30,
+		"policy":    map[string]any{"minRsaKeySize": 2048, "forbidSha1": true},
+	}
+	environments := map[string]any{
+		"prod": map[string]any{
+			"endpoint": "https://netscaler-prod.example.com",
+			"password": "secret",
+			"prefix":   "prod-",
+			"policy":   map[string]any{"minRsaKeySize": 3072},
+		},
+		"prod-dr": map[string]any{
+			"extends":  "prod",
+			"endpoint": "https://netscaler-dr.example.com",
+		},
+		"dev": map[string]any{
+			"endpoint":  "https://netscaler-dev.example.com",
+			"password":  "dev-secret",
+			"sslVerify": false,
+		},
+	}
+
+	resolved, err := ResolveEnvironments(defaults, environments)
+	if err != nil {
+		t.Fatalf("ResolveEnvironments() error = %v", err)
+	}
+
+	tests := []struct {
+		env         string
+		key         string
+		want        any
+		description string
+	}{
+		{"prod", "username", "admin", "should inherit defaults"},
+		{"prod", "timeout", 30, "should inherit defaults"},
+		{"dev", "sslVerify", false, "should override defaults"},
+		{"prod-dr", "endpoint", "https://netscaler-dr.example.com", "should override the extended environment"},
+		{"prod-dr", "password", "secret", "should inherit from the extended environment"},
+		{"prod-dr", "prefix", "prod-", "should inherit from the extended environment"},
+		{"prod-dr", "username", "admin", "should inherit defaults through the extended environment"},
+	}
+	for _, tt := range tests {
+		if got := resolved[tt.env][tt.key]; got != tt.want {
+			t.Errorf("%s.%s = %v, want %v (%s)", tt.env, tt.key, got, tt.want, tt.description)
+		}
+	}
+
+	policy := resolved["prod-dr"]["policy"].(map[string]any)
+	if policy["minRsaKeySize"] != 3072 || policy["forbidSha1"] != true {
+		t.Errorf("policy = %v, want nested objects merged", policy)
+	}
+	if _, ok := resolved["prod-dr"]["extends"]; ok {
+		t.Error("extends should be removed after merging")
+	}
+	if _, ok := defaults["endpoint"]; ok {
+		t.Error("ResolveEnvironments() should not modify the defaults")
+	}
+
+	cfg, err := NewConfig(resolved["prod-dr"])
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestResolveEnvironments_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		environments map[string]any
+		wantErr      string
+		description  string
+	}{
+		{
+			name: "loop",
+			environments: map[string]any{
+				"a": map[string]any{"extends": "b"},
+				"b": map[string]any{"extends": "a"},
+			},
+			wantErr:     "extends loop",
+			description: "should detect environments extending each other",
+		},
+		{
+			name: "self",
+			environments: map[string]any{
+				"a": map[string]any{"extends": "a"},
+			},
+			wantErr:     "extends loop a -> a",
+			description: "should detect environments extending themselves",
+		},
+		{
+			name: "unknown",
+			environments: map[string]any{
+				"a": map[string]any{"extends": "missing"},
+			},
+			wantErr:     "environment a: extends unknown environment missing",
+			description: "should fail on unknown environments",
+		},
+		{
+			name: "not a string",
+			environments: map[string]any{
+				"a": map[string]any{"extends": 1},
+			},
+			wantErr:     "field 'extends'",
+			description: "should fail when extends is not a string",
+		},
+		{
+			name: "not an object",
+			environments: map[string]any{
+				"a": "endpoint",
+			},
+			wantErr:     "environment a: config is not an object",
+			description: "should fail when an environment is not an object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResolveEnvironments(nil, tt.environments)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveEnvironments() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Endpoint: "https://netscaler.example.com", Username: "admin", Password: "secret"}
+
+	tests := []struct {
+		name        string
+		modify      func(c *Config)
+		wantField   string
+		description string
+	}{
+		{name: "valid", modify: func(c *Config) {}, description: "should accept complete configs"},
+		{name: "missing endpoint", modify: func(c *Config) { c.Endpoint = "" }, wantField: "endpoint", description: "should require the endpoint"},
+		{name: "missing username", modify: func(c *Config) { c.Username = "" }, wantField: "username", description: "should require the username"},
+		{name: "missing password", modify: func(c *Config) { c.Password = "" }, wantField: "password", description: "should require the password"},
+		{name: "negative timeout", modify: func(c *Config) { c.Timeout = -1 }, wantField: "timeout", description: "should reject negative timeouts"},
+		{name: "inventory", modify: func(c *Config) { *c = Config{Inventory: "inventory.json"} }, description: "should not require connection settings with an inventory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			err := c.Validate()
+
+			var fieldErr *FieldError
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+				t.Errorf("Validate() error = %v, want field %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestNewConfig_FieldTypeError(t *testing.T) {
+	_, err := NewConfig(map[string]any{"timeout": "30s"})
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "timeout" {
+		t.Errorf("NewConfig() error = %v, want a field error for timeout", err)
+	}
+}
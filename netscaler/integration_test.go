@@ -1,11 +1,10 @@
-//go:build integration
-// +build integration
-
 package netscaler
 
 import (
 	"os"
 	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
 )
 
 // Integration test configuration
@@ -15,20 +14,57 @@ type IntegrationTestConfig struct {
 	Password  string
 	Prefix    string
 	SslVerify bool
+	// Fake is set when the tests run against the in-repo NITRO emulator
+	Fake bool
 }
 
-func getIntegrationTestConfig() *IntegrationTestConfig {
+// getIntegrationTestConfig targets the appliance given by NETSCALER_ENDPOINT,
+// or a fake NITRO server seeded with sample certificates when it is not set
+func getIntegrationTestConfig(t *testing.T) *IntegrationTestConfig {
+	t.Helper()
+
+	if endpoint := os.Getenv("NETSCALER_ENDPOINT"); endpoint != "" {
+		return &IntegrationTestConfig{
+			Endpoint:  endpoint,
+			Username:  os.Getenv("NETSCALER_USERNAME"),
+			Password:  os.Getenv("NETSCALER_PASSWORD"),
+			Prefix:    os.Getenv("NETSCALER_PREFIX"),
+			SslVerify: os.Getenv("NETSCALER_SSL_VERIFY") == "true",
+		}
+	}
+
+	srv := netscalertest.NewServer()
+	t.Cleanup(srv.Close)
+	for _, cert := range []map[string]any{
+		{"certkey": "test-example.com", "cert": "test-example.com.crt", "key": "test-example.com.key", "status": "Valid"},
+		{"certkey": "test-www.example.com", "cert": "test-www.example.com.crt", "key": "test-www.example.com.key", "status": "Valid"},
+		{"certkey": "ns-server-certificate", "cert": "ns-server.cert", "key": "ns-server.key", "status": "Valid"},
+	} {
+		if err := srv.Store.Add("sslcertkey", cert); err != nil {
+			t.Fatalf("Failed to seed fake NITRO server: %v", err)
+		}
+	}
+
 	return &IntegrationTestConfig{
-		Endpoint:  os.Getenv("NETSCALER_ENDPOINT"),
-		Username:  os.Getenv("NETSCALER_USERNAME"),
-		Password:  os.Getenv("NETSCALER_PASSWORD"),
-		Prefix:    os.Getenv("NETSCALER_PREFIX"),
-		SslVerify: os.Getenv("NETSCALER_SSL_VERIFY") == "true",
+		Endpoint: srv.URL,
+		Username: srv.Username,
+		Password: srv.Password,
+		Prefix:   "test-",
+		Fake:     true,
+	}
+}
+
+// skipUnreachable skips the test when the real appliance cannot be reached, the fake server always has to work
+func skipUnreachable(t *testing.T, config *IntegrationTestConfig, err error) {
+	t.Helper()
+	if config.Fake {
+		t.Fatalf("Failed to connect to fake NITRO server: %v", err)
 	}
+	t.Skipf("Skipping integration test - failed to connect to Netscaler: %v", err)
 }
 
 func TestIntegration_NewClient(t *testing.T) {
-	config := getIntegrationTestConfig()
+	config := getIntegrationTestConfig(t)
 
 	clientConfig := &ClientConfig{
 		Endpoint:  config.Endpoint,
@@ -40,7 +76,7 @@ func TestIntegration_NewClient(t *testing.T) {
 
 	client, err := NewClient(config.Prefix, clientConfig)
 	if err != nil {
-		t.Skipf("Skipping integration test - failed to connect to Netscaler: %v", err)
+		skipUnreachable(t, config, err)
 	}
 
 	if client == nil {
@@ -53,7 +89,7 @@ func TestIntegration_NewClient(t *testing.T) {
 }
 
 func TestIntegration_GetAllCertificates(t *testing.T) {
-	config := getIntegrationTestConfig()
+	config := getIntegrationTestConfig(t)
 
 	clientConfig := &ClientConfig{
 		Endpoint:  config.Endpoint,
@@ -65,7 +101,7 @@ func TestIntegration_GetAllCertificates(t *testing.T) {
 
 	client, err := NewClient(config.Prefix, clientConfig)
 	if err != nil {
-		t.Skipf("Skipping integration test - failed to connect to Netscaler: %v", err)
+		skipUnreachable(t, config, err)
 	}
 
 	certificates, err := client.GetAllCertificates()
@@ -80,6 +116,10 @@ func TestIntegration_GetAllCertificates(t *testing.T) {
 
 	t.Logf("Retrieved %d certificates from Netscaler", len(certificates))
 
+	if config.Fake && len(certificates) != 2 {
+		t.Errorf("Expected 2 certificates with prefix %s from fake server, got %d", config.Prefix, len(certificates))
+	}
+
 	// If there are certificates, verify their structure
 	for i, cert := range certificates {
 		if cert == nil {
@@ -97,7 +137,7 @@ func TestIntegration_GetAllCertificates(t *testing.T) {
 }
 
 func TestIntegration_GetCertificate(t *testing.T) {
-	config := getIntegrationTestConfig()
+	config := getIntegrationTestConfig(t)
 
 	clientConfig := &ClientConfig{
 		Endpoint:  config.Endpoint,
@@ -109,7 +149,7 @@ func TestIntegration_GetCertificate(t *testing.T) {
 
 	client, err := NewClient(config.Prefix, clientConfig)
 	if err != nil {
-		t.Skipf("Skipping integration test - failed to connect to Netscaler: %v", err)
+		skipUnreachable(t, config, err)
 	}
 
 	// First, get all certificates to find a valid certificate name
@@ -169,7 +209,7 @@ func TestIntegration_GetCertificate(t *testing.T) {
 }
 
 func TestIntegration_GetCertificate_WithPrefix(t *testing.T) {
-	config := getIntegrationTestConfig()
+	config := getIntegrationTestConfig(t)
 
 	clientConfig := &ClientConfig{
 		Endpoint:  config.Endpoint,
@@ -181,7 +221,7 @@ func TestIntegration_GetCertificate_WithPrefix(t *testing.T) {
 
 	client, err := NewClient(config.Prefix, clientConfig)
 	if err != nil {
-		t.Skipf("Skipping integration test - failed to connect to Netscaler: %v", err)
+		skipUnreachable(t, config, err)
 	}
 
 	// Test with a sample certificate name
@@ -201,7 +241,7 @@ func TestIntegration_GetCertificate_WithPrefix(t *testing.T) {
 
 // Helper function to run integration tests only when explicitly requested
 func TestIntegration_Setup(t *testing.T) {
-	config := getIntegrationTestConfig()
+	config := getIntegrationTestConfig(t)
 	t.Logf("Integration test configuration:")
 	t.Logf("  Endpoint: %s", config.Endpoint)
 	t.Logf("  Username: %s", config.Username)
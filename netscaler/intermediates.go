@@ -0,0 +1,220 @@
+package netscaler
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// Intermediate is a certkey without private key, the CA certificate other certkeys link to
+type Intermediate struct {
+	Certkey     string `json:"certkey"`
+	Fingerprint string `json:"fingerprint"`
+	Subject     string `json:"subject"`
+	// LinkedBy lists the certkeys linked to this one
+	LinkedBy []string `json:"linkedBy,omitempty"`
+}
+
+// IntermediateGroup lists the certkeys holding the same CA certificate
+type IntermediateGroup struct {
+	Fingerprint string `json:"fingerprint"`
+	Subject     string `json:"subject"`
+	// Keep is the certkey the links of the others are moved to
+	Keep     string         `json:"keep"`
+	Certkeys []Intermediate `json:"certkeys"`
+}
+
+// Relink is a certkey whose link was moved by ConsolidateIntermediates
+type Relink struct {
+	Certkey string `json:"certkey"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate in hex
+func Fingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// IntermediateCertkey returns the name of the certkey a new CA certificate is installed as. It is
+// named after the fingerprint so certificates of all domains issued by the same CA share it.
+func (c *Client) IntermediateCertkey(cert *x509.Certificate) string {
+	return c.prefix + "ca-" + Fingerprint(cert)[:16]
+}
+
+// Intermediates returns the certkeys without private key on the appliance, regardless of the
+// environment prefix, with the fingerprints of their certificate files
+func (c *Client) Intermediates() ([]Intermediate, error) {
+	return c.intermediates(nil)
+}
+
+// DuplicateIntermediates groups the intermediates holding the same certificate. The certkey with
+// the most links is kept, preferring certkeys of the environment.
+func (c *Client) DuplicateIntermediates() ([]IntermediateGroup, error) {
+	intermediates, err := c.Intermediates()
+	if err != nil {
+		return nil, err
+	}
+
+	byFingerprint := make(map[string][]Intermediate)
+	for _, in := range intermediates {
+		byFingerprint[in.Fingerprint] = append(byFingerprint[in.Fingerprint], in)
+	}
+
+	var groups []IntermediateGroup
+	for fingerprint, certkeys := range byFingerprint {
+		if len(certkeys) < 2 {
+			continue
+		}
+		keep := slices.MinFunc(certkeys, func(a, b Intermediate) int {
+			if len(a.LinkedBy) != len(b.LinkedBy) {
+				return len(b.LinkedBy) - len(a.LinkedBy)
+			}
+			if ownA, ownB := strings.HasPrefix(a.Certkey, c.prefix), strings.HasPrefix(b.Certkey, c.prefix); ownA != ownB {
+				if ownA {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.Certkey, b.Certkey)
+		})
+		groups = append(groups, IntermediateGroup{
+			Fingerprint: fingerprint,
+			Subject:     certkeys[0].Subject,
+			Keep:        keep.Certkey,
+			Certkeys:    certkeys,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Keep < groups[j].Keep
+	})
+	return groups, nil
+}
+
+// ConsolidateIntermediates links the certkeys of the environment that are linked to a duplicate
+// to the certkey kept in its group. The duplicates themselves are left for prune.
+func (c *Client) ConsolidateIntermediates(groups []IntermediateGroup) ([]Relink, error) {
+	var relinked []Relink
+	for _, group := range groups {
+		for _, in := range group.Certkeys {
+			if in.Certkey == group.Keep {
+				continue
+			}
+			for _, certkey := range in.LinkedBy {
+				if !strings.HasPrefix(certkey, c.prefix) {
+					continue
+				}
+				if err := c.linkCertkey(certkey, group.Keep); err != nil {
+					return relinked, err
+				}
+				relinked = append(relinked, Relink{Certkey: certkey, From: in.Certkey, To: group.Keep})
+			}
+		}
+	}
+	return relinked, nil
+}
+
+// findIntermediate returns the certkey holding the CA certificate, preferring certkeys of the
+// environment, or an empty string if it isn't installed yet
+func (c *Client) findIntermediate(cert *x509.Certificate) (string, error) {
+	intermediates, err := c.intermediates(cert)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint := Fingerprint(cert)
+	found := ""
+	for _, in := range intermediates {
+		if in.Fingerprint != fingerprint {
+			continue
+		}
+		if strings.HasPrefix(in.Certkey, c.prefix) {
+			return in.Certkey, nil
+		}
+		if found == "" {
+			found = in.Certkey
+		}
+	}
+	return found, nil
+}
+
+// intermediates lists the certkeys without private key. If cert is given, only the certificate
+// files of certkeys whose serial number matches are downloaded.
+func (c *Client) intermediates(cert *x509.Certificate) ([]Intermediate, error) {
+	// FindAllResources reports failed requests as an empty list, deploy would install a duplicate
+	all, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: service.Sslcertkey.Type()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve certificates: %w", err)
+	}
+
+	linkedBy := make(map[string][]string)
+	for _, raw := range all {
+		if issuer := stringField(raw, "linkcertkeyname"); issuer != "" {
+			linkedBy[issuer] = append(linkedBy[issuer], stringField(raw, "certkey"))
+		}
+	}
+
+	var intermediates []Intermediate
+	for _, raw := range all {
+		if stringField(raw, "key") != "" {
+			continue
+		}
+		if cert != nil && !serialMatches(stringField(raw, "serial"), cert) {
+			continue
+		}
+		// Files outside the FileLocation can't be downloaded
+		file := c.sslFileName(stringField(raw, "cert"))
+		if file == "" {
+			continue
+		}
+
+		certkey := stringField(raw, "certkey")
+		installed, err := c.downloadCertificate(file)
+		if err != nil {
+			return nil, fmt.Errorf("certkey %s: %w", certkey, err)
+		}
+		linkers := linkedBy[certkey]
+		sort.Strings(linkers)
+		intermediates = append(intermediates, Intermediate{
+			Certkey:     certkey,
+			Fingerprint: Fingerprint(installed),
+			Subject:     installed.Subject.String(),
+			LinkedBy:    linkers,
+		})
+	}
+
+	sort.Slice(intermediates, func(i, j int) bool {
+		return intermediates[i].Certkey < intermediates[j].Certkey
+	})
+	return intermediates, nil
+}
+
+// downloadCertificate returns the first certificate of a PEM or DER file in the FileLocation
+func (c *Client) downloadCertificate(name string) (*x509.Certificate, error) {
+	data, err := c.DownloadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if certs, err := parsePEMCertificates(data); err == nil {
+		return certs[0], nil
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return cert, nil
+}
+
+// serialMatches reports whether the serial NITRO reports, in hex, is the serial of the
+// certificate. Without a reported serial every certificate matches.
+func serialMatches(serial string, cert *x509.Certificate) bool {
+	if serial == "" {
+		return true
+	}
+	return strings.EqualFold(strings.TrimLeft(serial, "0"), cert.SerialNumber.Text(16))
+}
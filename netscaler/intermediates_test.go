@@ -0,0 +1,170 @@
+package netscaler
+
+import (
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// addIntermediate installs the CA certificate as certkey without key, linked by the given certkeys
+func addIntermediate(t *testing.T, api *netscalertest.NitroClient, certkey string, ca *testCA, serial string, linkedBy ...string) {
+	t.Helper()
+	if err := api.Store.WriteFile(certkey+".crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})); err != nil {
+		t.Fatalf("Store.WriteFile() error = %v", err)
+	}
+	attrs := map[string]any{"certkey": certkey, "cert": certkey + ".crt"}
+	if serial != "" {
+		attrs["serial"] = serial
+	}
+	if err := api.Store.Add("sslcertkey", attrs); err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+	for _, name := range linkedBy {
+		if err := api.Store.Add("sslcertkey", map[string]any{"certkey": name, "cert": name + ".crt", "key": name + ".key", "linkcertkeyname": certkey}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+}
+
+func TestClient_DeployCertificate_ReusesIntermediate(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    map[string]string
+		other       bool
+		want        string
+		description string
+	}{
+		{
+			name:        "none",
+			want:        "",
+			description: "without a certkey holding the CA a new one named after the fingerprint should be installed",
+		},
+		{
+			name:        "legacy name",
+			existing:    map[string]string{"prod-example.net-ca": ""},
+			want:        "prod-example.net-ca",
+			description: "a certkey with the same fingerprint should be reused whatever its name",
+		},
+		{
+			name:        "other environment",
+			existing:    map[string]string{"staging-ca": "01"},
+			want:        "staging-ca",
+			description: "certkeys of other environments should be reused, the serial matches",
+		},
+		{
+			name:        "own environment first",
+			existing:    map[string]string{"staging-ca": "", "prod-example.net-ca": ""},
+			want:        "prod-example.net-ca",
+			description: "certkeys of the environment should be preferred",
+		},
+		{
+			name:        "different serial",
+			existing:    map[string]string{"staging-ca": "FF"},
+			want:        "",
+			description: "certkeys with another serial should not be downloaded nor reused",
+		},
+		{
+			name:        "different CA",
+			existing:    map[string]string{"prod-example.net-ca": ""},
+			other:       true,
+			want:        "",
+			description: "certkeys holding another CA with the same subject should not be reused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api := newTestKeyClient(t)
+			ca := newTestCA(t)
+			existingCA := ca
+			if tt.other {
+				existingCA = newTestCA(t)
+			}
+			for certkey, serial := range tt.existing {
+				addIntermediate(t, api, certkey, existingCA, serial)
+			}
+
+			keyfile := client.KeyFile("example.com")
+			if err := client.CreateKey(keyfile, KeySpec{Type: "rsa", Bits: 2048}); err != nil {
+				t.Fatalf("CreateKey() error = %v", err)
+			}
+			csr, err := client.CreateCSR(keyfile, client.CSRFile("example.com"), testCSRRequest)
+			if err != nil {
+				t.Fatalf("CreateCSR() error = %v", err)
+			}
+			d, err := client.DeployCertificate("example.com", ca.sign(t, csr, 2), keyfile)
+			if err != nil {
+				t.Fatalf("DeployCertificate() error = %v", err)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = client.IntermediateCertkey(ca.cert)
+			}
+			if d.ChainCertkey != want {
+				t.Errorf("ChainCertkey = %s, want %s", d.ChainCertkey, want)
+			}
+			if got := len(api.Store.List("sslcertkey")); tt.want != "" && got != len(tt.existing)+1 {
+				t.Errorf("DeployCertificate() should not install a duplicate, got %d certkeys", got)
+			}
+		})
+	}
+}
+
+func TestClient_FindIntermediate_Error(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	ca := newTestCA(t)
+	addIntermediate(t, api, "prod-example.net-ca", ca, "")
+
+	injected := errors.New("session expired")
+	api.FailNextCall("FindResourceArrayWithParams", "sslcertkey", injected)
+	if got, err := client.findIntermediate(ca.cert); !errors.Is(err, injected) {
+		t.Errorf("findIntermediate() = %q, %v, want the failed certkey list instead of a new intermediate", got, err)
+	}
+}
+
+func TestClient_ConsolidateIntermediates(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	ca, other := newTestCA(t), newTestCA(t)
+	addIntermediate(t, api, "prod-ca-shared", ca, "", "prod-a.com", "prod-b.com")
+	addIntermediate(t, api, "prod-example.net-ca", ca, "", "prod-example.net")
+	addIntermediate(t, api, "staging-ca", ca, "", "staging-a.com")
+	addIntermediate(t, api, "prod-other-ca", other, "", "prod-other.com")
+
+	groups, err := client.DuplicateIntermediates()
+	if err != nil {
+		t.Fatalf("DuplicateIntermediates() error = %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("DuplicateIntermediates() = %+v, want one group", groups)
+	}
+	if groups[0].Keep != "prod-ca-shared" || groups[0].Fingerprint != Fingerprint(ca.cert) || len(groups[0].Certkeys) != 3 {
+		t.Errorf("DuplicateIntermediates() = %+v", groups[0])
+	}
+
+	planned, planner := client.Planned()
+	if _, err := planned.ConsolidateIntermediates(groups); err != nil {
+		t.Fatalf("ConsolidateIntermediates() with planner error = %v", err)
+	}
+	if steps := planner.Plan("prod").Steps; len(steps) != 2 || steps[0].Action != "unlink" || steps[1].Action != "link" {
+		t.Errorf("planned steps = %+v, want unlink and link", steps)
+	}
+
+	relinked, err := client.ConsolidateIntermediates(groups)
+	if err != nil {
+		t.Fatalf("ConsolidateIntermediates() error = %v", err)
+	}
+	want := []Relink{{Certkey: "prod-example.net", From: "prod-example.net-ca", To: "prod-ca-shared"}}
+	if !reflect.DeepEqual(relinked, want) {
+		t.Errorf("ConsolidateIntermediates() = %+v, want %+v", relinked, want)
+	}
+	if res, _ := api.Store.Get("sslcertkey", "prod-example.net"); res["linkcertkeyname"] != "prod-ca-shared" {
+		t.Errorf("prod-example.net = %v, want it linked to prod-ca-shared", res)
+	}
+	if res, _ := api.Store.Get("sslcertkey", "staging-a.com"); res["linkcertkeyname"] != "staging-ca" {
+		t.Errorf("certkeys of other environments should keep their link, got %v", res)
+	}
+}
@@ -0,0 +1,202 @@
+package netscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// InventoryVersion is the version of the inventory snapshot format
+const InventoryVersion = 1
+
+// Inventory is a snapshot of the deployed certificates of several environments
+type Inventory struct {
+	Version      int                             `json:"version"`
+	GeneratedAt  time.Time                       `json:"generatedAt"`
+	Environments map[string]InventoryEnvironment `json:"environments"`
+}
+
+// InventoryEnvironment holds the certificates of one environment as returned by the NITRO API
+type InventoryEnvironment struct {
+	Prefix       string           `json:"prefix"`
+	Certificates []map[string]any `json:"certificates"`
+	// Details holds the parsed fields, bindings and chain of each certificate keyed by certkey
+	Details map[string]CertificateDetails `json:"details,omitempty"`
+}
+
+// CertificateDetails is a parsed certificate with everything that depends on it
+type CertificateDetails struct {
+	*Certificate
+	Bindings []Binding `json:"bindings"`
+	// Chain lists the linked certkeys, starting with the issuer
+	Chain []string `json:"chain"`
+}
+
+// NewInventory returns an empty inventory of the current format version
+func NewInventory() *Inventory {
+	return &Inventory{
+		Version:      InventoryVersion,
+		GeneratedAt:  time.Now().UTC(),
+		Environments: make(map[string]InventoryEnvironment),
+	}
+}
+
+// LoadInventory reads an inventory snapshot from a JSON file
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+
+	var inv Inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+	if inv.Version != InventoryVersion {
+		return nil, fmt.Errorf("unsupported inventory version %d in %s", inv.Version, path)
+	}
+
+	return &inv, nil
+}
+
+// Save writes the inventory as indented JSON
+func (i *Inventory) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(i)
+}
+
+// Source returns a certificate source serving the snapshot of an environment
+func (i *Inventory) Source(environment string) (*InventorySource, error) {
+	env, ok := i.Environments[environment]
+	if !ok {
+		return nil, fmt.Errorf("environment %s not found in inventory", environment)
+	}
+	return &InventorySource{prefix: env.Prefix, certificates: env.Certificates}, nil
+}
+
+// InventorySource is a certificate source backed by an inventory snapshot
+type InventorySource struct {
+	prefix       string
+	certificates []map[string]any
+}
+
+// GetAllCertificates returns all certificates of the snapshot
+func (s *InventorySource) GetAllCertificates() ([]map[string]any, error) {
+	certs := make([]map[string]any, 0, len(s.certificates))
+	for _, cert := range s.certificates {
+		certs = append(certs, copyMap(cert))
+	}
+	return certs, nil
+}
+
+// GetCertificate returns the certificate with the prefixed name
+func (s *InventorySource) GetCertificate(name string) (map[string]any, error) {
+	certkey := s.prefix + name
+	for _, cert := range s.certificates {
+		if cert["certkey"] == certkey {
+			return copyMap(cert), nil
+		}
+	}
+	return nil, fmt.Errorf("certificate %s not found in inventory", certkey)
+}
+
+// Health always succeeds as the snapshot is held in memory
+func (s *InventorySource) Health() error {
+	return nil
+}
+
+// Close does nothing
+func (s *InventorySource) Close() error {
+	return nil
+}
+
+// CertificateDetails returns the details of all certificates keyed by certkey.
+// Certificates of snapshots without details are parsed on the fly, without bindings and chain.
+func (e InventoryEnvironment) CertificateDetails() (map[string]CertificateDetails, error) {
+	details := make(map[string]CertificateDetails, len(e.Certificates))
+	for _, raw := range e.Certificates {
+		cert, err := ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := e.Details[cert.Certkey]; ok && d.Certificate != nil {
+			details[cert.Certkey] = d
+			continue
+		}
+		details[cert.Certkey] = CertificateDetails{Certificate: cert, Bindings: []Binding{}, Chain: []string{}}
+	}
+	return details, nil
+}
+
+// Snapshot captures the certificates of the environment with their bindings and chain.
+// Secrets such as passcrypt are redacted.
+func (c *Client) Snapshot() (*InventoryEnvironment, error) {
+	certs, err := c.GetAllCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	env := &InventoryEnvironment{
+		Prefix:       c.prefix,
+		Certificates: make([]map[string]any, 0, len(certs)),
+		Details:      make(map[string]CertificateDetails, len(certs)),
+	}
+	for _, raw := range certs {
+		env.Certificates = append(env.Certificates, redact(raw).(map[string]any))
+
+		cert, err := ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		bindings, err := c.GetCertificateBindings(cert.Certkey)
+		if err != nil {
+			return nil, err
+		}
+		chain, err := c.GetCertificateChain(cert)
+		if err != nil {
+			return nil, err
+		}
+		env.Details[cert.Certkey] = CertificateDetails{Certificate: cert, Bindings: bindings, Chain: chain}
+	}
+
+	return env, nil
+}
+
+// maxChainLength limits how many linked certkeys are followed
+const maxChainLength = 10
+
+// GetCertificateChain follows the links of a certificate and returns the linked certkeys, starting with the issuer
+func (c *Client) GetCertificateChain(cert *Certificate) ([]string, error) {
+	chain := []string{}
+	seen := map[string]bool{cert.Certkey: true}
+	for link := cert.LinkCertkey; link != ""; {
+		if seen[link] {
+			return nil, fmt.Errorf("certificate chain of %s contains a loop at %s", cert.Certkey, link)
+		}
+		if len(chain) == maxChainLength {
+			return nil, fmt.Errorf("certificate chain of %s is longer than %d", cert.Certkey, maxChainLength)
+		}
+		seen[link] = true
+		chain = append(chain, link)
+
+		raw, err := c.api.FindResource(service.Sslcertkey.Type(), link)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve linked certificate %s: %w", link, err)
+		}
+		link = stringField(raw, "linkcertkeyname")
+	}
+	return chain, nil
+}
+
+func copyMap(m map[string]any) map[string]any {
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
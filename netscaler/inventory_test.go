@@ -0,0 +1,155 @@
+package netscaler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func writeInventory(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write inventory: %v", err)
+	}
+	return path
+}
+
+func TestLoadInventory(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "valid inventory",
+			content:     `{"version": 1, "environments": {"prod": {"prefix": "prod-", "certificates": []}}}`,
+			wantErr:     false,
+			description: "should load a valid inventory",
+		},
+		{
+			name:        "unsupported version",
+			content:     `{"version": 2, "environments": {}}`,
+			wantErr:     true,
+			description: "should reject snapshots of an unknown format version",
+		},
+		{
+			name:        "invalid json",
+			content:     `{"version": `,
+			wantErr:     true,
+			description: "should fail on malformed JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadInventory(writeInventory(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadInventory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadInventory(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadInventory() should fail for missing files")
+	}
+}
+
+func TestInventorySource(t *testing.T) {
+	inv := &Inventory{
+		Version: InventoryVersion,
+		Environments: map[string]InventoryEnvironment{
+			"prod": {
+				Prefix: "prod-",
+				Certificates: []map[string]any{
+					{"certkey": "prod-example.com", "subject": "CN=example.com"},
+					{"certkey": "prod-www.example.com", "subject": "CN=www.example.com"},
+				},
+			},
+		},
+	}
+
+	if _, err := inv.Source("staging"); err == nil {
+		t.Error("Source() should fail for unknown environments")
+	}
+
+	src, err := inv.Source("prod")
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+
+	all, err := src.GetAllCertificates()
+	if err != nil || len(all) != 2 {
+		t.Errorf("GetAllCertificates() = %v, %v, want 2 certificates", all, err)
+	}
+
+	cert, err := src.GetCertificate("example.com")
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if cert["subject"] != "CN=example.com" {
+		t.Errorf("GetCertificate() subject = %v, want CN=example.com", cert["subject"])
+	}
+	cert["subject"] = "modified"
+	if again, _ := src.GetCertificate("example.com"); again["subject"] != "CN=example.com" {
+		t.Error("GetCertificate() should return a copy")
+	}
+
+	if _, err := src.GetCertificate("missing.example.com"); err == nil {
+		t.Error("GetCertificate() should fail for missing certificates")
+	}
+	if err := src.Health(); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+	if err := src.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestClient_Snapshot(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	for _, cert := range []map[string]any{
+		{"certkey": "root", "serial": "00"},
+		{"certkey": "intermediate", "serial": "01", "linkcertkeyname": "root"},
+		{"certkey": "prod-example.com", "serial": "02", "linkcertkeyname": "intermediate", "passcrypt": "secret"},
+		{"certkey": "prod-loop.example.com", "linkcertkeyname": "prod-loop.example.com"},
+	} {
+		_ = api.Store.Add("sslcertkey", cert)
+	}
+	_ = api.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	if _, err := client.Snapshot(); err == nil {
+		t.Fatal("Snapshot() should fail on chain loops")
+	}
+	_ = api.Store.Delete("sslcertkey", "prod-loop.example.com")
+
+	env, err := client.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if env.Prefix != "prod-" || len(env.Certificates) != 1 {
+		t.Fatalf("Snapshot() = %+v, want the prefixed certificate only", env)
+	}
+	if env.Certificates[0]["passcrypt"] != Redacted {
+		t.Errorf("passcrypt = %v, want it redacted", env.Certificates[0]["passcrypt"])
+	}
+
+	d := env.Details["prod-example.com"]
+	if d.Serial != "02" {
+		t.Errorf("Serial = %q, want 02", d.Serial)
+	}
+	if len(d.Chain) != 2 || d.Chain[0] != "intermediate" || d.Chain[1] != "root" {
+		t.Errorf("Chain = %v, want [intermediate root]", d.Chain)
+	}
+	if len(d.Bindings) != 1 || d.Bindings[0].Name != "vs-web" {
+		t.Errorf("Bindings = %v, want vs-web", d.Bindings)
+	}
+}
@@ -0,0 +1,379 @@
+package netscaler
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// SSLFileLocation is the directory of certificate, key and CSR files on the appliance. Admin
+// partitions have their own directory, see PartitionFileLocation.
+const SSLFileLocation = "/nsconfig/ssl"
+
+// PartitionFileLocation returns the directory of certificate, key and CSR files of an admin
+// partition, SSLFileLocation for the default partition
+func PartitionFileLocation(partition string) string {
+	if partition == "" || partition == DefaultPartition {
+		return SSLFileLocation
+	}
+	return path.Join("/nsconfig/partitions", partition, "ssl")
+}
+
+// errCodeNoSuchResource is the NITRO errorcode for missing resources
+const errCodeNoSuchResource = 258
+
+// eccCurves maps curve names to the NITRO names of sslecdsakey curves
+var eccCurves = map[string]string{
+	"P-256": "P_256",
+	"P-384": "P_384",
+}
+
+// KeySpec describes a private key generated on the appliance
+type KeySpec struct {
+	// Type is rsa or ec
+	Type string
+	// Bits is the size of RSA keys
+	Bits int
+	// Curve is the curve of EC keys, P-256 or P-384
+	Curve string
+}
+
+// CSRRequest holds the subject of a certificate signing request. The appliance requires
+// country, state and organization even though ACME CAs ignore them.
+type CSRRequest struct {
+	CommonName   string
+	SANs         []string
+	Country      string
+	State        string
+	Organization string
+}
+
+// Deployment describes a certificate installed by DeployCertificate
+type Deployment struct {
+	Certkey  string `json:"certkey"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ChainCertkey is the certkey the certificate is linked to, if a chain was deployed
+	ChainCertkey string `json:"chainCertkey,omitempty"`
+	// Updated is set when an existing certkey was updated in place
+	Updated bool `json:"updated"`
+	// Previous is the certkey version replaced by RotateCertificate
+	Previous string `json:"previous,omitempty"`
+	// Rebound lists the bindings moved from the previous version
+	Rebound []Binding `json:"rebound,omitempty"`
+	// Retired lists the versions deleted after their grace period
+	Retired []string `json:"retired,omitempty"`
+	// Verified holds the TLS handshakes with the VIPs, if the deployment was verified
+	Verified []TLSCheck `json:"verified,omitempty"`
+}
+
+// KeyFile returns the name of the on-box key file of a domain
+func (c *Client) KeyFile(name string) string {
+	return c.prefix + name + ".key"
+}
+
+// CSRFile returns the name of the on-box CSR file of a domain
+func (c *Client) CSRFile(name string) string {
+	return c.prefix + name + ".csr"
+}
+
+// FileLocation returns the directory of the certificate, key and CSR files of the partition of the client
+func (c *Client) FileLocation() string {
+	return PartitionFileLocation(c.partition)
+}
+
+// FileExists reports whether a file exists in the FileLocation
+func (c *Client) FileExists(name string) (bool, error) {
+	files, err := c.listFiles()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(files, name), nil
+}
+
+// DownloadFile returns the content of a file in the FileLocation
+func (c *Client) DownloadFile(name string) ([]byte, error) {
+	files, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: service.Systemfile.Type(),
+		ArgsMap: map[string]string{
+			"filelocation": url.QueryEscape(c.FileLocation()),
+			"filename":     name,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("file %s not found in %s", name, c.FileLocation())
+	}
+
+	content, err := base64.StdEncoding.DecodeString(stringField(files[0], "filecontent"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return content, nil
+}
+
+// UploadFile writes a new file to the FileLocation
+func (c *Client) UploadFile(name string, content []byte) error {
+	_, err := c.api.AddResource(service.Systemfile.Type(), name, map[string]any{
+		"filename":     name,
+		"filelocation": c.FileLocation(),
+		"filecontent":  base64.StdEncoding.EncodeToString(content),
+		"fileencoding": "BASE64",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+// CreateKey generates a private key on the appliance, the key never leaves it
+func (c *Client) CreateKey(keyfile string, spec KeySpec) error {
+	var err error
+	switch spec.Type {
+	case "rsa", "":
+		bits := spec.Bits
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits < 2048 {
+			return fmt.Errorf("RSA keys need at least 2048 bits, got %d", bits)
+		}
+		err = c.api.ActOnResource(service.Sslrsakey.Type(), map[string]any{
+			"keyfile":  keyfile,
+			"bits":     bits,
+			"exponent": "F4",
+			"keyform":  "PEM",
+		}, "create")
+	case "ec":
+		curve, ok := eccCurves[spec.Curve]
+		if !ok {
+			return fmt.Errorf("unsupported EC curve %q, use P-256 or P-384", spec.Curve)
+		}
+		err = c.api.ActOnResource("sslecdsakey", map[string]any{
+			"keyfile": keyfile,
+			"curve":   curve,
+			"keyform": "PEM",
+		}, "create")
+	default:
+		return fmt.Errorf("unsupported key type %q, use rsa or ec", spec.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create key %s: %w", keyfile, err)
+	}
+	return nil
+}
+
+// CreateCSR generates a certificate signing request for an on-box key and returns it PEM encoded.
+// Planned clients return no CSR.
+func (c *Client) CreateCSR(keyfile, reqfile string, req CSRRequest) ([]byte, error) {
+	if req.Country == "" || req.State == "" || req.Organization == "" {
+		return nil, fmt.Errorf("country, state and organization are required by the appliance")
+	}
+
+	sans := make([]string, 0, len(req.SANs))
+	for _, san := range req.SANs {
+		sans = append(sans, "DNS:"+san)
+	}
+	err := c.api.ActOnResource(service.Sslcertreq.Type(), map[string]any{
+		"reqfile":          reqfile,
+		"keyfile":          keyfile,
+		"keyform":          "PEM",
+		"commonname":       req.CommonName,
+		"subjectaltname":   strings.Join(sans, " "),
+		"countryname":      req.Country,
+		"statename":        req.State,
+		"organizationname": req.Organization,
+		"digestmethod":     "SHA256",
+	}, "create")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR %s: %w", reqfile, err)
+	}
+	if c.planning {
+		// A planned CSR cannot be read back
+		return nil, nil
+	}
+	return c.DownloadFile(reqfile)
+}
+
+// DeployCertificate installs a PEM certificate, optionally followed by its chain, against the
+// on-box key file. The current certkey of the domain, the newest version if it was rotated, is
+// updated in place. The certkey is linked to the first chain certificate, which is installed
+// unless a certkey already holds it.
+func (c *Client) DeployCertificate(name string, pemData []byte, keyfile string) (*Deployment, error) {
+	certs, err := parsePEMCertificates(pemData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.requireFile(keyfile); err != nil {
+		return nil, err
+	}
+
+	versions, err := c.certkeyVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	d := &Deployment{
+		Certkey: c.prefix + name,
+		KeyFile: keyfile,
+	}
+	if len(versions) > 0 {
+		d.Certkey = stringField(versions[len(versions)-1], "certkey")
+	}
+	d.CertFile = certFileName(d.Certkey, certs[0])
+	if d.Updated, err = c.installCertkey(d.Certkey, d.CertFile, certs[0], keyfile); err != nil {
+		return nil, err
+	}
+
+	if len(certs) > 1 {
+		if d.ChainCertkey, err = c.installChain(d.Certkey, certs[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// installChain links the certkey to the certkey holding its issuer, reusing any certkey with the
+// same fingerprint, and installs the issuer as IntermediateCertkey if there is none
+func (c *Client) installChain(certkey string, issuer *x509.Certificate) (string, error) {
+	chainCertkey, err := c.findIntermediate(issuer)
+	if err != nil {
+		return "", err
+	}
+	if chainCertkey == "" {
+		chainCertkey = c.IntermediateCertkey(issuer)
+		if _, err := c.installCertkey(chainCertkey, chainCertkey+".crt", issuer, ""); err != nil {
+			return "", err
+		}
+	}
+	if err := c.linkCertkey(certkey, chainCertkey); err != nil {
+		return "", err
+	}
+	return chainCertkey, nil
+}
+
+// requireFile fails unless the file exists in the FileLocation
+func (c *Client) requireFile(name string) error {
+	exists, err := c.FileExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("key file %s not found on the appliance", name)
+	}
+	return nil
+}
+
+// installCertkey uploads the certificate unless the file exists and adds the certkey, or updates it if it exists
+func (c *Client) installCertkey(certkey, certFile string, cert *x509.Certificate, keyfile string) (bool, error) {
+	uploaded, err := c.FileExists(certFile)
+	if err != nil {
+		return false, err
+	}
+	if !uploaded {
+		if err := c.UploadFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})); err != nil {
+			return false, err
+		}
+	}
+
+	existing, err := c.findCertkey(certkey)
+	if err != nil {
+		return false, err
+	}
+
+	attrs := map[string]any{"certkey": certkey, "cert": certFile}
+	if keyfile != "" {
+		attrs["key"] = keyfile
+	}
+	if existing == nil {
+		if _, err := c.api.AddResource(service.Sslcertkey.Type(), certkey, attrs); err != nil {
+			return false, fmt.Errorf("failed to add certkey %s: %w", certkey, err)
+		}
+		return false, nil
+	}
+
+	attrs["nodomaincheck"] = true
+	if err := c.api.ActOnResource(service.Sslcertkey.Type(), attrs, "update"); err != nil {
+		return false, fmt.Errorf("failed to update certkey %s: %w", certkey, err)
+	}
+	return true, nil
+}
+
+// linkCertkey links a certkey to its issuer, replacing an existing link to another certkey
+func (c *Client) linkCertkey(certkey, issuer string) error {
+	current, err := c.findCertkey(certkey)
+	if err != nil {
+		return err
+	}
+	switch linked := stringField(current, "linkcertkeyname"); linked {
+	case issuer:
+		return nil
+	case "":
+	default:
+		if err := c.api.ActOnResource(service.Sslcertkey.Type(), map[string]any{"certkey": certkey}, "unlink"); err != nil {
+			return fmt.Errorf("failed to unlink %s from %s: %w", certkey, linked, err)
+		}
+	}
+
+	if err := c.api.ActOnResource(service.Sslcertkey.Type(), map[string]any{"certkey": certkey, "linkcertkeyname": issuer}, "link"); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", certkey, issuer, err)
+	}
+	return nil
+}
+
+// findCertkey returns the certkey, or nil if it doesn't exist
+func (c *Client) findCertkey(certkey string) (map[string]any, error) {
+	res, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType:             service.Sslcertkey.Type(),
+		ResourceName:             certkey,
+		ResourceMissingErrorCode: errCodeNoSuchResource,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve certkey %s: %w", certkey, err)
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
+
+// certFileName names the file of a certificate after its certkey and fingerprint, so every
+// certificate gets its own file and uploading the same certificate again is a no-op
+func certFileName(certkey string, cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("%s-%x.crt", certkey, sum[:8])
+}
+
+// parsePEMCertificates returns the certificates of a PEM bundle, leaf first
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,300 @@
+package netscaler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// testCSRRequest is a CSR subject accepted by the appliance
+var testCSRRequest = CSRRequest{
+	CommonName:   "example.com",
+	SANs:         []string{"example.com", "www.example.com"},
+	Country:      "DE",
+	State:        "Berlin",
+	Organization: "Example",
+}
+
+// testCA is an intermediate CA signing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA creates a self-signed CA
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// sign issues a certificate for the PEM CSR and returns it followed by the CA certificate
+func (ca *testCA) sign(t *testing.T, csrPEM []byte, serial int64) []byte {
+	t.Helper()
+
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		t.Fatal("CSR is not PEM encoded")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to sign CSR: %v", err)
+	}
+
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+}
+
+// signTestCSR issues a certificate for the CSR by a new CA and returns it followed by the CA certificate
+func signTestCSR(t *testing.T, csrPEM []byte, serial int64) []byte {
+	t.Helper()
+	return newTestCA(t).sign(t, csrPEM, serial)
+}
+
+func newTestKeyClient(t *testing.T) (*Client, *netscalertest.NitroClient) {
+	t.Helper()
+	api := netscalertest.NewNitroClient()
+	client, err := NewClientFromNitro("prod-", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	return client, api
+}
+
+func TestClient_CreateKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        KeySpec
+		wantPEM     string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "rsa",
+			spec:        KeySpec{Type: "rsa", Bits: 2048},
+			wantPEM:     "RSA PRIVATE KEY",
+			description: "should create an RSA key with sslrsakey",
+		},
+		{
+			name:        "ec",
+			spec:        KeySpec{Type: "ec", Curve: "P-384"},
+			wantPEM:     "EC PRIVATE KEY",
+			description: "should create an EC key with sslecdsakey",
+		},
+		{
+			name:        "weak rsa",
+			spec:        KeySpec{Type: "rsa", Bits: 1024},
+			wantErr:     true,
+			description: "should reject RSA keys below 2048 bits",
+		},
+		{
+			name:        "unknown curve",
+			spec:        KeySpec{Type: "ec", Curve: "P-521"},
+			wantErr:     true,
+			description: "should reject curves the appliance doesn't support",
+		},
+		{
+			name:        "unknown type",
+			spec:        KeySpec{Type: "dsa"},
+			wantErr:     true,
+			description: "should reject unknown key types",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api := newTestKeyClient(t)
+
+			err := client.CreateKey("prod-example.com.key", tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			key, err := api.Store.ReadFile("prod-example.com.key")
+			if err != nil {
+				t.Fatalf("key file not created: %v", err)
+			}
+			if !strings.Contains(string(key), tt.wantPEM) {
+				t.Errorf("key file = %q, want %s", key, tt.wantPEM)
+			}
+		})
+	}
+}
+
+func TestClient_PartitionFiles(t *testing.T) {
+	store := newPartitionAPI(t)
+	client, err := NewPartitionClientFromNitro("prod-", "team-a", netscalertest.NewNitroClientWithStore(store))
+	if err != nil {
+		t.Fatalf("NewPartitionClientFromNitro() error = %v", err)
+	}
+	if got, want := client.FileLocation(), "/nsconfig/partitions/team-a/ssl"; got != want {
+		t.Errorf("FileLocation() = %q, want %q", got, want)
+	}
+
+	keyfile := client.KeyFile("example.com")
+	if err := client.CreateKey(keyfile, KeySpec{Type: "rsa", Bits: 2048}); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	if _, err := client.CreateCSR(keyfile, client.CSRFile("example.com"), testCSRRequest); err != nil {
+		t.Fatalf("CreateCSR() error = %v", err)
+	}
+	if err := client.UploadFile("prod-example.com.crt", []byte("cert")); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if _, err := store.Partition("team-a").ReadFile("/nsconfig/partitions/team-a/ssl/prod-example.com.crt"); err != nil {
+		t.Errorf("UploadFile() should write to the directory of the partition: %v", err)
+	}
+	if _, err := store.ReadFile("prod-example.com.key"); err == nil {
+		t.Error("CreateKey() should not write to the default partition")
+	}
+
+	_ = store.Partition("team-a").Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "cert": "/nsconfig/partitions/team-a/ssl/prod-example.com.crt", "key": keyfile})
+	files, err := client.CertkeyFiles("prod-example.com")
+	if want := []string{"prod-example.com.crt", "prod-example.com.key"}; err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("CertkeyFiles() = %v, %v, want %v", files, err, want)
+	}
+	if exists, err := client.FileExists("prod-example.com.csr"); err != nil || !exists {
+		t.Errorf("FileExists() = %v, %v, want the CSR in the partition", exists, err)
+	}
+	if got := PartitionFileLocation(DefaultPartition); got != SSLFileLocation {
+		t.Errorf("PartitionFileLocation(default) = %q, want %q", got, SSLFileLocation)
+	}
+}
+
+func TestClient_CreateCSR(t *testing.T) {
+	client, _ := newTestKeyClient(t)
+	if err := client.CreateKey(client.KeyFile("example.com"), KeySpec{Type: "ec", Curve: "P-256"}); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	csrPEM, err := client.CreateCSR(client.KeyFile("example.com"), client.CSRFile("example.com"), testCSRRequest)
+	if err != nil {
+		t.Fatalf("CreateCSR() error = %v", err)
+	}
+
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		t.Fatalf("CreateCSR() = %q, want a PEM CSR", csrPEM)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+	if csr.Subject.CommonName != "example.com" || !reflect.DeepEqual(csr.DNSNames, testCSRRequest.SANs) {
+		t.Errorf("CSR subject = %s, SANs = %v", csr.Subject, csr.DNSNames)
+	}
+
+	if _, err := client.CreateCSR(client.KeyFile("example.com"), "other.csr", CSRRequest{CommonName: "example.com"}); err == nil {
+		t.Error("CreateCSR() without country, state and organization should fail")
+	}
+	if _, err := client.CreateCSR("missing.key", "missing.csr", testCSRRequest); err == nil {
+		t.Error("CreateCSR() with a missing key should fail")
+	}
+}
+
+func TestClient_DeployCertificate(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	keyfile := client.KeyFile("example.com")
+	if err := client.CreateKey(keyfile, KeySpec{Type: "rsa", Bits: 2048}); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	csr, err := client.CreateCSR(keyfile, client.CSRFile("example.com"), testCSRRequest)
+	if err != nil {
+		t.Fatalf("CreateCSR() error = %v", err)
+	}
+
+	// First deployment adds the certkey and its chain
+	ca := newTestCA(t)
+	d, err := client.DeployCertificate("example.com", ca.sign(t, csr, 1), keyfile)
+	if err != nil {
+		t.Fatalf("DeployCertificate() error = %v", err)
+	}
+	if d.Certkey != "prod-example.com" || d.ChainCertkey != client.IntermediateCertkey(ca.cert) || d.Updated {
+		t.Errorf("DeployCertificate() = %+v", d)
+	}
+	res, err := api.Store.Get("sslcertkey", "prod-example.com")
+	if err != nil {
+		t.Fatalf("certkey not created: %v", err)
+	}
+	if res["key"] != keyfile || res["cert"] != d.CertFile || res["linkcertkeyname"] != d.ChainCertkey {
+		t.Errorf("certkey = %v", res)
+	}
+	if _, err := api.Store.ReadFile(d.CertFile); err != nil {
+		t.Errorf("certificate file not uploaded: %v", err)
+	}
+
+	// Renewals update the certkey in place against the same key
+	d, err = client.DeployCertificate("example.com", signTestCSR(t, csr, 2), keyfile)
+	if err != nil {
+		t.Fatalf("DeployCertificate() renewal error = %v", err)
+	}
+	if !d.Updated {
+		t.Errorf("DeployCertificate() renewal = %+v, want an update", d)
+	}
+	res, _ = api.Store.Get("sslcertkey", "prod-example.com")
+	if res["cert"] != d.CertFile || res["key"] != keyfile {
+		t.Errorf("certkey after renewal = %v", res)
+	}
+}
+
+func TestClient_DeployCertificate_Errors(t *testing.T) {
+	client, _ := newTestKeyClient(t)
+
+	if _, err := client.DeployCertificate("example.com", []byte("not a certificate"), "prod-example.com.key"); err == nil {
+		t.Error("DeployCertificate() without PEM certificate should fail")
+	}
+
+	cert := signTestCSR(t, mustTestCSR(t), 1)
+	if _, err := client.DeployCertificate("example.com", cert, "prod-example.com.key"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeployCertificate() without key file error = %v, want not found", err)
+	}
+}
+
+// mustTestCSR creates a CSR with a key generated in the test
+func mustTestCSR(t *testing.T) []byte {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "example.com"}}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
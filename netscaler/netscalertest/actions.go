@@ -0,0 +1,241 @@
+package netscalertest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Act applies a NITRO action such as update, link, unlink or create to a resource.
+// Creating sslrsakey, sslecdsakey and sslcertreq resources writes real key and CSR files.
+// Saving nsconfig is counted, see Saves, and syncing hafiles copies the files to the paired store.
+func (s *Store) Act(resourceType, name string, attrs map[string]any, action string) error {
+	if name == "" {
+		name = ResourceName(resourceType, attrs)
+	}
+
+	switch action {
+	case "update":
+		return s.Update(resourceType, name, attrs)
+	case "link":
+		return s.Update(resourceType, name, map[string]any{"linkcertkeyname": attrs["linkcertkeyname"]})
+	case "unlink":
+		return s.Update(resourceType, name, map[string]any{"linkcertkeyname": nil})
+	case "create":
+		switch resourceType {
+		case "sslrsakey", "sslecdsakey":
+			return s.createKey(resourceType, attrs)
+		case "sslcertreq":
+			return s.createCertReq(attrs)
+		}
+	case "save":
+		if resourceType == "nsconfig" {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.saves++
+			return nil
+		}
+	case "sync":
+		if resourceType == "hafiles" {
+			return s.syncFiles()
+		}
+	}
+	return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: fmt.Sprintf("Invalid action %s for %s", action, resourceType)}
+}
+
+// Files returns the systemfile entries of a directory, or the named file including its content
+func (s *Store) Files(location, filename string) ([]map[string]any, error) {
+	if location == "" {
+		location = s.FileLocation()
+	}
+
+	if filename != "" {
+		res, err := s.Get("systemfile", path.Join(location, filename))
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]any{res}, nil
+	}
+
+	var files []map[string]any
+	for _, f := range s.List("systemfile") {
+		if f["filelocation"] == location {
+			delete(f, "filecontent")
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// ReadFile returns the decoded content of a systemfile, relative names are looked up in FileLocation
+func (s *Store) ReadFile(name string) ([]byte, error) {
+	if !path.IsAbs(name) {
+		name = path.Join(s.FileLocation(), name)
+	}
+	res, err := s.Get("systemfile", name)
+	if err != nil {
+		return nil, err
+	}
+	content, _ := res["filecontent"].(string)
+	return base64.StdEncoding.DecodeString(content)
+}
+
+// WriteFile stores a systemfile, relative names are stored in FileLocation
+func (s *Store) WriteFile(name string, content []byte) error {
+	if !path.IsAbs(name) {
+		name = path.Join(s.FileLocation(), name)
+	}
+	return s.Add("systemfile", map[string]any{
+		"filename":     path.Base(name),
+		"filelocation": path.Dir(name),
+		"filecontent":  base64.StdEncoding.EncodeToString(content),
+		"fileencoding": "BASE64",
+	})
+}
+
+// Pair makes the store the primary of an HA pair, syncing hafiles copies its files to the secondary
+func (s *Store) Pair(secondary *Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secondary = secondary
+}
+
+// Saves returns how often the configuration was saved
+func (s *Store) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+// syncFiles copies the files of FileLocation the secondary doesn't have
+func (s *Store) syncFiles() error {
+	s.mu.Lock()
+	secondary := s.secondary
+	s.mu.Unlock()
+	if secondary == nil {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Operation not supported on a standalone node"}
+	}
+
+	for _, f := range s.List("systemfile") {
+		if f["filelocation"] != s.FileLocation() {
+			continue
+		}
+		if _, err := secondary.Get("systemfile", ResourceName("systemfile", f)); err == nil {
+			continue
+		}
+		if err := secondary.Add("systemfile", f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createKey generates the private key of an sslrsakey or sslecdsakey create action
+func (s *Store) createKey(resourceType string, attrs map[string]any) error {
+	keyfile, _ := attrs["keyfile"].(string)
+	if keyfile == "" {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Required argument missing [keyfile]"}
+	}
+
+	var der []byte
+	var blockType string
+	if resourceType == "sslrsakey" {
+		bits := 2048
+		if b, ok := attrs["bits"].(float64); ok {
+			bits = int(b)
+		} else if b, ok := attrs["bits"].(int); ok {
+			bits = b
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()}
+		}
+		der, blockType = x509.MarshalPKCS1PrivateKey(key), "RSA PRIVATE KEY"
+	} else {
+		curves := map[string]elliptic.Curve{"P_256": elliptic.P256(), "P_384": elliptic.P384()}
+		curveName, _ := attrs["curve"].(string)
+		curve, ok := curves[curveName]
+		if !ok {
+			return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: fmt.Sprintf("Invalid curve [%s]", curveName)}
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()}
+		}
+		if der, err = x509.MarshalECPrivateKey(key); err != nil {
+			return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()}
+		}
+		blockType = "EC PRIVATE KEY"
+	}
+
+	return s.WriteFile(keyfile, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+// createCertReq writes a CSR signed by an existing key file for an sslcertreq create action
+func (s *Store) createCertReq(attrs map[string]any) error {
+	reqfile, _ := attrs["reqfile"].(string)
+	keyfile, _ := attrs["keyfile"].(string)
+	commonName, _ := attrs["commonname"].(string)
+	if reqfile == "" || keyfile == "" || commonName == "" {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Required argument missing [reqfile, keyfile, commonname]"}
+	}
+
+	keyPEM, err := s.ReadFile(keyfile)
+	if err != nil {
+		return err
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()}
+	}
+
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}
+	if v, _ := attrs["organizationname"].(string); v != "" {
+		template.Subject.Organization = []string{v}
+	}
+	if v, _ := attrs["countryname"].(string); v != "" {
+		template.Subject.Country = []string{v}
+	}
+	if v, _ := attrs["statename"].(string); v != "" {
+		template.Subject.Province = []string{v}
+	}
+	san, _ := attrs["subjectaltname"].(string)
+	for _, name := range strings.Fields(san) {
+		template.DNSNames = append(template.DNSNames, strings.TrimPrefix(name, "DNS:"))
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()}
+	}
+	// like the appliance, a new CSR replaces an existing request file
+	if !path.IsAbs(reqfile) {
+		reqfile = path.Join(s.FileLocation(), reqfile)
+	}
+	_ = s.Delete("systemfile", reqfile)
+	return s.WriteFile(reqfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key file is not PEM encoded")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", block.Type)
+}
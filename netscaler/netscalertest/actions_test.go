@@ -0,0 +1,75 @@
+package netscalertest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestStore_ActCreate(t *testing.T) {
+	s := NewStore()
+
+	if err := s.Act("sslecdsakey", "", map[string]any{"keyfile": "web.key", "curve": "P_256"}, "create"); err != nil {
+		t.Fatalf("Act(sslecdsakey, create) error = %v", err)
+	}
+	err := s.Act("sslcertreq", "", map[string]any{
+		"reqfile":        "web.csr",
+		"keyfile":        "web.key",
+		"commonname":     "example.com",
+		"subjectaltname": "DNS:example.com DNS:www.example.com",
+	}, "create")
+	if err != nil {
+		t.Fatalf("Act(sslcertreq, create) error = %v", err)
+	}
+
+	data, err := s.ReadFile("/nsconfig/ssl/web.csr")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("CSR is not PEM encoded: %q", data)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificateRequest() error = %v", err)
+	}
+	if csr.Subject.CommonName != "example.com" || len(csr.DNSNames) != 2 {
+		t.Errorf("CSR = %s %v", csr.Subject, csr.DNSNames)
+	}
+
+	files, err := s.Files("", "")
+	if err != nil || len(files) != 2 {
+		t.Errorf("Files() = %v, %v, want key and CSR", files, err)
+	}
+	for _, f := range files {
+		if _, ok := f["filecontent"]; ok {
+			t.Errorf("Files() should not list file content, got %v", f)
+		}
+	}
+}
+
+func TestStore_ActErrors(t *testing.T) {
+	s := NewStore()
+
+	tests := []struct {
+		name         string
+		resourceType string
+		attrs        map[string]any
+		action       string
+		description  string
+	}{
+		{"missing key", "sslcertreq", map[string]any{"reqfile": "a.csr", "keyfile": "a.key", "commonname": "a"}, "create", "should fail for a key file that doesn't exist"},
+		{"unknown curve", "sslecdsakey", map[string]any{"keyfile": "a.key", "curve": "P_521"}, "create", "should reject unsupported curves"},
+		{"unknown action", "sslcertkey", map[string]any{"certkey": "a"}, "rename", "should reject unsupported actions"},
+		{"standalone sync", "hafiles", map[string]any{"mode": []string{"ssl"}}, "sync", "should fail without a paired secondary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Act(tt.resourceType, "", tt.attrs, tt.action); err == nil {
+				t.Errorf("Act(%s, %s) should fail", tt.resourceType, tt.action)
+			}
+		})
+	}
+}
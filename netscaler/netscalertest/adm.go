@@ -0,0 +1,218 @@
+package netscalertest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// admProxyHeader is the header ADM forwards a NITRO request to a managed instance with
+const admProxyHeader = "_MPS_API_PROXY_MANAGED_INSTANCE_IP"
+
+// admSessionCookie is the cookie holding the ADM session
+const admSessionCookie = "SESSID"
+
+// ADM is a fake NetScaler Console (ADM) served over TLS. It lists its managed instances and
+// instance groups and proxies NITRO requests with the proxy header to the Server of the instance.
+type ADM struct {
+	*httptest.Server
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	sessions  map[string]bool
+	logins    int
+	instances map[string]admInstance
+	groups    map[string][]string
+	proxied   map[string]int
+}
+
+// admInstance is a managed instance and the fake appliance its requests are proxied to
+type admInstance struct {
+	hostname string
+	server   *Server
+}
+
+// NewADM starts a fake ADM with the default credentials. Call Close when done.
+func NewADM() *ADM {
+	a := &ADM{
+		Username:  DefaultUsername,
+		Password:  DefaultPassword,
+		sessions:  make(map[string]bool),
+		instances: make(map[string]admInstance),
+		groups:    make(map[string][]string),
+		proxied:   make(map[string]int),
+	}
+	a.Server = httptest.NewTLSServer(a)
+	return a
+}
+
+// AddInstance adds a managed instance whose NITRO requests are served by the server
+func (a *ADM) AddInstance(ipAddress, hostname string, server *Server) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.instances[ipAddress] = admInstance{hostname: hostname, server: server}
+}
+
+// AddGroup adds an instance group with the instances at the IP addresses
+func (a *ADM) AddGroup(name string, ipAddresses ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.groups[name] = ipAddresses
+}
+
+// ExpireSessions invalidates all sessions, the next request fails with errorcode 444
+func (a *ADM) ExpireSessions() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions = make(map[string]bool)
+}
+
+// Logins returns the number of successful logins
+func (a *ADM) Logins() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.logins
+}
+
+// Sessions returns the number of active sessions
+func (a *ADM) Sessions() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.sessions)
+}
+
+// Proxied returns the number of NITRO requests proxied to the instance
+func (a *ADM) Proxied(ipAddress string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.proxied[ipAddress]
+}
+
+// ServeHTTP implements the login, managed_device and device_group APIs of ADM and the NITRO proxy
+func (a *ADM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resourceType, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, configPath), "/")
+	if resourceType == "login" {
+		a.login(w, r)
+		return
+	}
+	cookie, err := r.Cookie(admSessionCookie)
+	a.mu.Lock()
+	valid := err == nil && a.sessions[cookie.Value]
+	a.mu.Unlock()
+	if !valid {
+		writeError(w, &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeSessionExpired, Message: "Session expired or killed. Please login again"})
+		return
+	}
+
+	if ipAddress := r.Header.Get(admProxyHeader); ipAddress != "" {
+		a.proxy(w, r, ipAddress)
+		return
+	}
+
+	switch resourceType {
+	case "logout":
+		a.mu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"})
+	case "managed_device":
+		writeResources(w, resourceType, a.managedDevices())
+	case "device_group":
+		writeResources(w, resourceType, a.deviceGroups(parseArgs(r.URL.Query().Get("filter"))["name"]))
+	default:
+		writeError(w, &NitroError{Status: http.StatusNotFound, Code: ErrCodeNoSuchResource, Message: "Not found"})
+	}
+}
+
+// login returns the session in the login resource like ADM, not at the top level like an appliance
+func (a *ADM) login(w http.ResponseWriter, r *http.Request) {
+	attrs, decodeErr := decodeBody(r, "login")
+	if decodeErr != nil {
+		writeError(w, decodeErr)
+		return
+	}
+	if attrs["username"] != a.Username || attrs["password"] != a.Password {
+		writeError(w, &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeInvalidCredentials, Message: "Invalid username or password"})
+		return
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	sessionID := hex.EncodeToString(b)
+
+	a.mu.Lock()
+	a.sessions[sessionID] = true
+	a.logins++
+	a.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE", "login": []map[string]any{{"sessionid": sessionID}}})
+}
+
+// proxy forwards the request to the instance with the credentials ADM stores for it
+func (a *ADM) proxy(w http.ResponseWriter, r *http.Request, ipAddress string) {
+	a.mu.Lock()
+	instance, ok := a.instances[ipAddress]
+	if ok {
+		a.proxied[ipAddress]++
+	}
+	a.mu.Unlock()
+	if !ok {
+		writeError(w, &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Managed instance " + ipAddress + " not found"})
+		return
+	}
+
+	forwarded := r.Clone(r.Context())
+	for _, header := range []string{admProxyHeader, "Cookie", "Set-Cookie", "Authorization"} {
+		forwarded.Header.Del(header)
+	}
+	forwarded.Header.Set("X-NITRO-USER", instance.server.Username)
+	forwarded.Header.Set("X-NITRO-PASS", instance.server.Password)
+	instance.server.ServeHTTP(w, forwarded)
+}
+
+func (a *ADM) managedDevices() []map[string]any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	devices := make([]map[string]any, 0, len(a.instances))
+	for _, ipAddress := range sortedMapKeys(a.instances) {
+		devices = append(devices, map[string]any{
+			"ip_address":     ipAddress,
+			"hostname":       a.instances[ipAddress].hostname,
+			"type":           "nsvpx",
+			"instance_state": "Up",
+		})
+	}
+	return devices
+}
+
+func (a *ADM) deviceGroups(name string) []map[string]any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var groups []map[string]any
+	for _, group := range sortedMapKeys(a.groups) {
+		if name != "" && group != name {
+			continue
+		}
+		members := a.groups[group]
+		groups = append(groups, map[string]any{
+			"name":                   group,
+			"static_device_list":     strings.Join(members, ","),
+			"static_device_list_arr": members,
+		})
+	}
+	return groups
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,329 @@
+package netscalertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// Call is a method invocation recorded by NitroClient
+type Call struct {
+	Method       string
+	ResourceType string
+	Name         string
+}
+
+// NitroClient is a stateful, in-memory implementation of netscaler.NitroClientInterface.
+// Resources are kept in Store, keyed by resource type and name. After switching to an admin
+// partition the calls use the store of the partition, see Store.Partition.
+type NitroClient struct {
+	Store *Store
+
+	mu        sync.Mutex
+	loggedIn  bool
+	expired   bool
+	partition string
+	latency   time.Duration
+	failures  map[string][]error
+	calls     []Call
+}
+
+// NewNitroClient returns a client backed by an empty store
+func NewNitroClient() *NitroClient {
+	return NewNitroClientWithStore(NewStore())
+}
+
+// NewNitroClientWithStore returns a client backed by the given store, e.g. the store of a Server
+func NewNitroClientWithStore(store *Store) *NitroClient {
+	return &NitroClient{
+		Store:    store,
+		failures: make(map[string][]error),
+	}
+}
+
+// SetLatency delays every call by the given duration
+func (c *NitroClient) SetLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = d
+}
+
+// FailNext makes the next call of the named method (e.g. "FindResource") return err
+func (c *NitroClient) FailNext(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[method] = append(c.failures[method], err)
+}
+
+// FailNextCall makes the next call of the named method on the resource type return err,
+// e.g. the next AddResource of sslvserver_sslcertkey_binding
+func (c *NitroClient) FailNextCall(method, resourceType string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := method + "(" + resourceType + ")"
+	c.failures[key] = append(c.failures[key], err)
+}
+
+// ExpireSession invalidates the session on the appliance. Like nitro-go the next call fails with
+// errorcode 444 and drops the session, later calls are sent with credentials in the default partition.
+func (c *NitroClient) ExpireSession() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expired = true
+}
+
+// Calls returns all calls made so far
+func (c *NitroClient) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// IsLoggedIn reports whether the client holds a valid session
+func (c *NitroClient) IsLoggedIn() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loggedIn
+}
+
+// Partition returns the admin partition of the session, empty for the default partition
+func (c *NitroClient) Partition() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.partition
+}
+
+// Login starts a new session in the default partition
+func (c *NitroClient) Login() error {
+	if err := c.call("Login", "login", ""); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loggedIn = true
+	c.expired = false
+	c.partition = ""
+	return nil
+}
+
+// Logout ends the session
+func (c *NitroClient) Logout() error {
+	if err := c.call("Logout", "logout", ""); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loggedIn = false
+	return nil
+}
+
+// FindAllResources returns all resources of the type. Like nitro-go it returns an empty list
+// instead of an error when the request fails, including injected failures and expired sessions.
+func (c *NitroClient) FindAllResources(resourceType string) ([]map[string]any, error) {
+	if err := c.call("FindAllResources", resourceType, ""); err != nil {
+		return []map[string]any{}, nil
+	}
+	return c.store().List(resourceType), nil
+}
+
+// FindResource returns the named resource, or the first resource of the type if name is empty.
+// Like nitro-go every failure is reported as the same generic error without the NITRO errorcode.
+func (c *NitroClient) FindResource(resourceType string, name string) (map[string]any, error) {
+	if err := c.call("FindResource", resourceType, name); err != nil {
+		return nil, findResourceError(resourceType, name)
+	}
+	if name == "" {
+		if list := c.store().List(resourceType); len(list) > 0 {
+			return list[0], nil
+		}
+		return nil, findResourceError(resourceType, name)
+	}
+	res, err := c.store().Get(resourceType, name)
+	if err != nil {
+		return nil, findResourceError(resourceType, name)
+	}
+	return res, nil
+}
+
+// findResourceError is the error nitro-go returns from FindResource for any failed request
+func findResourceError(resourceType, name string) error {
+	return fmt.Errorf("[INFO] nitro-go: FindResource: No resource %s of type %s found", name, resourceType)
+}
+
+// AddResource creates a resource, or a binding for binding types
+func (c *NitroClient) AddResource(resourceType string, name string, resourceStruct any) (string, error) {
+	if err := c.call("AddResource", resourceType, name); err != nil {
+		return "", err
+	}
+	attrs, err := toAttrs(resourceStruct)
+	if err != nil {
+		return "", err
+	}
+	store := c.store()
+	if resourceType == "systemfile" && attrs["filelocation"] == nil {
+		attrs["filelocation"] = store.FileLocation()
+	}
+	return name, store.Add(resourceType, attrs)
+}
+
+// UpdateResource merges the attributes into an existing resource
+func (c *NitroClient) UpdateResource(resourceType string, name string, resourceStruct any) (string, error) {
+	if err := c.call("UpdateResource", resourceType, name); err != nil {
+		return "", err
+	}
+	attrs, err := toAttrs(resourceStruct)
+	if err != nil {
+		return "", err
+	}
+	return name, c.store().Update(resourceType, name, attrs)
+}
+
+// ActOnResource applies an action such as update, link or create, see Store.Act. The Switch
+// action of nspartition changes the partition of the session.
+func (c *NitroClient) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	if err := c.call("ActOnResource", resourceType, action); err != nil {
+		return err
+	}
+	attrs, err := toAttrs(resourceStruct)
+	if err != nil {
+		return err
+	}
+	if resourceType == "nspartition" && action == "Switch" {
+		return c.switchPartition(attrs)
+	}
+	return c.store().Act(resourceType, "", attrs, action)
+}
+
+// DeleteResource removes a resource
+func (c *NitroClient) DeleteResource(resourceType string, name string) error {
+	if err := c.call("DeleteResource", resourceType, name); err != nil {
+		return err
+	}
+	return c.store().Remove(resourceType, name, nil)
+}
+
+// DeleteResourceWithArgsMap removes a resource or the bindings matching args, see Store.Remove
+func (c *NitroClient) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	if err := c.call("DeleteResourceWithArgsMap", resourceType, name); err != nil {
+		return err
+	}
+	unescaped := make(map[string]string, len(args))
+	for k, v := range args {
+		unescaped[k], _ = url.QueryUnescape(v)
+	}
+	return c.store().Remove(resourceType, name, unescaped)
+}
+
+// FindResourceArrayWithParams supports systemfile queries by filelocation and filename and
+// filters, other resource types are looked up by name or listed. Bindings are looked up by owner.
+func (c *NitroClient) FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error) {
+	if err := c.call("FindResourceArrayWithParams", findParams.ResourceType, findParams.ResourceName); err != nil {
+		return nil, err
+	}
+
+	if findParams.ResourceType == "systemfile" {
+		location, _ := url.QueryUnescape(findParams.ArgsMap["filelocation"])
+		files, err := c.store().Files(location, findParams.ArgsMap["filename"])
+		return missingAsEmpty(files, err, findParams.ResourceMissingErrorCode)
+	}
+	if len(findParams.FilterMap) > 0 && findParams.ResourceName == "" {
+		filter := make(map[string]string, len(findParams.FilterMap))
+		for k, v := range findParams.FilterMap {
+			filter[k], _ = url.QueryUnescape(v)
+		}
+		return c.store().Filter(findParams.ResourceType, filter)
+	}
+	if findParams.ResourceName == "" {
+		return c.store().List(findParams.ResourceType), nil
+	}
+	if isOwnerBinding(findParams.ResourceType) {
+		return c.store().Bindings(findParams.ResourceType, findParams.ResourceName), nil
+	}
+	res, err := c.store().Get(findParams.ResourceType, findParams.ResourceName)
+	return missingAsEmpty([]map[string]any{res}, err, findParams.ResourceMissingErrorCode)
+}
+
+// missingAsEmpty returns an empty result instead of the error when it has the given errorcode, like NITRO clients do
+func missingAsEmpty(res []map[string]any, err error, missingCode int) ([]map[string]any, error) {
+	var nitroErr *NitroError
+	if errors.As(err, &nitroErr) && missingCode != 0 && nitroErr.Code == missingCode {
+		return []map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// toAttrs converts a resource struct to NITRO attributes the way it is sent on the wire
+func toAttrs(resourceStruct any) (map[string]any, error) {
+	data, err := json.Marshal(resourceStruct)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]any)
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// call records the call, applies the latency and returns injected failures
+func (c *NitroClient) call(method, resourceType, name string) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Method: method, ResourceType: resourceType, Name: name})
+	latency := c.latency
+	var err error
+	typed := method + "(" + resourceType + ")"
+	if queue := c.failures[typed]; len(queue) > 0 {
+		err = queue[0]
+		c.failures[typed] = queue[1:]
+	} else if queue := c.failures[method]; len(queue) > 0 {
+		err = queue[0]
+		c.failures[method] = queue[1:]
+	} else if c.expired && c.loggedIn && method != "Login" {
+		err = &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeSessionExpired, Message: "Session expired or killed. Please login again"}
+		c.loggedIn = false
+		c.partition = ""
+	}
+	c.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return err
+}
+
+// switchPartition changes the partition of the session to an existing partition
+func (c *NitroClient) switchPartition(attrs map[string]any) error {
+	name, _ := attrs["partitionname"].(string)
+	if name == DefaultPartition {
+		name = ""
+	}
+	if name != "" {
+		if _, err := c.Store.Get("nspartition", name); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partition = name
+	return nil
+}
+
+// store returns the store of the partition of the session
+func (c *NitroClient) store() *Store {
+	c.mu.Lock()
+	partition := c.partition
+	c.mu.Unlock()
+	return c.Store.Partition(partition)
+}
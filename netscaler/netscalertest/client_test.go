@@ -0,0 +1,360 @@
+package netscalertest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+func newSeededNitroClient(t *testing.T) *NitroClient {
+	t.Helper()
+	client := NewNitroClient()
+	if err := client.Store.LoadFile("testdata/certificates.json"); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return client
+}
+
+func TestNitroClient_FindResource(t *testing.T) {
+	client := newSeededNitroClient(t)
+
+	tests := []struct {
+		name         string
+		resourceType string
+		resourceName string
+		wantErr      bool
+		description  string
+	}{
+		{
+			name:         "existing certificate",
+			resourceType: "sslcertkey",
+			resourceName: "prod-example.com",
+			description:  "should return seeded resources",
+		},
+		{
+			name:         "missing certificate",
+			resourceType: "sslcertkey",
+			resourceName: "prod-missing.example.com",
+			wantErr:      true,
+			description:  "should fail for resources that were not seeded",
+		},
+		{
+			name:         "certkey bindings",
+			resourceType: "sslcertkey_binding",
+			resourceName: "prod-example.com",
+			description:  "should synthesize bindings from seeded binding fixtures",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.FindResource(tt.resourceType, tt.resourceName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindResource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got["certkey"] != tt.resourceName {
+				t.Errorf("FindResource() certkey = %v, want %s", got["certkey"], tt.resourceName)
+			}
+		})
+	}
+}
+
+func TestNitroClient_FindAllResources(t *testing.T) {
+	client := newSeededNitroClient(t)
+
+	all, err := client.FindAllResources("sslcertkey")
+	if err != nil {
+		t.Fatalf("FindAllResources() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("FindAllResources() returned %d resources, want 2", len(all))
+	}
+
+	none, err := client.FindAllResources("sslvserver")
+	if err != nil || len(none) != 0 {
+		t.Errorf("FindAllResources() of unknown type = %v, %v, want empty list", none, err)
+	}
+}
+
+func TestNitroClient_FaultInjection(t *testing.T) {
+	client := newSeededNitroClient(t)
+
+	injected := errors.New("connection reset")
+	client.FailNext("FindResourceArrayWithParams", injected)
+	if _, err := client.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey"}); !errors.Is(err, injected) {
+		t.Errorf("FindResourceArrayWithParams() error = %v, want injected error", err)
+	}
+
+	// nitro-go replaces every error of FindResource by a generic one and FindAllResources returns an empty list
+	client.FailNext("FindResource", injected)
+	if _, err := client.FindResource("sslcertkey", "prod-example.com"); err == nil || errors.Is(err, injected) || strings.Contains(err.Error(), "errorcode") {
+		t.Errorf("FindResource() error = %v, want the generic error of nitro-go", err)
+	}
+	client.FailNext("FindAllResources", injected)
+	if all, err := client.FindAllResources("sslcertkey"); err != nil || len(all) != 0 {
+		t.Errorf("FindAllResources() = %v, %v, want an empty list", all, err)
+	}
+	if _, err := client.FindResource("sslcertkey", "prod-example.com"); err != nil {
+		t.Errorf("FindResource() should succeed after the injected failure, got %v", err)
+	}
+
+	client.FailNextCall("FindResource", "sslvserver", injected)
+	if _, err := client.FindResource("sslcertkey", "prod-example.com"); err != nil {
+		t.Errorf("FindResource() of another resource type should not fail, got %v", err)
+	}
+	if _, err := client.FindResource("sslvserver", "vs-web"); err == nil {
+		t.Error("FindResource(sslvserver) should fail")
+	}
+
+	client.ExpireSession()
+	var nitroErr *NitroError
+	if _, err := client.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey"}); !errors.As(err, &nitroErr) || nitroErr.Code != ErrCodeSessionExpired {
+		t.Errorf("FindResourceArrayWithParams() error = %v, want errorcode %d", err, ErrCodeSessionExpired)
+	}
+	if client.IsLoggedIn() {
+		t.Error("IsLoggedIn() should be false after the session expired")
+	}
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if _, err := client.FindAllResources("sslcertkey"); err != nil {
+		t.Errorf("FindAllResources() should succeed after login, got %v", err)
+	}
+
+	client.SetLatency(20 * time.Millisecond)
+	start := time.Now()
+	_, _ = client.FindResource("sslcertkey", "prod-example.com")
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("FindResource() took %v, want at least the configured latency", elapsed)
+	}
+
+	calls := client.Calls()
+	last := calls[len(calls)-1]
+	if last.Method != "FindResource" || last.Name != "prod-example.com" {
+		t.Errorf("last call = %+v, want FindResource of prod-example.com", last)
+	}
+}
+
+func TestStore_Load(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "valid fixture",
+			fixture:     `{"sslcertkey": [{"certkey": "web"}], "sslvserver": [{"vservername": "vs-web"}]}`,
+			description: "should add all resources",
+		},
+		{
+			name:        "invalid json",
+			fixture:     `{"sslcertkey": `,
+			wantErr:     true,
+			description: "should fail on malformed fixtures",
+		},
+		{
+			name:        "duplicate resource",
+			fixture:     `{"sslcertkey": [{"certkey": "web"}, {"certkey": "web"}]}`,
+			wantErr:     true,
+			description: "should fail when a resource is seeded twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewStore().Load(strings.NewReader(tt.fixture))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNitroClient_Write(t *testing.T) {
+	c := NewNitroClient()
+
+	if _, err := c.AddResource("systemfile", "web.crt", map[string]any{"filename": "web.crt", "filecontent": "Y2VydA==", "fileencoding": "BASE64"}); err != nil {
+		t.Fatalf("AddResource(systemfile) error = %v", err)
+	}
+	if _, err := c.AddResource("sslcertkey", "web", map[string]any{"certkey": "web", "cert": "web.crt"}); err != nil {
+		t.Fatalf("AddResource(sslcertkey) error = %v", err)
+	}
+	if err := c.ActOnResource("sslcertkey", map[string]any{"certkey": "web", "linkcertkeyname": "ca"}, "link"); err != nil {
+		t.Fatalf("ActOnResource(link) error = %v", err)
+	}
+	if _, err := c.UpdateResource("sslcertkey", "web", map[string]any{"expirymonitor": "ENABLED"}); err != nil {
+		t.Fatalf("UpdateResource() error = %v", err)
+	}
+
+	res, _ := c.Store.Get("sslcertkey", "web")
+	if res["linkcertkeyname"] != "ca" || res["expirymonitor"] != "ENABLED" {
+		t.Errorf("sslcertkey after writes = %v", res)
+	}
+
+	files, err := c.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: "systemfile",
+		ArgsMap:      map[string]string{"filelocation": "%2Fnsconfig%2Fssl", "filename": "web.crt"},
+	})
+	if err != nil || len(files) != 1 || files[0]["filecontent"] != "Y2VydA==" {
+		t.Errorf("FindResourceArrayWithParams(systemfile) = %v, %v", files, err)
+	}
+
+	missing, err := c.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey", ResourceName: "missing", ResourceMissingErrorCode: ErrCodeNoSuchResource})
+	if err != nil || len(missing) != 0 {
+		t.Errorf("FindResourceArrayWithParams() of a missing resource = %v, %v, want empty", missing, err)
+	}
+	if _, err := c.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey", ResourceName: "missing"}); err == nil {
+		t.Error("FindResourceArrayWithParams() without missing errorcode should fail")
+	}
+
+	if _, err := c.AddResource("sslvserver_sslcertkey_binding", "vs-web", map[string]any{"vservername": "vs-web", "certkeyname": "web", "snicert": true}); err != nil {
+		t.Fatalf("AddResource(binding) error = %v", err)
+	}
+	bound, err := c.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslvserver_sslcertkey_binding", ResourceName: "vs-web"})
+	if err != nil || len(bound) != 1 || bound[0]["snicert"] != true {
+		t.Errorf("FindResourceArrayWithParams(binding) = %v, %v", bound, err)
+	}
+	if err := c.DeleteResourceWithArgsMap("sslvserver_sslcertkey_binding", "vs-web", map[string]string{"certkeyname": "web", "snicert": "true"}); err != nil {
+		t.Fatalf("DeleteResourceWithArgsMap() error = %v", err)
+	}
+	if err := c.DeleteResource("sslcertkey", "web"); err != nil {
+		t.Fatalf("DeleteResource() error = %v", err)
+	}
+	if _, err := c.Store.Get("sslcertkey", "web"); err == nil {
+		t.Error("DeleteResource() should remove the certkey")
+	}
+}
+
+func TestStore_Filter(t *testing.T) {
+	s := NewStore()
+	for _, certkey := range []string{"prod-example.com", "prod-example.com-20260101000000", "prod-example.org", "staging-example.com"} {
+		_ = s.Add("sslcertkey", map[string]any{"certkey": certkey})
+	}
+
+	tests := []struct {
+		name        string
+		filter      map[string]string
+		want        []string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "regular expression",
+			filter:      map[string]string{"certkey": `/^prod-example\.com/`},
+			want:        []string{"prod-example.com", "prod-example.com-20260101000000"},
+			description: "values enclosed in slashes should match as regular expression",
+		},
+		{
+			name:        "exact value",
+			filter:      map[string]string{"certkey": "prod-example.org"},
+			want:        []string{"prod-example.org"},
+			description: "other values should match exactly",
+		},
+		{
+			name:        "no match",
+			filter:      map[string]string{"certkey": "/^dev-/"},
+			description: "a filter matching nothing should return no resources",
+		},
+		{
+			name:        "invalid expression",
+			filter:      map[string]string{"certkey": "/(/"},
+			wantErr:     true,
+			description: "an invalid regular expression should be rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.Filter("sslcertkey", tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Filter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, res := range list {
+				got = append(got, res["certkey"].(string))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNitroClient_Partition(t *testing.T) {
+	store := NewStore()
+	_ = store.Add("sslcertkey", map[string]any{"certkey": "default"})
+	_ = store.Partition("team-a").Add("sslcertkey", map[string]any{"certkey": "team-a"})
+	c := NewNitroClientWithStore(store)
+	if err := c.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if err := c.ActOnResource("nspartition", map[string]any{"partitionname": "team-b"}, "Switch"); err == nil {
+		t.Error("Switch to a missing partition should fail")
+	}
+	if err := c.ActOnResource("nspartition", map[string]any{"partitionname": "team-a"}, "Switch"); err != nil {
+		t.Fatalf("Switch error = %v", err)
+	}
+	if certs, _ := c.FindAllResources("sslcertkey"); len(certs) != 1 || certs[0]["certkey"] != "team-a" {
+		t.Errorf("FindAllResources() in team-a = %v", certs)
+	}
+	if _, err := c.AddResource("sslcertkey", "added", map[string]any{"certkey": "added"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	if _, err := store.Partition("team-a").Get("sslcertkey", "added"); err != nil {
+		t.Errorf("AddResource() should write to the partition, got %v", err)
+	}
+
+	if err := c.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if c.Partition() != "" {
+		t.Errorf("Partition() after login = %q, sessions should start in the default partition", c.Partition())
+	}
+	if certs, _ := c.FindAllResources("sslcertkey"); len(certs) != 1 || certs[0]["certkey"] != "default" {
+		t.Errorf("FindAllResources() in the default partition = %v", certs)
+	}
+	if partitions := store.List("nspartition"); len(partitions) != 1 || partitions[0]["partitionname"] != "team-a" {
+		t.Errorf("nspartition = %v, want team-a", partitions)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	cluster := NewCluster("192.0.2.10", "192.0.2.11", "192.0.2.12")
+
+	if err := cluster.Add("sslcertkey", map[string]any{"certkey": "web"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	for _, address := range []string{"192.0.2.10", "192.0.2.11", "192.0.2.12"} {
+		if _, err := cluster.Store(address).Get("sslcertkey", "web"); err != nil {
+			t.Errorf("Add() should add to %s, got %v", address, err)
+		}
+	}
+
+	node, err := cluster.Client("192.0.2.12")
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	if again, _ := cluster.Client("192.0.2.12"); again != node {
+		t.Error("Client() should return the same client for an address")
+	}
+	if _, err := cluster.Client("192.0.2.99"); err == nil {
+		t.Error("Client() of an unknown address should fail")
+	}
+
+	nodes, _ := node.FindAllResources("clusternode")
+	if len(nodes) != 2 || nodes[0]["isconfigurationcoordinator"] != true {
+		t.Errorf("clusternode = %v, want two nodes, the first coordinating", nodes)
+	}
+	clips, _ := node.FindResourceArrayWithParams(service.FindParams{ResourceType: "nsip", FilterMap: map[string]string{"type": "CLIP"}})
+	if len(clips) != 1 || clips[0]["ipaddress"] != "192.0.2.10" {
+		t.Errorf("nsip of type CLIP = %v", clips)
+	}
+}
@@ -0,0 +1,77 @@
+package netscalertest
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Cluster is a NetScaler cluster of in-memory appliances. The cluster IP and every node have their
+// own store, seeded with the clusternode and nsip resources, so tests can make a node miss changes
+// made on the cluster IP.
+type Cluster struct {
+	CLIP string
+	// Nodes lists the NSIPs of the nodes, the first is the configuration coordinator
+	Nodes []string
+
+	mu      sync.Mutex
+	stores  map[string]*Store
+	clients map[string]*NitroClient
+}
+
+// NewCluster returns a cluster with the cluster IP and nodes, numbered from 0 in the given order
+func NewCluster(clip string, nodes ...string) *Cluster {
+	c := &Cluster{
+		CLIP:    clip,
+		Nodes:   nodes,
+		stores:  make(map[string]*Store),
+		clients: make(map[string]*NitroClient),
+	}
+	for _, address := range append([]string{clip}, nodes...) {
+		c.stores[address] = NewStore()
+	}
+	_ = c.Add("nsip", map[string]any{"ipaddress": clip, "type": "CLIP"})
+	for id, address := range nodes {
+		_ = c.Add("nsip", map[string]any{"ipaddress": address, "type": "NSIP"})
+		_ = c.Add("clusternode", map[string]any{
+			"nodeid":                     id,
+			"ipaddress":                  address,
+			"state":                      "ACTIVE",
+			"health":                     "UP",
+			"isconfigurationcoordinator": id == 0,
+		})
+	}
+	return c
+}
+
+// Store returns the store of the cluster IP or a node, nil for other addresses
+func (c *Cluster) Store(address string) *Store {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stores[address]
+}
+
+// Add adds the resource to the cluster IP and every node, like a change propagated to all nodes
+func (c *Cluster) Add(resourceType string, attrs map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, store := range c.stores {
+		if err := store.Add(resourceType, attrs); err != nil {
+			return fmt.Errorf("%s: %w", address, err)
+		}
+	}
+	return nil
+}
+
+// Client returns the client of the cluster IP or a node, the same client for every call
+func (c *Cluster) Client(address string) (*NitroClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	store, ok := c.stores[address]
+	if !ok {
+		return nil, fmt.Errorf("dial tcp %s:443: connect: connection refused", address)
+	}
+	if _, ok := c.clients[address]; !ok {
+		c.clients[address] = NewNitroClientWithStore(store)
+	}
+	return c.clients[address], nil
+}
@@ -0,0 +1,401 @@
+package netscalertest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Default credentials accepted by the fake server
+const (
+	DefaultUsername = "nsroot"
+	DefaultPassword = "nsroot"
+)
+
+const configPath = "/nitro/v1/config/"
+
+// Action is a NITRO action request (?action=...) received by the fake server
+type Action struct {
+	ResourceType string
+	Action       string
+	Attrs        map[string]any
+}
+
+// Server is a fake NITRO API served over TLS.
+// Its resources live in Store, which tests can seed and inspect directly. A session switches to
+// an admin partition with the Switch action of nspartition and then uses the store of the partition,
+// requests authenticated with credentials headers always use the default partition.
+type Server struct {
+	*httptest.Server
+	Store    *Store
+	Username string
+	Password string
+
+	mu sync.Mutex
+	// sessions maps the session tokens to the partition of the session, empty for the default partition
+	sessions map[string]string
+	failures map[string][]*NitroError
+	actions  []Action
+}
+
+// NewServer starts a fake NITRO server with the default credentials. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		Store:    NewStore(),
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		sessions: make(map[string]string),
+		failures: make(map[string][]*NitroError),
+	}
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// ExpireSessions invalidates all sessions, the next request with a session token fails with errorcode 444
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]string)
+}
+
+// Sessions returns the number of active sessions
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// FailNext makes the next request for the resource type fail with the given error
+func (s *Server) FailNext(resourceType string, err *NitroError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[resourceType] = append(s.failures[resourceType], err)
+}
+
+// Actions returns all action requests received so far
+func (s *Server) Actions() []Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Action(nil), s.actions...)
+}
+
+// ServeHTTP implements the NITRO config API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	escaped, ok := strings.CutPrefix(r.URL.EscapedPath(), configPath)
+	if !ok {
+		writeError(w, &NitroError{Status: http.StatusNotFound, Code: ErrCodeNoSuchResource, Message: "Not found"})
+		return
+	}
+	resourceType, escapedName, _ := strings.Cut(escaped, "/")
+	name, err := unescapeName(escapedName)
+	if err != nil {
+		writeError(w, &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: err.Error()})
+		return
+	}
+
+	if resourceType == "login" {
+		s.login(w, r)
+		return
+	}
+	if err := s.authenticate(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	if resourceType == "logout" {
+		s.logout(r)
+		writeJSON(w, http.StatusCreated, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"})
+		return
+	}
+	if err := s.nextFailure(resourceType); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	store := s.store(r)
+	args := parseArgs(r.URL.Query().Get("args"))
+	switch r.Method {
+	case http.MethodGet:
+		if filter := r.URL.Query().Get("filter"); filter != "" && name == "" {
+			s.filter(w, store, resourceType, parseArgs(filter))
+			return
+		}
+		s.get(w, store, resourceType, name, args)
+	case http.MethodPost:
+		s.post(w, r, store, resourceType, name)
+	case http.MethodPut:
+		s.put(w, r, store, resourceType, name)
+	case http.MethodDelete:
+		s.delete(w, store, resourceType, name, args)
+	default:
+		writeError(w, &NitroError{Status: http.StatusMethodNotAllowed, Code: ErrCodeInvalidArgument, Message: "Method not allowed"})
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, store *Store, resourceType, name string, args map[string]string) {
+	if resourceType == "systemfile" {
+		s.getFiles(w, store, args)
+		return
+	}
+
+	if name == "" {
+		writeResources(w, resourceType, store.List(resourceType))
+		return
+	}
+
+	if isOwnerBinding(resourceType) {
+		writeResources(w, resourceType, store.Bindings(resourceType, name))
+		return
+	}
+
+	res, err := store.Get(resourceType, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResources(w, resourceType, []map[string]any{res})
+}
+
+func (s *Server) filter(w http.ResponseWriter, store *Store, resourceType string, filter map[string]string) {
+	list, err := store.Filter(resourceType, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResources(w, resourceType, list)
+}
+
+func (s *Server) getFiles(w http.ResponseWriter, store *Store, args map[string]string) {
+	files, err := store.Files(args["filelocation"], args["filename"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResources(w, "systemfile", files)
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request, store *Store, resourceType, name string) {
+	attrs, decodeErr := decodeBody(r, resourceType)
+	if decodeErr != nil {
+		writeError(w, decodeErr)
+		return
+	}
+
+	var err error
+	action := r.URL.Query().Get("action")
+	if action == "" {
+		if resourceType == "systemfile" && attrs["filelocation"] == nil {
+			attrs["filelocation"] = store.FileLocation()
+		}
+		err = store.Add(resourceType, attrs)
+	} else if resourceType == "nspartition" && action == "Switch" {
+		err = s.switchPartition(r, attrs)
+	} else {
+		err = store.Act(resourceType, name, attrs, action)
+	}
+
+	if action != "" {
+		s.mu.Lock()
+		s.actions = append(s.actions, Action{ResourceType: resourceType, Action: action, Attrs: attrs})
+		s.mu.Unlock()
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"})
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, store *Store, resourceType, name string) {
+	attrs, decodeErr := decodeBody(r, resourceType)
+	if decodeErr != nil {
+		writeError(w, decodeErr)
+		return
+	}
+	if err := store.Update(resourceType, name, attrs); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"})
+}
+
+func (s *Server) delete(w http.ResponseWriter, store *Store, resourceType, name string, args map[string]string) {
+	if err := store.Remove(resourceType, name, args); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"})
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	attrs, decodeErr := decodeBody(r, "login")
+	if decodeErr != nil {
+		writeError(w, decodeErr)
+		return
+	}
+	if attrs["username"] != s.Username || attrs["password"] != s.Password {
+		writeError(w, &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeInvalidCredentials, Message: "Invalid username or password"})
+		return
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	sessionID := "##" + strings.ToUpper(hex.EncodeToString(b))
+
+	s.mu.Lock()
+	s.sessions[sessionID] = ""
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE", "sessionid": sessionID})
+}
+
+func (s *Server) logout(r *http.Request) {
+	if token := sessionToken(r); token != "" {
+		s.mu.Lock()
+		delete(s.sessions, token)
+		s.mu.Unlock()
+	}
+}
+
+// authenticate accepts a session token or the X-NITRO-USER/X-NITRO-PASS and basic auth credentials
+func (s *Server) authenticate(r *http.Request) *NitroError {
+	if token := sessionToken(r); token != "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.sessions[token]; !ok {
+			return &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeSessionExpired, Message: "Session expired or killed. Please login again"}
+		}
+		return nil
+	}
+
+	username, password := r.Header.Get("X-NITRO-USER"), r.Header.Get("X-NITRO-PASS")
+	if u, p, ok := r.BasicAuth(); ok {
+		username, password = u, p
+	}
+	if username != s.Username || password != s.Password {
+		return &NitroError{Status: http.StatusUnauthorized, Code: ErrCodeInvalidCredentials, Message: "Invalid username or password"}
+	}
+	return nil
+}
+
+// switchPartition changes the partition of the session of the request to an existing partition
+func (s *Server) switchPartition(r *http.Request, attrs map[string]any) error {
+	name, _ := attrs["partitionname"].(string)
+	if name == DefaultPartition {
+		name = ""
+	}
+	if name != "" {
+		if _, err := s.Store.Get("nspartition", name); err != nil {
+			return err
+		}
+	}
+	token := sessionToken(r)
+	if token == "" {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Switching partitions requires a session"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[token] = name
+	return nil
+}
+
+// store returns the store of the partition of the session of the request
+func (s *Server) store(r *http.Request) *Store {
+	s.mu.Lock()
+	partition := s.sessions[sessionToken(r)]
+	s.mu.Unlock()
+	return s.Store.Partition(partition)
+}
+
+func (s *Server) nextFailure(resourceType string) *NitroError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := s.failures[resourceType]
+	if len(queue) == 0 {
+		return nil
+	}
+	s.failures[resourceType] = queue[1:]
+	return queue[0]
+}
+
+// sessionToken returns the NITRO_AUTH_TOKEN sent by nitro-go in the Set-Cookie or Cookie header
+func sessionToken(r *http.Request) string {
+	for _, header := range []string{"Set-Cookie", "Cookie"} {
+		for _, part := range strings.Split(r.Header.Get(header), ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(part), "NITRO_AUTH_TOKEN="); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// unescapeName reverses the double path escaping nitro-go applies to resource names
+func unescapeName(escaped string) (string, error) {
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", err
+	}
+	return url.PathUnescape(name)
+}
+
+// parseArgs parses the NITRO "key:value,key:value" argument syntax
+func parseArgs(raw string) map[string]string {
+	args := make(map[string]string)
+	if raw == "" {
+		return args
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, _ := strings.Cut(pair, ":")
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		args[k] = v
+	}
+	return args
+}
+
+func decodeBody(r *http.Request, resourceType string) (map[string]any, *NitroError) {
+	var body map[string]map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Invalid JSON: " + err.Error()}
+	}
+	attrs, ok := body[resourceType]
+	if !ok {
+		return nil, &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Missing object " + resourceType}
+	}
+	if attrs == nil {
+		attrs = make(map[string]any)
+	}
+	return attrs, nil
+}
+
+func writeResources(w http.ResponseWriter, resourceType string, resources []map[string]any) {
+	body := map[string]any{"errorcode": 0, "message": "Done", "severity": "NONE"}
+	if len(resources) > 0 {
+		body[resourceType] = resources
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var nitroErr *NitroError
+	if !errors.As(err, &nitroErr) {
+		nitroErr = &NitroError{Status: http.StatusInternalServerError, Code: ErrCodeInvalidArgument, Message: err.Error()}
+	}
+	writeJSON(w, nitroErr.Status, map[string]any{"errorcode": nitroErr.Code, "message": nitroErr.Message, "severity": "ERROR"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
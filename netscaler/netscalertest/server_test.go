@@ -0,0 +1,337 @@
+package netscalertest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+func newTestNitroClient(t *testing.T, srv *Server, password string) *service.NitroClient {
+	t.Helper()
+	client, err := service.NewNitroClientFromParams(service.NitroParams{
+		Url:      srv.URL,
+		Username: srv.Username,
+		Password: password,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create NITRO client: %v", err)
+	}
+	return client
+}
+
+func TestServer_Login(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	if err := newTestNitroClient(t, srv, "wrong").Login(); err == nil {
+		t.Error("Login() with wrong password should fail")
+	}
+
+	client := newTestNitroClient(t, srv, srv.Password)
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if srv.Sessions() != 1 {
+		t.Errorf("Sessions() = %d, want 1", srv.Sessions())
+	}
+
+	if err := client.Logout(); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if srv.Sessions() != 0 {
+		t.Errorf("Sessions() = %d after logout, want 0", srv.Sessions())
+	}
+}
+
+func TestServer_SslcertkeyCRUD(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	client := newTestNitroClient(t, srv, srv.Password)
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if _, err := client.AddResource("sslcertkey", "a/b", map[string]any{"certkey": "a/b", "cert": "a.crt"}); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+	if _, err := client.AddResource("sslcertkey", "a/b", map[string]any{"certkey": "a/b"}); err == nil {
+		t.Error("AddResource() of an existing certkey should fail")
+	}
+
+	res, err := client.FindResource("sslcertkey", "a/b")
+	if err != nil {
+		t.Fatalf("FindResource() error = %v", err)
+	}
+	if res["cert"] != "a.crt" {
+		t.Errorf("FindResource() = %v", res)
+	}
+
+	if _, err := client.UpdateResource("sslcertkey", "a/b", map[string]any{"certkey": "a/b", "cert": "b.crt"}); err != nil {
+		t.Fatalf("UpdateResource() error = %v", err)
+	}
+	if err := client.ActOnResource("sslcertkey", map[string]any{"certkey": "a/b", "linkcertkeyname": "ca"}, "link"); err != nil {
+		t.Fatalf("ActOnResource(link) error = %v", err)
+	}
+	res, _ = client.FindResource("sslcertkey", "a/b")
+	if res["cert"] != "b.crt" || res["linkcertkeyname"] != "ca" {
+		t.Errorf("FindResource() after update = %v", res)
+	}
+
+	if err := client.DeleteResource("sslcertkey", "a/b"); err != nil {
+		t.Fatalf("DeleteResource() error = %v", err)
+	}
+	if _, err := client.FindResource("sslcertkey", "a/b"); err == nil {
+		t.Error("FindResource() after delete should fail")
+	}
+	if all, _ := client.FindAllResources("sslcertkey"); len(all) != 0 {
+		t.Errorf("FindAllResources() after delete = %v", all)
+	}
+}
+
+func TestServer_Bindings(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "web"})
+	client := newTestNitroClient(t, srv, srv.Password)
+
+	binding := map[string]any{"vservername": "vs-web", "certkeyname": "web", "snicert": true}
+	if _, err := client.AddResource("sslvserver_sslcertkey_binding", "vs-web", binding); err != nil {
+		t.Fatalf("AddResource(binding) error = %v", err)
+	}
+
+	res, err := client.FindResource("sslcertkey_binding", "web")
+	if err != nil {
+		t.Fatalf("FindResource(sslcertkey_binding) error = %v", err)
+	}
+	if entries, _ := res["sslcertkey_sslvserver_binding"].([]any); len(entries) != 1 {
+		t.Errorf("FindResource(sslcertkey_binding) = %v", res)
+	}
+
+	if err := client.DeleteResourceWithArgsMap("sslvserver_sslcertkey_binding", "vs-web", map[string]string{"certkeyname": "web"}); err != nil {
+		t.Fatalf("DeleteResourceWithArgsMap() error = %v", err)
+	}
+	if got := srv.Store.Bindings("sslvserver_sslcertkey_binding", "vs-web"); len(got) != 0 {
+		t.Errorf("Bindings() after unbind = %v", got)
+	}
+}
+
+func TestServer_Filter(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	for _, certkey := range []string{"prod-example.com", "prod-example.com-20260101000000", "staging-example.com"} {
+		_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": certkey})
+	}
+	client := newTestNitroClient(t, srv, srv.Password)
+
+	res, err := client.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: "sslcertkey",
+		FilterMap:    map[string]string{"certkey": url.QueryEscape(`/^prod-example\.com/`)},
+	})
+	if err != nil {
+		t.Fatalf("FindResourceArrayWithParams() error = %v", err)
+	}
+	if len(res) != 2 {
+		t.Errorf("FindResourceArrayWithParams() with filter = %v, want 2 certkeys", res)
+	}
+
+	if err := client.DeleteResource("sslcertkey", "staging-example.com"); err != nil {
+		t.Fatalf("DeleteResource() error = %v", err)
+	}
+	if _, err := srv.Store.Get("sslcertkey", "staging-example.com"); err == nil {
+		t.Error("DeleteResource() should remove the certkey")
+	}
+}
+
+func TestServer_Systemfile(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	client := newTestNitroClient(t, srv, srv.Password)
+	file := map[string]any{"filename": "a.crt", "filelocation": "/nsconfig/ssl", "filecontent": "Y2VydA==", "fileencoding": "BASE64"}
+	if _, err := client.AddResource("systemfile", "a.crt", file); err != nil {
+		t.Fatalf("AddResource(systemfile) error = %v", err)
+	}
+
+	files, err := client.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: "systemfile",
+		ArgsMap:      map[string]string{"filelocation": "%2Fnsconfig%2Fssl", "filename": "a.crt"},
+	})
+	if err != nil {
+		t.Fatalf("FindResourceArrayWithParams() error = %v", err)
+	}
+	if len(files) != 1 || files[0]["filecontent"] != "Y2VydA==" {
+		t.Errorf("FindResourceArrayWithParams() = %v", files)
+	}
+}
+
+func TestServer_SessionExpiry(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "web"})
+	client := newTestNitroClient(t, srv, srv.Password)
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	srv.ExpireSessions()
+	if _, err := client.FindResource("sslcertkey", "web"); err == nil {
+		t.Error("FindResource() with expired session should fail")
+	}
+	if client.IsLoggedIn() {
+		t.Error("IsLoggedIn() should be false after the session expired")
+	}
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() after expiry error = %v", err)
+	}
+	if _, err := client.FindResource("sslcertkey", "web"); err != nil {
+		t.Errorf("FindResource() after re-login error = %v", err)
+	}
+}
+
+func TestServer_Partitions(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "default"})
+	_ = srv.Store.Partition("team-a").Add("sslcertkey", map[string]any{"certkey": "team-a"})
+	client := newTestNitroClient(t, srv, srv.Password)
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if err := client.ActOnResource("nspartition", map[string]any{"partitionname": "team-c"}, "Switch"); err == nil {
+		t.Error("ActOnResource(Switch) should fail for a missing partition")
+	}
+	if err := client.ActOnResource("nspartition", map[string]any{"partitionname": "team-a"}, "Switch"); err != nil {
+		t.Fatalf("ActOnResource(Switch) error = %v", err)
+	}
+	if res, err := client.FindResource("sslcertkey", "team-a"); err != nil || res["certkey"] != "team-a" {
+		t.Errorf("FindResource() in team-a = %v, %v", res, err)
+	}
+
+	// nitro-go falls back to the credentials headers after the session expired
+	srv.ExpireSessions()
+	_, _ = client.FindAllResources("sslcertkey")
+	list, err := client.FindAllResources("sslcertkey")
+	if err != nil || len(list) != 1 || list[0]["certkey"] != "default" {
+		t.Errorf("FindAllResources() without session = %v, %v, want the default partition", list, err)
+	}
+}
+
+func TestServer_FailNext(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "web"})
+	srv.FailNext("sslcertkey", &NitroError{Status: http.StatusServiceUnavailable, Code: 1, Message: "busy"})
+
+	client := newTestNitroClient(t, srv, srv.Password)
+	if _, err := client.FindResource("sslcertkey", "web"); err == nil {
+		t.Error("FindResource() should fail once")
+	}
+	if _, err := client.FindResource("sslcertkey", "web"); err != nil {
+		t.Errorf("FindResource() should succeed after the injected failure, got %v", err)
+	}
+}
+
+func TestStore_Errors(t *testing.T) {
+	s := NewStore()
+
+	var nitroErr *NitroError
+	if err := s.Delete("sslcertkey", "missing"); !errors.As(err, &nitroErr) || nitroErr.Code != ErrCodeNoSuchResource {
+		t.Errorf("Delete() error = %v, want errorcode %d", err, ErrCodeNoSuchResource)
+	}
+	if err := s.Add("sslcertkey", map[string]any{}); err == nil {
+		t.Error("Add() without name should fail")
+	}
+}
+
+func TestServer_AggregateBindings(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_ = srv.Store.Add("csvserver", map[string]any{"name": "cs-web", "servicetype": "SSL"})
+	_ = srv.Store.Add("csvserver_cspolicy_binding", map[string]any{"name": "cs-web", "policyname": "pol-www"})
+	_ = srv.Store.Add("csvserver_cspolicy_binding", map[string]any{"name": "cs-other", "policyname": "pol-other"})
+	client := newTestNitroClient(t, srv, srv.Password)
+
+	res, err := client.FindResource("csvserver_binding", "cs-web")
+	if err != nil {
+		t.Fatalf("FindResource(csvserver_binding) error = %v", err)
+	}
+	entries, _ := res["csvserver_cspolicy_binding"].([]any)
+	if len(entries) != 1 || entries[0].(map[string]any)["policyname"] != "pol-www" {
+		t.Errorf("FindResource(csvserver_binding) = %v", res)
+	}
+
+	if _, err := client.FindResource("sslvserver_binding", "missing"); err == nil {
+		t.Error("FindResource(sslvserver_binding) of a missing vserver should fail")
+	}
+}
+
+func TestADM_Proxy(t *testing.T) {
+	adm := NewADM()
+	defer adm.Close()
+	srv := NewServer()
+	defer srv.Close()
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "web"})
+	adm.AddInstance("192.0.2.21", "adc1", srv)
+
+	// nitro-go can't parse the session of an ADM login, log in like the ADM client does
+	resp, err := adm.Client().Post(adm.URL+configPath+"login", "application/json",
+		strings.NewReader(`{"login":{"username":"nsroot","password":"nsroot"}}`))
+	if err != nil {
+		t.Fatalf("login error = %v", err)
+	}
+	var login struct {
+		Login []struct {
+			SessionID string `json:"sessionid"`
+		} `json:"login"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&login)
+	_ = resp.Body.Close()
+	if err != nil || len(login.Login) != 1 || adm.Logins() != 1 {
+		t.Fatalf("login = %+v, %v, want one session", login, err)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		instance    string
+		cookie      string
+		wantErr     string
+		description string
+	}{
+		{name: "proxied", instance: "192.0.2.21", cookie: login.Login[0].SessionID, description: "the request should reach the instance"},
+		{name: "unknown instance", instance: "192.0.2.99", cookie: login.Login[0].SessionID, wantErr: "Managed instance 192.0.2.99 not found", description: "unmanaged instances should fail"},
+		{name: "no session", instance: "192.0.2.21", cookie: "invalid", wantErr: `"errorcode":444`, description: "requests without a valid ADM session should fail"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := service.NewNitroClientFromParams(service.NitroParams{
+				Url:     adm.URL,
+				Headers: map[string]string{admProxyHeader: tt.instance, "Cookie": admSessionCookie + "=" + tt.cookie},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create NITRO client: %v", err)
+			}
+			res, err := client.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey", ResourceName: "web"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("FindResourceArrayWithParams() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(res) != 1 || res[0]["certkey"] != "web" || adm.Proxied(tt.instance) != 1 {
+				t.Errorf("FindResourceArrayWithParams() = %v, %v, want web proxied once", res, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+// Package netscalertest provides fakes of the NetScaler NITRO API for tests.
+package netscalertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NITRO error codes returned by the fakes
+const (
+	ErrCodeNoSuchResource     = 258
+	ErrCodeResourceExists     = 273
+	ErrCodeInvalidCredentials = 354
+	ErrCodeSessionExpired     = 444
+	ErrCodeInvalidArgument    = 1093
+)
+
+// DefaultVersion is the firmware version reported by the nsversion resource of a new store
+const DefaultVersion = "NetScaler NS14.1: Build 25.53.nc"
+
+// DefaultFileLocation is the directory systemfile entries are stored in when no location is given,
+// admin partitions use their own directory, see Store.FileLocation
+const DefaultFileLocation = "/nsconfig/ssl"
+
+// DefaultPartition is the admin partition sessions start in
+const DefaultPartition = "default"
+
+// nameKeys maps resource types to the attribute holding their name, "name" is used for all others
+var nameKeys = map[string]string{
+	"sslcertkey":   "certkey",
+	"systemfile":   "filename",
+	"sslvserver":   "vservername",
+	"sslservice":   "servicename",
+	"sslcrl":       "crlname",
+	"sslrsakey":    "keyfile",
+	"sslecdsakey":  "keyfile",
+	"sslcertreq":   "reqfile",
+	"cspolicy":     "policyname",
+	"nspartition":  "partitionname",
+	"clusternode":  "nodeid",
+	"nsip":         "ipaddress",
+	"service":      "name",
+	"servicegroup": "servicegroupname",
+	"nsversion":    "version",
+	"hanode":       "id",
+}
+
+// bindingOwnerKeys maps binding types to the attribute holding the name of the resource bound to
+var bindingOwnerKeys = map[string]string{
+	"sslvserver_sslcertkey_binding":      "vservername",
+	"sslservice_sslcertkey_binding":      "servicename",
+	"sslservicegroup_sslcertkey_binding": "servicegroupname",
+	"csvserver_cspolicy_binding":         "name",
+}
+
+// certkeyBindingSources lists the bindings that are reported by sslcertkey_binding, with the
+// type and name attribute they are reported as
+var certkeyBindingSources = []struct {
+	bindingType string
+	boundType   string
+	nameKey     string
+}{
+	{"sslvserver_sslcertkey_binding", "sslvserver", "servername"},
+	{"sslservice_sslcertkey_binding", "service", "servicename"},
+	{"sslservicegroup_sslcertkey_binding", "servicegroup", "servicegroupname"},
+}
+
+// aggregateBindingOwners lists the resource types whose <type>_binding resource reports
+// all bindings of a resource, e.g. csvserver_binding with its csvserver_cspolicy_binding entries
+var aggregateBindingOwners = map[string]bool{
+	"csvserver":  true,
+	"sslvserver": true,
+}
+
+// NitroError is an error in the format the NITRO API reports it
+type NitroError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"errorcode"`
+	Message string `json:"message"`
+}
+
+func (e *NitroError) Error() string {
+	return fmt.Sprintf("%d %s (errorcode %d: %s)", e.Status, http.StatusText(e.Status), e.Code, e.Message)
+}
+
+func noSuchResource(resourceType, name string) *NitroError {
+	return &NitroError{
+		Status:  http.StatusNotFound,
+		Code:    ErrCodeNoSuchResource,
+		Message: fmt.Sprintf("No such resource [%s, %s]", resourceType, name),
+	}
+}
+
+// Store is a concurrency safe, in-memory set of NITRO resources keyed by resource type and name
+type Store struct {
+	mu        sync.Mutex
+	resources map[string]map[string]map[string]any
+	bindings  map[string][]map[string]any
+	// saves counts the saves of nsconfig
+	saves int
+	// secondary receives the files on hafiles sync, see Pair
+	secondary *Store
+	// partitions holds the resources of the admin partitions, see Partition
+	partitions map[string]*Store
+	// fileLocation is the directory of the partition's files, DefaultFileLocation if empty
+	fileLocation string
+}
+
+// NewStore returns a store holding only the nsversion resource and the hanode of a standalone
+// appliance, which NITRO always reports
+func NewStore() *Store {
+	return &Store{
+		resources: map[string]map[string]map[string]any{
+			"nsversion": {DefaultVersion: {"version": DefaultVersion, "mode": "1"}},
+			"hanode":    {"0": {"id": 0, "state": "UNKNOWN"}},
+		},
+		bindings: make(map[string][]map[string]any),
+	}
+}
+
+// Partition returns the store of an admin partition, adding the nspartition resource if it doesn't
+// exist yet. The default partition is the store itself.
+func (s *Store) Partition(name string) *Store {
+	if name == "" || name == DefaultPartition {
+		return s
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if partition, ok := s.partitions[name]; ok {
+		return partition
+	}
+	if s.partitions == nil {
+		s.partitions = make(map[string]*Store)
+	}
+	if s.resources["nspartition"] == nil {
+		s.resources["nspartition"] = make(map[string]map[string]any)
+	}
+	s.resources["nspartition"][name] = map[string]any{"partitionname": name}
+	partition := NewStore()
+	partition.fileLocation = path.Join("/nsconfig/partitions", name, "ssl")
+	s.partitions[name] = partition
+	return partition
+}
+
+// FileLocation returns the directory relative file names are resolved in: DefaultFileLocation, or the
+// ssl directory of an admin partition like /nsconfig/partitions/team-web/ssl
+func (s *Store) FileLocation() string {
+	if s.fileLocation == "" {
+		return DefaultFileLocation
+	}
+	return s.fileLocation
+}
+
+// ResourceName returns the name of a resource as used in NITRO URLs
+func ResourceName(resourceType string, attrs map[string]any) string {
+	key, ok := nameKeys[resourceType]
+	if !ok {
+		key = "name"
+	}
+	if resourceType == "systemfile" {
+		return filePath(attrs)
+	}
+	switch name := attrs[key].(type) {
+	case string:
+		return name
+	case nil:
+		return ""
+	default:
+		// numeric names such as the nodeid of clusternode
+		return fmt.Sprint(name)
+	}
+}
+
+// filePath returns the full path of a systemfile resource
+func filePath(attrs map[string]any) string {
+	location, _ := attrs["filelocation"].(string)
+	if location == "" {
+		location = DefaultFileLocation
+	}
+	name, _ := attrs["filename"].(string)
+	return path.Join(location, name)
+}
+
+// Load seeds the store from a JSON fixture in the format of a NITRO GET response, an object
+// mapping resource types to lists of resources, e.g. {"sslcertkey": [{"certkey": "web"}]}
+func (s *Store) Load(r io.Reader) error {
+	var fixture map[string][]map[string]any
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		return fmt.Errorf("failed to decode fixture: %w", err)
+	}
+
+	types := make([]string, 0, len(fixture))
+	for resourceType := range fixture {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+
+	for _, resourceType := range types {
+		for _, attrs := range fixture[resourceType] {
+			if err := s.Add(resourceType, attrs); err != nil {
+				return fmt.Errorf("failed to add %s fixture: %w", resourceType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadFile seeds the store from a JSON fixture file, see Load
+func (s *Store) LoadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.Load(f)
+}
+
+// Add creates a resource, bindings are added with Bind
+func (s *Store) Add(resourceType string, attrs map[string]any) error {
+	if strings.HasSuffix(resourceType, "_binding") {
+		return s.Bind(resourceType, attrs)
+	}
+
+	name := ResourceName(resourceType, attrs)
+	if name == "" {
+		return &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: "Required argument missing"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resources[resourceType][name]; ok {
+		return &NitroError{
+			Status:  http.StatusConflict,
+			Code:    ErrCodeResourceExists,
+			Message: fmt.Sprintf("Resource already exists [%s]", name),
+		}
+	}
+	if s.resources[resourceType] == nil {
+		s.resources[resourceType] = make(map[string]map[string]any)
+	}
+	s.resources[resourceType][name] = copyAttrs(attrs)
+
+	return nil
+}
+
+// Get returns a copy of a resource
+func (s *Store) Get(resourceType, name string) (map[string]any, error) {
+	if resourceType == "sslcertkey_binding" {
+		return s.certkeyBindings(name)
+	}
+	if owner, ok := aggregateBindingOwner(resourceType); ok {
+		return s.ownerBindings(owner, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, ok := s.resources[resourceType][name]
+	if !ok {
+		return nil, noSuchResource(resourceType, name)
+	}
+	return copyAttrs(res), nil
+}
+
+// List returns copies of all resources of a type sorted by name
+func (s *Store) List(resourceType string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.resources[resourceType]))
+	for name := range s.resources[resourceType] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		list = append(list, copyAttrs(s.resources[resourceType][name]))
+	}
+	return list
+}
+
+// Update merges the attributes into an existing resource.
+// Attributes set to nil are removed.
+func (s *Store) Update(resourceType, name string, attrs map[string]any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, ok := s.resources[resourceType][name]
+	if !ok {
+		return noSuchResource(resourceType, name)
+	}
+	for k, v := range attrs {
+		if v == nil {
+			delete(res, k)
+		} else {
+			res[k] = v
+		}
+	}
+	return nil
+}
+
+// Delete removes a resource
+func (s *Store) Delete(resourceType, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resources[resourceType][name]; !ok {
+		return noSuchResource(resourceType, name)
+	}
+	delete(s.resources[resourceType], name)
+	return nil
+}
+
+// Remove deletes a resource or, for binding types, the bindings of the named resource matching args.
+// systemfile entries are named by filename and located by the filelocation argument.
+func (s *Store) Remove(resourceType, name string, args map[string]string) error {
+	switch {
+	case strings.HasSuffix(resourceType, "_binding"):
+		return s.Unbind(resourceType, name, args)
+	case resourceType == "systemfile":
+		location := args["filelocation"]
+		if location == "" {
+			location = s.FileLocation()
+		}
+		return s.Delete(resourceType, path.Join(location, name))
+	default:
+		return s.Delete(resourceType, name)
+	}
+}
+
+// Filter returns the resources of a type matching all filters, like the NITRO filter query parameter.
+// A value enclosed in slashes is a regular expression, other values have to match exactly.
+func (s *Store) Filter(resourceType string, filter map[string]string) ([]map[string]any, error) {
+	patterns := make(map[string]*regexp.Regexp, len(filter))
+	for k, v := range filter {
+		expr, ok := strings.CutPrefix(v, "/")
+		if expr, ok = strings.CutSuffix(expr, "/"); !ok || expr == "" {
+			expr = "^" + regexp.QuoteMeta(v) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, &NitroError{Status: http.StatusBadRequest, Code: ErrCodeInvalidArgument, Message: fmt.Sprintf("Invalid filter %s: %v", k, err)}
+		}
+		patterns[k] = re
+	}
+
+	var list []map[string]any
+	for _, res := range s.List(resourceType) {
+		matched := true
+		for k, re := range patterns {
+			if !re.MatchString(fmt.Sprint(res[k])) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			list = append(list, res)
+		}
+	}
+	return list, nil
+}
+
+// Bind adds a binding such as sslvserver_sslcertkey_binding
+func (s *Store) Bind(bindingType string, attrs map[string]any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bindings[bindingType] = append(s.bindings[bindingType], copyAttrs(attrs))
+	return nil
+}
+
+// Bindings returns the bindings of the given type for the named resource
+func (s *Store) Bindings(bindingType, owner string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ownerKey := bindingOwnerKey(bindingType)
+	var list []map[string]any
+	for _, b := range s.bindings[bindingType] {
+		if b[ownerKey] == owner {
+			list = append(list, copyAttrs(b))
+		}
+	}
+	return list
+}
+
+// Unbind removes the bindings of the named resource matching all given attributes
+func (s *Store) Unbind(bindingType, owner string, match map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ownerKey := bindingOwnerKey(bindingType)
+	kept := s.bindings[bindingType][:0]
+	removed := 0
+	for _, b := range s.bindings[bindingType] {
+		if b[ownerKey] == owner && matches(b, match) {
+			removed++
+			continue
+		}
+		kept = append(kept, b)
+	}
+	s.bindings[bindingType] = kept
+
+	if removed == 0 {
+		return noSuchResource(bindingType, owner)
+	}
+	return nil
+}
+
+// certkeyBindings synthesizes the sslcertkey_binding resource of a certkey
+func (s *Store) certkeyBindings(certkey string) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resources["sslcertkey"][certkey]; !ok {
+		return nil, noSuchResource("sslcertkey_binding", certkey)
+	}
+
+	res := map[string]any{"certkey": certkey}
+	for _, src := range certkeyBindingSources {
+		var entries []any
+		for _, b := range s.bindings[src.bindingType] {
+			if b["certkeyname"] == certkey {
+				entries = append(entries, map[string]any{
+					"certkey":   certkey,
+					src.nameKey: b[bindingOwnerKey(src.bindingType)],
+				})
+			}
+		}
+		if len(entries) > 0 {
+			res["sslcertkey_"+src.boundType+"_binding"] = entries
+		}
+	}
+	return res, nil
+}
+
+// ownerBindings synthesizes the aggregate binding resource of a vserver, e.g. csvserver_binding
+func (s *Store) ownerBindings(owner, name string) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resources[owner][name]; !ok {
+		return nil, noSuchResource(owner+"_binding", name)
+	}
+
+	nameKey, ok := nameKeys[owner]
+	if !ok {
+		nameKey = "name"
+	}
+	res := map[string]any{nameKey: name}
+	for bindingType, bindings := range s.bindings {
+		if !strings.HasPrefix(bindingType, owner+"_") {
+			continue
+		}
+		var entries []any
+		for _, b := range bindings {
+			if b[bindingOwnerKey(bindingType)] == name {
+				entries = append(entries, copyAttrs(b))
+			}
+		}
+		if len(entries) > 0 {
+			res[bindingType] = entries
+		}
+	}
+	return res, nil
+}
+
+// aggregateBindingOwner returns the owner type of an aggregate binding resource such as csvserver_binding
+func aggregateBindingOwner(resourceType string) (string, bool) {
+	owner, ok := strings.CutSuffix(resourceType, "_binding")
+	return owner, ok && aggregateBindingOwners[owner]
+}
+
+// isOwnerBinding reports whether the resource type is a binding listed per owner, e.g. sslvserver_sslcertkey_binding
+func isOwnerBinding(resourceType string) bool {
+	_, aggregate := aggregateBindingOwner(resourceType)
+	return strings.HasSuffix(resourceType, "_binding") && !aggregate && resourceType != "sslcertkey_binding"
+}
+
+func bindingOwnerKey(bindingType string) string {
+	if key, ok := bindingOwnerKeys[bindingType]; ok {
+		return key
+	}
+	return "name"
+}
+
+func matches(attrs map[string]any, match map[string]string) bool {
+	for k, v := range match {
+		if fmt.Sprint(attrs[k]) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func copyAttrs(attrs map[string]any) map[string]any {
+	c := make(map[string]any, len(attrs))
+	for k, v := range attrs {
+		c[k] = v
+	}
+	return c
+}
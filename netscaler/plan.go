@@ -0,0 +1,350 @@
+package netscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// actionNameKeys maps resource types to the attribute naming the resource in ActOnResource requests
+var actionNameKeys = map[string]string{
+	"sslcertkey":  "certkey",
+	"sslrsakey":   "keyfile",
+	"sslecdsakey": "keyfile",
+	"sslcertreq":  "reqfile",
+}
+
+// volatileAttributes change on the appliance without a write, e.g. daily. ApplyPlan doesn't compare them.
+var volatileAttributes = map[string]bool{
+	"daystoexpiration": true,
+	"status":           true,
+}
+
+// AttributeChange is the change of one attribute by a plan step
+type AttributeChange struct {
+	Attribute string `json:"attribute"`
+	Old       any    `json:"old,omitempty"`
+	New       any    `json:"new,omitempty"`
+}
+
+// PlanStep is a NITRO write operation of a plan
+type PlanStep struct {
+	// Method is the NitroClientInterface method executing the step
+	Method       string `json:"method"`
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name,omitempty"`
+	// Action is add, update, delete or the NITRO action such as link or create
+	Action     string            `json:"action"`
+	Attributes map[string]any    `json:"attributes,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Diff       []AttributeChange `json:"diff,omitempty"`
+}
+
+// Plan is the ordered list of NITRO write operations of a change to one environment
+type Plan struct {
+	Environment string     `json:"environment"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Steps       []PlanStep `json:"steps"`
+}
+
+// LoadPlan reads a plan written as JSON
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if p.Environment == "" {
+		return nil, fmt.Errorf("plan %s has no environment", path)
+	}
+	return &p, nil
+}
+
+// Planner is a NitroClientInterface that passes reads to the wrapped client and records
+// writes as plan steps instead of executing them
+type Planner struct {
+	api NitroClientInterface
+
+	mu    sync.Mutex
+	steps []PlanStep
+}
+
+// NewPlanner wraps api, writes are only planned
+func NewPlanner(api NitroClientInterface) *Planner {
+	return &Planner{api: api}
+}
+
+// Planned returns a client that plans all writes with the returned planner instead of executing them.
+// It shares the session of c.
+func (c *Client) Planned() (*Client, *Planner) {
+	planner := NewPlanner(c.api)
+	return &Client{api: planner, prefix: c.prefix, partition: c.partition, planning: true}, planner
+}
+
+// Plan returns the steps planned so far as plan for the environment
+func (p *Planner) Plan(env string) *Plan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &Plan{
+		Environment: env,
+		CreatedAt:   time.Now().UTC(),
+		Steps:       append([]PlanStep{}, p.steps...),
+	}
+}
+
+// Login logs in with the wrapped client
+func (p *Planner) Login() error {
+	return p.api.Login()
+}
+
+// Logout logs out with the wrapped client
+func (p *Planner) Logout() error {
+	return p.api.Logout()
+}
+
+// FindAllResources queries the wrapped client
+func (p *Planner) FindAllResources(resourceType string) ([]map[string]any, error) {
+	return p.api.FindAllResources(resourceType)
+}
+
+// FindResource queries the wrapped client
+func (p *Planner) FindResource(resourceType string, name string) (map[string]any, error) {
+	return p.api.FindResource(resourceType, name)
+}
+
+// FindResourceArrayWithParams queries the wrapped client
+func (p *Planner) FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error) {
+	return p.api.FindResourceArrayWithParams(findParams)
+}
+
+// AddResource plans the creation of a resource or binding
+func (p *Planner) AddResource(resourceType string, name string, resourceStruct any) (string, error) {
+	attrs, err := attributes(resourceStruct)
+	if err != nil {
+		return "", err
+	}
+	p.plan(PlanStep{Method: "AddResource", ResourceType: resourceType, Name: name, Action: "add", Attributes: attrs, Diff: diffAttributes(nil, attrs)})
+	return name, nil
+}
+
+// UpdateResource plans an update, diffed against the current resource
+func (p *Planner) UpdateResource(resourceType string, name string, resourceStruct any) (string, error) {
+	attrs, err := attributes(resourceStruct)
+	if err != nil {
+		return "", err
+	}
+	current, err := p.current(resourceType, name)
+	if err != nil {
+		return "", err
+	}
+	p.plan(PlanStep{Method: "UpdateResource", ResourceType: resourceType, Name: name, Action: "update", Attributes: attrs, Diff: diffAttributes(current, attrs)})
+	return name, nil
+}
+
+// ActOnResource plans an action. Updates and links are diffed against the current resource.
+func (p *Planner) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	attrs, err := attributes(resourceStruct)
+	if err != nil {
+		return err
+	}
+	nameKey, ok := actionNameKeys[resourceType]
+	if !ok {
+		nameKey = "name"
+	}
+	name := stringField(attrs, nameKey)
+
+	step := PlanStep{Method: "ActOnResource", ResourceType: resourceType, Name: name, Action: action, Attributes: attrs}
+	if !step.readsCurrent() {
+		step.Diff = diffAttributes(nil, attrs)
+		p.plan(step)
+		return nil
+	}
+	current, err := p.current(resourceType, name)
+	if err != nil {
+		return err
+	}
+	if action != "unlink" {
+		step.Diff = diffAttributes(current, attrs)
+	} else if linked := stringField(current, "linkcertkeyname"); linked != "" {
+		step.Diff = []AttributeChange{{Attribute: "linkcertkeyname", Old: linked}}
+	}
+	p.plan(step)
+	return nil
+}
+
+// DeleteResource plans the removal of a resource
+func (p *Planner) DeleteResource(resourceType string, name string) error {
+	current, err := p.current(resourceType, name)
+	if err != nil {
+		return err
+	}
+	p.plan(PlanStep{Method: "DeleteResource", ResourceType: resourceType, Name: name, Action: "delete", Diff: diffAttributes(current, nil)})
+	return nil
+}
+
+// DeleteResourceWithArgsMap plans the removal of a resource or binding
+func (p *Planner) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	p.plan(PlanStep{Method: "DeleteResourceWithArgsMap", ResourceType: resourceType, Name: name, Action: "delete", Args: args})
+	return nil
+}
+
+func (p *Planner) plan(step PlanStep) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, step)
+}
+
+// current returns the resource a step changes, nil if it doesn't exist (yet)
+func (p *Planner) current(resourceType, name string) (map[string]any, error) {
+	return currentResource(p.api, resourceType, name)
+}
+
+// currentResource reads the resource a step changes, nil if it doesn't exist
+func currentResource(api NitroClientInterface, resourceType, name string) (map[string]any, error) {
+	if name == "" {
+		return nil, nil
+	}
+	res, err := api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType:             resourceType,
+		ResourceName:             name,
+		ResourceMissingErrorCode: errCodeNoSuchResource,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s: %w", resourceType, name, err)
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
+
+// CheckPlan verifies that the resources the steps of a plan change are still in the state the plan
+// was made from, see ApplyPlan. Nothing is changed.
+func (c *Client) CheckPlan(plan *Plan) error {
+	touched := make(map[string]bool)
+	for i, step := range plan.Steps {
+		if err := step.check(c.api, touched); err != nil {
+			return fmt.Errorf("step %d (%s %s %s): %w", i+1, step.Action, step.ResourceType, step.Name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyPlan executes the steps of a plan in order and stops at the first failure. Before each step
+// the old values of its diff are compared with the appliance, a step is refused if the resource was
+// changed or removed since the plan was made. It returns the number of steps applied.
+func (c *Client) ApplyPlan(plan *Plan) (int, error) {
+	touched := make(map[string]bool)
+	for i, step := range plan.Steps {
+		if err := step.check(c.api, touched); err != nil {
+			return i, fmt.Errorf("step %d (%s %s %s) refused: %w", i+1, step.Action, step.ResourceType, step.Name, err)
+		}
+		if err := step.apply(c.api); err != nil {
+			return i, fmt.Errorf("step %d (%s %s %s) failed: %w", i+1, step.Action, step.ResourceType, step.Name, err)
+		}
+	}
+	return len(plan.Steps), nil
+}
+
+// readsCurrent reports whether the planner diffed the step against the resource it changes
+func (s PlanStep) readsCurrent() bool {
+	switch s.Method {
+	case "UpdateResource", "DeleteResource":
+		return true
+	case "ActOnResource":
+		return s.Action == "update" || s.Action == "link" || s.Action == "unlink"
+	}
+	return false
+}
+
+// check compares the old values of the diff of the step with the current resource. Resources
+// changed by earlier steps of the plan are not compared, touched records them.
+func (s PlanStep) check(api NitroClientInterface, touched map[string]bool) error {
+	key := s.ResourceType + "/" + s.Name
+	if touched[key] || !s.readsCurrent() {
+		touched[key] = true
+		return nil
+	}
+	touched[key] = true
+
+	current, err := currentResource(api, s.ResourceType, s.Name)
+	if err != nil {
+		return err
+	}
+	for _, change := range s.Diff {
+		if change.Old == nil || volatileAttributes[change.Attribute] {
+			continue
+		}
+		if current == nil {
+			return fmt.Errorf("%s %s was removed since the plan was made", s.ResourceType, s.Name)
+		}
+		if now, ok := current[change.Attribute]; !ok || fmt.Sprint(now) != fmt.Sprint(change.Old) {
+			return fmt.Errorf("%s of %s %s changed since the plan was made: planned from %v, now %v",
+				change.Attribute, s.ResourceType, s.Name, change.Old, now)
+		}
+	}
+	return nil
+}
+
+// apply executes the step with the method it was planned with
+func (s PlanStep) apply(api NitroClientInterface) error {
+	var err error
+	switch s.Method {
+	case "AddResource":
+		_, err = api.AddResource(s.ResourceType, s.Name, s.Attributes)
+	case "UpdateResource":
+		_, err = api.UpdateResource(s.ResourceType, s.Name, s.Attributes)
+	case "ActOnResource":
+		err = api.ActOnResource(s.ResourceType, s.Attributes, s.Action)
+	case "DeleteResource":
+		err = api.DeleteResource(s.ResourceType, s.Name)
+	case "DeleteResourceWithArgsMap":
+		err = api.DeleteResourceWithArgsMap(s.ResourceType, s.Name, s.Args)
+	default:
+		err = fmt.Errorf("unsupported method %q", s.Method)
+	}
+	return err
+}
+
+// attributes converts a resource struct to NITRO attributes the way it is sent on the wire
+func attributes(resourceStruct any) (map[string]any, error) {
+	data, err := json.Marshal(resourceStruct)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]any)
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// diffAttributes lists the attributes that differ between the current and the new resource, sorted
+// by name. A nil current resource is created, a nil new resource removed.
+func diffAttributes(current, updated map[string]any) []AttributeChange {
+	var diff []AttributeChange
+	if updated == nil {
+		for k, v := range current {
+			diff = append(diff, AttributeChange{Attribute: k, Old: v})
+		}
+	}
+	for k, v := range updated {
+		old, ok := current[k]
+		if !ok || fmt.Sprint(old) != fmt.Sprint(v) {
+			diff = append(diff, AttributeChange{Attribute: k, Old: old, New: v})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Attribute < diff[j].Attribute })
+	return diff
+}
+
+var _ NitroClientInterface = (*Planner)(nil)
@@ -0,0 +1,218 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestClient_Planned(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	keyfile := client.KeyFile("example.com")
+	if err := client.CreateKey(keyfile, KeySpec{Type: "rsa", Bits: 2048}); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	csr, err := client.CreateCSR(keyfile, client.CSRFile("example.com"), testCSRRequest)
+	if err != nil {
+		t.Fatalf("CreateCSR() error = %v", err)
+	}
+	ca := newTestCA(t)
+	pemData := ca.sign(t, csr, 1)
+	chainCertkey := client.IntermediateCertkey(ca.cert)
+
+	planned, planner := client.Planned()
+	d, err := planned.DeployCertificate("example.com", pemData, keyfile)
+	if err != nil {
+		t.Fatalf("DeployCertificate() with planner error = %v", err)
+	}
+	if _, err := api.Store.Get("sslcertkey", d.Certkey); err == nil {
+		t.Fatal("a planned deployment should not change the appliance")
+	}
+
+	plan := planner.Plan("prod")
+	var got []string
+	for _, step := range plan.Steps {
+		got = append(got, step.Action+" "+step.ResourceType+" "+step.Name)
+	}
+	want := []string{
+		"add systemfile " + d.CertFile,
+		"add sslcertkey prod-example.com",
+		"add systemfile " + chainCertkey + ".crt",
+		"add sslcertkey " + chainCertkey,
+		"link sslcertkey prod-example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planned steps = %v, want %v", got, want)
+	}
+	if link := plan.Steps[4].Diff; len(link) != 2 || link[1].Attribute != "linkcertkeyname" || link[1].New != chainCertkey {
+		t.Errorf("diff of the link step = %+v", link)
+	}
+
+	applied, err := client.ApplyPlan(plan)
+	if err != nil || applied != len(plan.Steps) {
+		t.Fatalf("ApplyPlan() = %d, %v", applied, err)
+	}
+	res, err := api.Store.Get("sslcertkey", "prod-example.com")
+	if err != nil || res["linkcertkeyname"] != chainCertkey || res["key"] != keyfile {
+		t.Errorf("certkey after ApplyPlan() = %v, %v", res, err)
+	}
+
+	// A planned rotation moves the bindings without verifying them
+	if err := client.bindCertkey("prod-example.com", Binding{Type: "sslvserver", Name: "vs-web"}); err != nil {
+		t.Fatalf("bindCertkey() error = %v", err)
+	}
+	planned, planner = client.Planned()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := planned.RotateCertificate("example.com", signTestCSR(t, csr, 2), keyfile, Rotation{Now: now}); err != nil {
+		t.Fatalf("RotateCertificate() with planner error = %v", err)
+	}
+	plan = planner.Plan("prod")
+	last := plan.Steps[len(plan.Steps)-2:]
+	if last[0].Method != "DeleteResourceWithArgsMap" || last[0].Args["certkeyname"] != "prod-example.com" ||
+		last[1].Attributes["certkeyname"] != client.VersionedCertkey("example.com", now) {
+		t.Errorf("planned rebind = %+v", last)
+	}
+	if bound := api.Store.Bindings("sslvserver_sslcertkey_binding", "vs-web"); len(bound) != 1 || bound[0]["certkeyname"] != "prod-example.com" {
+		t.Errorf("a planned rotation should not change the bindings, got %v", bound)
+	}
+
+	// Applying stops at the first failing step
+	api.FailNextCall("DeleteResourceWithArgsMap", "sslvserver_sslcertkey_binding", errors.New("connection reset"))
+	applied, err = client.ApplyPlan(plan)
+	if err == nil || applied != len(plan.Steps)-2 || !strings.Contains(err.Error(), "step") {
+		t.Errorf("ApplyPlan() with failing step = %d, %v", applied, err)
+	}
+}
+
+func TestClient_ApplyPlan_Refuses(t *testing.T) {
+	tests := []struct {
+		name        string
+		plan        func(p *Planner) error
+		change      func(api *netscalertest.NitroClient) error
+		wantErr     string
+		description string
+	}{
+		{
+			name: "link changed",
+			plan: func(p *Planner) error {
+				return p.ActOnResource("sslcertkey", map[string]any{"certkey": "prod-example.com"}, "unlink")
+			},
+			change: func(api *netscalertest.NitroClient) error {
+				return api.Store.Update("sslcertkey", "prod-example.com", map[string]any{"linkcertkeyname": "prod-other-ca"})
+			},
+			wantErr:     "linkcertkeyname of sslcertkey prod-example.com changed since the plan was made: planned from prod-ca, now prod-other-ca",
+			description: "a step should be refused if an old value of its diff changed",
+		},
+		{
+			name: "removed",
+			plan: func(p *Planner) error {
+				return p.DeleteResource("sslcertkey", "prod-example.com")
+			},
+			change: func(api *netscalertest.NitroClient) error {
+				return api.Store.Delete("sslcertkey", "prod-example.com")
+			},
+			wantErr:     "sslcertkey prod-example.com was removed since the plan was made",
+			description: "a step should be refused if its resource was removed",
+		},
+		{
+			name: "volatile",
+			plan: func(p *Planner) error {
+				return p.DeleteResource("sslcertkey", "prod-example.com")
+			},
+			change: func(api *netscalertest.NitroClient) error {
+				return api.Store.Update("sslcertkey", "prod-example.com", map[string]any{"daystoexpiration": 29})
+			},
+			description: "attributes changing without a write should not be compared",
+		},
+		{
+			name: "read fails",
+			plan: func(p *Planner) error {
+				return p.DeleteResource("sslcertkey", "prod-example.com")
+			},
+			change: func(api *netscalertest.NitroClient) error {
+				api.FailNext("FindResourceArrayWithParams", errors.New("connection reset"))
+				return nil
+			},
+			wantErr:     "failed to read sslcertkey prod-example.com: connection reset",
+			description: "a step should be refused if its resource can't be read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api := newTestKeyClient(t)
+			if err := api.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "cert": "prod-example.com.crt", "linkcertkeyname": "prod-ca", "daystoexpiration": 30}); err != nil {
+				t.Fatalf("Store.Add() error = %v", err)
+			}
+			planner := NewPlanner(api)
+			if err := tt.plan(planner); err != nil {
+				t.Fatalf("planning error = %v", err)
+			}
+			if err := tt.change(api); err != nil {
+				t.Fatalf("changing the appliance error = %v", err)
+			}
+
+			applied, err := client.ApplyPlan(planner.Plan("prod"))
+			if tt.wantErr == "" {
+				if err != nil || applied != 1 {
+					t.Errorf("ApplyPlan() = %d, %v, want the step applied", applied, err)
+				}
+				return
+			}
+			if err == nil || applied != 0 || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ApplyPlan() = %d, %v, want %q", applied, err, tt.wantErr)
+			}
+			if _, err := api.Store.Get("sslcertkey", "prod-example.com"); tt.name != "removed" && err != nil {
+				t.Errorf("a refused step should not change the appliance, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiffAttributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     map[string]any
+		updated     map[string]any
+		want        []AttributeChange
+		description string
+	}{
+		{
+			name:        "create",
+			updated:     map[string]any{"certkey": "web", "cert": "web.crt"},
+			want:        []AttributeChange{{Attribute: "cert", New: "web.crt"}, {Attribute: "certkey", New: "web"}},
+			description: "all attributes of a new resource should be listed",
+		},
+		{
+			name:        "update",
+			current:     map[string]any{"certkey": "web", "cert": "old.crt", "status": "Valid"},
+			updated:     map[string]any{"certkey": "web", "cert": "new.crt"},
+			want:        []AttributeChange{{Attribute: "cert", Old: "old.crt", New: "new.crt"}},
+			description: "only changed attributes should be listed",
+		},
+		{
+			name:        "delete",
+			current:     map[string]any{"certkey": "web"},
+			want:        []AttributeChange{{Attribute: "certkey", Old: "web"}},
+			description: "all attributes of a removed resource should be listed",
+		},
+		{
+			name:        "numbers",
+			current:     map[string]any{"bits": 2048},
+			updated:     map[string]any{"bits": float64(2048)},
+			description: "numbers decoded from JSON should equal their integer value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffAttributes(tt.current, tt.updated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffAttributes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
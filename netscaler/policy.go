@@ -0,0 +1,156 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Severity classifies how serious a policy violation is
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Policy rule identifiers, used in violations and to override severities
+const (
+	RuleMinRSAKeySize    = "minRsaKeySize"
+	RuleAllowedECCurves  = "allowedEcCurves"
+	RuleForbidSHA1       = "forbidSha1"
+	RuleMaxValidityDays  = "maxValidityDays"
+	RuleRequiredIssuer   = "requiredIssuer"
+	RuleDomainInSAN      = "requireDomainInSan"
+	RuleUnparsableFields = "parse"
+)
+
+var defaultSeverities = map[string]Severity{
+	RuleMinRSAKeySize:    SeverityCritical,
+	RuleAllowedECCurves:  SeverityCritical,
+	RuleForbidSHA1:       SeverityCritical,
+	RuleMaxValidityDays:  SeverityWarning,
+	RuleRequiredIssuer:   SeverityWarning,
+	RuleDomainInSAN:      SeverityCritical,
+	RuleUnparsableFields: SeverityWarning,
+}
+
+// ecCurvesBySize maps the NITRO public key size of an EC key to its curve name
+var ecCurvesBySize = map[int]string{
+	256: "P-256",
+	384: "P-384",
+	521: "P-521",
+}
+
+// Policy describes the compliance rules a deployed certificate has to satisfy.
+// Zero values disable the corresponding rule.
+type Policy struct {
+	MinRSAKeySize      int                 `json:"minRsaKeySize,omitempty"`
+	AllowedECCurves    []string            `json:"allowedEcCurves,omitempty"`
+	ForbidSHA1         bool                `json:"forbidSha1,omitempty"`
+	MaxValidityDays    int                 `json:"maxValidityDays,omitempty"`
+	RequiredIssuer     string              `json:"requiredIssuer,omitempty"`
+	RequireDomainInSAN bool                `json:"requireDomainInSan,omitempty"`
+	Severities         map[string]Severity `json:"severities,omitempty"`
+}
+
+// Violation is a single failed policy rule
+type Violation struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Evaluate checks the raw NITRO certificate attributes against the policy.
+// The domains are the names the certificate was requested for.
+func (p *Policy) Evaluate(raw map[string]any, domains ...string) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{
+			Rule:     rule,
+			Severity: p.severity(rule),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	cert, err := ParseCertificate(raw)
+	if err != nil {
+		add(RuleUnparsableFields, "failed to parse certificate: %v", err)
+		return violations
+	}
+
+	isEC := isECKey(cert.KeyType)
+	// the key size is only checked when the firmware reports an RSA key and its size
+	isRSA := isRSAKey(cert.KeyType) && cert.KeySize > 0
+	if p.MinRSAKeySize > 0 && isRSA && cert.KeySize < p.MinRSAKeySize {
+		add(RuleMinRSAKeySize, "RSA key size %d is below the minimum of %d", cert.KeySize, p.MinRSAKeySize)
+	}
+	if len(p.AllowedECCurves) > 0 && isEC {
+		curve := ecCurvesBySize[cert.KeySize]
+		if !slices.ContainsFunc(p.AllowedECCurves, func(c string) bool { return strings.EqualFold(c, curve) }) {
+			add(RuleAllowedECCurves, "EC curve %q (%d bit) is not in %v", curve, cert.KeySize, p.AllowedECCurves)
+		}
+	}
+	if p.ForbidSHA1 && strings.Contains(strings.ToLower(cert.SignatureAlg), "sha1") {
+		add(RuleForbidSHA1, "certificate is signed with %s", cert.SignatureAlg)
+	}
+	if p.MaxValidityDays > 0 && !cert.NotBefore.IsZero() && !cert.NotAfter.IsZero() {
+		validity := int(cert.NotAfter.Sub(cert.NotBefore) / (24 * time.Hour))
+		if validity > p.MaxValidityDays {
+			add(RuleMaxValidityDays, "validity period of %d days exceeds the maximum of %d", validity, p.MaxValidityDays)
+		}
+	}
+	if p.RequiredIssuer != "" && !strings.Contains(cert.Issuer, p.RequiredIssuer) {
+		add(RuleRequiredIssuer, "issuer %q does not match %q", cert.Issuer, p.RequiredIssuer)
+	}
+	if p.RequireDomainInSAN {
+		for _, domain := range domains {
+			if !slices.ContainsFunc(cert.SANs, func(san string) bool { return matchHostname(san, domain) }) {
+				add(RuleDomainInSAN, "subject alternative names %v do not include %s", cert.SANs, domain)
+			}
+		}
+	}
+
+	return violations
+}
+
+// isRSAKey reports whether the publickey of a certkey names an RSA key. The appliance reports the
+// OpenSSL name of the algorithm, rsaEncryption, older firmware and snapshots may report RSA.
+func isRSAKey(keyType string) bool {
+	return strings.HasPrefix(strings.ToLower(keyType), "rsa")
+}
+
+// isECKey reports whether the publickey of a certkey names an EC key, id-ecPublicKey as reported
+// by the appliance, or EC and ECDSA
+func isECKey(keyType string) bool {
+	keyType = strings.ToLower(keyType)
+	return keyType == "id-ecpublickey" || strings.HasPrefix(keyType, "ec")
+}
+
+// validateSeverities rejects severity overrides of unknown rules and unknown severities
+func (p *Policy) validateSeverities() error {
+	var errs []error
+	for _, rule := range slices.Sorted(maps.Keys(p.Severities)) {
+		field := "policy.severities." + rule
+		if _, ok := defaultSeverities[rule]; !ok {
+			errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf("is not a policy rule, use one of %s", strings.Join(slices.Sorted(maps.Keys(defaultSeverities)), ", "))})
+		}
+		switch p.Severities[rule] {
+		case SeverityCritical, SeverityWarning, SeverityInfo:
+		default:
+			errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf("must be %s, %s or %s, got %q", SeverityCritical, SeverityWarning, SeverityInfo, p.Severities[rule])})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Policy) severity(rule string) Severity {
+	if s, ok := p.Severities[rule]; ok {
+		return s
+	}
+	return defaultSeverities[rule]
+}
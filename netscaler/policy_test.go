@@ -0,0 +1,205 @@
+package netscaler
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCertificate(overrides map[string]any) map[string]any {
+	cert := map[string]any{
+		"certkey":             "prod-example.com",
+		"subject":             "CN=example.com",
+		"issuer":              "C=US, O=Let's Encrypt, CN=R11",
+		"clientcertnotbefore": "Mar  1 00:00:00 2025 GMT",
+		"clientcertnotafter":  "May 30 00:00:00 2025 GMT",
+		"publickey":           "RSA",
+		"publickeysize":       float64(2048),
+		"signaturealg":        "sha256WithRSAEncryption",
+		"sandns":              "example.com www.example.com",
+	}
+	for k, v := range overrides {
+		cert[k] = v
+	}
+	return cert
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *Policy
+		cert        map[string]any
+		domains     []string
+		wantRules   []string
+		description string
+	}{
+		{
+			name:        "empty policy",
+			policy:      &Policy{},
+			cert:        testCertificate(nil),
+			description: "should not report anything without rules",
+		},
+		{
+			name: "compliant certificate",
+			policy: &Policy{
+				MinRSAKeySize:      2048,
+				ForbidSHA1:         true,
+				MaxValidityDays:    90,
+				RequiredIssuer:     "Let's Encrypt",
+				RequireDomainInSAN: true,
+			},
+			cert:        testCertificate(nil),
+			domains:     []string{"example.com", "www.example.com"},
+			description: "should not report violations for a compliant certificate",
+		},
+		{
+			name:        "rsa key too small",
+			policy:      &Policy{MinRSAKeySize: 3072},
+			cert:        testCertificate(nil),
+			wantRules:   []string{RuleMinRSAKeySize},
+			description: "should report small RSA keys",
+		},
+		{
+			name:        "unknown key type",
+			policy:      &Policy{MinRSAKeySize: 3072},
+			cert:        testCertificate(map[string]any{"publickey": ""}),
+			description: "should not report the RSA rule when the key type is not reported",
+		},
+		{
+			name:        "unknown key size",
+			policy:      &Policy{MinRSAKeySize: 3072},
+			cert:        testCertificate(map[string]any{"publickeysize": float64(0)}),
+			description: "should not report the RSA rule when the key size is not reported",
+		},
+		{
+			name:        "ec curve not allowed",
+			policy:      &Policy{MinRSAKeySize: 4096, AllowedECCurves: []string{"P-384"}},
+			cert:        testCertificate(map[string]any{"publickey": "ECDSA", "publickeysize": float64(256)}),
+			wantRules:   []string{RuleAllowedECCurves},
+			description: "should check EC curves and skip the RSA rule for EC keys",
+		},
+		{
+			name:        "openssl rsa name",
+			policy:      &Policy{MinRSAKeySize: 3072},
+			cert:        testCertificate(map[string]any{"publickey": "rsaEncryption"}),
+			wantRules:   []string{RuleMinRSAKeySize},
+			description: "should recognize the OpenSSL name of RSA keys the appliance reports",
+		},
+		{
+			name:        "openssl ec name",
+			policy:      &Policy{MinRSAKeySize: 4096, AllowedECCurves: []string{"P-384"}},
+			cert:        testCertificate(map[string]any{"publickey": "id-ecPublicKey", "publickeysize": float64(256)}),
+			wantRules:   []string{RuleAllowedECCurves},
+			description: "should recognize the OpenSSL name of EC keys the appliance reports",
+		},
+		{
+			name:        "sha1 signature",
+			policy:      &Policy{ForbidSHA1: true},
+			cert:        testCertificate(map[string]any{"signaturealg": "sha1WithRSAEncryption"}),
+			wantRules:   []string{RuleForbidSHA1},
+			description: "should report SHA-1 signatures",
+		},
+		{
+			name:        "validity too long",
+			policy:      &Policy{MaxValidityDays: 47},
+			cert:        testCertificate(nil),
+			wantRules:   []string{RuleMaxValidityDays},
+			description: "should report long validity periods",
+		},
+		{
+			name:        "wrong issuer",
+			policy:      &Policy{RequiredIssuer: "DigiCert"},
+			cert:        testCertificate(nil),
+			wantRules:   []string{RuleRequiredIssuer},
+			description: "should report unexpected issuers",
+		},
+		{
+			name:        "domain missing in san",
+			policy:      &Policy{RequireDomainInSAN: true},
+			cert:        testCertificate(nil),
+			domains:     []string{"example.com", "api.example.com"},
+			wantRules:   []string{RuleDomainInSAN},
+			description: "should report each requested domain missing from the SANs",
+		},
+		{
+			name:        "unparsable certificate",
+			policy:      &Policy{ForbidSHA1: true},
+			cert:        testCertificate(map[string]any{"clientcertnotafter": "never"}),
+			wantRules:   []string{RuleUnparsableFields},
+			description: "should report certificates that cannot be parsed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Evaluate(tt.cert, tt.domains...)
+			if len(got) != len(tt.wantRules) {
+				t.Fatalf("Evaluate() = %v, want rules %v", got, tt.wantRules)
+			}
+			for i, v := range got {
+				if v.Rule != tt.wantRules[i] {
+					t.Errorf("Evaluate()[%d].Rule = %v, want %v", i, v.Rule, tt.wantRules[i])
+				}
+				if v.Severity == "" {
+					t.Errorf("Evaluate()[%d].Severity is empty", i)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicy_SeverityOverride(t *testing.T) {
+	policy := &Policy{
+		RequiredIssuer: "DigiCert",
+		Severities:     map[string]Severity{RuleRequiredIssuer: SeverityInfo},
+	}
+
+	got := policy.Evaluate(testCertificate(nil))
+	if len(got) != 1 {
+		t.Fatalf("Evaluate() returned %d violations, want 1", len(got))
+	}
+	if got[0].Severity != SeverityInfo {
+		t.Errorf("Evaluate() severity = %v, want %v", got[0].Severity, SeverityInfo)
+	}
+}
+
+func TestNewConfig_PolicySeverities(t *testing.T) {
+	tests := []struct {
+		name        string
+		severities  map[string]any
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "valid",
+			severities:  map[string]any{RuleRequiredIssuer: "info", RuleForbidSHA1: "warning"},
+			description: "known rules and severities should be accepted",
+		},
+		{
+			name:        "unknown rule",
+			severities:  map[string]any{"forbidSHA1": "info"},
+			wantErr:     "field 'policy.severities.forbidSHA1' is not a policy rule",
+			description: "a misspelled rule should be rejected",
+		},
+		{
+			name:        "unknown severity",
+			severities:  map[string]any{RuleForbidSHA1: "error"},
+			wantErr:     `field 'policy.severities.forbidSha1' must be critical, warning or info, got "error"`,
+			description: "an unknown severity should be rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConfig(map[string]any{"policy": map[string]any{"forbidSha1": true, "severities": tt.severities}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
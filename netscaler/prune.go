@@ -0,0 +1,279 @@
+package netscaler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// Reasons a resource is a prune candidate
+const (
+	PruneExpired  = "expired"
+	PruneUnbound  = "unbound"
+	PruneOrphaned = "orphaned"
+	// PruneReplaced is a certkey version replaced by RotateCertificate longer than the grace period ago
+	PruneReplaced = "replaced"
+)
+
+// DefaultProtected are the names of the certificates and files of the appliance itself, which are never pruned
+var DefaultProtected = []string{
+	"ns-server-certificate",
+	"ns-sftrust-certificate",
+	"ns-server.cert",
+	"ns-server.key",
+	"ns-root.cert",
+	"ns-root.key",
+	"ns-sftrust.cert",
+	"ns-sftrust.key",
+}
+
+// fileReferences are the attributes of resources other than certkeys that reference files in the
+// FileLocation, e.g. DH parameter files created with ssldhparam and CRLs
+var fileReferences = []struct {
+	resourceType string
+	attrs        []string
+}{
+	{"sslcrl", []string{"crlpath", "cacertfile"}},
+	{"sslprofile", []string{"dhfile"}},
+	{"sslservice", []string{"dhfile"}},
+	{"sslservicegroup", []string{"dhfile"}},
+	{"sslvserver", []string{"dhfile"}},
+}
+
+// Pruning configures PruneCandidates
+type Pruning struct {
+	// Protected are globs or /regular expressions/ of names that are never pruned
+	Protected []string
+	// Others are the prefixes of the other environments: names starting with a longer prefix that
+	// extends the prefix of the environment, e.g. prod-eu- for prod-, belong to that environment
+	Others []string
+	// Grace is how long versions replaced by RotateCertificate are kept, like RetireVersions does
+	Grace time.Duration
+	// All allows an environment without prefix, where every certkey and file of the appliance matches
+	All bool
+	// Now decides which certkeys are expired, the current time if zero
+	Now time.Time
+}
+
+// PruneCandidate is a certkey or file in the FileLocation that is no longer needed
+type PruneCandidate struct {
+	// Type is sslcertkey or systemfile
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// Bindings of an expired certkey, it has to be unbound before it can be deleted
+	Bindings []Binding `json:"bindings,omitempty"`
+}
+
+// PruneCandidates lists the certkeys of the environment that are expired or not bound, and the files
+// with the environment prefix that neither a certkey nor one of the fileReferences references.
+// Certkeys other certkeys link to, pending CSRs with their keys, versions replaced within the grace
+// period and protected names are kept, as are the names of other environments.
+func (c *Client) PruneCandidates(p Pruning) ([]PruneCandidate, error) {
+	if c.prefix == "" && !p.All {
+		return nil, fmt.Errorf("refusing to prune an environment without prefix, every certkey and file of the appliance would match")
+	}
+	now := p.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	// FindAllResources reports failed requests as an empty list, which would orphan every file
+	all, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: service.Sslcertkey.Type()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve certificates: %w", err)
+	}
+
+	linked := make(map[string]bool)
+	referenced := make(map[string]bool)
+	names := make([]string, 0, len(all))
+	for _, raw := range all {
+		names = append(names, stringField(raw, "certkey"))
+		if issuer := stringField(raw, "linkcertkeyname"); issuer != "" {
+			linked[issuer] = true
+		}
+		for _, attr := range []string{"cert", "key"} {
+			if file := c.sslFileName(stringField(raw, attr)); file != "" {
+				referenced[file] = true
+			}
+		}
+	}
+
+	replaced := c.replacedVersions(names)
+	var candidates []PruneCandidate
+	for _, raw := range all {
+		cert, err := ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !c.ownsName(p.Others, cert.Certkey) || linked[cert.Certkey] || isProtected(p.Protected, cert.Certkey) {
+			continue
+		}
+
+		bindings, err := c.GetCertificateBindings(cert.Certkey)
+		if err != nil {
+			return nil, err
+		}
+		replacedAt, isReplaced := replaced[cert.Certkey]
+		switch {
+		case cert.Status == "Expired" || (!cert.NotAfter.IsZero() && cert.NotAfter.Before(now)):
+			candidates = append(candidates, PruneCandidate{Type: service.Sslcertkey.Type(), Name: cert.Certkey, Reason: PruneExpired, Bindings: bindings})
+		case len(bindings) > 0:
+		case isReplaced && now.Sub(replacedAt) < p.Grace:
+			// Kept to roll back the rotation by rebinding it
+		case isReplaced:
+			candidates = append(candidates, PruneCandidate{Type: service.Sslcertkey.Type(), Name: cert.Certkey, Reason: PruneReplaced})
+		default:
+			candidates = append(candidates, PruneCandidate{Type: service.Sslcertkey.Type(), Name: cert.Certkey, Reason: PruneUnbound})
+		}
+	}
+
+	files, err := c.listFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.referencedFiles(referenced); err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		if !c.ownsName(p.Others, name) || referenced[name] || isProtected(p.Protected, name) || isPendingCSR(files, referenced, name) {
+			continue
+		}
+		// The appliance always has its own certkeys, without any the list can't be trusted
+		if len(all) == 0 {
+			return nil, fmt.Errorf("no certkeys found but files with prefix %s exist, refusing to treat them as orphaned", c.prefix)
+		}
+		candidates = append(candidates, PruneCandidate{Type: service.Systemfile.Type(), Name: name, Reason: PruneOrphaned})
+	}
+
+	return candidates, nil
+}
+
+// referencedFiles adds the files in the FileLocation the fileReferences reference. A failed query
+// is an error, the files it would protect would be listed as orphaned otherwise.
+func (c *Client) referencedFiles(referenced map[string]bool) error {
+	for _, ref := range fileReferences {
+		res, err := c.api.FindResourceArrayWithParams(service.FindParams{ResourceType: ref.resourceType})
+		if err != nil {
+			return fmt.Errorf("failed to retrieve %s: %w", ref.resourceType, err)
+		}
+		for _, raw := range res {
+			for _, attr := range ref.attrs {
+				if file := c.sslFileName(stringField(raw, attr)); file != "" {
+					referenced[file] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ownsName reports whether a certkey or file name has the prefix of the environment and none of the
+// longer prefixes of other environments extending it
+func (c *Client) ownsName(others []string, name string) bool {
+	if !strings.HasPrefix(name, c.prefix) {
+		return false
+	}
+	for _, other := range others {
+		if len(other) > len(c.prefix) && strings.HasPrefix(other, c.prefix) && strings.HasPrefix(name, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// Prune deletes the candidates, certkeys before the files they may reference. Bound certkeys are
+// skipped. It returns the candidates deleted.
+func (c *Client) Prune(candidates []PruneCandidate) ([]PruneCandidate, error) {
+	ordered := append([]PruneCandidate(nil), candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Type == service.Sslcertkey.Type() && ordered[j].Type != service.Sslcertkey.Type()
+	})
+
+	var deleted []PruneCandidate
+	for _, candidate := range ordered {
+		var err error
+		switch {
+		case len(candidate.Bindings) > 0:
+			continue
+		case candidate.Type == service.Sslcertkey.Type():
+			err = c.api.DeleteResource(candidate.Type, candidate.Name)
+		case candidate.Type == service.Systemfile.Type():
+			err = c.api.DeleteResourceWithArgsMap(candidate.Type, candidate.Name, map[string]string{
+				"filelocation": url.QueryEscape(c.FileLocation()),
+			})
+		default:
+			err = fmt.Errorf("unsupported type %s", candidate.Type)
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s %s: %w", candidate.Type, candidate.Name, err)
+		}
+		deleted = append(deleted, candidate)
+	}
+	return deleted, nil
+}
+
+// listFiles returns the names of the files in the FileLocation
+func (c *Client) listFiles() ([]string, error) {
+	files, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: service.Systemfile.Type(),
+		ArgsMap:      map[string]string{"filelocation": url.QueryEscape(c.FileLocation())},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", c.FileLocation(), err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		// Directories are reported with filemode DIRECTORY
+		if name := stringField(f, "filename"); name != "" && stringField(f, "filemode") != "DIRECTORY" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sslFileName returns the name of a file referenced by a certkey if it is located in the FileLocation
+func (c *Client) sslFileName(file string) string {
+	if file == "" || !path.IsAbs(file) {
+		return file
+	}
+	if path.Dir(file) != c.FileLocation() {
+		return ""
+	}
+	return path.Base(file)
+}
+
+// isPendingCSR reports whether the file is a CSR, or the key of a CSR, whose key is not used by a
+// certkey yet, i.e. the CSR is waiting for its certificate
+func isPendingCSR(files []string, referenced map[string]bool, name string) bool {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	var other string
+	switch path.Ext(name) {
+	case ".csr":
+		other = base + ".key"
+	case ".key":
+		other = base + ".csr"
+	default:
+		return false
+	}
+	i := sort.SearchStrings(files, other)
+	return i < len(files) && files[i] == other && !referenced[base+".key"]
+}
+
+// isProtected reports whether the name matches one of the default or given protected names
+func isProtected(protected []string, name string) bool {
+	for _, pattern := range slices.Concat(DefaultProtected, protected) {
+		if ok, _ := matchDomain(pattern, strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
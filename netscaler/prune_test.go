@@ -0,0 +1,274 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// seedPrune adds certkeys and files in every state PruneCandidates distinguishes
+func seedPrune(t *testing.T, api *netscalertest.NitroClient) {
+	t.Helper()
+	certkeys := []map[string]any{
+		{"certkey": "prod-live.com", "cert": "prod-live.crt", "key": "prod-live.key", "linkcertkeyname": "prod-ca", "status": "Valid"},
+		{"certkey": "prod-ca", "cert": "prod-ca.crt", "status": "Valid"},
+		{"certkey": "prod-old.com", "cert": "prod-old.crt", "status": "Expired"},
+		{"certkey": "prod-past.com", "cert": "prod-past.crt", "clientcertnotafter": "Jan 1 00:00:00 2026 GMT"},
+		{"certkey": "prod-unused.com", "cert": "/nsconfig/ssl/prod-unused.crt", "status": "Valid"},
+		{"certkey": "prod-keep.com", "cert": "prod-keep.crt", "status": "Valid"},
+		{"certkey": "staging-unused.com", "cert": "staging-unused.crt", "status": "Valid"},
+		{"certkey": "prod-eu-unused.com", "cert": "prod-eu-unused.crt", "status": "Valid"},
+		{"certkey": "ns-server-certificate", "cert": "ns-server.cert", "key": "ns-server.key", "status": "Valid"},
+	}
+	for _, certkey := range certkeys {
+		if err := api.Store.Add("sslcertkey", certkey); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	for resourceType, res := range map[string]map[string]any{
+		"sslprofile": {"name": "prod-profile", "dhfile": "/nsconfig/ssl/prod-dh.pem"},
+		"sslcrl":     {"crlname": "prod-crl", "crlpath": "/nsconfig/ssl/prod-ca.crl"},
+	} {
+		if err := api.Store.Add(resourceType, res); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	for _, binding := range []map[string]any{
+		{"vservername": "vs-live", "certkeyname": "prod-live.com"},
+		{"vservername": "vs-old", "certkeyname": "prod-old.com"},
+	} {
+		_ = api.Store.Bind("sslvserver_sslcertkey_binding", binding)
+	}
+	for _, file := range []string{
+		"prod-live.crt", "prod-live.key", "prod-live.csr", "prod-ca.crt", "prod-unused.crt", "prod-stale.crt",
+		"prod-pending.key", "prod-pending.csr", "staging-stale.crt", "prod-eu-stale.crt", "ns-server.cert", "ns-server.key",
+		"prod-dh.pem", "prod-ca.crl",
+	} {
+		if err := api.Store.WriteFile(file, []byte("test")); err != nil {
+			t.Fatalf("Store.WriteFile() error = %v", err)
+		}
+	}
+}
+
+func TestClient_PruneCandidates(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	seedPrune(t, api)
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	others := []string{"prod-", "prod-eu-", "pro", "staging-"}
+	got, err := client.PruneCandidates(Pruning{Protected: []string{"prod-keep*"}, Others: others, Now: now})
+	if err != nil {
+		t.Fatalf("PruneCandidates() error = %v", err)
+	}
+	want := []PruneCandidate{
+		{Type: "sslcertkey", Name: "prod-old.com", Reason: PruneExpired, Bindings: []Binding{{Type: "sslvserver", Name: "vs-old"}}},
+		{Type: "sslcertkey", Name: "prod-past.com", Reason: PruneExpired, Bindings: []Binding{}},
+		{Type: "sslcertkey", Name: "prod-unused.com", Reason: PruneUnbound},
+		{Type: "systemfile", Name: "prod-live.csr", Reason: PruneOrphaned},
+		{Type: "systemfile", Name: "prod-stale.crt", Reason: PruneOrphaned},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PruneCandidates() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	deleted, err := client.Prune(got)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(deleted) != 4 {
+		t.Errorf("Prune() deleted %+v, want all but the bound certkey", deleted)
+	}
+	if _, err := api.Store.Get("sslcertkey", "prod-old.com"); err != nil {
+		t.Error("Prune() should keep bound certkeys")
+	}
+	if _, err := api.Store.Get("sslcertkey", "prod-unused.com"); err == nil {
+		t.Error("Prune() should delete unbound certkeys")
+	}
+	if _, err := api.Store.ReadFile("prod-stale.crt"); err == nil {
+		t.Error("Prune() should delete orphaned files")
+	}
+	if _, err := api.Store.ReadFile("prod-pending.key"); err != nil {
+		t.Error("Prune() should keep the keys of pending CSRs")
+	}
+
+	// The files of the deleted certkeys are orphaned now
+	again, err := client.PruneCandidates(Pruning{Protected: []string{"prod-keep*"}, Others: others, Now: now})
+	if err != nil {
+		t.Fatalf("PruneCandidates() again error = %v", err)
+	}
+	var names []string
+	for _, candidate := range again {
+		names = append(names, candidate.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"prod-old.com", "prod-unused.crt"}) {
+		t.Errorf("PruneCandidates() after Prune() = %v", names)
+	}
+}
+
+func TestClient_PruneCandidates_All(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	seedPrune(t, api)
+	client, err := NewClientFromNitro("", api)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	got, err := client.PruneCandidates(Pruning{Others: []string{"staging-"}, All: true})
+	if err != nil {
+		t.Fatalf("PruneCandidates() error = %v", err)
+	}
+	names := make(map[string]bool)
+	for _, candidate := range got {
+		names[candidate.Name] = true
+	}
+	for name, want := range map[string]bool{
+		"prod-unused.com":       true,
+		"prod-eu-unused.com":    true,
+		"staging-unused.com":    false,
+		"ns-server-certificate": false,
+		"prod-dh.pem":           false,
+	} {
+		if names[name] != want {
+			t.Errorf("PruneCandidates() lists %s = %v, want %v", name, names[name], want)
+		}
+	}
+}
+
+func TestClient_PruneCandidates_Replaced(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	for _, certkey := range []string{"prod-rot.com", "prod-rot.com-20260901000000", "prod-rot.com-20260930120000"} {
+		_ = api.Store.Add("sslcertkey", map[string]any{"certkey": certkey, "status": "Valid"})
+	}
+	_ = api.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-rot", "certkeyname": "prod-rot.com-20260930120000"})
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	got, err := client.PruneCandidates(Pruning{Grace: 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("PruneCandidates() error = %v", err)
+	}
+	want := []PruneCandidate{{Type: "sslcertkey", Name: "prod-rot.com", Reason: PruneReplaced}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PruneCandidates() =\n%+v\nwant the version replaced before the grace period only\n%+v", got, want)
+	}
+
+	got, err = client.PruneCandidates(Pruning{Grace: time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("PruneCandidates() error = %v", err)
+	}
+	if len(got) != 2 || got[1].Name != "prod-rot.com-20260901000000" || got[1].Reason != PruneReplaced {
+		t.Errorf("PruneCandidates() = %+v, want both replaced versions after the grace period", got)
+	}
+}
+
+func TestClient_PruneCandidates_Refuses(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		seed        bool
+		failType    string
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "empty prefix",
+			prefix:      "",
+			seed:        true,
+			wantErr:     "without prefix",
+			description: "an environment without prefix would prune every certkey of the appliance",
+		},
+		{
+			name:        "failed certkey list",
+			prefix:      "prod-",
+			seed:        true,
+			failType:    "sslcertkey",
+			wantErr:     "failed to retrieve certificates",
+			description: "a failed read of the certkeys should not orphan their files",
+		},
+		{
+			name:        "failed file reference",
+			prefix:      "prod-",
+			seed:        true,
+			failType:    "sslprofile",
+			wantErr:     "failed to retrieve sslprofile",
+			description: "a failed read of the profiles should not orphan their DH parameter files",
+		},
+		{
+			name:        "no certkeys",
+			prefix:      "prod-",
+			wantErr:     "refusing to treat them as orphaned",
+			description: "files without any certkey on the appliance point to a broken certkey list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := netscalertest.NewNitroClient()
+			if tt.seed {
+				seedPrune(t, api)
+			} else if err := api.Store.WriteFile("prod-live.crt", []byte("test")); err != nil {
+				t.Fatalf("Store.WriteFile() error = %v", err)
+			}
+			client, err := NewClientFromNitro(tt.prefix, api)
+			if err != nil {
+				t.Fatalf("NewClientFromNitro() error = %v", err)
+			}
+			if tt.failType != "" {
+				api.FailNextCall("FindResourceArrayWithParams", tt.failType, errors.New("session expired"))
+			}
+
+			got, err := client.PruneCandidates(Pruning{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PruneCandidates() = %v, %v, want error %q", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsProtected(t *testing.T) {
+	tests := []struct {
+		name        string
+		protected   []string
+		target      string
+		want        bool
+		description string
+	}{
+		{
+			name:        "appliance certificate",
+			target:      "ns-server-certificate",
+			want:        true,
+			description: "the certificates of the appliance should always be protected",
+		},
+		{
+			name:        "glob",
+			protected:   []string{"prod-legacy-*"},
+			target:      "prod-legacy-example.com",
+			want:        true,
+			description: "globs should protect matching names",
+		},
+		{
+			name:        "regular expression",
+			protected:   []string{`/\.pfx$/`},
+			target:      "prod-export.pfx",
+			want:        true,
+			description: "regular expressions should protect matching names",
+		},
+		{
+			name:        "unprotected",
+			protected:   []string{"prod-legacy-*"},
+			target:      "prod-example.com",
+			description: "other names should not be protected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isProtected(tt.protected, tt.target); got != tt.want {
+				t.Errorf("isProtected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package netscaler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// Redacted replaces secrets in recorded fixtures
+const Redacted = "REDACTED"
+
+// redactedFields are attributes holding credentials, session tokens or key material
+var redactedFields = map[string]bool{
+	"password":    true,
+	"passplain":   true,
+	"passcrypt":   true,
+	"passphrase":  true,
+	"sessionid":   true,
+	"token":       true,
+	"filecontent": true,
+}
+
+// Interaction is a recorded NITRO API call
+type Interaction struct {
+	Method       string `json:"method"`
+	ResourceType string `json:"resourceType,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Request      any    `json:"request,omitempty"`
+	Response     any    `json:"response,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Fixture is a recorded sequence of NITRO API calls of one appliance
+type Fixture struct {
+	Firmware   string    `json:"firmware,omitempty"`
+	RecordedAt time.Time `json:"recordedAt"`
+	// Note describes fixtures that were not recorded, see testdata/fixtures/synthetic
+	Note         string        `json:"note,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadFixture reads a recorded fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Recorder is a NitroClientInterface that records all calls to the wrapped client with secrets redacted
+type Recorder struct {
+	api  NitroClientInterface
+	path string
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder wraps api and records its calls, Save writes them to path
+func NewRecorder(api NitroClientInterface, path string) *Recorder {
+	return &Recorder{
+		api:     api,
+		path:    path,
+		fixture: Fixture{RecordedAt: time.Now().UTC()},
+	}
+}
+
+// Login logs in and records the call
+func (r *Recorder) Login() error {
+	err := r.api.Login()
+	r.record(Interaction{Method: "Login"}, nil, err)
+	return err
+}
+
+// Logout logs out and records the call
+func (r *Recorder) Logout() error {
+	err := r.api.Logout()
+	r.record(Interaction{Method: "Logout"}, nil, err)
+	return err
+}
+
+// IsLoggedIn reports whether the wrapped client holds a session, see session
+func (r *Recorder) IsLoggedIn() bool {
+	reporter, ok := r.api.(loginReporter)
+	return !ok || reporter.IsLoggedIn()
+}
+
+// FindAllResources queries the wrapped client and records the response
+func (r *Recorder) FindAllResources(resourceType string) ([]map[string]any, error) {
+	res, err := r.api.FindAllResources(resourceType)
+	r.record(Interaction{Method: "FindAllResources", ResourceType: resourceType}, res, err)
+	return res, err
+}
+
+// FindResource queries the wrapped client and records the response
+func (r *Recorder) FindResource(resourceType string, name string) (map[string]any, error) {
+	res, err := r.api.FindResource(resourceType, name)
+	r.record(Interaction{Method: "FindResource", ResourceType: resourceType, Name: name}, res, err)
+	if err == nil && resourceType == service.Nsversion.Type() {
+		r.mu.Lock()
+		r.fixture.Firmware, _ = res["version"].(string)
+		r.mu.Unlock()
+	}
+	return res, err
+}
+
+// FindResourceArrayWithParams queries the wrapped client and records the response
+func (r *Recorder) FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error) {
+	res, err := r.api.FindResourceArrayWithParams(findParams)
+	r.record(Interaction{Method: "FindResourceArrayWithParams", ResourceType: findParams.ResourceType, Name: paramsName(findParams)}, res, err)
+	return res, err
+}
+
+// AddResource creates the resource and records the request
+func (r *Recorder) AddResource(resourceType string, name string, resourceStruct any) (string, error) {
+	res, err := r.api.AddResource(resourceType, name, resourceStruct)
+	r.record(Interaction{Method: "AddResource", ResourceType: resourceType, Name: name, Request: redact(resourceStruct)}, nil, err)
+	return res, err
+}
+
+// UpdateResource updates the resource and records the request
+func (r *Recorder) UpdateResource(resourceType string, name string, resourceStruct any) (string, error) {
+	res, err := r.api.UpdateResource(resourceType, name, resourceStruct)
+	r.record(Interaction{Method: "UpdateResource", ResourceType: resourceType, Name: name, Request: redact(resourceStruct)}, nil, err)
+	return res, err
+}
+
+// ActOnResource applies the action and records the request, the action is recorded as name
+func (r *Recorder) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	err := r.api.ActOnResource(resourceType, resourceStruct, action)
+	r.record(Interaction{Method: "ActOnResource", ResourceType: resourceType, Name: action, Request: redact(resourceStruct)}, nil, err)
+	return err
+}
+
+// DeleteResource deletes the resource and records the call
+func (r *Recorder) DeleteResource(resourceType string, name string) error {
+	err := r.api.DeleteResource(resourceType, name)
+	r.record(Interaction{Method: "DeleteResource", ResourceType: resourceType, Name: name}, nil, err)
+	return err
+}
+
+// DeleteResourceWithArgsMap deletes the resource or binding and records the arguments
+func (r *Recorder) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	err := r.api.DeleteResourceWithArgsMap(resourceType, name, args)
+	r.record(Interaction{Method: "DeleteResourceWithArgsMap", ResourceType: resourceType, Name: name, Request: args}, nil, err)
+	return err
+}
+
+// Fixture returns the calls recorded so far
+func (r *Recorder) Fixture() Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.fixture
+	f.Interactions = append([]Interaction(nil), r.fixture.Interactions...)
+	return f
+}
+
+// Save writes the recorded calls to the fixture file
+func (r *Recorder) Save() error {
+	data, err := json.MarshalIndent(r.Fixture(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) record(i Interaction, res any, err error) {
+	if err != nil {
+		i.Error = redactError(err)
+	} else if res != nil {
+		i.Response = redact(res)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Interactions = append(r.fixture.Interactions, i)
+}
+
+var (
+	// nitroResponseBody matches the response body the NITRO client includes in its errors
+	nitroResponseBody = regexp.MustCompile(`(?s)\{.*\}`)
+	nitroErrorCode    = regexp.MustCompile(`"errorcode"\s*:\s*(\d+)`)
+	nitroErrorMessage = regexp.MustCompile(`"message"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// redactError returns the error of a recorded call with the NITRO response body in it reduced to
+// the errorcode and message, the rest of the body may echo attributes of the request
+func redactError(err error) string {
+	msg := err.Error()
+	body := nitroResponseBody.FindStringIndex(msg)
+	if body == nil {
+		return msg
+	}
+
+	text := msg[body[0]:body[1]]
+	summary := Redacted
+	if code := nitroErrorCode.FindStringSubmatch(text); code != nil {
+		summary = "errorcode " + code[1]
+		if message := nitroErrorMessage.FindStringSubmatch(text); message != nil {
+			summary += ": " + message[1]
+		}
+	}
+	return msg[:body[0]] + summary + msg[body[1]:]
+}
+
+// redact returns a copy of a NITRO response with secrets replaced
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, val := range v {
+			if redactedFields[k] {
+				c[k] = Redacted
+			} else {
+				c[k] = redact(val)
+			}
+		}
+		return c
+	case []map[string]any:
+		c := make([]any, 0, len(v))
+		for _, m := range v {
+			c = append(c, redact(m))
+		}
+		return c
+	case []any:
+		c := make([]any, 0, len(v))
+		for _, val := range v {
+			c = append(c, redact(val))
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// Replayer is a NitroClientInterface answering calls from a recorded fixture.
+// Calls are matched by method, resource type and name; repeated calls are answered in recorded order.
+type Replayer struct {
+	mu      sync.Mutex
+	pending map[string][]Interaction
+}
+
+// NewReplayer returns a replayer for the fixture
+func NewReplayer(f *Fixture) *Replayer {
+	r := &Replayer{pending: make(map[string][]Interaction)}
+	for _, i := range f.Interactions {
+		key := interactionKey(i.Method, i.ResourceType, i.Name)
+		r.pending[key] = append(r.pending[key], i)
+	}
+	return r
+}
+
+// Login replays a recorded login
+func (r *Replayer) Login() error {
+	_, err := r.replay("Login", "", "")
+	return err
+}
+
+// Logout replays a recorded logout
+func (r *Replayer) Logout() error {
+	_, err := r.replay("Logout", "", "")
+	return err
+}
+
+// FindAllResources replays a recorded response
+func (r *Replayer) FindAllResources(resourceType string) ([]map[string]any, error) {
+	res, err := r.replay("FindAllResources", resourceType, "")
+	if err != nil {
+		return nil, err
+	}
+	return recordedList(resourceType, res)
+}
+
+// FindResourceArrayWithParams replays a recorded response
+func (r *Replayer) FindResourceArrayWithParams(findParams service.FindParams) ([]map[string]any, error) {
+	res, err := r.replay("FindResourceArrayWithParams", findParams.ResourceType, paramsName(findParams))
+	if err != nil {
+		return nil, err
+	}
+	return recordedList(findParams.ResourceType, res)
+}
+
+// AddResource replays the result of a recorded create
+func (r *Replayer) AddResource(resourceType string, name string, _ any) (string, error) {
+	_, err := r.replay("AddResource", resourceType, name)
+	return name, err
+}
+
+// UpdateResource replays the result of a recorded update
+func (r *Replayer) UpdateResource(resourceType string, name string, _ any) (string, error) {
+	_, err := r.replay("UpdateResource", resourceType, name)
+	return name, err
+}
+
+// ActOnResource replays the result of a recorded action
+func (r *Replayer) ActOnResource(resourceType string, _ any, action string) error {
+	_, err := r.replay("ActOnResource", resourceType, action)
+	return err
+}
+
+// DeleteResource replays the result of a recorded delete
+func (r *Replayer) DeleteResource(resourceType string, name string) error {
+	_, err := r.replay("DeleteResource", resourceType, name)
+	return err
+}
+
+// DeleteResourceWithArgsMap replays the result of a recorded delete
+func (r *Replayer) DeleteResourceWithArgsMap(resourceType string, name string, _ map[string]string) error {
+	_, err := r.replay("DeleteResourceWithArgsMap", resourceType, name)
+	return err
+}
+
+// recordedList converts a recorded list response
+func recordedList(resourceType string, res any) ([]map[string]any, error) {
+	list, _ := res.([]any)
+	all := make([]map[string]any, 0, len(list))
+	for _, v := range list {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("recorded %s resource is not an object: %v", resourceType, v)
+		}
+		all = append(all, m)
+	}
+	return all, nil
+}
+
+// FindResource replays a recorded response
+func (r *Replayer) FindResource(resourceType string, name string) (map[string]any, error) {
+	res, err := r.replay("FindResource", resourceType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := res.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("recorded %s resource %s is not an object: %v", resourceType, name, res)
+	}
+	return m, nil
+}
+
+// replay returns the next recorded response of a call. The last recorded interaction is
+// repeated once all others were used, so clients may poll.
+func (r *Replayer) replay(method, resourceType, name string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := interactionKey(method, resourceType, name)
+	queue := r.pending[key]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s", key)
+	}
+	i := queue[0]
+	if len(queue) > 1 {
+		r.pending[key] = queue[1:]
+	}
+
+	if i.Error != "" {
+		return nil, errors.New(i.Error)
+	}
+	return i.Response, nil
+}
+
+// paramsName identifies a parameterized query by resource name or its sorted arguments and filters
+func paramsName(findParams service.FindParams) string {
+	if findParams.ResourceName != "" {
+		return findParams.ResourceName
+	}
+	args := make([]string, 0, len(findParams.ArgsMap)+len(findParams.FilterMap))
+	for k, v := range findParams.ArgsMap {
+		args = append(args, k+":"+v)
+	}
+	for k, v := range findParams.FilterMap {
+		args = append(args, "filter "+k+":"+v)
+	}
+	sort.Strings(args)
+	return strings.Join(args, ",")
+}
+
+func interactionKey(method, resourceType, name string) string {
+	return fmt.Sprintf("%s(%s, %s)", method, resourceType, name)
+}
+
+var (
+	_ NitroClientInterface = (*Recorder)(nil)
+	_ NitroClientInterface = (*Replayer)(nil)
+)
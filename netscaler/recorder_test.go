@@ -0,0 +1,309 @@
+package netscaler
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestRecorder(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	_ = api.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com", "passcrypt": "secret"})
+	_ = api.Store.Add("systemfile", map[string]any{"filename": "prod-example.com.key", "filecontent": "LS0tLS1CRUdJTi..."})
+
+	path := filepath.Join(t.TempDir(), "fixtures", "recorded.json")
+	recorder := NewRecorder(api, path)
+
+	client, err := NewClientFromNitro("prod-", recorder)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+	if err := client.Health(); err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if _, err := client.GetAllCertificates(); err != nil {
+		t.Fatalf("GetAllCertificates() error = %v", err)
+	}
+	if _, err := client.GetCertificate("missing.example.com"); err == nil {
+		t.Fatal("GetCertificate() of a missing certificate should fail")
+	}
+	_, _ = recorder.FindAllResources("systemfile")
+	if err := recorder.Logout(); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("LoadFixture() error = %v", err)
+	}
+	if fixture.Firmware != netscalertest.DefaultVersion {
+		t.Errorf("Firmware = %q, want %q", fixture.Firmware, netscalertest.DefaultVersion)
+	}
+	if len(fixture.Interactions) != 7 {
+		t.Fatalf("recorded %d interactions, want 7", len(fixture.Interactions))
+	}
+
+	certs := fixture.Interactions[2].Response.([]any)
+	if got := certs[0].(map[string]any)["passcrypt"]; got != Redacted {
+		t.Errorf("passcrypt = %v, want it redacted", got)
+	}
+	files := fixture.Interactions[5].Response.([]any)
+	if got := files[0].(map[string]any)["filecontent"]; got != Redacted {
+		t.Errorf("filecontent = %v, want it redacted", got)
+	}
+	if fixture.Interactions[3].Error == "" {
+		t.Error("failed calls should be recorded with their error")
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         string
+		want        string
+		description string
+	}{
+		{
+			name:        "response body",
+			err:         `[ERROR] nitro-go: Failed to create resource of type sslcertkey, name=prod-a, err=failed: 400 Bad Request ({ "errorcode": 1097, "message": "Invalid argument \"key\"", "severity": "ERROR", "passplain": "secret" })`,
+			want:        `[ERROR] nitro-go: Failed to create resource of type sslcertkey, name=prod-a, err=failed: 400 Bad Request (errorcode 1097: Invalid argument \"key\")`,
+			description: "only the errorcode and message of the body should be recorded",
+		},
+		{
+			name:        "session expired",
+			err:         `failed: 401 Unauthorized ({ "errorcode": 444, "message": "Session expired or killed. Please login again", "severity": "ERROR" })`,
+			want:        `failed: 401 Unauthorized (errorcode 444: Session expired or killed. Please login again)`,
+			description: "replayed errors should still be recognized as expired sessions",
+		},
+		{
+			name:        "body without errorcode",
+			err:         `failed: 502 Bad Gateway ({"passphrase": "secret"})`,
+			want:        `failed: 502 Bad Gateway (REDACTED)`,
+			description: "other bodies should be replaced",
+		},
+		{
+			name:        "no body",
+			err:         "connection refused",
+			want:        "connection refused",
+			description: "errors without a body should be kept",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactError(errors.New(tt.err))
+			if got != tt.want {
+				t.Errorf("redactError() = %s, want %s", got, tt.want)
+			}
+			if strings.Contains(got, "secret") {
+				t.Errorf("redactError() = %s contains the secret", got)
+			}
+		})
+	}
+	if !sessionExpired.MatchString(redactError(errors.New(tests[1].err))) {
+		t.Error("sessionExpired should match the redacted error")
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	replayer := NewReplayer(&Fixture{Interactions: []Interaction{
+		{Method: "Login"},
+		{Method: "FindResource", ResourceType: "sslcertkey", Name: "prod-example.com", Response: map[string]any{"certkey": "prod-example.com", "status": "Valid"}},
+		{Method: "FindResource", ResourceType: "sslcertkey", Name: "prod-example.com", Response: map[string]any{"certkey": "prod-example.com", "status": "Expired"}},
+		{Method: "FindResource", ResourceType: "sslcertkey", Name: "prod-missing.example.com", Error: "No such resource"},
+		{Method: "FindResourceArrayWithParams", ResourceType: "sslcertkey", Name: "filter certkey:%2F%5Eprod-missing%5C.example%5C.com%2F"},
+	}})
+
+	client, err := NewClientFromNitro("prod-", replayer)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	for _, want := range []string{"Valid", "Expired", "Expired"} {
+		cert, err := client.GetCertificate("example.com")
+		if err != nil {
+			t.Fatalf("GetCertificate() error = %v", err)
+		}
+		if cert["status"] != want {
+			t.Errorf("GetCertificate() status = %v, want %s", cert["status"], want)
+		}
+	}
+
+	if _, err := client.GetCertificate("missing.example.com"); err == nil || err.Error() != "No such resource" {
+		t.Errorf("GetCertificate() error = %v, want the recorded error", err)
+	}
+	if _, err := client.GetAllCertificates(); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("GetAllCertificates() error = %v, want an unrecorded call error", err)
+	}
+}
+
+func TestRecorder_Writes(t *testing.T) {
+	api := netscalertest.NewNitroClient()
+	recorder := NewRecorder(api, filepath.Join(t.TempDir(), "recorded.json"))
+	client, err := NewClientFromNitro("prod-", recorder)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro() error = %v", err)
+	}
+
+	if err := client.UploadFile("prod-example.com.crt", []byte("certificate")); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if _, err := client.DownloadFile("prod-example.com.crt"); err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+	_ = client.CreateKey("prod-example.com.key", KeySpec{Type: "ec", Curve: "P-256"})
+
+	f := recorder.Fixture()
+	replayer := NewReplayer(&f)
+	replayed, err := NewClientFromNitro("prod-", replayer)
+	if err != nil {
+		t.Fatalf("NewClientFromNitro(replayer) error = %v", err)
+	}
+
+	for _, i := range f.Interactions {
+		if req, ok := i.Request.(map[string]any); ok && req["filecontent"] != nil && req["filecontent"] != Redacted {
+			t.Errorf("recorded request %s(%s) contains file content", i.Method, i.ResourceType)
+		}
+	}
+	if err := replayed.UploadFile("prod-example.com.crt", []byte("certificate")); err != nil {
+		t.Errorf("replayed UploadFile() error = %v", err)
+	}
+	if err := replayed.CreateKey("prod-example.com.key", KeySpec{Type: "ec", Curve: "P-256"}); err != nil {
+		t.Errorf("replayed CreateKey() error = %v", err)
+	}
+	if content, _ := replayed.DownloadFile("prod-example.com.crt"); string(content) == "certificate" {
+		t.Error("replayed DownloadFile() should not return the recorded file content")
+	}
+}
+
+// TestFirmwareFixtures checks that the client handles the payloads of every recorded firmware version
+// and of the hand-written fixtures in testdata/fixtures/synthetic
+func TestFirmwareFixtures(t *testing.T) {
+	paths, err := filepath.Glob("testdata/fixtures/*/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, path := range paths {
+		dir := filepath.Base(filepath.Dir(path))
+		t.Run(dir+"/"+filepath.Base(path), func(t *testing.T) {
+			fixture, err := LoadFixture(path)
+			if err != nil {
+				t.Fatalf("LoadFixture() error = %v", err)
+			}
+			// Only recordings of a real appliance belong in a firmware directory
+			if dir == "synthetic" && fixture.Note == "" {
+				t.Error("synthetic fixture should have a note on how it was made")
+			}
+			if dir != "synthetic" && (fixture.Firmware == "" || fixture.RecordedAt.IsZero()) {
+				t.Error("firmware fixture should be recorded, put hand-written fixtures in testdata/fixtures/synthetic")
+			}
+
+			client, err := NewClientFromNitro("prod-", NewReplayer(fixture))
+			if err != nil {
+				t.Fatalf("NewClientFromNitro() error = %v", err)
+			}
+			if err := client.Health(); err != nil {
+				t.Fatalf("Health() error = %v", err)
+			}
+
+			all, err := client.GetAllCertificates()
+			if err != nil {
+				t.Fatalf("GetAllCertificates() error = %v", err)
+			}
+			if len(all) == 0 {
+				t.Fatal("GetAllCertificates() returned no certificates")
+			}
+
+			for _, raw := range all {
+				cert, err := ParseCertificate(raw)
+				if err != nil {
+					t.Fatalf("ParseCertificate() error = %v", err)
+				}
+				if cert.NotAfter.IsZero() || cert.KeySize == 0 || len(cert.SANs) == 0 {
+					t.Errorf("ParseCertificate() = %+v, want expiry, key size and SANs", cert)
+				}
+
+				name := strings.TrimPrefix(cert.Certkey, "prod-")
+				if _, err := client.GetCertificate(name); err != nil {
+					t.Errorf("GetCertificate(%s) error = %v", name, err)
+				}
+				if _, err := client.GetCertificateBindings(cert.Certkey); err != nil {
+					t.Errorf("GetCertificateBindings(%s) error = %v", cert.Certkey, err)
+				}
+			}
+
+			if _, err := client.GetCertificate("missing.example.com"); err == nil {
+				t.Error("GetCertificate() of a missing certificate should fail")
+			}
+			if err := client.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := redact(map[string]any{
+		"certkey":  "prod-example.com",
+		"password": "secret",
+		"nested":   []any{map[string]any{"sessionid": "abc"}},
+	}).(map[string]any)
+
+	if got["certkey"] != "prod-example.com" || got["password"] != Redacted {
+		t.Errorf("redact() = %v", got)
+	}
+	if nested := got["nested"].([]any)[0].(map[string]any); nested["sessionid"] != Redacted {
+		t.Errorf("redact() nested = %v", nested)
+	}
+
+	var err error = errors.New("unchanged")
+	if redact(err) != err {
+		t.Error("redact() should return other values unchanged")
+	}
+}
+
+func TestNewClient_Record(t *testing.T) {
+	srv := netscalertest.NewServer()
+	defer srv.Close()
+	_ = srv.Store.Add("sslcertkey", map[string]any{"certkey": "prod-example.com"})
+
+	path := filepath.Join(t.TempDir(), "recorded.json")
+	client, err := NewClient("prod-", &ClientConfig{
+		Endpoint: srv.URL,
+		Username: srv.Username,
+		Password: srv.Password,
+		Record:   path,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := client.GetCertificate("example.com"); err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("LoadFixture() error = %v", err)
+	}
+	var methods []string
+	for _, i := range fixture.Interactions {
+		methods = append(methods, i.Method)
+	}
+	// FindResourceArrayWithParams is the clusternode query of the cluster detection
+	if got := strings.Join(methods, ","); got != "Login,FindResourceArrayWithParams,FindResource,Logout" {
+		t.Errorf("recorded %s, want Login,FindResourceArrayWithParams,FindResource,Logout", got)
+	}
+}
@@ -0,0 +1,378 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// versionLayout is the timestamp suffix of the certkeys created by RotateCertificate
+const versionLayout = "20060102150405"
+
+// versionSuffixPattern matches the version suffix of a rotated certkey
+var versionSuffixPattern = regexp.MustCompile(`^-\d{14}$`)
+
+// certBindingResources maps the bound resource type to its binding resource and the attribute holding its name
+var certBindingResources = map[string]struct {
+	bindingType string
+	ownerKey    string
+}{
+	"sslvserver":   {"sslvserver_sslcertkey_binding", "vservername"},
+	"service":      {"sslservice_sslcertkey_binding", "servicename"},
+	"servicegroup": {"sslservicegroup_sslcertkey_binding", "servicegroupname"},
+}
+
+// Rotation configures RotateCertificate
+type Rotation struct {
+	// Grace is how long a replaced version is kept before it is retired
+	Grace time.Duration
+	// Now names the new version, the current time if zero
+	Now time.Time
+}
+
+// VersionedCertkey returns the name of the certkey version of a domain created at t
+func (c *Client) VersionedCertkey(name string, t time.Time) string {
+	return c.prefix + name + "-" + t.UTC().Format(versionLayout)
+}
+
+// CertkeyDomain returns the domain of a certkey without the environment prefix and version suffix,
+// false if the certkey doesn't have the prefix
+func CertkeyDomain(prefix, certkey string) (string, bool) {
+	name, ok := strings.CutPrefix(certkey, prefix)
+	if !ok {
+		return "", false
+	}
+	if i := len(name) - len(versionLayout) - 1; i > 0 && versionSuffixPattern.MatchString(name[i:]) {
+		name = name[:i]
+	}
+	return name, true
+}
+
+// certkeyVersions returns the certkeys of a domain oldest first: the unversioned certkey followed
+// by the versions created by RotateCertificate
+func (c *Client) certkeyVersions(name string) ([]map[string]any, error) {
+	base := c.prefix + name
+	res, err := c.api.FindResourceArrayWithParams(service.FindParams{
+		ResourceType: service.Sslcertkey.Type(),
+		FilterMap:    map[string]string{"certkey": url.QueryEscape("/^" + regexp.QuoteMeta(base) + "/")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve versions of %s: %w", base, err)
+	}
+
+	var versions []map[string]any
+	for _, cert := range res {
+		certkey := stringField(cert, "certkey")
+		if suffix, ok := strings.CutPrefix(certkey, base); ok && (suffix == "" || versionSuffixPattern.MatchString(suffix)) {
+			versions = append(versions, cert)
+		}
+	}
+	// The fixed width timestamps sort chronologically, the unversioned certkey sorts first
+	sort.Slice(versions, func(i, j int) bool {
+		return stringField(versions[i], "certkey") < stringField(versions[j], "certkey")
+	})
+	return versions, nil
+}
+
+// versionTime returns when a certkey version was created, the zero time for unversioned certkeys
+func versionTime(certkey string) time.Time {
+	if len(certkey) <= len(versionLayout) {
+		return time.Time{}
+	}
+	t, err := time.Parse(versionLayout, certkey[len(certkey)-len(versionLayout):])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// replacedVersions returns when each of the certkeys was replaced by the next version of its domain.
+// The newest certkey of a domain and certkeys without the prefix have no entry.
+func (c *Client) replacedVersions(certkeys []string) map[string]time.Time {
+	byDomain := make(map[string][]string)
+	for _, certkey := range certkeys {
+		if domain, ok := CertkeyDomain(c.prefix, certkey); ok {
+			byDomain[domain] = append(byDomain[domain], certkey)
+		}
+	}
+
+	replaced := make(map[string]time.Time)
+	for _, versions := range byDomain {
+		// The fixed width timestamps sort chronologically, the unversioned certkey sorts first
+		sort.Strings(versions)
+		for i := 0; i < len(versions)-1; i++ {
+			replaced[versions[i]] = versionTime(versions[i+1])
+		}
+	}
+	return replaced
+}
+
+// RotateCertificate installs a certificate as a new certkey version of the domain and moves all
+// bindings of the current version to it, SNI bindings included. The bindings are verified
+// afterwards; on any failure the previous bindings are restored and the new version is removed.
+// Versions replaced longer than the grace period ago are retired.
+func (c *Client) RotateCertificate(name string, pemData []byte, keyfile string, rotation Rotation) (*Deployment, error) {
+	certs, err := parsePEMCertificates(pemData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.requireFile(keyfile); err != nil {
+		return nil, err
+	}
+
+	now := rotation.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	versions, err := c.certkeyVersions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deployment{
+		Certkey: c.VersionedCertkey(name, now),
+		KeyFile: keyfile,
+	}
+	d.CertFile = certFileName(d.Certkey, certs[0])
+	if len(versions) > 0 {
+		d.Previous = stringField(versions[len(versions)-1], "certkey")
+		if d.Previous == d.Certkey {
+			return nil, fmt.Errorf("certkey %s already exists", d.Certkey)
+		}
+	}
+
+	if _, err := c.installCertkey(d.Certkey, d.CertFile, certs[0], keyfile); err != nil {
+		return nil, err
+	}
+	if len(certs) > 1 {
+		if d.ChainCertkey, err = c.installChain(d.Certkey, certs[1]); err != nil {
+			return nil, c.discardCertkey(d.Certkey, err)
+		}
+	}
+
+	if d.Previous != "" {
+		bindings, err := c.certkeyBindings(d.Previous)
+		if err != nil {
+			return nil, c.discardCertkey(d.Certkey, err)
+		}
+		if err := c.rebind(d.Previous, d.Certkey, bindings); err != nil {
+			return nil, c.discardCertkey(d.Certkey, err)
+		}
+		d.Rebound = bindings
+	}
+
+	if d.Retired, err = c.RetireVersions(name, rotation.Grace, now); err != nil {
+		return d, fmt.Errorf("rotated %s to %s: %w", name, d.Certkey, err)
+	}
+	return d, nil
+}
+
+// RetireVersions deletes the versions of a domain that were replaced longer than grace before now
+// and are not bound anymore. The newest version is never retired.
+func (c *Client) RetireVersions(name string, grace time.Duration, now time.Time) ([]string, error) {
+	versions, err := c.certkeyVersions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var retired []string
+	for i := 0; i < len(versions)-1; i++ {
+		certkey := stringField(versions[i], "certkey")
+		replaced := versionTime(stringField(versions[i+1], "certkey"))
+		if now.Sub(replaced) < grace {
+			continue
+		}
+		bindings, err := c.GetCertificateBindings(certkey)
+		if err != nil {
+			return retired, err
+		}
+		if len(bindings) > 0 {
+			continue
+		}
+		if err := c.api.DeleteResource(service.Sslcertkey.Type(), certkey); err != nil {
+			return retired, fmt.Errorf("failed to retire %s: %w", certkey, err)
+		}
+		retired = append(retired, certkey)
+	}
+	return retired, nil
+}
+
+// certkeyBindings returns the bindings of a certkey, with the SNI flag of vserver bindings
+func (c *Client) certkeyBindings(certkey string) ([]Binding, error) {
+	bindings, err := c.GetCertificateBindings(certkey)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range bindings {
+		if b.Type != "sslvserver" {
+			continue
+		}
+		res, err := c.api.FindResourceArrayWithParams(service.FindParams{
+			ResourceType: certBindingResources[b.Type].bindingType,
+			ResourceName: b.Name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve certificates of %s: %w", b.Name, err)
+		}
+		for _, entry := range res {
+			if stringField(entry, "certkeyname") == certkey && fmt.Sprint(entry["snicert"]) == "true" {
+				bindings[i].SNI = true
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// rebindStep is the progress of moving one binding, so it can be rolled back
+type rebindStep struct {
+	binding Binding
+	unbound bool
+	rebound bool
+}
+
+// rebind moves the bindings from one certkey to the other and verifies the result. SNI bindings
+// are added to the new certkey before they are removed, so the vserver serves the domain throughout.
+// A vserver, service or service group holds only one non-SNI certificate: those bindings are removed
+// first, and the entity has no certificate until the new one is bound. On failure the previous
+// bindings are restored.
+func (c *Client) rebind(from, to string, bindings []Binding) error {
+	steps := make([]rebindStep, len(bindings))
+	err := func() error {
+		for i, b := range bindings {
+			steps[i].binding = b
+			if b.SNI {
+				if err := c.bindCertkey(to, b); err != nil {
+					return err
+				}
+				steps[i].rebound = true
+			}
+			if err := c.unbindCertkey(from, b); err != nil {
+				return err
+			}
+			steps[i].unbound = true
+			if !b.SNI {
+				if err := c.bindCertkey(to, b); err != nil {
+					return err
+				}
+				steps[i].rebound = true
+			}
+		}
+		if c.planning {
+			// Planned bindings cannot be read back
+			return nil
+		}
+		return c.verifyRebind(from, to, bindings)
+	}()
+	if err == nil {
+		return nil
+	}
+
+	var rollbackErrs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		// SNI bindings are restored before the new certkey is removed, like they were moved
+		if step.unbound && step.binding.SNI {
+			if err := c.bindCertkey(from, step.binding); err != nil {
+				rollbackErrs = append(rollbackErrs, err)
+			}
+		}
+		if step.rebound {
+			if err := c.unbindCertkey(to, step.binding); err != nil {
+				rollbackErrs = append(rollbackErrs, err)
+			}
+		}
+		if step.unbound && !step.binding.SNI {
+			if err := c.bindCertkey(from, step.binding); err != nil {
+				rollbackErrs = append(rollbackErrs, err)
+			}
+		}
+	}
+	if len(rollbackErrs) > 0 {
+		return fmt.Errorf("failed to move bindings from %s to %s: %w, rollback failed: %w", from, to, err, errors.Join(rollbackErrs...))
+	}
+	return fmt.Errorf("failed to move bindings from %s to %s, rolled back: %w", from, to, err)
+}
+
+// verifyRebind checks that the new certkey holds exactly the moved bindings and the old one none
+func (c *Client) verifyRebind(from, to string, want []Binding) error {
+	got, err := c.certkeyBindings(to)
+	if err != nil {
+		return err
+	}
+	if !sameBindings(got, want) {
+		return fmt.Errorf("%s is bound to %v, want %v", to, got, want)
+	}
+
+	left, err := c.GetCertificateBindings(from)
+	if err != nil {
+		return err
+	}
+	if len(left) > 0 {
+		return fmt.Errorf("%s is still bound to %v", from, left)
+	}
+	return nil
+}
+
+// bindCertkey binds a certkey to a vserver, service or service group
+func (c *Client) bindCertkey(certkey string, b Binding) error {
+	res, ok := certBindingResources[b.Type]
+	if !ok {
+		return fmt.Errorf("unsupported binding type %s", b.Type)
+	}
+	attrs := map[string]any{res.ownerKey: b.Name, "certkeyname": certkey}
+	if b.SNI {
+		attrs["snicert"] = true
+	}
+	if _, err := c.api.AddResource(res.bindingType, b.Name, attrs); err != nil {
+		return fmt.Errorf("failed to bind %s to %s %s: %w", certkey, b.Type, b.Name, err)
+	}
+	return nil
+}
+
+// unbindCertkey removes the binding of a certkey from a vserver, service or service group
+func (c *Client) unbindCertkey(certkey string, b Binding) error {
+	res, ok := certBindingResources[b.Type]
+	if !ok {
+		return fmt.Errorf("unsupported binding type %s", b.Type)
+	}
+	args := map[string]string{"certkeyname": certkey}
+	if b.SNI {
+		args["snicert"] = "true"
+	}
+	if err := c.api.DeleteResourceWithArgsMap(res.bindingType, b.Name, args); err != nil {
+		return fmt.Errorf("failed to unbind %s from %s %s: %w", certkey, b.Type, b.Name, err)
+	}
+	return nil
+}
+
+// discardCertkey removes a certkey created by a failed rotation and returns the cause
+func (c *Client) discardCertkey(certkey string, cause error) error {
+	if err := c.api.DeleteResource(service.Sslcertkey.Type(), certkey); err != nil {
+		return fmt.Errorf("%w, failed to remove %s: %w", cause, certkey, err)
+	}
+	return cause
+}
+
+// sameBindings reports whether both lists hold the same bindings regardless of order
+func sameBindings(a, b []Binding) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[Binding]int, len(a))
+	for _, binding := range a {
+		counts[binding]++
+	}
+	for _, binding := range b {
+		if counts[binding] == 0 {
+			return false
+		}
+		counts[binding]--
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package netscaler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// rotationBindings are the bindings of the certkey rotated in the tests
+var rotationBindings = []Binding{
+	{Type: "service", Name: "svc-web"},
+	{Type: "sslvserver", Name: "vs-sni", SNI: true},
+	{Type: "sslvserver", Name: "vs-web"},
+}
+
+// newTestRotationClient deploys a certificate for example.com and binds it like rotationBindings
+func newTestRotationClient(t *testing.T) (*Client, *netscalertest.NitroClient, []byte) {
+	t.Helper()
+	client, api := newTestKeyClient(t)
+	keyfile := client.KeyFile("example.com")
+	if err := client.CreateKey(keyfile, KeySpec{Type: "rsa", Bits: 2048}); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	csr, err := client.CreateCSR(keyfile, client.CSRFile("example.com"), testCSRRequest)
+	if err != nil {
+		t.Fatalf("CreateCSR() error = %v", err)
+	}
+	if _, err := client.DeployCertificate("example.com", signTestCSR(t, csr, 1), keyfile); err != nil {
+		t.Fatalf("DeployCertificate() error = %v", err)
+	}
+	for _, b := range rotationBindings {
+		if err := client.bindCertkey("prod-example.com", b); err != nil {
+			t.Fatalf("bindCertkey() error = %v", err)
+		}
+	}
+	return client, api, csr
+}
+
+func TestClient_RotateCertificate(t *testing.T) {
+	client, api, csr := newTestRotationClient(t)
+	keyfile := client.KeyFile("example.com")
+	first := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	before := len(api.Calls())
+
+	ca := newTestCA(t)
+	d, err := client.RotateCertificate("example.com", ca.sign(t, csr, 2), keyfile, Rotation{Grace: time.Hour, Now: first})
+	if err != nil {
+		t.Fatalf("RotateCertificate() error = %v", err)
+	}
+	if d.Certkey != "prod-example.com-20261001120000" || d.Previous != "prod-example.com" || d.ChainCertkey != client.IntermediateCertkey(ca.cert) {
+		t.Errorf("RotateCertificate() = %+v", d)
+	}
+	if !reflect.DeepEqual(d.Rebound, rotationBindings) {
+		t.Errorf("Rebound = %v, want %v", d.Rebound, rotationBindings)
+	}
+	// The SNI binding is added before it is removed, the others are removed first
+	var moves []string
+	for _, call := range api.Calls()[before:] {
+		if strings.HasSuffix(call.ResourceType, "_sslcertkey_binding") && call.Method != "FindResourceArrayWithParams" {
+			moves = append(moves, call.Method+" "+call.Name)
+		}
+	}
+	wantMoves := []string{
+		"DeleteResourceWithArgsMap svc-web", "AddResource svc-web",
+		"AddResource vs-sni", "DeleteResourceWithArgsMap vs-sni",
+		"DeleteResourceWithArgsMap vs-web", "AddResource vs-web",
+	}
+	if !reflect.DeepEqual(moves, wantMoves) {
+		t.Errorf("binding changes = %v, want %v", moves, wantMoves)
+	}
+	if len(d.Retired) != 0 {
+		t.Errorf("Retired = %v, the previous version is within its grace period", d.Retired)
+	}
+
+	got, err := client.certkeyBindings(d.Certkey)
+	if err != nil || !reflect.DeepEqual(got, rotationBindings) {
+		t.Errorf("bindings of the new version = %v, %v, want %v", got, err, rotationBindings)
+	}
+	if old, _ := client.GetCertificateBindings("prod-example.com"); len(old) != 0 {
+		t.Errorf("bindings of the previous version = %v, want none", old)
+	}
+	res, _ := api.Store.Get("sslcertkey", d.Certkey)
+	if res["linkcertkeyname"] != d.ChainCertkey || res["key"] != keyfile {
+		t.Errorf("new version = %v", res)
+	}
+	if cert, err := client.GetCertificate("example.com"); err != nil || cert["certkey"] != "prod-example.com" {
+		t.Errorf("GetCertificate() without rotation = %v, %v, want the unversioned certkey", cert, err)
+	}
+	client.rotation = true
+	if cert, err := client.GetCertificate("example.com"); err != nil || cert["certkey"] != d.Certkey {
+		t.Errorf("GetCertificate() = %v, %v, want the newest version", cert, err)
+	}
+
+	// The next rotation retires the unversioned certkey, replaced longer than the grace period ago
+	d, err = client.RotateCertificate("example.com", signTestCSR(t, csr, 3), keyfile, Rotation{Grace: time.Hour, Now: first.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("RotateCertificate() again error = %v", err)
+	}
+	if d.Previous != "prod-example.com-20261001120000" || !reflect.DeepEqual(d.Retired, []string{"prod-example.com"}) {
+		t.Errorf("RotateCertificate() again = %+v", d)
+	}
+	if _, err := api.Store.Get("sslcertkey", "prod-example.com"); err == nil {
+		t.Error("retired certkey should be deleted")
+	}
+	client.rotation = false
+	if cert, err := client.GetCertificate("example.com"); err != nil || cert["certkey"] != d.Certkey {
+		t.Errorf("GetCertificate() without the unversioned certkey = %v, %v, want the newest version", cert, err)
+	}
+	injected := errors.New("connection reset")
+	api.FailNextCall("FindResourceArrayWithParams", "sslcertkey", injected)
+	if _, err := client.GetCertificate("example.com"); !errors.Is(err, injected) {
+		t.Errorf("GetCertificate() error = %v, want the failed versions query", err)
+	}
+
+	retired, err := client.RetireVersions("example.com", time.Hour, first.Add(4*time.Hour))
+	if err != nil || !reflect.DeepEqual(retired, []string{"prod-example.com-20261001120000"}) {
+		t.Errorf("RetireVersions() = %v, %v", retired, err)
+	}
+
+	// In-place deployments update the newest version
+	d, err = client.DeployCertificate("example.com", signTestCSR(t, csr, 4), keyfile)
+	if err != nil || d.Certkey != "prod-example.com-20261001140000" || !d.Updated {
+		t.Errorf("DeployCertificate() after rotation = %+v, %v", d, err)
+	}
+}
+
+func TestClient_RotateCertificate_Rollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		resource string
+		// generic is set for calls nitro-go reports with a generic error instead of the cause
+		generic     bool
+		description string
+	}{
+		{
+			name:        "unbind fails",
+			method:      "DeleteResourceWithArgsMap",
+			resource:    "sslservice_sslcertkey_binding",
+			description: "a failure before anything moved should only remove the new version",
+		},
+		{
+			name:        "bind fails midway",
+			method:      "AddResource",
+			resource:    "sslvserver_sslcertkey_binding",
+			description: "the bindings moved so far should be restored",
+		},
+		{
+			name:        "verification fails",
+			method:      "FindResource",
+			resource:    "sslcertkey_binding",
+			generic:     true,
+			description: "bindings that cannot be verified should be restored",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api, csr := newTestRotationClient(t)
+			now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+			if tt.method == "FindResource" {
+				// The bindings of the previous version are read once before they are moved
+				api.FailNextCall(tt.method, tt.resource, nil)
+			}
+			injected := errors.New("connection reset")
+			api.FailNextCall(tt.method, tt.resource, injected)
+
+			_, err := client.RotateCertificate("example.com", signTestCSR(t, csr, 2), client.KeyFile("example.com"), Rotation{Now: now})
+			if err == nil || !(tt.generic || errors.Is(err, injected)) || !strings.Contains(err.Error(), "rolled back") {
+				t.Fatalf("RotateCertificate() error = %v, want rolled back injected error", err)
+			}
+
+			got, err := client.certkeyBindings("prod-example.com")
+			if err != nil || !reflect.DeepEqual(got, rotationBindings) {
+				t.Errorf("bindings after rollback = %v, %v, want %v", got, err, rotationBindings)
+			}
+			if _, err := api.Store.Get("sslcertkey", client.VersionedCertkey("example.com", now)); err == nil {
+				t.Error("the new version should be removed after a rollback")
+			}
+			if cert, err := client.GetCertificate("example.com"); err != nil || cert["certkey"] != "prod-example.com" {
+				t.Errorf("GetCertificate() after rollback = %v, %v", cert, err)
+			}
+		})
+	}
+}
+
+func TestCertkeyDomain(t *testing.T) {
+	tests := []struct {
+		name        string
+		certkey     string
+		want        string
+		wantOK      bool
+		description string
+	}{
+		{
+			name:        "unversioned",
+			certkey:     "prod-example.com",
+			want:        "example.com",
+			wantOK:      true,
+			description: "the prefix should be removed",
+		},
+		{
+			name:        "versioned",
+			certkey:     "prod-example.com-20261001120000",
+			want:        "example.com",
+			wantOK:      true,
+			description: "the version suffix should be removed",
+		},
+		{
+			name:        "numeric label",
+			certkey:     "prod-shop-2026.example.com",
+			want:        "shop-2026.example.com",
+			wantOK:      true,
+			description: "numbers that are no version suffix should be kept",
+		},
+		{
+			name:        "other prefix",
+			certkey:     "staging-example.com",
+			description: "certkeys of other environments should be rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CertkeyDomain("prod-", tt.certkey)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("CertkeyDomain() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package netscaler
+
+import (
+	"reflect"
+	"strings"
+)
+
+// schemaDescriptions documents the config fields in the generated JSON Schema, keyed by definition and field
+var schemaDescriptions = map[string]map[string]string{
+	"environment": {
+		"prefix":             "Prefix of the certificate names of the environment, e.g. prod-",
+		"endpoint":           "NITRO API endpoint, an https URL such as https://netscaler.example.com",
+		"username":           "NetScaler user",
+		"password":           "Password of the NetScaler user",
+		"sslVerify":          "Verify the TLS certificate of the endpoint",
+		"backend":            "nitro to connect to the appliance at endpoint, adm to send the NITRO calls through NetScaler Console at endpoint",
+		"managedInstance":    "IP address of the instance managed by NetScaler Console the NITRO calls are proxied to",
+		"instanceGroup":      "NetScaler Console instance group the environment is expanded to, one environment per instance",
+		"partition":          "Admin partition the certificates live in, the client switches to it after every login",
+		"timeout":            "Timeout of NITRO API requests in seconds",
+		"rootCaPath":         "PEM file with the CA certificates used to verify the endpoint",
+		"policy":             "Compliance rules evaluated against each retrieved certificate",
+		"inventory":          "Path of an inventory snapshot served instead of the NITRO API",
+		"record":             "Path of a fixture file the NITRO calls are recorded to",
+		"includeDomains":     "Only query the environment for domains matching one of these globs or /regular expressions/",
+		"excludeDomains":     "Never query the environment for domains matching one of these globs or /regular expressions/",
+		"discoverHostnames":  "Report hostnames routed to SSL content switching vservers that no bound certificate covers",
+		"saveConfig":         "Save the running configuration after commands changed the appliance",
+		"haSyncFiles":        "Synchronize the certificate and key files to the HA secondary after commands changed the appliance",
+		"haPeerEndpoint":     "NITRO endpoint of the HA secondary, by default its NSIP with the scheme and port of endpoint",
+		"clusterNodeQueries": "Look up certificates on every node of a cluster and report nodes that didn't receive them",
+		"rotation":           "Report the newest certkey version created by deploy -strategy rotate",
+		"extends":            "Name of an environment to inherit all settings from",
+	},
+	"policy": {
+		"minRsaKeySize":      "Minimum RSA key size in bits",
+		"allowedEcCurves":    "Allowed curves for EC keys, e.g. P-256",
+		"forbidSha1":         "Reject SHA-1 signatures",
+		"maxValidityDays":    "Maximum validity period in days",
+		"requiredIssuer":     "Substring the issuer has to contain",
+		"requireDomainInSan": "The domain and its alternative names must be in the SANs",
+		"severities":         "Severity per rule, overriding the defaults",
+	},
+}
+
+// ConfigSchema returns the JSON Schema of the plugin config, generated from Config
+func ConfigSchema() map[string]any {
+	environment := structSchema(reflect.TypeOf(Config{}), "environment")
+	environment["properties"].(map[string]any)[extendsKey] = map[string]any{
+		"type":        "string",
+		"description": schemaDescriptions["environment"][extendsKey],
+	}
+	environment["properties"].(map[string]any)["endpoint"].(map[string]any)["pattern"] = "^https://"
+	environment["properties"].(map[string]any)["haPeerEndpoint"].(map[string]any)["pattern"] = "^https://"
+	environment["properties"].(map[string]any)["backend"].(map[string]any)["enum"] = []string{BackendNITRO, BackendADM}
+
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "NetScaler metadata plugin config",
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"environments"},
+		"properties": map[string]any{
+			"logLevel": map[string]any{
+				"type":        "string",
+				"description": "Log level of the plugin",
+				"enum":        []string{"trace", "debug", "info", "warn", "error"},
+			},
+			"defaults": map[string]any{
+				"$ref":        "#/$defs/environment",
+				"description": "Settings shared by all environments",
+			},
+			"environments": map[string]any{
+				"type":                 "object",
+				"description":          "NetScaler environments by name",
+				"additionalProperties": map[string]any{"$ref": "#/$defs/environment"},
+			},
+		},
+		"$defs": map[string]any{
+			"environment": environment,
+			"policy":      structSchema(reflect.TypeOf(Policy{}), "policy"),
+		},
+	}
+}
+
+// structSchema describes the JSON fields of a struct type
+func structSchema(typ reflect.Type, definition string) map[string]any {
+	properties := make(map[string]any)
+	for name, field := range jsonFields(typ) {
+		s := typeSchema(field.Type)
+		if description, ok := schemaDescriptions[definition][name]; ok {
+			s["description"] = description
+		}
+		properties[name] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+// typeSchema maps a Go type to a JSON Schema type, structs are referenced by their lowercase name
+func typeSchema(typ reflect.Type) map[string]any {
+	if typ == reflect.TypeOf(Severity("")) {
+		return map[string]any{"type": "string", "enum": []Severity{SeverityCritical, SeverityWarning, SeverityInfo}}
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return typeSchema(typ.Elem())
+	case reflect.Struct:
+		return map[string]any{"$ref": "#/$defs/" + strings.ToLower(typ.Name())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(typ.Elem())}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
@@ -0,0 +1,38 @@
+package netscaler
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestConfigSchema_UpToDate fails when config.schema.json has not been regenerated after a config change
+func TestConfigSchema_UpToDate(t *testing.T) {
+	want, err := json.MarshalIndent(ConfigSchema(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile("../config.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), want) {
+		t.Error("config.schema.json is outdated, run go generate ./...")
+	}
+}
+
+func TestConfigSchema_Descriptions(t *testing.T) {
+	for definition, typ := range map[string]reflect.Type{
+		"environment": reflect.TypeOf(Config{}),
+		"policy":      reflect.TypeOf(Policy{}),
+	} {
+		for name := range jsonFields(typ) {
+			if schemaDescriptions[definition][name] == "" {
+				t.Errorf("field %s.%s has no schema description", definition, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// sessionExpired matches the NITRO errorcodes of expired sessions, 444 and 1027 (authentication timeout),
+// in the response bodies the NITRO client includes in its errors
+var sessionExpired = regexp.MustCompile(`errorcode\W+(444|1027)\b`)
+
+// DefaultPartition is the admin partition NITRO sessions start in
+const DefaultPartition = "default"
+
+// session logs in again when the NITRO session expired and retries the call. After every login it
+// switches to the admin partition, the NITRO client would silently continue in the default partition
+// with credentials sent on every request otherwise.
+//
+// nitro-go doesn't report every expired session as an error: FindAllResources returns an empty list
+// and FindResource a generic error. It drops the session on errorcode 444 and 1027 though, so the
+// session is also renewed when the client reports that it is no longer logged in.
+type session struct {
+	api       NitroClientInterface
+	partition string
+
+	// mu serializes logins of concurrent calls
+	mu sync.Mutex
+}
+
+// newSession wraps the NITRO client, an empty partition stays in the default partition
+func newSession(api NitroClientInterface, partition string) *session {
+	if partition == DefaultPartition {
+		partition = ""
+	}
+	return &session{api: api, partition: partition}
+}
+
+// Login logs in and switches to the partition
+func (s *session) Login() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.login()
+}
+
+func (s *session) login() error {
+	if err := s.api.Login(); err != nil {
+		return err
+	}
+	if s.partition == "" {
+		return nil
+	}
+	if err := s.api.ActOnResource("nspartition", map[string]any{"partitionname": s.partition}, "Switch"); err != nil {
+		return fmt.Errorf("failed to switch to partition %s: %w", s.partition, err)
+	}
+	return nil
+}
+
+// Logout ends the session
+func (s *session) Logout() error {
+	return s.api.Logout()
+}
+
+// loginReporter is implemented by NITRO clients that know whether they hold a session, like nitro-go
+type loginReporter interface {
+	IsLoggedIn() bool
+}
+
+// loggedIn reports whether the NITRO client holds a session, clients that can't tell are assumed to
+func (s *session) loggedIn() bool {
+	reporter, ok := s.api.(loginReporter)
+	return !ok || reporter.IsLoggedIn()
+}
+
+// retry runs the call and runs it once more after a new login if the session expired. A session
+// dropped by an earlier call is renewed before the call.
+func (s *session) retry(call func() error) error {
+	if !s.loggedIn() {
+		if err := s.Login(); err != nil {
+			return fmt.Errorf("login again failed: %w", err)
+		}
+	}
+
+	err := call()
+	expired := err != nil && sessionExpired.MatchString(err.Error())
+	if !expired && s.loggedIn() {
+		return err
+	}
+	if loginErr := s.Login(); loginErr != nil {
+		if err == nil {
+			err = errors.New("session expired")
+		}
+		return fmt.Errorf("%w, login again failed: %w", err, loginErr)
+	}
+	return call()
+}
+
+// FindAllResources lists the resources of the type
+func (s *session) FindAllResources(resourceType string) (res []map[string]any, err error) {
+	err = s.retry(func() error {
+		res, err = s.api.FindAllResources(resourceType)
+		return err
+	})
+	return res, err
+}
+
+// FindResource returns the named resource
+func (s *session) FindResource(resourceType string, name string) (res map[string]any, err error) {
+	err = s.retry(func() error {
+		res, err = s.api.FindResource(resourceType, name)
+		return err
+	})
+	return res, err
+}
+
+// FindResourceArrayWithParams runs the query
+func (s *session) FindResourceArrayWithParams(findParams service.FindParams) (res []map[string]any, err error) {
+	err = s.retry(func() error {
+		res, err = s.api.FindResourceArrayWithParams(findParams)
+		return err
+	})
+	return res, err
+}
+
+// AddResource creates a resource
+func (s *session) AddResource(resourceType string, name string, resourceStruct any) (res string, err error) {
+	err = s.retry(func() error {
+		res, err = s.api.AddResource(resourceType, name, resourceStruct)
+		return err
+	})
+	return res, err
+}
+
+// UpdateResource updates a resource
+func (s *session) UpdateResource(resourceType string, name string, resourceStruct any) (res string, err error) {
+	err = s.retry(func() error {
+		res, err = s.api.UpdateResource(resourceType, name, resourceStruct)
+		return err
+	})
+	return res, err
+}
+
+// ActOnResource applies an action to a resource
+func (s *session) ActOnResource(resourceType string, resourceStruct any, action string) error {
+	return s.retry(func() error {
+		return s.api.ActOnResource(resourceType, resourceStruct, action)
+	})
+}
+
+// DeleteResource removes a resource
+func (s *session) DeleteResource(resourceType string, name string) error {
+	return s.retry(func() error {
+		return s.api.DeleteResource(resourceType, name)
+	})
+}
+
+// DeleteResourceWithArgsMap removes a resource or bindings
+func (s *session) DeleteResourceWithArgsMap(resourceType string, name string, args map[string]string) error {
+	return s.retry(func() error {
+		return s.api.DeleteResourceWithArgsMap(resourceType, name, args)
+	})
+}
@@ -0,0 +1,212 @@
+package netscaler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/citrix/adc-nitro-go/service"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newPartitionAPI returns a fake appliance with a certificate in the default partition and the partitions team-a and team-b
+func newPartitionAPI(t *testing.T) *netscalertest.Store {
+	t.Helper()
+	store := netscalertest.NewStore()
+	for _, res := range []struct {
+		store   *netscalertest.Store
+		certkey string
+	}{
+		{store, "prod-default.com"},
+		{store.Partition("team-a"), "prod-a.com"},
+		{store.Partition("team-b"), "prod-b.com"},
+	} {
+		if err := res.store.Add("sslcertkey", map[string]any{"certkey": res.certkey}); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestNewPartitionClientFromNitro(t *testing.T) {
+	tests := []struct {
+		name        string
+		partition   string
+		want        string
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "default",
+			want:        "prod-default.com",
+			description: "without a partition the client should stay in the default partition",
+		},
+		{
+			name:        "explicit default",
+			partition:   "default",
+			want:        "prod-default.com",
+			description: "the default partition should not be switched to",
+		},
+		{
+			name:        "team-a",
+			partition:   "team-a",
+			want:        "prod-a.com",
+			description: "the client should only see the certificates of its partition",
+		},
+		{
+			name:        "missing",
+			partition:   "team-c",
+			wantErr:     "failed to switch to partition team-c",
+			description: "an unknown partition should fail the login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := netscalertest.NewNitroClientWithStore(newPartitionAPI(t))
+			client, err := NewPartitionClientFromNitro("prod-", tt.partition, api)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewPartitionClientFromNitro() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPartitionClientFromNitro() error = %v", err)
+			}
+			if client.Partition() != tt.partition {
+				t.Errorf("Partition() = %q, want %q", client.Partition(), tt.partition)
+			}
+
+			certs, err := client.GetAllCertificates()
+			if err != nil {
+				t.Fatalf("GetAllCertificates() error = %v", err)
+			}
+			if len(certs) != 1 || certs[0]["certkey"] != tt.want {
+				t.Errorf("GetAllCertificates() = %v, want %s", certs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSession_Relogin(t *testing.T) {
+	api := netscalertest.NewNitroClientWithStore(newPartitionAPI(t))
+	client, err := NewPartitionClientFromNitro("prod-", "team-a", api)
+	if err != nil {
+		t.Fatalf("NewPartitionClientFromNitro() error = %v", err)
+	}
+
+	// A new session starts in the default partition, the client has to switch again
+	api.ExpireSession()
+	cert, err := client.GetCertificate("a.com")
+	if err != nil {
+		t.Fatalf("GetCertificate() after the session expired error = %v", err)
+	}
+	if cert["certkey"] != "prod-a.com" || api.Partition() != "team-a" {
+		t.Errorf("GetCertificate() = %v in partition %q, want prod-a.com in team-a", cert, api.Partition())
+	}
+
+	var switches int
+	for _, call := range api.Calls() {
+		if call.ResourceType == "nspartition" && call.Name == "Switch" {
+			switches++
+		}
+	}
+	if switches != 2 {
+		t.Errorf("switched partitions %d times, want after both logins", switches)
+	}
+
+	injected := errors.New("connection reset")
+	api.FailNextCall("FindResourceArrayWithParams", "sslcertkey", injected)
+	if _, err := client.api.FindResourceArrayWithParams(service.FindParams{ResourceType: "sslcertkey"}); !errors.Is(err, injected) {
+		t.Errorf("FindResourceArrayWithParams() error = %v, other errors should not be retried", err)
+	}
+
+	api.ExpireSession()
+	api.FailNext("Login", errors.New("authentication failed"))
+	if _, err := client.GetAllCertificates(); err == nil || !strings.Contains(err.Error(), "login again failed") {
+		t.Errorf("GetAllCertificates() error = %v, want the failed login", err)
+	}
+}
+
+func TestSession_ReloginServer(t *testing.T) {
+	srv := netscalertest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Store = newPartitionAPI(t)
+
+	client, err := NewClient("prod-", &ClientConfig{Endpoint: srv.URL, Username: srv.Username, Password: srv.Password, Partition: "team-a"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// nitro-go returns no certificates instead of the expired session error
+	srv.ExpireSessions()
+	certs, err := client.GetAllCertificates()
+	if err != nil || len(certs) != 1 || certs[0]["certkey"] != "prod-a.com" {
+		t.Fatalf("GetAllCertificates() after the session expired = %v, %v, want prod-a.com", certs, err)
+	}
+
+	// and a generic error for a single resource
+	srv.ExpireSessions()
+	cert, err := client.GetCertificate("a.com")
+	if err != nil || cert["certkey"] != "prod-a.com" {
+		t.Errorf("GetCertificate() after the session expired = %v, %v, want prod-a.com", cert, err)
+	}
+	if srv.Sessions() != 1 {
+		t.Errorf("Sessions() = %d, want the renewed session", srv.Sessions())
+	}
+
+	var switches int
+	for _, action := range srv.Actions() {
+		if action.ResourceType == "nspartition" && action.Action == "Switch" {
+			switches++
+		}
+	}
+	if switches != 3 {
+		t.Errorf("switched partitions %d times, want after every login", switches)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         string
+		want        bool
+		description string
+	}{
+		{
+			name:        "nitro client",
+			err:         `failed: 401 Unauthorized ({ "errorcode": 444, "message": "Session expired or killed. Please login again", "severity": "ERROR" })`,
+			want:        true,
+			description: "errors of the NITRO client include the response body",
+		},
+		{
+			name:        "authentication timeout",
+			err:         `failed: 401 Unauthorized ({ "errorcode": 1027, "message": "Authentication timeout" })`,
+			want:        true,
+			description: "authentication timeouts should also log in again",
+		},
+		{
+			name:        "fake",
+			err:         (&netscalertest.NitroError{Status: 401, Code: netscalertest.ErrCodeSessionExpired, Message: "Session expired"}).Error(),
+			want:        true,
+			description: "errors of the fake client should match",
+		},
+		{
+			name:        "other errorcode",
+			err:         `failed: 404 Not Found ({ "errorcode": 4440, "message": "No such resource" })`,
+			want:        false,
+			description: "errorcodes starting with 444 should not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionExpired.MatchString(tt.err); got != tt.want {
+				t.Errorf("sessionExpired.MatchString(%q) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
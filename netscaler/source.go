@@ -0,0 +1,18 @@
+package netscaler
+
+// CertificateSource provides the deployed certificates of an environment
+type CertificateSource interface {
+	// GetCertificate returns the certificate of a domain, without the environment prefix
+	GetCertificate(name string) (map[string]any, error)
+	// GetAllCertificates returns all certificates of the environment
+	GetAllCertificates() ([]map[string]any, error)
+	// Health returns an error if the source can't be queried
+	Health() error
+	// Close releases the resources held by the source
+	Close() error
+}
+
+var (
+	_ CertificateSource = (*Client)(nil)
+	_ CertificateSource = (*InventorySource)(nil)
+)
@@ -0,0 +1,173 @@
+package netscaler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultHTTPSPort is used to identify appliances whose endpoint has no explicit port
+const defaultHTTPSPort = "443"
+
+// unknownFields returns an error for every key of raw that is not a JSON field of typ,
+// descending into nested structs. path is prepended to the reported field names.
+func unknownFields(raw map[string]any, typ reflect.Type, path string) []error {
+	fields := jsonFields(typ)
+
+	var errs []error
+	for _, key := range sortedMapKeys(raw) {
+		field, ok := fields[key]
+		if !ok {
+			msg := "is unknown"
+			if suggestion := suggestField(key, fields); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean '%s'?", path+suggestion)
+			}
+			errs = append(errs, &FieldError{Field: path + key, Message: msg})
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if nested, ok := raw[key].(map[string]any); ok && ft.Kind() == reflect.Struct {
+			errs = append(errs, unknownFields(nested, ft, path+key+".")...)
+		}
+	}
+	return errs
+}
+
+// jsonFields maps the JSON names of the exported fields of a struct type to the fields
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// suggestField returns the known field closest to an unknown key, or "" if none is similar
+func suggestField(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(key)); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validateEndpoint checks that the endpoint is an https URL with a host and a valid port
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return &FieldError{Field: "endpoint", Message: "is not a valid URL"}
+	}
+	if u.Scheme != "https" {
+		return &FieldError{Field: "endpoint", Message: fmt.Sprintf("must be an https URL, got scheme '%s'", u.Scheme)}
+	}
+	if u.Hostname() == "" {
+		return &FieldError{Field: "endpoint", Message: "must contain a host"}
+	}
+	if port := u.Port(); port != "" {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return &FieldError{Field: "endpoint", Message: fmt.Sprintf("has invalid port %s", port)}
+		}
+	}
+	return nil
+}
+
+// Appliance identifies the NetScaler an environment connects to as lowercase host and port. For
+// environments proxied through ADM it is the managed instance, empty for an instance group.
+func (c *Config) Appliance() string {
+	if c.ADM() {
+		if c.ManagedInstance == "" {
+			return ""
+		}
+		return net.JoinHostPort(strings.ToLower(c.ManagedInstance), defaultHTTPSPort)
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultHTTPSPort
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// CheckDuplicatePrefixes returns an error for every pair of environments using the same prefix in the
+// same partition of an appliance, as they would manage the same certificates
+func CheckDuplicatePrefixes(configs map[string]Config) []error {
+	owners := make(map[string]string)
+	var errs []error
+	for _, env := range sortedMapKeys(configs) {
+		cfg := configs[env]
+		appliance := cfg.Appliance()
+		if cfg.Inventory != "" || appliance == "" {
+			continue
+		}
+		partition := cfg.Partition
+		if partition == DefaultPartition {
+			partition = ""
+		}
+		key := appliance + "\x00" + partition + "\x00" + cfg.Prefix
+		if other, ok := owners[key]; ok {
+			if partition != "" {
+				appliance += " partition " + partition
+			}
+			errs = append(errs, fmt.Errorf("environments %s and %s use the same prefix '%s' on %s", other, env, cfg.Prefix, appliance))
+			continue
+		}
+		owners[key] = env
+	}
+	return errs
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
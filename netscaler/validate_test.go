@@ -0,0 +1,180 @@
+package netscaler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewConfig_UnknownFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       map[string]any
+		want        []string
+		description string
+	}{
+		{
+			name:        "wrong case",
+			input:       map[string]any{"sslverify": true},
+			want:        []string{"field 'sslverify' is unknown, did you mean 'sslVerify'?"},
+			description: "should suggest the field with the right case",
+		},
+		{
+			name:        "typo",
+			input:       map[string]any{"endpont": "https://netscaler.example.com"},
+			want:        []string{"field 'endpont' is unknown, did you mean 'endpoint'?"},
+			description: "should suggest similar fields",
+		},
+		{
+			name:        "nested",
+			input:       map[string]any{"policy": map[string]any{"minRsaKeysize": 2048, "foo": 1}},
+			want:        []string{"field 'policy.foo' is unknown", "field 'policy.minRsaKeysize' is unknown, did you mean 'policy.minRsaKeySize'?"},
+			description: "should check nested objects",
+		},
+		{
+			name:        "several",
+			input:       map[string]any{"usrname": "admin", "unrelatedSetting": true},
+			want:        []string{"field 'unrelatedSetting' is unknown", "field 'usrname' is unknown, did you mean 'username'?"},
+			description: "should report every unknown field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConfig(tt.input)
+			if err == nil {
+				t.Fatal("NewConfig() should fail on unknown fields")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("NewConfig() error = %q, want %d problems", err, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    string
+		wantErr     string
+		description string
+	}{
+		{name: "valid", endpoint: "https://netscaler.example.com", description: "should accept https URLs"},
+		{name: "valid with port", endpoint: "https://10.0.0.1:8443", description: "should accept explicit ports"},
+		{name: "http", endpoint: "http://netscaler.example.com", wantErr: "must be an https URL", description: "should reject plain http"},
+		{name: "no scheme", endpoint: "netscaler.example.com", wantErr: "must be an https URL", description: "should reject URLs without scheme"},
+		{name: "no host", endpoint: "https://", wantErr: "must contain a host", description: "should require a host"},
+		{name: "port out of range", endpoint: "https://netscaler.example.com:70000", wantErr: "invalid port", description: "should reject ports above 65535"},
+		{name: "port zero", endpoint: "https://netscaler.example.com:0", wantErr: "invalid port", description: "should reject port 0"},
+		{name: "malformed", endpoint: "https://netscaler.example.com:https", wantErr: "is not a valid URL", description: "should reject unparsable URLs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Endpoint: tt.endpoint, Username: "admin", Password: "secret"}
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateReportsAll(t *testing.T) {
+	err := (&Config{Endpoint: "http://netscaler.example.com", Timeout: -1}).Validate()
+
+	var fields []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fieldErr *FieldError
+		if errors.As(e, &fieldErr) {
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+	if got := strings.Join(fields, ","); got != "endpoint,username,password,timeout" {
+		t.Errorf("Validate() reported %s, want endpoint,username,password,timeout", got)
+	}
+}
+
+func TestConfig_ValidateBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		wantErr     string
+		description string
+	}{
+		{name: "default", config: Config{}, description: "the nitro backend should be the default"},
+		{name: "nitro", config: Config{Backend: "nitro"}, description: "should accept the nitro backend"},
+		{name: "adm instance", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21"}, description: "should accept a managed instance"},
+		{name: "adm group", config: Config{Backend: "adm", InstanceGroup: "web"}, description: "should accept an instance group"},
+		{name: "unknown", config: Config{Backend: "console"}, wantErr: "field 'backend' must be nitro or adm, got 'console'", description: "should reject unknown backends"},
+		{name: "adm without instance", config: Config{Backend: "adm"}, wantErr: "field 'managedInstance' or instanceGroup is required", description: "adm needs an instance to proxy to"},
+		{name: "adm instance and group", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21", InstanceGroup: "web"}, wantErr: "field 'instanceGroup' can't be combined with managedInstance", description: "an environment is either one instance or a group"},
+		{name: "nitro instance", config: Config{ManagedInstance: "192.0.2.21"}, wantErr: "field 'managedInstance' requires backend adm", description: "managed instances need the adm backend"},
+		{name: "nitro group", config: Config{InstanceGroup: "web"}, wantErr: "field 'instanceGroup' requires backend adm", description: "instance groups need the adm backend"},
+		{name: "adm partition", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21", Partition: "team-a"}, wantErr: "field 'partition' is not supported with backend adm", description: "partitions need a direct session"},
+		{name: "adm default partition", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21", Partition: "default"}, description: "the default partition needs no switch"},
+		{name: "adm cluster", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21", ClusterNodeQueries: true}, wantErr: "field 'clusterNodeQueries' is not supported with backend adm", description: "cluster nodes are queried directly"},
+		{name: "adm peer", config: Config{Backend: "adm", ManagedInstance: "192.0.2.21", HAPeerEndpoint: "https://192.0.2.22"}, wantErr: "field 'haPeerEndpoint' is not supported with backend adm", description: "the secondary is reached through ADM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			c.Endpoint, c.Username, c.Password = "https://adm.example.com", "admin", "secret"
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckDuplicatePrefixes(t *testing.T) {
+	configs := map[string]Config{
+		"prod":      {Endpoint: "https://netscaler.example.com", Prefix: "prod-"},
+		"prod-copy": {Endpoint: "https://NetScaler.example.com:443/", Prefix: "prod-"},
+		"dev":       {Endpoint: "https://netscaler.example.com", Prefix: "dev-"},
+		"prod-dr":   {Endpoint: "https://netscaler-dr.example.com", Prefix: "prod-"},
+		"other":     {Endpoint: "https://netscaler.example.com:8443", Prefix: "prod-"},
+		"offline":   {Inventory: "inventory.json", Prefix: "prod-"},
+		"team-a":    {Endpoint: "https://netscaler.example.com", Prefix: "prod-", Partition: "team-a"},
+		"team-b":    {Endpoint: "https://netscaler.example.com", Prefix: "prod-", Partition: "team-b"},
+		"z-default": {Endpoint: "https://netscaler.example.com", Prefix: "dev-", Partition: "default"},
+		"adm":       {Endpoint: "https://adm.example.com", Backend: "adm", ManagedInstance: "192.0.2.21", Prefix: "prod-"},
+		"adm-other": {Endpoint: "https://adm.example.com", Backend: "adm", ManagedInstance: "192.0.2.22", Prefix: "prod-"},
+		"adm-group": {Endpoint: "https://adm.example.com", Backend: "adm", InstanceGroup: "web", Prefix: "prod-"},
+		"direct":    {Endpoint: "https://192.0.2.21", Prefix: "prod-"},
+	}
+
+	errs := CheckDuplicatePrefixes(configs)
+	if len(errs) != 3 {
+		t.Fatalf("CheckDuplicatePrefixes() = %v, want 3 errors", errs)
+	}
+	if want := "environments adm and direct use the same prefix 'prod-' on 192.0.2.21:443"; errs[0].Error() != want {
+		t.Errorf("CheckDuplicatePrefixes() = %q, want %q, environments proxied through ADM are identified by the instance", errs[0], want)
+	}
+	if want := "environments prod and prod-copy use the same prefix 'prod-' on netscaler.example.com:443"; errs[1].Error() != want {
+		t.Errorf("CheckDuplicatePrefixes() = %q, want %q", errs[1], want)
+	}
+	if want := "environments dev and z-default use the same prefix 'dev-' on netscaler.example.com:443"; errs[2].Error() != want {
+		t.Errorf("CheckDuplicatePrefixes() = %q, want %q, the default partition is the partition of environments without one", errs[2], want)
+	}
+}
@@ -0,0 +1,139 @@
+package netscaler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/citrix/adc-nitro-go/service"
+)
+
+// defaultVerifyTimeout limits each TLS handshake of VerifyTLS
+const defaultVerifyTimeout = 10 * time.Second
+
+// vserverTypes are the vserver types an sslvserver binding can refer to
+var vserverTypes = []string{service.Lbvserver.Type(), service.Csvserver.Type()}
+
+// Verification configures VerifyTLS
+type Verification struct {
+	// ServerName is sent as SNI
+	ServerName string
+	// Address is dialed instead of the address of every vserver, e.g. a local TLS listener
+	Address string
+	// Timeout limits each handshake, the default is 10 seconds
+	Timeout time.Duration
+}
+
+// TLSCheck is the result of a handshake with a VIP a certkey is bound to
+type TLSCheck struct {
+	Vserver    string `json:"vserver"`
+	Address    string `json:"address"`
+	ServerName string `json:"serverName"`
+	// Expected is the fingerprint of the certkey, Served the fingerprint of the leaf certificate received
+	Expected string `json:"expected"`
+	Served   string `json:"served,omitempty"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// VerifyTLS connects to the address of every SSL vserver the certkey is bound to, with SNI set to
+// the server name, and compares the fingerprint of the served leaf certificate with the certificate
+// of the certkey. Failed handshakes and mismatches are reported in the checks, errors are returned
+// only if the certkey or its vservers can't be read.
+func (c *Client) VerifyTLS(certkey string, v Verification) ([]TLSCheck, error) {
+	raw, err := c.findCertkey(certkey)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("certkey %s not found", certkey)
+	}
+	file := c.sslFileName(stringField(raw, "cert"))
+	if file == "" {
+		return nil, fmt.Errorf("certificate file %s of %s is not in %s", stringField(raw, "cert"), certkey, c.FileLocation())
+	}
+	cert, err := c.downloadCertificate(file)
+	if err != nil {
+		return nil, err
+	}
+	expected := Fingerprint(cert)
+
+	bindings, err := c.certkeyBindings(certkey)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []TLSCheck{}
+	for _, b := range bindings {
+		if b.Type != service.Sslvserver.Type() {
+			continue
+		}
+		address := v.Address
+		if address == "" {
+			if address, err = c.vserverAddress(b.Name); err != nil {
+				return nil, err
+			}
+		}
+		check := TLSCheck{Vserver: b.Name, Address: address, ServerName: v.ServerName, Expected: expected}
+		if check.Served, err = servedFingerprint(address, v); err != nil {
+			check.Error = err.Error()
+		} else if check.OK = check.Served == expected; !check.OK {
+			check.Error = "served certificate doesn't match the certkey"
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// vserverAddress returns the IP and port of the load balancing or content switching vserver
+func (c *Client) vserverAddress(name string) (string, error) {
+	for _, vserverType := range vserverTypes {
+		res, err := c.api.FindResourceArrayWithParams(service.FindParams{
+			ResourceType:             vserverType,
+			ResourceName:             name,
+			ResourceMissingErrorCode: errCodeNoSuchResource,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve %s %s: %w", vserverType, name, err)
+		}
+		if len(res) == 0 {
+			continue
+		}
+		ip := stringField(res[0], "ipv46")
+		if ip == "" || ip == "0.0.0.0" {
+			return "", fmt.Errorf("%s %s has no address", vserverType, name)
+		}
+		return net.JoinHostPort(ip, fmt.Sprint(res[0]["port"])), nil
+	}
+	return "", fmt.Errorf("vserver %s not found", name)
+}
+
+// servedFingerprint performs a TLS handshake and returns the fingerprint of the leaf certificate
+func servedFingerprint(address string, v Verification) (string, error) {
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = defaultVerifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName: v.ServerName,
+		MinVersion: tls.VersionTLS12,
+		// The served certificate is compared by fingerprint, it may not be trusted yet
+		InsecureSkipVerify: true, //nolint:gosec
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("handshake with %s failed: %w", address, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("%s served no certificate", address)
+	}
+	return Fingerprint(certs[0]), nil
+}
@@ -0,0 +1,152 @@
+package netscaler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// newTestTLSCertificate returns a self-signed certificate for the domain
+func newTestTLSCertificate(t *testing.T, domain string) tls.Certificate {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// newTestTLSListener serves the certificate and returns its address
+func newTestTLSListener(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String()
+}
+
+// addVerifiedCertkey installs the certificate as prod-example.com bound to the load balancing vserver vs-web
+func addVerifiedCertkey(t *testing.T, api *netscalertest.NitroClient, cert tls.Certificate) {
+	t.Helper()
+	if err := api.Store.WriteFile("prod-example.com.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Leaf.Raw})); err != nil {
+		t.Fatalf("Store.WriteFile() error = %v", err)
+	}
+	for _, res := range []struct {
+		resourceType string
+		attrs        map[string]any
+	}{
+		{"sslcertkey", map[string]any{"certkey": "prod-example.com", "cert": "prod-example.com.crt", "key": "prod-example.com.key"}},
+		{"lbvserver", map[string]any{"name": "vs-web", "servicetype": "SSL", "ipv46": "192.0.2.10", "port": 443}},
+		{"csvserver", map[string]any{"name": "cs-web", "servicetype": "SSL", "ipv46": "0.0.0.0", "port": 0}},
+	} {
+		if err := api.Store.Add(res.resourceType, res.attrs); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	_ = api.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+	_ = api.Store.Bind("sslservice_sslcertkey_binding", map[string]any{"servicename": "svc-web", "certkeyname": "prod-example.com"})
+}
+
+func TestClient_VerifyTLS(t *testing.T) {
+	deployed := newTestTLSCertificate(t, "example.com")
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedAddress := closed.Addr().String()
+	_ = closed.Close()
+
+	tests := []struct {
+		name        string
+		served      *tls.Certificate
+		address     string
+		wantOK      bool
+		wantErr     string
+		description string
+	}{
+		{
+			name:        "served",
+			served:      &deployed,
+			wantOK:      true,
+			description: "the VIP should serve the deployed certificate",
+		},
+		{
+			name:        "mismatch",
+			served:      func() *tls.Certificate { c := newTestTLSCertificate(t, "example.com"); return &c }(),
+			wantErr:     "doesn't match",
+			description: "another certificate for the same domain should be reported",
+		},
+		{
+			name:        "unreachable",
+			address:     closedAddress,
+			wantErr:     "handshake with " + closedAddress + " failed",
+			description: "failed handshakes should be reported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, api := newTestKeyClient(t)
+			addVerifiedCertkey(t, api, deployed)
+			address := tt.address
+			if tt.served != nil {
+				address = newTestTLSListener(t, *tt.served)
+			}
+
+			checks, err := client.VerifyTLS("prod-example.com", Verification{ServerName: "example.com", Address: address, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatalf("VerifyTLS() error = %v", err)
+			}
+			if len(checks) != 1 {
+				t.Fatalf("VerifyTLS() = %+v, want one check for the SSL vserver", checks)
+			}
+			check := checks[0]
+			if check.Vserver != "vs-web" || check.Address != address || check.Expected != Fingerprint(deployed.Leaf) {
+				t.Errorf("VerifyTLS() = %+v", check)
+			}
+			if check.OK != tt.wantOK || !strings.Contains(check.Error, tt.wantErr) {
+				t.Errorf("VerifyTLS() OK = %v, Error = %q, want %v, %q", check.OK, check.Error, tt.wantOK, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_VserverAddress(t *testing.T) {
+	client, api := newTestKeyClient(t)
+	addVerifiedCertkey(t, api, newTestTLSCertificate(t, "example.com"))
+
+	if got, err := client.vserverAddress("vs-web"); err != nil || got != "192.0.2.10:443" {
+		t.Errorf("vserverAddress(vs-web) = %q, %v", got, err)
+	}
+	if _, err := client.vserverAddress("cs-web"); err == nil || !strings.Contains(err.Error(), "has no address") {
+		t.Errorf("vserverAddress(cs-web) error = %v, want no address", err)
+	}
+	if _, err := client.vserverAddress("vs-missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("vserverAddress(vs-missing) error = %v, want not found", err)
+	}
+	if _, err := client.VerifyTLS("prod-missing", Verification{}); err == nil {
+		t.Error("VerifyTLS() of a missing certkey should fail")
+	}
+}
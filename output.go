@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported output formats of the CLI commands
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+	formatCSV   = "csv"
+)
+
+// tabular is implemented by command results that can be rendered as table or CSV
+type tabular interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// render writes the data in the requested output format
+func render(w io.Writer, format string, data tabular) error {
+	switch format {
+	case formatTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, strings.Join(data.Header(), "\t"))
+		for _, row := range data.Rows() {
+			_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(data.Header()); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(data.Rows()); err != nil {
+			return err
+		}
+		return cw.Error()
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case formatYAML:
+		return writeYAML(w, data)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeYAML renders the value as YAML, reusing its JSON field names and order
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML, decoding into a node keeps the field order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
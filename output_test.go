@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+func testRows() certificateRows {
+	return certificateRows{
+		{
+			Environment: "prod",
+			Certificate: &netscaler.Certificate{
+				Certkey:          "prod-example.com",
+				Subject:          "CN=example.com",
+				Issuer:           "CN=R11",
+				NotAfter:         time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC),
+				DaysToExpiration: 12,
+				Status:           "Valid",
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		contains    []string
+		wantErr     bool
+		description string
+	}{
+		{
+			name:        "table",
+			format:      formatTable,
+			contains:    []string{"ENVIRONMENT", "prod-example.com", "2025-05-30"},
+			description: "should render aligned columns with a header",
+		},
+		{
+			name:        "csv",
+			format:      formatCSV,
+			contains:    []string{"ENVIRONMENT,CERTKEY", "prod,prod-example.com,CN=example.com,CN=R11,2025-05-30,12,Valid"},
+			description: "should render comma separated values",
+		},
+		{
+			name:        "json",
+			format:      formatJSON,
+			contains:    []string{`"environment": "prod"`, `"certkey": "prod-example.com"`, `"daysToExpiration": 12`},
+			description: "should render the JSON field names",
+		},
+		{
+			name:        "yaml",
+			format:      formatYAML,
+			contains:    []string{"- environment: prod", "certkey: prod-example.com", "daysToExpiration: 12"},
+			description: "should render YAML with the JSON field names",
+		},
+		{
+			name:        "unknown",
+			format:      "xml",
+			wantErr:     true,
+			description: "should reject unsupported formats",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := render(&buf, tt.format, testRows())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, want := range tt.contains {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("render() output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
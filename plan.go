@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// maxPlanValueLength is the length up to which attribute values are shown in plan tables
+const maxPlanValueLength = 48
+
+// planRows is a plan printed by -dry-run, as JSON it can be passed to apply
+type planRows struct {
+	*netscaler.Plan
+}
+
+func (r planRows) Header() []string {
+	return []string{"#", "ACTION", "RESOURCE TYPE", "NAME", "CHANGES"}
+}
+
+func (r planRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r.Steps))
+	for i, step := range r.Steps {
+		var changes []string
+		for _, change := range step.Diff {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", change.Attribute, planValue(change.Old), planValue(change.New)))
+		}
+		for _, k := range sortedKeys(step.Args) {
+			changes = append(changes, fmt.Sprintf("%s=%s", k, step.Args[k]))
+		}
+		rows = append(rows, []string{fmt.Sprint(i + 1), step.Action, step.ResourceType, step.Name, strings.Join(changes, "; ")})
+	}
+	return rows
+}
+
+// planValue formats an attribute value for the plan table, long values such as file contents are abbreviated
+func planValue(v any) string {
+	if v == nil {
+		return "-"
+	}
+	s := fmt.Sprint(v)
+	if len(s) > maxPlanValueLength {
+		return fmt.Sprintf("<%d characters>", len(s))
+	}
+	return s
+}
+
+// runApply executes a plan written by -dry-run -format json, exactly as it was reviewed. Steps are
+// refused if the appliance changed since the plan was made.
+func runApply(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("apply", opts)
+	planPath := fs.String("plan", "", "Plan file written with -dry-run -format json")
+	dryRun := fs.Bool("dry-run", false, "Check the plan against the appliance and print it with the save and file synchronization of the environment instead of executing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *planPath == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: apply [flags] -plan <file>")
+	}
+
+	plan, err := netscaler.LoadPlan(*planPath)
+	if err != nil {
+		return err
+	}
+	if opts.environments != "" && opts.environments != plan.Environment {
+		return fmt.Errorf("plan is for environment %s, not %s", plan.Environment, opts.environments)
+	}
+	opts.environments = plan.Environment
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	if *dryRun {
+		if err := client.CheckPlan(plan); err != nil {
+			return fmt.Errorf("environment %s: %w", env, err)
+		}
+		after, err := c.planAfterWrite(env, client)
+		if err != nil {
+			return fmt.Errorf("environment %s: %w", env, err)
+		}
+		checked := *plan
+		checked.Steps = append(append([]netscaler.PlanStep{}, plan.Steps...), after...)
+		return render(c.stdout, opts.format, planRows{&checked})
+	}
+
+	applied, err := client.ApplyPlan(plan)
+	if err != nil {
+		return fmt.Errorf("applied %d of %d step(s) to environment %s: %w", applied, len(plan.Steps), env, err)
+	}
+	var files []string
+	for _, step := range plan.Steps {
+		if step.ResourceType == "systemfile" && step.Action == "add" {
+			files = append(files, step.Name)
+		}
+	}
+	if err := c.afterWrite(env, client, files); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.stdout, "Applied %d step(s) to environment %s\n", applied, env)
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+func TestRunCSR_DryRun(t *testing.T) {
+	prod := netscalertest.NewNitroClient()
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": prod})
+	args := []string{"-config", writeTestConfig(t, testConfigYAML), "-env", "prod", "-dry-run", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com"}
+	if err := runCSR(c, args); err != nil {
+		t.Fatalf("runCSR() -dry-run error = %v", err)
+	}
+	for _, want := range []string{"create", "sslrsakey", "prod-example.com.key", "sslcertreq", "prod-example.com.csr"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("runCSR() -dry-run output should contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+	if _, err := prod.Store.ReadFile("prod-example.com.key"); err == nil {
+		t.Error("runCSR() -dry-run should not create the key")
+	}
+}
+
+func TestRunDeploy_DryRunAndApply(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	apis := map[string]*netscalertest.NitroClient{"prod-": prod}
+
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runCSR(c, []string{"-config", config, "-env", "prod", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com"}); err != nil {
+		t.Fatalf("runCSR() error = %v", err)
+	}
+	cert := signCSR(t, stdout.Bytes())
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(cert)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-dry-run", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() -dry-run error = %v", err)
+	}
+	for _, want := range []string{"ACTION", "add", "sslcertkey", "prod-example.com", "key: - -> prod-example.com.key"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("runDeploy() -dry-run output should contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+	if _, err := prod.Store.Get("sslcertkey", "prod-example.com"); err == nil {
+		t.Fatal("runDeploy() -dry-run should not change the appliance")
+	}
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(cert)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "-dry-run", "-format", "json", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() -dry-run -format json error = %v", err)
+	}
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planPath, stdout.Bytes(), 0o600); err != nil {
+		t.Fatalf("Failed to write plan: %v", err)
+	}
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runApply(c, []string{"-config", config, "-env", "dev", "-plan", planPath}); err == nil || !strings.Contains(err.Error(), "plan is for environment prod") {
+		t.Errorf("runApply() for another environment error = %v", err)
+	}
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runApply(c, []string{"-config", config, "-dry-run", "-plan", planPath}); err != nil {
+		t.Fatalf("runApply() -dry-run error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "prod-example.com") {
+		t.Errorf("runApply() -dry-run output = %q", stdout.String())
+	}
+	if _, err := prod.Store.Get("sslcertkey", "prod-example.com"); err == nil {
+		t.Fatal("runApply() -dry-run should not change the appliance")
+	}
+
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runApply(c, []string{"-config", config, "-plan", planPath}); err != nil {
+		t.Fatalf("runApply() error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Applied 2 step(s) to environment prod") {
+		t.Errorf("runApply() output = %q", stdout.String())
+	}
+	if res, err := prod.Store.Get("sslcertkey", "prod-example.com"); err != nil || res["key"] != "prod-example.com.key" {
+		t.Errorf("certkey after apply = %v, %v", res, err)
+	}
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runApply(c, []string{"-config", config}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("runApply() without plan error = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// pruneRows lists certkeys and files that prune deletes
+type pruneRows []netscaler.PruneCandidate
+
+func (r pruneRows) Header() []string {
+	return []string{"TYPE", "NAME", "REASON", "BOUND TO"}
+}
+
+func (r pruneRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, candidate := range r {
+		bound := make([]string, 0, len(candidate.Bindings))
+		for _, b := range candidate.Bindings {
+			bound = append(bound, b.Type+"/"+b.Name)
+		}
+		rows = append(rows, []string{candidate.Type, candidate.Name, candidate.Reason, strings.Join(bound, ",")})
+	}
+	return rows
+}
+
+// runPrune lists expired, unbound and replaced certkeys and orphaned files of an environment, and deletes
+// them after confirmation when -delete is given
+func runPrune(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("prune", opts)
+	deleteFlag := fs.Bool("delete", false, "Delete the listed certkeys and files instead of only listing them")
+	yes := fs.Bool("yes", false, "Don't ask for confirmation before deleting")
+	dryRun := fs.Bool("dry-run", false, "With -delete, print the planned NITRO operations instead of executing them, see apply")
+	protect := fs.String("protect", "", "Comma separated globs or /regular expressions/ of names that are never pruned, in addition to the appliance certificates")
+	grace := fs.Duration("grace", 24*time.Hour, "How long certkey versions replaced by deploy -strategy rotate are kept before they are pruned")
+	all := fs.Bool("all", false, "Allow an environment without prefix, every certkey and file of the appliance is considered. With -delete the candidates are listed and have to be confirmed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *all && *deleteFlag && *yes && !*dryRun {
+		return fmt.Errorf("-all can't be combined with -yes, the listed candidates have to be confirmed")
+	}
+
+	var protected []string
+	for _, name := range strings.Split(*protect, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			protected = append(protected, name)
+		}
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+	if client.Prefix() == "" && !*all {
+		return fmt.Errorf("environment %s has no prefix, every certkey and file of the appliance would be pruned; use -all to list them anyway", env)
+	}
+
+	// The certkeys of environments with a longer prefix, e.g. prod-eu- next to prod-, are not pruned
+	var others []string
+	for name, cfg := range c.configured {
+		if name != env {
+			others = append(others, cfg.Prefix)
+		}
+	}
+
+	candidates, err := client.PruneCandidates(netscaler.Pruning{Protected: protected, Others: others, Grace: *grace, All: *all})
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	if !*deleteFlag {
+		return render(c.stdout, opts.format, pruneRows(candidates))
+	}
+
+	if *dryRun {
+		planned, planner := client.Planned()
+		if _, err := planned.Prune(candidates); err != nil {
+			return err
+		}
+		return render(c.stdout, opts.format, planRows{planner.Plan(env)})
+	}
+
+	var certkeys, files, bound int
+	for _, candidate := range candidates {
+		switch {
+		case len(candidate.Bindings) > 0:
+			bound++
+		case candidate.Type == "systemfile":
+			files++
+		default:
+			certkeys++
+		}
+	}
+	if bound > 0 {
+		_, _ = fmt.Fprintf(c.stderr, "Keeping %d expired certkey(s) that are still bound, unbind them first\n", bound)
+	}
+	if certkeys+files == 0 {
+		_, _ = fmt.Fprintf(c.stderr, "Nothing to prune in environment %s\n", env)
+		return nil
+	}
+
+	if *all {
+		// Without prefix the candidates may include certkeys and files other tools manage
+		if err := render(c.stderr, opts.format, pruneRows(candidates)); err != nil {
+			return err
+		}
+	}
+	if !*yes && !c.confirm(fmt.Sprintf("Delete %d certkey(s) and %d file(s) from environment %s?", certkeys, files, env)) {
+		return fmt.Errorf("prune aborted")
+	}
+
+	deleted, err := client.Prune(candidates)
+	if len(deleted) > 0 {
+		if saveErr := c.afterWrite(env, client, nil); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	if renderErr := render(c.stdout, opts.format, pruneRows(deleted)); renderErr != nil && err == nil {
+		err = renderErr
+	}
+	return err
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin, anything but yes is a no
+func (c *cli) confirm(question string) bool {
+	_, _ = fmt.Fprintf(c.stderr, "%s [y/N] ", question)
+	if c.stdin == nil {
+		return false
+	}
+	answer, _ := bufio.NewReader(c.stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// pruneConfigYAML adds prod-eu, whose prefix extends the one of prod, on the same appliance
+const pruneConfigYAML = testConfigYAML + `
+  prod-eu:
+    endpoint: https://netscaler-prod.example.com
+    username: admin
+    password: secret
+    prefix: prod-eu-
+`
+
+// newPruneAPI returns an appliance with an unbound certkey, a bound expired certkey and an orphaned file,
+// and an unbound certkey of prod-eu
+func newPruneAPI(t *testing.T) *netscalertest.NitroClient {
+	t.Helper()
+	api := netscalertest.NewNitroClient()
+	for _, certkey := range []map[string]any{
+		{"certkey": "prod-unused.com", "cert": "prod-unused.crt", "status": "Valid"},
+		{"certkey": "prod-old.com", "cert": "prod-old.crt", "status": "Expired"},
+		{"certkey": "prod-legacy.com", "cert": "prod-legacy.crt", "status": "Valid"},
+		{"certkey": "prod-eu-unused.com", "cert": "prod-eu-unused.crt", "status": "Valid"},
+	} {
+		if err := api.Store.Add("sslcertkey", certkey); err != nil {
+			t.Fatalf("Store.Add() error = %v", err)
+		}
+	}
+	_ = api.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-old", "certkeyname": "prod-old.com"})
+	for _, file := range []string{"prod-unused.crt", "prod-old.crt", "prod-legacy.crt", "prod-stale.crt", "prod-eu-stale.crt"} {
+		if err := api.Store.WriteFile(file, []byte("test")); err != nil {
+			t.Fatalf("Store.WriteFile() error = %v", err)
+		}
+	}
+	return api
+}
+
+func TestRunPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		stdin       string
+		wantErr     string
+		wantOutput  []string
+		wantDeleted bool
+		description string
+	}{
+		{
+			name:        "list",
+			args:        []string{"-protect", "prod-legacy*"},
+			wantOutput:  []string{"prod-unused.com", "unbound", "prod-old.com", "expired", "sslvserver/vs-old", "prod-stale.crt", "orphaned"},
+			description: "without -delete the candidates should only be listed",
+		},
+		{
+			name:        "declined",
+			args:        []string{"-delete"},
+			stdin:       "n\n",
+			wantErr:     "prune aborted",
+			description: "deleting should require confirmation",
+		},
+		{
+			name:        "confirmed",
+			args:        []string{"-delete", "-protect", "prod-legacy*"},
+			stdin:       "yes\n",
+			wantOutput:  []string{"prod-unused.com", "prod-stale.crt"},
+			wantDeleted: true,
+			description: "confirmed candidates should be deleted",
+		},
+		{
+			name:        "yes",
+			args:        []string{"-delete", "-yes", "-protect", "prod-legacy*"},
+			wantOutput:  []string{"prod-unused.com", "prod-stale.crt"},
+			wantDeleted: true,
+			description: "-yes should skip the confirmation",
+		},
+		{
+			name:        "dry run",
+			args:        []string{"-delete", "-dry-run", "-protect", "prod-legacy*"},
+			wantOutput:  []string{"ACTION", "delete", "sslcertkey", "prod-unused.com", "systemfile", "prod-stale.crt"},
+			description: "-dry-run should print the plan without deleting",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newPruneAPI(t)
+			c, stdout, _ := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"prod-": api})
+			if tt.stdin != "" {
+				c.stdin = strings.NewReader(tt.stdin)
+			}
+
+			err := runPrune(c, append([]string{"-config", writeTestConfig(t, pruneConfigYAML), "-env", "prod"}, tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runPrune() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("runPrune() error = %v", err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("runPrune() output should contain %q, got:\n%s", want, stdout.String())
+				}
+			}
+			if strings.Contains(stdout.String(), "prod-legacy") {
+				t.Errorf("runPrune() output should not contain protected names, got:\n%s", stdout.String())
+			}
+			if strings.Contains(stdout.String(), "prod-eu-") {
+				t.Errorf("runPrune() output should not contain the names of prod-eu, got:\n%s", stdout.String())
+			}
+
+			_, err = api.Store.Get("sslcertkey", "prod-unused.com")
+			if deleted := err != nil; deleted != tt.wantDeleted {
+				t.Errorf("prod-unused.com deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if _, err := api.Store.Get("sslcertkey", "prod-old.com"); err != nil {
+				t.Error("runPrune() should keep bound certkeys")
+			}
+		})
+	}
+}
+
+func TestRunPrune_NoPrefix(t *testing.T) {
+	config := writeTestConfig(t, `
+environments:
+  shared:
+    endpoint: https://netscaler-shared.example.com
+    username: admin
+    password: secret
+`)
+	tests := []struct {
+		name        string
+		args        []string
+		stdin       string
+		wantErr     string
+		wantDeleted bool
+		description string
+	}{
+		{
+			name:        "without all",
+			wantErr:     "use -all",
+			description: "an environment without prefix should require -all",
+		},
+		{
+			name:        "all with yes",
+			args:        []string{"-all", "-delete", "-yes"},
+			wantErr:     "can't be combined with -yes",
+			description: "-all should not delete without confirming the listed candidates",
+		},
+		{
+			name:        "all confirmed",
+			args:        []string{"-all", "-delete"},
+			stdin:       "y\n",
+			wantDeleted: true,
+			description: "-all should list the candidates before asking for confirmation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newPruneAPI(t)
+			c, _, stderr := newTestCLI()
+			c.clientFactory = memoryClientFactory(map[string]*netscalertest.NitroClient{"": api})
+			if tt.stdin != "" {
+				c.stdin = strings.NewReader(tt.stdin)
+			}
+
+			err := runPrune(c, append([]string{"-config", config, "-env", "shared"}, tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runPrune() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("runPrune() error = %v", err)
+			}
+			if tt.wantDeleted {
+				listed := strings.Index(stderr.String(), "prod-eu-unused.com")
+				if listed < 0 || listed > strings.Index(stderr.String(), "[y/N]") {
+					t.Errorf("runPrune() should list the candidates before the confirmation, got:\n%s", stderr.String())
+				}
+			}
+
+			_, err = api.Store.Get("sslcertkey", "prod-eu-unused.com")
+			if deleted := err != nil; deleted != tt.wantDeleted {
+				t.Errorf("prod-eu-unused.com deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// topLevelConfigKeys are the keys allowed at the top level of the plugin config
+var topLevelConfigKeys = map[string]bool{
+	"environments": true,
+	"defaults":     true,
+	"logLevel":     true,
+}
+
+// validateConfig returns every problem of a plugin config
+func validateConfig(config map[string]any) []error {
+	var errs []error
+	for _, key := range sortedKeys(config) {
+		if !topLevelConfigKeys[key] {
+			errs = append(errs, fmt.Errorf("invalid config: %w", &netscaler.FieldError{Field: key, Message: "is unknown"}))
+		}
+	}
+
+	environments, ok := config["environments"].(map[string]any)
+	if !ok {
+		return append(errs, fmt.Errorf("invalid config format: environments is not a map"))
+	}
+
+	var defaults map[string]any
+	if v, ok := config["defaults"]; ok {
+		if defaults, ok = v.(map[string]any); !ok {
+			return append(errs, fmt.Errorf("invalid config format: defaults is not a map"))
+		}
+	}
+
+	_, envErrs := validateEnvironments(defaults, environments)
+	return append(errs, envErrs...)
+}
+
+func runValidateConfig(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("validate-config", opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfigFile(opts.config)
+	if err != nil {
+		return err
+	}
+
+	problems := validateConfig(config)
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintf(c.stdout, "%s: config is valid\n", opts.config)
+		return nil
+	}
+
+	for _, p := range problems {
+		_, _ = fmt.Fprintln(c.stdout, p)
+	}
+	return &exitError{code: 1, err: fmt.Errorf("%s: %d problem(s) found", opts.config, len(problems))}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunValidateConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantErr     bool
+		want        []string
+		description string
+	}{
+		{
+			name:        "valid",
+			config:      testConfigYAML,
+			want:        []string{"config is valid"},
+			description: "should accept a valid config",
+		},
+		{
+			name: "every problem",
+			config: `
+logLevel: debug
+unknown: true
+defaults:
+  username: admin
+environments:
+  prod:
+    endpoint: https://netscaler.example.com
+    password: secret
+    prefix: prod-
+    sslverify: true
+  prod-copy:
+    endpoint: https://netscaler.example.com:443
+    password: secret
+    prefix: prod-
+  dev:
+    endpoint: http://netscaler-dev.example.com
+    haPeerEndpoint: http://netscaler-dev-2.example.com
+    timeout: -1
+`,
+			wantErr: true,
+			want: []string{
+				"invalid config: field 'unknown' is unknown",
+				"invalid config for environment dev: field 'endpoint' must be an https URL, got scheme 'http'",
+				"invalid config for environment dev: field 'password' is required",
+				"invalid config for environment dev: field 'timeout' must not be negative",
+				"invalid config for environment dev: field 'haPeerEndpoint' must be an https URL, got scheme 'http'",
+				"invalid config for environment prod: field 'sslverify' is unknown, did you mean 'sslVerify'?",
+			},
+			description: "should report all problems at once",
+		},
+		{
+			name: "duplicate prefix",
+			config: `
+environments:
+  prod:
+    endpoint: https://netscaler.example.com
+    username: admin
+    password: secret
+    prefix: prod-
+  prod-copy:
+    endpoint: https://NETSCALER.example.com:443
+    username: admin
+    password: secret
+    prefix: prod-
+`,
+			wantErr:     true,
+			want:        []string{"invalid config: environments prod and prod-copy use the same prefix 'prod-' on netscaler.example.com:443"},
+			description: "should detect environments managing the same certificates",
+		},
+		{
+			name: "partitions",
+			config: `
+defaults:
+  endpoint: https://netscaler.example.com
+  username: admin
+  password: secret
+  prefix: le-
+environments:
+  team-a:
+    partition: team-a
+  team-b:
+    partition: team-b
+  team-b-copy:
+    partition: team-b
+`,
+			wantErr:     true,
+			want:        []string{"invalid config: environments team-b and team-b-copy use the same prefix 'le-' on netscaler.example.com:443 partition team-b"},
+			description: "environments in different partitions of an appliance may share the prefix",
+		},
+		{
+			name: "reserved names",
+			config: `
+defaults:
+  endpoint: https://netscaler.example.com
+  username: admin
+  password: secret
+environments:
+  drift:
+    prefix: drift-
+  uncoveredHostnames:
+    prefix: hosts-
+`,
+			wantErr: true,
+			want: []string{
+				"invalid config for environment drift: the name is reserved for the metadata of all environments",
+				"invalid config for environment uncoveredHostnames: the name is reserved for the metadata of all environments",
+			},
+			description: "should reject environment names colliding with the metadata keys of the drift report",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI()
+			err := runValidateConfig(c, []string{"-config", writeTestConfig(t, tt.config)})
+
+			var exitErr *exitError
+			if tt.wantErr != (err != nil) || (err != nil && (!errors.As(err, &exitErr) || exitErr.code != 1)) {
+				t.Fatalf("runValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("runValidateConfig() printed %q, want %d lines", stdout.String(), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(lines[i], want) {
+					t.Errorf("line %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler"
+)
+
+// Verification states of a VIP
+const (
+	verifyOK     = "ok"
+	verifyFailed = "failed"
+)
+
+// tlsCheckRows lists the TLS handshakes with the VIPs of a certkey
+type tlsCheckRows []netscaler.TLSCheck
+
+func (r tlsCheckRows) Header() []string {
+	return []string{"VSERVER", "ADDRESS", "SERVER NAME", "EXPECTED", "SERVED", "STATE", "ERROR"}
+}
+
+func (r tlsCheckRows) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, check := range r {
+		rows = append(rows, []string{check.Vserver, check.Address, check.ServerName, shortFingerprint(check.Expected),
+			shortFingerprint(check.Served), checkState(check), check.Error})
+	}
+	return rows
+}
+
+// checkState returns ok or failed
+func checkState(check netscaler.TLSCheck) string {
+	if check.OK {
+		return verifyOK
+	}
+	return verifyFailed
+}
+
+// shortFingerprint abbreviates a fingerprint for tables
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[:16]
+	}
+	return fingerprint
+}
+
+// verificationError returns an error if any VIP didn't serve the certificate
+func verificationError(env string, checks []netscaler.TLSCheck) error {
+	failed := 0
+	for _, check := range checks {
+		if !check.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d VIP(s) in environment %s don't serve the certificate", failed, len(checks), env)
+	}
+	return nil
+}
+
+// runVerify connects to every VIP the certificate of a domain is bound to and checks that it
+// serves the certificate
+func runVerify(c *cli, args []string) error {
+	opts := &commonOptions{}
+	fs := c.newFlagSet("verify", opts)
+	address := fs.String("address", "", "Address to connect to instead of the address of each VIP, e.g. a local TLS listener")
+	serverName := fs.String("servername", "", "SNI server name (default: the domain)")
+	timeout := fs.Duration("timeout", 0, "Timeout of each handshake (default: 10s)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: verify [flags] <domain>")
+	}
+	domain := fs.Arg(0)
+	if *serverName == "" {
+		*serverName = domain
+	}
+
+	env, client, err := c.openEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	defer c.closeEnvironments(map[string]*netscaler.Client{env: client})
+
+	cert, err := client.GetCertificate(domain)
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	certkey, _ := cert["certkey"].(string)
+	checks, err := client.VerifyTLS(certkey, netscaler.Verification{ServerName: *serverName, Address: *address, Timeout: *timeout})
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", env, err)
+	}
+	if len(checks) == 0 {
+		_, _ = fmt.Fprintf(c.stderr, "Certkey %s is not bound to any SSL vserver in environment %s\n", certkey, env)
+		return nil
+	}
+
+	if err := render(c.stdout, opts.format, tlsCheckRows(checks)); err != nil {
+		return err
+	}
+	return verificationError(env, checks)
+}
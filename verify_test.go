@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-netscaler/netscaler/netscalertest"
+)
+
+// serveTLS starts a TLS listener serving the PEM certificate and key and returns its address
+func serveTLS(t *testing.T, certPEM, keyPEM []byte) string {
+	t.Helper()
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String()
+}
+
+func TestRunDeployAndVerify(t *testing.T) {
+	config := writeTestConfig(t, testConfigYAML)
+	prod := netscalertest.NewNitroClient()
+	apis := map[string]*netscalertest.NitroClient{"prod-": prod}
+
+	c, stdout, _ := newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runCSR(c, []string{"-config", config, "-env", "prod", "-country", "DE", "-state", "Berlin", "-organization", "Example", "example.com"}); err != nil {
+		t.Fatalf("runCSR() error = %v", err)
+	}
+	csr := stdout.Bytes()
+	first := signCSR(t, csr)
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(first)
+	if err := runDeploy(c, []string{"-config", config, "-env", "prod", "example.com"}); err != nil {
+		t.Fatalf("runDeploy() error = %v", err)
+	}
+	if err := prod.Store.Add("lbvserver", map[string]any{"name": "vs-web", "servicetype": "SSL", "ipv46": "192.0.2.10", "port": 443}); err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+	_ = prod.Store.Bind("sslvserver_sslcertkey_binding", map[string]any{"vservername": "vs-web", "certkeyname": "prod-example.com"})
+
+	// The VIP still serves the first certificate after the renewal is deployed
+	key, _ := prod.Store.ReadFile("prod-example.com.key")
+	address := serveTLS(t, first, key)
+	renewal := signCSR(t, csr)
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	c.stdin = bytes.NewReader(renewal)
+	err := runDeploy(c, []string{"-config", config, "-env", "prod", "-verify", "-verify-address", address, "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 VIP(s) in environment prod don't serve the certificate") {
+		t.Errorf("runDeploy() -verify of a stale VIP error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "vs-web:failed") {
+		t.Errorf("runDeploy() -verify output should report the VIP, got:\n%s", stdout.String())
+	}
+
+	address = serveTLS(t, renewal, key)
+	c, stdout, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runVerify(c, []string{"-config", config, "-env", "prod", "-address", address, "example.com"}); err != nil {
+		t.Fatalf("runVerify() error = %v", err)
+	}
+	for _, want := range []string{"VSERVER", "vs-web", address, "example.com", "ok"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("runVerify() output should contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+
+	c, _, _ = newTestCLI()
+	c.clientFactory = memoryClientFactory(apis)
+	if err := runVerify(c, []string{"-config", config, "-env", "prod"}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("runVerify() without domain error = %v", err)
+	}
+}